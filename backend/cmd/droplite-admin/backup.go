@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"droplite/internal/backup"
+	"droplite/internal/migrations"
+	postgresrepo "droplite/internal/repository/postgres"
+	"droplite/internal/storage/backend"
+)
+
+// archiveKind 根据目标名称决定备份形式：.tar、.tar.gz/.tgz 或 - 为 tar 流，其余为目录。
+func archiveKind(target string) (tarball, compress bool) {
+	switch {
+	case target == "-":
+		return true, true
+	case strings.HasSuffix(target, ".tar.gz"), strings.HasSuffix(target, ".tgz"):
+		return true, true
+	case strings.HasSuffix(target, ".tar"):
+		return true, false
+	}
+	return false, false
+}
+
+func runBackup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("backup requires exactly one target (file.tar.gz, file.tar, directory or -)")
+	}
+	target := fs.Arg(0)
+
+	cfg, db, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	objects, err := backend.Open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	var (
+		w       backup.Writer
+		partial string
+		file    *os.File
+	)
+	if tarball, compress := archiveKind(target); tarball {
+		var out io.Writer = os.Stdout
+		if target != "-" {
+			// 先写临时文件，成功后再改名，避免中断时留下看似完整的备份
+			partial = target + ".partial"
+			if file, err = os.Create(partial); err != nil {
+				return err
+			}
+			defer os.Remove(partial)
+			defer file.Close()
+			out = file
+		}
+		w = backup.NewTarWriter(out, compress)
+	} else if w, err = backup.NewDirWriter(target); err != nil {
+		return err
+	}
+
+	manifest, err := backup.Create(ctx, postgresrepo.NewFileRepository(db), objects, w)
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if file != nil {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(partial, target); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "backed up %d records and %d objects (%d bytes)\n", manifest.Records, manifest.Objects, manifest.ObjectBytes)
+	if len(manifest.MissingObjects) > 0 {
+		for _, key := range manifest.MissingObjects {
+			fmt.Fprintf(os.Stderr, "warning: object %s referenced by a stored file is missing\n", key)
+		}
+		return errIncomplete
+	}
+	return nil
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := fs.Bool("force", false, "允许恢复到已有文件记录的库，同 ID 的记录被覆盖")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("restore requires exactly one source (file, directory or -)")
+	}
+	source := fs.Arg(0)
+
+	var r backup.Reader
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		if r, err = backup.NewDirReader(source); err != nil {
+			return err
+		}
+	} else {
+		var in io.Reader = os.Stdin
+		if source != "-" {
+			file, err := os.Open(source)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			in = file
+		}
+		if r, err = backup.NewTarReader(in); err != nil {
+			return err
+		}
+	}
+	defer r.Close()
+
+	cfg, db, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrations.Apply(ctx, db); err != nil {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+	objects, err := backend.Open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	result, err := backup.Restore(ctx, r, postgresrepo.NewFileRepository(db), objects, backup.RestoreOptions{Overwrite: *force})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "restored %d objects and %d records (%d inserted, %d updated, %d unchanged) from backup taken at %s\n",
+		result.Objects, result.Records.Inserted+result.Records.Updated+result.Records.Unchanged,
+		result.Records.Inserted, result.Records.Updated, result.Records.Unchanged,
+		result.Manifest.CreatedAt.Format("2006-01-02 15:04:05Z07:00"))
+	if len(result.Manifest.MissingObjects) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d stored files had no object when the backup was taken\n", len(result.Manifest.MissingObjects))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// droplite-admin 是直接访问数据库与存储的运维工具，使用与服务端相同的环境变量配置。
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"droplite/internal/config"
+	"droplite/internal/database"
+)
+
+const usage = `用法: droplite-admin <command> [flags]
+
+命令:
+  export   将文件记录导出为 NDJSON      droplite-admin export -o files.ndjson
+  import   从 NDJSON 按 ID upsert 记录  droplite-admin import -verify-storage files.ndjson
+  backup   备份文件记录与全部对象       droplite-admin backup backup-20260101.tar.gz
+  restore  从备份恢复数据库与存储       droplite-admin restore backup-20260101.tar.gz
+
+备份目标以 .tar.gz/.tgz 或 .tar 结尾时写为 tar，- 表示以 tar.gz 写到标准输出，其余视为目录。
+
+数据库与存储配置读取与服务端相同的环境变量（DATABASE_URL、STORAGE_DRIVER 等）。
+
+退出码: 0 成功, 1 错误, 2 用法错误, 3 完成但存在冲突、无效行或缺失对象
+`
+
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitIncomplete = 3
+)
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"export":  runExport,
+	"import":  runImport,
+	"backup":  runBackup,
+	"restore": runRestore,
+}
+
+// errIncomplete 表示命令执行完毕但有记录未能处理，细节已经输出。
+var errIncomplete = errors.New("completed with problems")
+
+// usageError 表示参数错误。
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "droplite-admin: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cmd(ctx, args[1:])
+	var uerr *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errIncomplete):
+		return exitIncomplete
+	case errors.As(err, &uerr):
+		fmt.Fprintf(os.Stderr, "droplite-admin %s: %v\n", args[0], err)
+		return exitUsage
+	default:
+		fmt.Fprintf(os.Stderr, "droplite-admin %s: %v\n", args[0], err)
+		return exitError
+	}
+}
+
+// parseFlags 解析参数；flag 包已经输出错误时返回 usageError 以设置退出码。
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usagef("%v", err)
+	}
+	return nil
+}
+
+func connect(ctx context.Context) (*config.Config, *sql.DB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	db, err := database.Connect(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+	return cfg, db, nil
+}
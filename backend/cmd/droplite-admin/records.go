@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"droplite/internal/migrations"
+	postgresrepo "droplite/internal/repository/postgres"
+	"droplite/internal/service"
+	"droplite/internal/storage/backend"
+)
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("o", "-", "输出文件，- 表示标准输出")
+	owner := fs.String("owner", "", "只导出该 owner 的记录")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("export takes no arguments")
+	}
+
+	_, db, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+
+	n, err := service.ExportFiles(ctx, postgresrepo.NewFileRepository(db), buffered, *owner)
+	if err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d records\n", n)
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	onConflict := fs.String("on-conflict", string(service.ConflictSkip), "冲突处理：skip、overwrite 或 fail")
+	verify := fs.Bool("verify-storage", false, "校验 stored 记录的 storage_path 在目标存储中存在且大小一致")
+	dryRun := fs.Bool("dry-run", false, "只报告结果，不写入数据库")
+	jsonOutput := fs.Bool("json", false, "逐行以 JSON 输出结果（包括 inserted 与 unchanged）")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	policy := service.ConflictPolicy(*onConflict)
+	switch policy {
+	case service.ConflictSkip, service.ConflictOverwrite, service.ConflictFail:
+	default:
+		return usagef("-on-conflict must be skip, overwrite or fail")
+	}
+	if fs.NArg() > 1 {
+		return usagef("import takes at most one file")
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	cfg, db, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrations.Apply(ctx, db); err != nil {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+
+	opts := service.ImportOptions{OnConflict: policy, DryRun: *dryRun}
+	if *verify {
+		if opts.Storage, err = backend.Open(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	summary, err := service.ImportFiles(ctx, postgresrepo.NewFileRepository(db), r, opts, func(result service.ImportResult) {
+		switch {
+		case *jsonOutput:
+			_ = enc.Encode(result)
+		case result.Outcome == service.ImportInserted || result.Outcome == service.ImportUnchanged:
+		case result.Reason == "":
+			fmt.Printf("line %d: %s %s\n", result.Line, result.Outcome, result.ID)
+		default:
+			fmt.Printf("line %d: %s %s: %s\n", result.Line, result.Outcome, result.ID, result.Reason)
+		}
+	})
+
+	prefix := ""
+	if *dryRun {
+		prefix = "dry run: "
+	}
+	fmt.Fprintf(os.Stderr, "%s%d inserted, %d updated, %d unchanged, %d conflicts, %d invalid, %d missing objects\n",
+		prefix, summary.Inserted, summary.Updated, summary.Unchanged, summary.Conflicts, summary.Invalid, summary.MissingObjects)
+	if err != nil {
+		return err
+	}
+	if !summary.Clean() {
+		return errIncomplete
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"droplite/pkg/client"
+)
+
+// session 是解析完参数后的命令执行环境。
+type session struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   *options
+	client *client.Client
+	args   []string
+}
+
+// start 解析参数、补全配置并创建客户端。
+func start(ctx context.Context, fs *flag.FlagSet, opts *options, args []string) (*session, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		// flag 包已经输出了错误与用法
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, &reportedError{code: exitOK}
+		}
+		return nil, &reportedError{code: exitUsage}
+	}
+	if err := opts.resolve(); err != nil {
+		return nil, err
+	}
+	c, err := opts.client()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{opts: opts, client: c, args: positional}
+	if opts.timeout > 0 {
+		s.ctx, s.cancel = context.WithTimeout(ctx, opts.timeout)
+	} else {
+		s.ctx, s.cancel = context.WithCancel(ctx)
+	}
+	return s, nil
+}
+
+// stringsFlag 是可重复出现的字符串参数。
+type stringsFlag []string
+
+func (f *stringsFlag) String() string     { return strings.Join(*f, ",") }
+func (f *stringsFlag) Set(v string) error { *f = append(*f, v); return nil }
+
+type uploadResult struct {
+	Path  string       `json:"path"`
+	File  *client.File `json:"file,omitempty"`
+	Error *itemError   `json:"error,omitempty"`
+	err   error
+}
+
+func runUpload(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("upload")
+	parallel := fs.Int("parallel", 4, "同时上传的文件数")
+	var meta stringsFlag
+	fs.Var(&meta, "meta", "metadata 字段 key=value，可重复")
+	metadataJSON := fs.String("metadata", "", "metadata JSON 对象，与 -meta 合并")
+	mimeType := fs.String("mime", "", "MIME 类型，默认由服务端识别")
+	name := fs.String("name", "", "覆盖文件名，只能用于单个文件")
+	expiresIn := fs.Duration("expires-in", 0, "文件过期时间，如 72h")
+	progress := fs.Bool("progress", false, "在 stderr 输出上传进度")
+
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) == 0 {
+		return usagef("upload requires at least one path")
+	}
+	if *parallel < 1 {
+		return usagef("parallel must be at least 1")
+	}
+	paths, err := expandPaths(s.args)
+	if err != nil {
+		return err
+	}
+	if *name != "" && len(paths) > 1 {
+		return usagef("-name can only be used with a single file")
+	}
+	metadata, err := buildMetadata(*metadataJSON, meta)
+	if err != nil {
+		return err
+	}
+	var expiresAt *time.Time
+	if *expiresIn > 0 {
+		t := time.Now().Add(*expiresIn)
+		expiresAt = &t
+	}
+
+	var (
+		results  = make([]uploadResult, len(paths))
+		sem      = make(chan struct{}, *parallel)
+		wg       sync.WaitGroup
+		stderrMu sync.Mutex
+	)
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			input := client.UploadInput{
+				Name:      firstNonEmpty(*name, filepath.Base(path)),
+				MimeType:  *mimeType,
+				Metadata:  metadata,
+				ExpiresAt: expiresAt,
+			}
+			if *progress {
+				lastDecile := int64(-1)
+				input.Progress = func(sent, total int64) {
+					if total <= 0 || sent*10/total == lastDecile {
+						return
+					}
+					lastDecile = sent * 10 / total
+					stderrMu.Lock()
+					fmt.Fprintf(os.Stderr, "%s: %d%% (%s/%s)\n", path, lastDecile*10, formatSize(sent), formatSize(total))
+					stderrMu.Unlock()
+				}
+			}
+			file, err := uploadPath(s.ctx, s.client, path, input)
+			results[i] = uploadResult{Path: path, File: file, Error: toItemError(err), err: err}
+		}()
+	}
+	wg.Wait()
+
+	errs := make([]error, len(results))
+	for i, r := range results {
+		errs[i] = r.err
+	}
+	if s.opts.json() {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+		return summarize(errs)
+	}
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", r.Path, r.err)
+			continue
+		}
+		fmt.Printf("uploaded %s -> %s (%s)\n", r.Path, r.File.ID, formatSize(r.File.SizeBytes))
+	}
+	return summarize(errs)
+}
+
+func uploadPath(ctx context.Context, c *client.Client, path string, input client.UploadInput) (*client.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	input.Reader = f
+	input.Size = info.Size()
+	return c.UploadFile(ctx, input)
+}
+
+// expandPaths 展开通配符（引号中的通配符不会被 shell 展开），并拒绝目录。
+func expandPaths(args []string) ([]string, error) {
+	seen := map[string]bool{}
+	var paths []string
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, usagef("invalid pattern %q: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, usagef("no files match %q", arg)
+			}
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, usagef("%v", err)
+			}
+			if info.IsDir() {
+				if len(matches) == 1 && matches[0] == arg {
+					return nil, usagef("%s is a directory", path)
+				}
+				continue
+			}
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// buildMetadata 合并 -metadata 的 JSON 对象与逐个 -meta key=value。
+func buildMetadata(raw string, pairs []string) (map[string]any, error) {
+	if raw == "" && len(pairs) == 0 {
+		return nil, nil
+	}
+	metadata := map[string]any{}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			return nil, usagef("-metadata must be a JSON object: %v", err)
+		}
+	}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, usagef("-meta expects key=value, got %q", pair)
+		}
+		metadata[strings.TrimSpace(key)] = value
+	}
+	return metadata, nil
+}
+
+type downloadResult struct {
+	ID    string     `json:"id"`
+	Path  string     `json:"path,omitempty"`
+	Bytes int64      `json:"bytes"`
+	Error *itemError `json:"error,omitempty"`
+	err   error
+}
+
+func runDownload(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("download")
+	out := fs.String("o", "", "目标文件或目录，- 表示标准输出；多个文件时必须是目录，默认当前目录")
+	force := fs.Bool("force", false, "覆盖已存在的文件")
+
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) == 0 {
+		return usagef("download requires at least one file id")
+	}
+	if *out == "-" {
+		if len(s.args) != 1 {
+			return usagef("-o - can only be used with a single file")
+		}
+		d, err := s.client.DownloadFile(s.ctx, s.args[0])
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		_, err = io.Copy(os.Stdout, d)
+		return err
+	}
+
+	dir, target := ".", ""
+	if *out != "" {
+		if info, err := os.Stat(*out); (err == nil && info.IsDir()) || strings.HasSuffix(*out, string(os.PathSeparator)) {
+			dir = *out
+		} else if len(s.args) > 1 {
+			return usagef("-o must be a directory when downloading multiple files")
+		} else {
+			target = *out
+		}
+	}
+
+	results := make([]downloadResult, len(s.args))
+	errs := make([]error, len(s.args))
+	for i, id := range s.args {
+		path, n, err := downloadTo(s.ctx, s.client, id, dir, target, *force)
+		results[i] = downloadResult{ID: id, Path: path, Bytes: n, Error: toItemError(err), err: err}
+		errs[i] = err
+	}
+
+	if s.opts.json() {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+		return summarize(errs)
+	}
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", r.ID, r.err)
+			continue
+		}
+		fmt.Printf("downloaded %s -> %s (%s)\n", r.ID, r.Path, formatSize(r.Bytes))
+	}
+	return summarize(errs)
+}
+
+// downloadTo 将文件写入 target，target 为空时写入 dir 下以服务端文件名命名的文件。
+// 写入失败时删除不完整的文件。
+func downloadTo(ctx context.Context, c *client.Client, id, dir, target string, force bool) (string, int64, error) {
+	d, err := c.DownloadFile(ctx, id)
+	if err != nil {
+		return "", 0, err
+	}
+	defer d.Close()
+
+	if target == "" {
+		name := filepath.Base(filepath.Clean("/" + d.Filename))
+		if name == "/" || name == "." {
+			name = id
+		}
+		target = filepath.Join(dir, name)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(target, flags, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return target, 0, fmt.Errorf("%s already exists (use -force to overwrite)", target)
+		}
+		return target, 0, err
+	}
+	n, err := io.Copy(f, d)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(target)
+		return target, n, err
+	}
+	return target, n, nil
+}
+
+func runList(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("ls")
+	var statuses stringsFlag
+	fs.Var(&statuses, "status", "按状态过滤（pending/stored/failed/deleted），可重复")
+	namePattern := fs.String("name", "", "按文件名通配符过滤，如 '*.pdf'")
+	mimePrefix := fs.String("mime", "", "按 MIME 类型前缀过滤，如 image/")
+	limit := fs.Int("limit", 0, "最多列出的文件数，0 表示全部")
+	pageSize := fs.Int("page-size", 100, "每次请求的文件数")
+
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) > 0 {
+		return usagef("ls takes no arguments")
+	}
+	if *namePattern != "" {
+		if _, err := filepath.Match(*namePattern, ""); err != nil {
+			return usagef("invalid -name pattern: %v", err)
+		}
+	}
+
+	listOpts := client.ListOptions{Limit: *pageSize}
+	for _, status := range statuses {
+		listOpts.Statuses = append(listOpts.Statuses, client.FileStatus(status))
+	}
+
+	files := []client.File{}
+	for file, err := range s.client.Files(s.ctx, listOpts) {
+		if err != nil {
+			return err
+		}
+		if *namePattern != "" {
+			if ok, _ := filepath.Match(*namePattern, file.OriginalName); !ok {
+				continue
+			}
+		}
+		if !strings.HasPrefix(file.MimeType, *mimePrefix) {
+			continue
+		}
+		files = append(files, file)
+		if *limit > 0 && len(files) >= *limit {
+			break
+		}
+	}
+
+	if s.opts.json() {
+		return printJSON(files)
+	}
+	tw := newTable()
+	fmt.Fprintln(tw, "ID\tNAME\tSIZE\tSTATUS\tCREATED")
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.ID, f.OriginalName, formatSize(f.SizeBytes), f.Status, f.CreatedAt.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+type removeResult struct {
+	ID      string     `json:"id"`
+	Deleted bool       `json:"deleted"`
+	Error   *itemError `json:"error,omitempty"`
+}
+
+func runRemove(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("rm")
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) == 0 {
+		return usagef("rm requires at least one file id")
+	}
+
+	results := make([]removeResult, len(s.args))
+	errs := make([]error, len(s.args))
+	for i, id := range s.args {
+		errs[i] = s.client.DeleteFile(s.ctx, id)
+		results[i] = removeResult{ID: id, Deleted: errs[i] == nil, Error: toItemError(errs[i])}
+	}
+
+	if s.opts.json() {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+		return summarize(errs)
+	}
+	for i, id := range s.args {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", id, errs[i])
+			continue
+		}
+		fmt.Printf("deleted %s\n", id)
+	}
+	return summarize(errs)
+}
+
+func runInfo(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("info")
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) != 1 {
+		return usagef("info requires exactly one file id")
+	}
+	file, err := s.client.GetFile(s.ctx, s.args[0])
+	if err != nil {
+		return err
+	}
+
+	if s.opts.json() {
+		return printJSON(file)
+	}
+	tw := newTable()
+	fmt.Fprintf(tw, "ID:\t%s\n", file.ID)
+	fmt.Fprintf(tw, "Name:\t%s\n", file.OriginalName)
+	fmt.Fprintf(tw, "Size:\t%s (%d bytes)\n", formatSize(file.SizeBytes), file.SizeBytes)
+	fmt.Fprintf(tw, "Type:\t%s\n", file.MimeType)
+	fmt.Fprintf(tw, "Status:\t%s\n", file.Status)
+	if file.Checksum != nil {
+		fmt.Fprintf(tw, "Checksum:\t%s\n", *file.Checksum)
+	}
+	fmt.Fprintf(tw, "Created:\t%s\n", file.CreatedAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(tw, "Updated:\t%s\n", file.UpdatedAt.Local().Format(time.RFC3339))
+	if file.ExpiresAt != nil {
+		fmt.Fprintf(tw, "Expires:\t%s\n", file.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	keys := make([]string, 0, len(file.Metadata))
+	for k := range file.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value, _ := json.Marshal(file.Metadata[k])
+		fmt.Fprintf(tw, "Metadata.%s:\t%s\n", k, value)
+	}
+	return tw.Flush()
+}
+
+func runShare(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("share")
+	ttl := fs.Duration("ttl", 0, "链接有效期，如 1h，默认 24h，最长 720h")
+
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) != 1 {
+		return usagef("share requires exactly one file id")
+	}
+	if *ttl < 0 {
+		return usagef("-ttl must not be negative")
+	}
+	link, err := s.client.ShareFile(s.ctx, s.args[0], *ttl)
+	if err != nil {
+		return err
+	}
+
+	if s.opts.json() {
+		return printJSON(link)
+	}
+	// 链接单独输出到 stdout，便于 $(droplite share <id>) 直接取值
+	fmt.Println(link.URL)
+	fmt.Fprintf(os.Stderr, "expires %s\n", link.ExpiresAt.Local().Format(time.RFC3339))
+	return nil
+}
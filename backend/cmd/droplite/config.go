@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"droplite/pkg/client"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// options 是各命令共用的连接与输出参数。
+type options struct {
+	profile string
+	url     string
+	apiKey  string
+	token   string
+	output  string
+	timeout time.Duration
+}
+
+// newFlagSet 创建带通用参数的 FlagSet。
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := &options{}
+	fs.StringVar(&opts.profile, "profile", "", "配置文件中的 profile")
+	fs.StringVar(&opts.url, "url", "", "服务地址")
+	fs.StringVar(&opts.apiKey, "api-key", "", "API Key")
+	fs.StringVar(&opts.token, "token", "", "Bearer Token")
+	fs.StringVar(&opts.output, "output", "", "输出格式 text 或 json")
+	fs.DurationVar(&opts.timeout, "timeout", 0, "整条命令的超时时间，0 表示不限")
+	return fs, opts
+}
+
+// parseArgs 允许参数与位置参数交错出现，如 `droplite rm <id> -output json`。
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// resolve 按 参数 > 环境变量 > 配置文件 的优先级补全 options。
+func (o *options) resolve() error {
+	profileName := firstNonEmpty(o.profile, os.Getenv("DROPLITE_PROFILE"), "default")
+	profile, err := loadProfile(profileName, o.profile != "" || os.Getenv("DROPLITE_PROFILE") != "")
+	if err != nil {
+		return err
+	}
+
+	o.url = firstNonEmpty(o.url, os.Getenv("DROPLITE_URL"), profile["url"], defaultURL)
+	// 参数或环境变量中显式给出任一凭证时，不再使用配置文件中的凭证
+	o.apiKey = firstNonEmpty(o.apiKey, os.Getenv("DROPLITE_API_KEY"))
+	o.token = firstNonEmpty(o.token, os.Getenv("DROPLITE_TOKEN"))
+	if o.apiKey == "" && o.token == "" {
+		o.apiKey = profile["api_key"]
+		o.token = profile["token"]
+	}
+	if o.apiKey != "" && o.token != "" {
+		return usagef("api key and token are mutually exclusive")
+	}
+
+	o.output = firstNonEmpty(o.output, os.Getenv("DROPLITE_OUTPUT"), profile["output"], "text")
+	if o.output != "text" && o.output != "json" {
+		return usagef("output must be text or json, got %q", o.output)
+	}
+	return nil
+}
+
+func (o *options) client() (*client.Client, error) {
+	clientOpts := []client.Option{client.WithUserAgent("droplite-cli")}
+	switch {
+	case o.apiKey != "":
+		clientOpts = append(clientOpts, client.WithAPIKey(o.apiKey))
+	case o.token != "":
+		clientOpts = append(clientOpts, client.WithBearerToken(o.token))
+	}
+	c, err := client.New(o.url, clientOpts...)
+	if err != nil {
+		return nil, usagef("%v", err)
+	}
+	return c, nil
+}
+
+func (o *options) json() bool {
+	return o.output == "json"
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("DROPLITE_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "droplite", "config"), nil
+}
+
+// loadProfile 读取配置文件中的一个 profile；文件不存在时返回空 profile，
+// 除非 required 为 true（用户显式指定了 profile）。
+func loadProfile(name string, required bool) (map[string]string, error) {
+	path, err := configPath()
+	if err != nil {
+		if required {
+			return nil, usagef("locate config file: %v", err)
+		}
+		return map[string]string{}, nil
+	}
+
+	profiles, err := parseProfiles(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, usagef("read config %s: %v", path, err)
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		if required {
+			return nil, usagef("profile %q not found in %s", name, path)
+		}
+		return map[string]string{}, nil
+	}
+	return profile, nil
+}
+
+// parseProfiles 解析 INI 风格的配置文件：[name] 开始一个 profile，其后为 key = value，# 或 ; 开头为注释。
+func parseProfiles(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if profiles[name] == nil {
+				profiles[name] = map[string]string{}
+			}
+			current = profiles[name]
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok || current == nil {
+				return nil, fmt.Errorf("line %d: expected key = value inside a [profile] section", lineNo)
+			}
+			current[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return profiles, scanner.Err()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+// droplite 是面向脚本与 CI 的命令行客户端。
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"droplite/pkg/client"
+)
+
+// 退出码，供 CI 区分失败原因。
+const (
+	exitOK          = 0
+	exitError       = 1 // 未分类错误：网络、本地文件读写等
+	exitUsage       = 2 // 参数或配置错误
+	exitAuth        = 3 // unauthorized / forbidden
+	exitNotFound    = 4 // not_found
+	exitRejected    = 5 // validation / conflict / payload_too_large / quota_exceeded
+	exitUnavailable = 6 // rate_limited / *_unavailable / internal，通常可以重试
+	exitPartial     = 7 // 多个目标中部分成功、部分失败
+)
+
+const usage = `用法: droplite <command> [flags] [args]
+
+命令:
+  upload    上传文件，支持通配符与并发          droplite upload -meta env=prod 'dist/*.tar.gz'
+  download  下载文件到本地                      droplite download -o ./out <id>...
+  ls        列出文件                            droplite ls -status stored -name '*.pdf'
+  rm        删除文件                            droplite rm <id>...
+  info      查看文件元数据                      droplite info <id>
+  share     签发限时下载链接                    droplite share -ttl 1h <id>
+  sync      将本地目录同步到远端                droplite sync -delete -prefix builds/app ./dist
+
+通用参数（各命令均支持，优先级：参数 > 环境变量 > 配置文件）:
+  -profile  配置文件中的 profile，默认 $DROPLITE_PROFILE 或 default
+  -url      服务地址（$DROPLITE_URL）
+  -api-key  API Key（$DROPLITE_API_KEY）
+  -token    Bearer Token（$DROPLITE_TOKEN）
+  -output   输出格式 text 或 json（$DROPLITE_OUTPUT）
+  -timeout  整条命令的超时时间，如 30s，默认不限
+
+配置文件默认为 <用户配置目录>/droplite/config，可用 $DROPLITE_CONFIG 指定，格式:
+  [default]
+  url = http://localhost:8080
+  api_key = dev-api-key-123456
+
+退出码: 0 成功, 1 其他错误, 2 用法错误, 3 鉴权失败, 4 不存在,
+        5 请求被拒绝, 6 服务暂不可用（可重试）, 7 部分失败
+`
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"upload":   runUpload,
+	"download": runDownload,
+	"ls":       runList,
+	"rm":       runRemove,
+	"info":     runInfo,
+	"share":    runShare,
+	"sync":     runSync,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "droplite: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cmd(ctx, args[1:])
+	if err != nil && !errors.Is(err, errReported) {
+		fmt.Fprintf(os.Stderr, "droplite %s: %v\n", args[0], err)
+	}
+	return exitCode(err)
+}
+
+// usageError 表示参数或配置错误。
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// errReported 表示错误已经输出，只需设置退出码。
+var errReported = errors.New("reported")
+
+// reportedError 携带已输出错误对应的退出码。
+type reportedError struct{ code int }
+
+func (e *reportedError) Error() string        { return errReported.Error() }
+func (e *reportedError) Is(target error) bool { return target == errReported }
+
+// summarize 根据逐项错误计算整体结果：全部成功为 nil，部分失败为 exitPartial，全部失败取第一个错误的退出码。
+func summarize(errs []error) error {
+	failed := 0
+	var first error
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case failed < len(errs):
+		return &reportedError{code: exitPartial}
+	default:
+		return &reportedError{code: exitCode(first)}
+	}
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var reported *reportedError
+	if errors.As(err, &reported) {
+		return reported.code
+	}
+	var uerr *usageError
+	if errors.As(err, &uerr) {
+		return exitUsage
+	}
+	switch client.ErrorCode(err) {
+	case client.CodeUnauthorized, client.CodeForbidden:
+		return exitAuth
+	case client.CodeNotFound:
+		return exitNotFound
+	case client.CodeValidation, client.CodeConflict, client.CodePayloadTooLarge, client.CodeQuotaExceeded:
+		return exitRejected
+	case client.CodeRateLimited, client.CodeStorageUnavailable, client.CodeDatabaseUnavailable, client.CodeInternal:
+		return exitUnavailable
+	}
+	return exitError
+}
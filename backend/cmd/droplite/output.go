@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"droplite/pkg/client"
+)
+
+// itemError 是 JSON 输出中单个目标的错误。
+type itemError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func toItemError(err error) *itemError {
+	if err == nil {
+		return nil
+	}
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		return &itemError{Code: apiErr.Code, Message: apiErr.Message}
+	}
+	return &itemError{Code: "client_error", Message: err.Error()}
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"droplite/pkg/client"
+)
+
+// sync 管理的文件通过这两个 metadata 字段识别：sync_root 为远端前缀，sync_path 为相对路径。
+// 删除只会作用于带有相同 sync_root 的文件，其他方式上传的同名文件不受影响。
+const (
+	syncRootKey = "sync_root"
+	syncPathKey = "sync_path"
+)
+
+// 同步动作。
+const (
+	actionUpload    = "upload"    // 远端不存在
+	actionUpdate    = "update"    // 内容变化：先上传新版本，再删除旧记录
+	actionDelete    = "delete"    // 本地已删除（-delete）或是重复的旧版本
+	actionUnchanged = "unchanged" // 校验和一致
+)
+
+type localFile struct {
+	Path     string // 以 / 分隔的相对路径
+	abs      string
+	Size     int64
+	ModTime  time.Time
+	Checksum string // sha256:<hex>
+}
+
+type syncAction struct {
+	Action string     `json:"action"`
+	Path   string     `json:"path"`
+	FileID string     `json:"file_id,omitempty"`
+	Size   int64      `json:"size"`
+	Error  *itemError `json:"error,omitempty"`
+
+	local *localFile
+	stale []string // 上传成功后需要删除的旧记录
+	err   error
+}
+
+type syncSummary struct {
+	Uploaded  int `json:"uploaded"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+type syncReport struct {
+	DryRun  bool         `json:"dry_run"`
+	Root    string       `json:"root"`
+	Actions []syncAction `json:"actions"`
+	Summary syncSummary  `json:"summary"`
+}
+
+func runSync(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("sync")
+	prefix := fs.String("prefix", "", "远端路径前缀，默认为本地目录名")
+	deleteRemote := fs.Bool("delete", false, "删除本地已不存在的远端文件")
+	dryRun := fs.Bool("dry-run", false, "只输出将要执行的动作")
+	parallel := fs.Int("parallel", 4, "同时上传的文件数")
+	var excludes stringsFlag
+	fs.Var(&excludes, "exclude", "排除匹配的相对路径或文件名（通配符），可重复")
+	var meta stringsFlag
+	fs.Var(&meta, "meta", "附加到上传文件的 metadata key=value，可重复")
+	statePath := fs.String("state", "", "本地状态文件，默认位于用户缓存目录")
+
+	s, err := start(ctx, fs, opts, args)
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if len(s.args) != 1 {
+		return usagef("sync requires exactly one directory")
+	}
+	if *parallel < 1 {
+		return usagef("parallel must be at least 1")
+	}
+	for _, pattern := range excludes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return usagef("invalid -exclude pattern %q: %v", pattern, err)
+		}
+	}
+	dir, err := filepath.Abs(s.args[0])
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return usagef("%v", err)
+	} else if !info.IsDir() {
+		return usagef("%s is not a directory", s.args[0])
+	}
+	root := strings.Trim(firstNonEmpty(*prefix, filepath.Base(dir)), "/")
+	if root == "" {
+		return usagef("-prefix must not be empty")
+	}
+	metadata, err := buildMetadata("", meta)
+	if err != nil {
+		return err
+	}
+	if *statePath == "" {
+		if *statePath, err = defaultStatePath(s.opts.url, dir, root); err != nil {
+			return err
+		}
+	}
+
+	state := loadSyncState(*statePath)
+	locals, err := scanLocal(s.ctx, dir, excludes, state)
+	if err != nil {
+		return err
+	}
+	// 哈希结果先落盘，中断后重跑无需重新计算未变化的文件
+	if err := state.save(*statePath); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: save sync state: %v\n", err)
+	}
+
+	remotes, err := listRemote(s.ctx, s.client, root)
+	if err != nil {
+		return err
+	}
+	actions := planSync(locals, remotes, *deleteRemote)
+
+	if !*dryRun {
+		executeSync(s.ctx, s.client, root, metadata, actions, *parallel)
+	}
+	return reportSync(s.opts, syncReport{DryRun: *dryRun, Root: root, Actions: actions})
+}
+
+// scanLocal 遍历目录中的普通文件；大小与修改时间与状态文件一致时复用缓存的校验和。
+func scanLocal(ctx context.Context, dir string, excludes []string, state *syncState) ([]localFile, error) {
+	var files []localFile
+	seen := map[string]bool{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if excluded(rel, excludes) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		file := localFile{Path: rel, abs: p, Size: info.Size(), ModTime: info.ModTime()}
+		if cached, ok := state.Files[rel]; ok && cached.Size == file.Size && cached.ModTime.Equal(file.ModTime) {
+			file.Checksum = cached.Checksum
+		} else {
+			if file.Checksum, err = hashFile(p); err != nil {
+				return err
+			}
+			state.Files[rel] = stateEntry{Size: file.Size, ModTime: file.ModTime, Checksum: file.Checksum}
+		}
+		seen[rel] = true
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for rel := range state.Files {
+		if !seen[rel] {
+			delete(state.Files, rel)
+		}
+	}
+	return files, nil
+}
+
+func excluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// listRemote 返回 sync_root 为 root 的已存储文件，按相对路径分组，每组按创建时间倒序。
+func listRemote(ctx context.Context, c *client.Client, root string) (map[string][]client.File, error) {
+	remotes := map[string][]client.File{}
+	for file, err := range c.Files(ctx, client.ListOptions{Statuses: []client.FileStatus{client.FileStatusStored}}) {
+		if err != nil {
+			return nil, err
+		}
+		fileRoot, _ := file.Metadata[syncRootKey].(string)
+		rel, _ := file.Metadata[syncPathKey].(string)
+		if fileRoot != root || rel == "" {
+			continue
+		}
+		remotes[rel] = append(remotes[rel], file)
+	}
+	for _, files := range remotes {
+		sort.SliceStable(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	}
+	return remotes, nil
+}
+
+// planSync 比较本地与远端。同一路径有多条远端记录时（例如上次同步在删除旧版本前中断），
+// 以最新一条为准，其余作为旧版本删除。
+func planSync(locals []localFile, remotes map[string][]client.File, deleteRemote bool) []syncAction {
+	var actions []syncAction
+	for i := range locals {
+		local := &locals[i]
+		existing := remotes[local.Path]
+		delete(remotes, local.Path)
+
+		switch {
+		case len(existing) == 0:
+			actions = append(actions, syncAction{Action: actionUpload, Path: local.Path, Size: local.Size, local: local})
+		case existing[0].Checksum != nil && *existing[0].Checksum == local.Checksum && existing[0].SizeBytes == local.Size:
+			actions = append(actions, syncAction{Action: actionUnchanged, Path: local.Path, FileID: existing[0].ID, Size: local.Size})
+			for _, old := range existing[1:] {
+				actions = append(actions, syncAction{Action: actionDelete, Path: local.Path, FileID: old.ID, Size: old.SizeBytes})
+			}
+		default:
+			stale := make([]string, len(existing))
+			for j, old := range existing {
+				stale[j] = old.ID
+			}
+			actions = append(actions, syncAction{Action: actionUpdate, Path: local.Path, Size: local.Size, local: local, stale: stale})
+		}
+	}
+
+	if deleteRemote {
+		paths := make([]string, 0, len(remotes))
+		for rel := range remotes {
+			paths = append(paths, rel)
+		}
+		sort.Strings(paths)
+		for _, rel := range paths {
+			for _, old := range remotes[rel] {
+				actions = append(actions, syncAction{Action: actionDelete, Path: rel, FileID: old.ID, Size: old.SizeBytes})
+			}
+		}
+	}
+	return actions
+}
+
+// executeSync 先并发上传，再删除旧记录，保证同步过程中每个路径始终至少有一个可下载的版本。
+func executeSync(ctx context.Context, c *client.Client, root string, metadata map[string]any, actions []syncAction, parallel int) {
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range actions {
+		action := &actions[i]
+		if action.local == nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fileMetadata := map[string]any{}
+			for k, v := range metadata {
+				fileMetadata[k] = v
+			}
+			fileMetadata[syncRootKey] = root
+			fileMetadata[syncPathKey] = action.Path
+
+			file, err := uploadPath(ctx, c, action.local.abs, client.UploadInput{
+				Name:     root + "/" + action.Path,
+				Checksum: action.local.Checksum,
+				Metadata: fileMetadata,
+			})
+			if err != nil {
+				action.err = err
+				return
+			}
+			action.FileID = file.ID
+			for _, id := range action.stale {
+				if err := c.DeleteFile(ctx, id); err != nil && client.ErrorCode(err) != client.CodeNotFound {
+					action.err = fmt.Errorf("uploaded %s but failed to remove previous version %s: %w", file.ID, id, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range actions {
+		action := &actions[i]
+		if action.Action != actionDelete {
+			continue
+		}
+		if err := c.DeleteFile(ctx, action.FileID); err != nil && client.ErrorCode(err) != client.CodeNotFound {
+			action.err = err
+		}
+	}
+}
+
+func reportSync(opts *options, report syncReport) error {
+	var errs []error
+	for i := range report.Actions {
+		action := &report.Actions[i]
+		action.Error = toItemError(action.err)
+		switch {
+		case action.err != nil:
+			report.Summary.Failed++
+		case action.Action == actionUpload:
+			report.Summary.Uploaded++
+		case action.Action == actionUpdate:
+			report.Summary.Updated++
+		case action.Action == actionDelete:
+			report.Summary.Deleted++
+		case action.Action == actionUnchanged:
+			report.Summary.Unchanged++
+			continue
+		}
+		errs = append(errs, action.err)
+	}
+	if report.Actions == nil {
+		report.Actions = []syncAction{}
+	}
+
+	if opts.json() {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+		return summarize(errs)
+	}
+
+	verb := ""
+	if report.DryRun {
+		verb = "would "
+	}
+	for _, action := range report.Actions {
+		switch {
+		case action.Action == actionUnchanged:
+			continue
+		case action.err != nil:
+			fmt.Fprintf(os.Stderr, "error: %s %s: %v\n", action.Action, action.Path, action.err)
+		case action.Action == actionDelete:
+			fmt.Printf("%sdelete %s (%s)\n", verb, action.Path, action.FileID)
+		default:
+			fmt.Printf("%s%s %s (%s)\n", verb, action.Action, action.Path, formatSize(action.Size))
+		}
+	}
+	sum := report.Summary
+	if report.DryRun {
+		fmt.Print("dry run: ")
+	}
+	fmt.Printf("%d uploaded, %d updated, %d deleted, %d unchanged, %d failed\n",
+		sum.Uploaded, sum.Updated, sum.Deleted, sum.Unchanged, sum.Failed)
+	return summarize(errs)
+}
+
+// syncState 缓存本地文件的校验和，键为相对路径。
+type syncState struct {
+	Version int                   `json:"version"`
+	Files   map[string]stateEntry `json:"files"`
+}
+
+type stateEntry struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Checksum string    `json:"checksum"`
+}
+
+const syncStateVersion = 1
+
+// loadSyncState 读取状态文件；文件不存在或无法解析时从空状态开始，只会多算一次哈希。
+func loadSyncState(p string) *syncState {
+	state := &syncState{Version: syncStateVersion, Files: map[string]stateEntry{}}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return state
+	}
+	var loaded syncState
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Version != syncStateVersion || loaded.Files == nil {
+		return state
+	}
+	return &loaded
+}
+
+// save 先写临时文件再重命名，避免中断时留下半个文件。
+func (s *syncState) save(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// defaultStatePath 按 服务地址 + 本地目录 + 远端前缀 区分状态文件。
+func defaultStatePath(baseURL, dir, root string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.New("locate cache dir for sync state, use -state to set it explicitly")
+	}
+	sum := sha256.Sum256([]byte(baseURL + "\n" + dir + "\n" + root))
+	return filepath.Join(cacheDir, "droplite", "sync", hex.EncodeToString(sum[:8])+".json"), nil
+}
@@ -2,21 +2,28 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"droplite/internal/api"
 	"droplite/internal/config"
 	"droplite/internal/database"
+	"droplite/internal/dav"
+	"droplite/internal/grpcapi"
 	"droplite/internal/logging"
+	dlmiddleware "droplite/internal/middleware"
 	"droplite/internal/migrations"
 	postgresrepo "droplite/internal/repository/postgres"
+	"droplite/internal/s3api"
 	"droplite/internal/service"
-	"droplite/internal/storage"
-	"droplite/internal/storage/local"
-	s3storage "droplite/internal/storage/s3"
+	"droplite/internal/storage/backend"
+	"droplite/internal/tlsreload"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -24,6 +31,9 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if err := cfg.ValidateSecrets(); err != nil {
+		panic(err)
+	}
 
 	logger := logging.New()
 	logger.Println("配置加载完成，开始启动服务")
@@ -42,32 +52,110 @@ func main() {
 	fileRepo := postgresrepo.NewFileRepository(db)
 
 	// 根据配置选择存储后端
-	var fileStorage storage.Storage
-	switch cfg.StorageDriver {
-	case "s3":
+	if cfg.StorageDriver == "s3" {
 		logger.Printf("使用 S3 存储: endpoint=%s, bucket=%s", cfg.S3Endpoint, cfg.S3Bucket)
-		s3Store, err := s3storage.New(dbCtx, s3storage.Config{
-			Endpoint:  cfg.S3Endpoint,
-			AccessKey: cfg.S3AccessKey,
-			SecretKey: cfg.S3SecretKey,
-			Bucket:    cfg.S3Bucket,
-			Region:    cfg.S3Region,
-			UseSSL:    cfg.S3UseSSL,
-			PathStyle: cfg.S3PathStyle,
-		})
-		if err != nil {
-			logger.Fatalf("初始化 S3 存储失败: %v", err)
-		}
-		fileStorage = s3Store
-	default:
+	} else {
 		logger.Printf("使用本地存储: dir=%s", cfg.StorageDir)
-		fileStorage = local.NewWriter(cfg.StorageDir, "")
 	}
+	fileStorage, err := backend.Open(dbCtx, cfg)
+	if err != nil {
+		logger.Fatalf("初始化存储失败: %v", err)
+	}
+
+	schemaService := service.NewSchemaService(postgresrepo.NewMetadataSchemaRepository(db))
+
+	var credentialBox *service.SecretBox
+	if len(cfg.CredentialEncryptionKey) > 0 {
+		if credentialBox, err = service.NewSecretBox(cfg.CredentialEncryptionKey); err != nil {
+			logger.Fatalf("初始化凭证加密失败: %v", err)
+		}
+	}
+	apiKeyService := service.NewAPIKeyService(postgresrepo.NewAPIKeyRepository(db), credentialBox)
+	if n, err := apiKeyService.EncryptStoredSecrets(dbCtx); err != nil {
+		logger.Fatalf("加密已保存的 S3 secret 失败: %v", err)
+	} else if n > 0 {
+		logger.Printf("已加密 %d 个以明文保存的 S3 secret", n)
+	}
+	auditService := service.NewAuditService(postgresrepo.NewAuditRepository(db))
+
+	webhookRepo := postgresrepo.NewWebhookRepository(db)
+	webhookService := service.NewWebhookService(webhookRepo)
+
+	eventRepo := postgresrepo.NewEventRepository(db)
+	eventStream := service.NewEventStream(eventRepo)
 
 	fileService := service.NewFileService(fileRepo, fileStorage)
+	fileService.SetMetadataValidator(schemaService)
+	fileService.SetEventPublisher(service.EventPublishers{webhookService, eventStream})
 	fileHandler := api.NewFileHandler(fileService, cfg.MaxUploadSize)
+	transfers := dlmiddleware.NewTransferLimiter(dlmiddleware.TransferLimits{
+		MaxConcurrent:            cfg.UploadMaxConcurrent,
+		MaxConcurrentPerOwner:    cfg.UploadMaxConcurrentPerOwner,
+		MaxInFlightBytes:         cfg.UploadMaxInFlightBytes,
+		MaxInFlightBytesPerOwner: cfg.UploadMaxInFlightBytesPerOwner,
+		QueueTimeout:             cfg.UploadQueueTimeout,
+		OwnerBytesPerSecond:      cfg.OwnerBytesPerSecond,
+		TransferTimeout:          cfg.TransferTimeout,
+	})
+	fileHandler.SetTransferLimiter(transfers)
+	requestHandler := api.NewFileRequestHandler(service.NewFileRequests(postgresrepo.NewFileRequestRepository(db), fileService, cfg.MaxUploadSize), cfg.MaxUploadSize, cfg.PublicBaseURL)
+	requestHandler.SetTransferLimiter(transfers)
 
-	router := api.NewRouter(cfg, fileHandler)
+	shareHandler := api.NewShareHandler(fileService, service.NewShareLinks(fileService, cfg.ShareLinkSecret), cfg.PublicBaseURL)
+	shareHandler.SetTransferLimiter(transfers)
+
+	handlers := api.Handlers{
+		Files:    fileHandler,
+		Shares:   shareHandler,
+		Requests: requestHandler,
+		Events:   api.NewEventsHandler(eventStream),
+		Webhooks: api.NewWebhookHandler(webhookService),
+		Schemas:  api.NewSchemaHandler(schemaService),
+		APIKeys:  api.NewAPIKeyHandler(apiKeyService),
+		Audit:    api.NewAuditHandler(auditService),
+		DAV:      dav.NewHandler(fileService, cfg.MaxUploadSize, "/dav", transfers),
+	}
+	// 多副本部署时使用 Postgres 共享限流额度
+	var rateLimiter *postgresrepo.RateLimiter
+	if cfg.RateLimitBackend == "postgres" {
+		rateLimiter = postgresrepo.NewRateLimiter(db)
+		handlers.RateLimiter = rateLimiter
+	}
+	// 多副本部署时共享签名 nonce 记录，避免请求在另一个副本重放
+	var nonceStore *postgresrepo.NonceStore
+	if cfg.RequestSigningNonceBackend == "postgres" {
+		nonceStore = postgresrepo.NewNonceStore(db)
+		handlers.Nonces = nonceStore
+	}
+	router := api.NewRouter(cfg, handlers)
+
+	// 后台任务：webhook 投递、事件通知监听与过期清理，随服务关闭一起停止
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go service.NewWebhookDispatcher(webhookRepo, nil).Run(workerCtx, cfg.WebhookPollInterval)
+	go eventStream.Run(workerCtx, eventRepo)
+	go runEvery(workerCtx, cfg.ExpirySweepInterval, func(ctx context.Context) {
+		n, err := fileService.ExpireFiles(ctx, time.Now().UTC())
+		if err != nil {
+			logger.Printf("过期文件清理失败: %v", err)
+		} else if n > 0 {
+			logger.Printf("已清理 %d 个过期文件", n)
+		}
+		if _, err := eventStream.Prune(ctx, time.Now().UTC().Add(-cfg.EventRetention)); err != nil {
+			logger.Printf("事件日志清理失败: %v", err)
+		}
+		if rateLimiter != nil {
+			if _, err := rateLimiter.Prune(ctx); err != nil {
+				logger.Printf("限流状态清理失败: %v", err)
+			}
+		}
+		if nonceStore != nil {
+			if _, err := nonceStore.Prune(ctx); err != nil {
+				logger.Printf("签名 nonce 清理失败: %v", err)
+			}
+		}
+	})
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.HTTPPort,
@@ -77,24 +165,144 @@ func main() {
 		Handler:      router,
 	}
 
+	// 配置了证书时以 TLS 监听；证书文件变化或收到 SIGHUP 时重新加载，已建立的连接不受影响
+	var certs *tlsreload.Reloader
+	if cfg.TLSCertFile != "" {
+		certs, err = tlsreload.New(tlsreload.Files{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			ClientCAFile: cfg.TLSClientCAFile,
+		}, cfg.TLSRequireClientCert)
+		if err != nil {
+			logger.Fatalf("加载 TLS 证书失败: %v", err)
+		}
+		srv.TLSConfig = certs.TLSConfig()
+
+		go runEvery(workerCtx, cfg.TLSReloadInterval, func(ctx context.Context) {
+			if reloaded, err := certs.ReloadIfChanged(); err != nil {
+				logger.Printf("重新加载 TLS 证书失败，继续使用原证书: %v", err)
+			} else if reloaded {
+				logger.Printf("TLS 证书已重新加载")
+			}
+		})
+		go func() {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+			for {
+				select {
+				case <-workerCtx.Done():
+					return
+				case <-hup:
+					if err := certs.Reload(); err != nil {
+						logger.Printf("重新加载 TLS 证书失败，继续使用原证书: %v", err)
+					} else {
+						logger.Printf("收到 SIGHUP，TLS 证书已重新加载")
+					}
+				}
+			}
+		}()
+	}
+
 	logger.Printf("服务监听端口 :%s\n", cfg.HTTPPort)
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if certs != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("监听失败: %v", err)
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		var auth dlmiddleware.Authenticator
+		if cfg.AuthEnabled {
+			// gRPC 不支持请求签名，nonce 记录不会被用到
+			auth, err = dlmiddleware.NewAuthenticator(cfg, apiKeyService, nil)
+			if err != nil {
+				logger.Fatalf("初始化 gRPC 鉴权失败: %v", err)
+			}
+		}
+		grpcFiles := grpcapi.NewServer(fileService, cfg.MaxUploadSize)
+		grpcFiles.SetTransferLimiter(transfers)
+		grpcFiles.SetAuditRecorder(auditService)
+		grpcServer = grpcapi.NewGRPCServer(grpcFiles, auth)
+
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			logger.Fatalf("gRPC 监听失败: %v", err)
+		}
+		logger.Printf("gRPC 服务监听端口 :%s\n", cfg.GRPCPort)
+
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				logger.Fatalf("gRPC 服务异常退出: %v", err)
+			}
+		}()
+	}
+
+	var s3Server *http.Server
+	if cfg.S3GatewayPort != "" {
+		// S3 凭证由 API_KEYS 与数据库签发的 Key 派生；关闭鉴权时网关不校验签名
+		var creds s3api.CredentialStore
+		if cfg.AuthEnabled {
+			creds = s3api.NewCredentialStore(cfg.APIKeys, apiKeyService)
+		}
+		s3Server = &http.Server{
+			Addr:              ":" + cfg.S3GatewayPort,
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			Handler:           s3api.NewHandler(fileService, creds, cfg.S3GatewayRegion, cfg.MaxUploadSize, cfg.TrustedProxies, transfers, auditService),
+		}
+		logger.Printf("S3 网关监听端口 :%s\n", cfg.S3GatewayPort)
+
+		go func() {
+			if err := s3Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("S3 网关监听失败: %v", err)
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
 	<-stop
 
+	stopWorkers()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Printf("优雅关闭失败: %v", err)
 	}
+	if s3Server != nil {
+		if err := s3Server.Shutdown(ctx); err != nil {
+			logger.Printf("S3 网关优雅关闭失败: %v", err)
+		}
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
 
 	logger.Println("服务已停止")
 }
+
+// runEvery 立即执行一次 fn，之后每隔 interval 执行，直到 ctx 结束。
+func runEvery(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS metadata_schemas (
+    id UUID PRIMARY KEY,
+    scope TEXT NOT NULL CHECK (scope IN ('type', 'owner')),
+    scope_value TEXT NOT NULL,
+    schema JSONB NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (scope, scope_value)
+);
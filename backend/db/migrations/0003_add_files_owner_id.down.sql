@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_files_owner_name;
+
+ALTER TABLE files DROP COLUMN IF EXISTS owner_id;
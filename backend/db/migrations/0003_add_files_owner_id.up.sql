@@ -0,0 +1,4 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_files_owner_name
+    ON files (owner_id, original_name);
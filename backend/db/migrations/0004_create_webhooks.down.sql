@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_files_expires_at;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
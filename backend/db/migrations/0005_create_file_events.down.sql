@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS file_events_notify ON file_events;
+DROP FUNCTION IF EXISTS notify_file_event();
+DROP TABLE IF EXISTS file_events;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS file_events (
+    seq BIGSERIAL PRIMARY KEY,
+    id UUID NOT NULL UNIQUE,
+    owner_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_events_owner_seq
+    ON file_events (owner_id, seq);
+
+CREATE INDEX IF NOT EXISTS idx_file_events_created_at
+    ON file_events (created_at);
+
+-- 事务提交后通知所有副本，payload 为 "<seq>:<owner_id>"
+CREATE OR REPLACE FUNCTION notify_file_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('file_events', NEW.seq || ':' || NEW.owner_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS file_events_notify ON file_events;
+CREATE TRIGGER file_events_notify
+    AFTER INSERT ON file_events
+    FOR EACH ROW EXECUTE FUNCTION notify_file_event();
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    s3_access_key_id TEXT NOT NULL,
+    s3_secret TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix
+    ON api_keys (prefix);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_s3_access_key_id
+    ON api_keys (s3_access_key_id);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_owner_created_at
+    ON api_keys (owner_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- 令牌桶以 GCRA 形式保存：tat 为理论到达时间，早于当前时间的行等价于满桶，可随时清理
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tat TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_tat
+    ON rate_limit_buckets (tat);
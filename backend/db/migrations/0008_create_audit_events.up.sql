@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor TEXT NOT NULL DEFAULT '',
+    key_id TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    file_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    outcome TEXT NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at
+    ON audit_events (occurred_at);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id
+    ON audit_events (actor, id);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_file_id_id
+    ON audit_events (file_id, id);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_action_id
+    ON audit_events (action, id);
+
+-- 审计日志只允许追加，拒绝任何修改、删除与清空
+CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_event_change();
+
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_change();
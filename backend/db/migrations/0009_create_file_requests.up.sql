@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS file_requests (
+    id UUID PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    tags JSONB NOT NULL DEFAULT '[]',
+    max_file_size BIGINT NOT NULL DEFAULT 0,
+    max_files INTEGER NOT NULL DEFAULT 0,
+    allowed_mime_types JSONB NOT NULL DEFAULT '[]',
+    upload_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_file_requests_prefix
+    ON file_requests (prefix);
+
+CREATE INDEX IF NOT EXISTS idx_file_requests_owner_created_at
+    ON file_requests (owner_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- 请求签名已使用的 nonce，过期后同一 nonce 可被重新登记，过期的行可随时清理
+CREATE TABLE IF NOT EXISTS request_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at
+    ON request_nonces (expires_at);
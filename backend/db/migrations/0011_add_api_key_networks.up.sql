@@ -0,0 +1,3 @@
+-- 空数组表示不限制；denied_cidrs 中的网络始终被拒绝
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS allowed_cidrs JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS denied_cidrs JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyHandler 提供签发、查询、轮换与吊销 API Key 的管理端点。
+type APIKeyHandler struct {
+	service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(s *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: s}
+}
+
+func (h *APIKeyHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/api-keys", func(r chi.Router) {
+		r.Get("/", h.ListAPIKeys)
+		r.Post("/", h.CreateAPIKey)
+		r.Get("/{id}", h.GetAPIKey)
+		r.Patch("/{id}", h.UpdateAPIKey)
+		r.Delete("/{id}", h.RevokeAPIKey)
+		r.Post("/{id}/rotate", h.RotateAPIKey)
+	})
+}
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	OwnerID   string     `json:"owner_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// AllowedCIDRs 与 DeniedCIDRs 限制 Key 可以从哪些网络使用
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	DeniedCIDRs  []string `json:"denied_cidrs"`
+}
+
+// CreateAPIKey 签发 API Key，响应中包含仅返回一次的明文 key。
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+
+	key, err := h.service.Create(r.Context(), service.CreateAPIKeyInput{
+		OwnerID:      req.OwnerID,
+		Name:         req.Name,
+		Scopes:       req.Scopes,
+		ExpiresAt:    req.ExpiresAt,
+		AllowedCIDRs: req.AllowedCIDRs,
+		DeniedCIDRs:  req.DeniedCIDRs,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, envelope{Data: key})
+}
+
+// ListAPIKeys 分页返回 API Key，可按 owner_id 过滤，include_revoked=true 时包含已吊销的 Key。
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	query := r.URL.Query()
+	params := repository.ListAPIKeysParams{OwnerID: query.Get("owner_id")}
+	if include, err := strconv.ParseBool(query.Get("include_revoked")); err == nil {
+		params.IncludeRevoked = include
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil {
+		params.Limit = limit
+	}
+	if offset, err := strconv.Atoi(query.Get("offset")); err == nil && offset > 0 {
+		params.Offset = offset
+	}
+
+	keys, err := h.service.List(r.Context(), params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []repository.APIKey{}
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: keys})
+}
+
+// GetAPIKey 返回单个 API Key。
+func (h *APIKeyHandler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	key, err := h.service.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: key})
+}
+
+// updateAPIKeyRequest 中省略的字段保持不变，空数组表示清除。
+type updateAPIKeyRequest struct {
+	AllowedCIDRs *[]string `json:"allowed_cidrs"`
+	DeniedCIDRs  *[]string `json:"denied_cidrs"`
+}
+
+// UpdateAPIKey 替换 Key 的 CIDR 允许与拒绝列表，明文与其他字段不变。
+func (h *APIKeyHandler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	var req updateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+
+	key, err := h.service.UpdateNetworks(r.Context(), chi.URLParam(r, "id"), service.UpdateAPIKeyNetworksInput{
+		AllowedCIDRs: req.AllowedCIDRs,
+		DeniedCIDRs:  req.DeniedCIDRs,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: key})
+}
+
+// RotateAPIKey 为 Key 生成新的明文，旧明文立即失效。
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	key, err := h.service.Rotate(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: key})
+}
+
+// RevokeAPIKey 吊销 Key，记录保留以便审计。
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	key, err := h.service.Revoke(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: key})
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"droplite/internal/config"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+)
+
+type handlerAPIKeyRepo struct {
+	keys []repository.APIKey
+}
+
+func (m *handlerAPIKeyRepo) Create(ctx context.Context, key *repository.APIKey) (*repository.APIKey, error) {
+	key.CreatedAt = time.Now().UTC()
+	key.UpdatedAt = key.CreatedAt
+	m.keys = append(m.keys, *key)
+	return key, nil
+}
+
+func (m *handlerAPIKeyRepo) find(match func(repository.APIKey) bool) (*repository.APIKey, error) {
+	for i := range m.keys {
+		if match(m.keys[i]) {
+			key := m.keys[i]
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerAPIKeyRepo) GetByID(ctx context.Context, id string) (*repository.APIKey, error) {
+	return m.find(func(k repository.APIKey) bool { return k.ID == id })
+}
+
+func (m *handlerAPIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*repository.APIKey, error) {
+	return m.find(func(k repository.APIKey) bool { return k.Prefix == prefix })
+}
+
+func (m *handlerAPIKeyRepo) GetByS3AccessKeyID(ctx context.Context, accessKeyID string) (*repository.APIKey, error) {
+	return m.find(func(k repository.APIKey) bool { return k.S3AccessKeyID == accessKeyID })
+}
+
+func (m *handlerAPIKeyRepo) List(ctx context.Context, params repository.ListAPIKeysParams) ([]repository.APIKey, error) {
+	return append([]repository.APIKey(nil), m.keys...), nil
+}
+
+func (m *handlerAPIKeyRepo) UpdateSecret(ctx context.Context, id string, secret repository.APIKeySecret) (*repository.APIKey, error) {
+	for i := range m.keys {
+		if m.keys[i].ID == id {
+			m.keys[i].Prefix = secret.Prefix
+			m.keys[i].KeyHash = secret.KeyHash
+			m.keys[i].S3AccessKeyID = secret.S3AccessKeyID
+			m.keys[i].S3Secret = secret.S3Secret
+			key := m.keys[i]
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerAPIKeyRepo) UpdateNetworks(ctx context.Context, id string, allowed, denied []string) (*repository.APIKey, error) {
+	for i := range m.keys {
+		if m.keys[i].ID == id {
+			m.keys[i].AllowedCIDRs = allowed
+			m.keys[i].DeniedCIDRs = denied
+			key := m.keys[i]
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerAPIKeyRepo) Revoke(ctx context.Context, id string, at time.Time) (*repository.APIKey, error) {
+	for i := range m.keys {
+		if m.keys[i].ID == id {
+			m.keys[i].RevokedAt = &at
+			key := m.keys[i]
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerAPIKeyRepo) TouchLastUsed(ctx context.Context, id string, at time.Time, minInterval time.Duration) error {
+	return nil
+}
+
+func TestAPIKeyHandler_LifecycleThroughRouter(t *testing.T) {
+	webhooks := &handlerWebhookRepo{}
+	router := NewRouter(&config.Config{
+		AuthEnabled:  true,
+		APIKeys:      []string{"legacy-key"},
+		AdminAPIKeys: []string{"admin-key"},
+		// httptest 请求的对端地址为 192.0.2.1
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	}, Handlers{
+		Webhooks: NewWebhookHandler(service.NewWebhookService(webhooks)),
+		APIKeys:  NewAPIKeyHandler(service.NewAPIKeyService(&handlerAPIKeyRepo{}, nil)),
+	})
+
+	send := func(method, path, authorization string, body []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		return serveValidated(t, router, req)
+	}
+	decode := func(rec *httptest.ResponseRecorder) service.IssuedAPIKey {
+		t.Helper()
+		var resp struct {
+			Data service.IssuedAPIKey `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp.Data
+	}
+
+	if rec := send(http.MethodPost, "/admin/api-keys", "ApiKey legacy-key", []byte(`{"name":"ci"}`)); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected owner keys to be rejected on /admin, got %d", rec.Code)
+	}
+
+	rec := send(http.MethodPost, "/admin/api-keys", "ApiKey admin-key", []byte(`{"name":"ci","owner_id":"legacy-key"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	issued := decode(rec)
+	if issued.Key == "" || issued.OwnerID != "legacy-key" || bytes.Contains(rec.Body.Bytes(), []byte("key_hash")) {
+		t.Fatalf("unexpected create response: %s", rec.Body.String())
+	}
+
+	// 新 Key 与旧的静态 Key 对应同一 owner
+	hook := []byte(`{"url":"https://hooks.example.com/a","events":["file.created"]}`)
+	if rec := send(http.MethodPost, "/webhooks", "ApiKey "+issued.Key, hook); rec.Code != http.StatusCreated {
+		t.Fatalf("expected issued key to authenticate, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if webhooks.webhooks[0].OwnerID != "legacy-key" {
+		t.Fatalf("expected owner legacy-key, got %q", webhooks.webhooks[0].OwnerID)
+	}
+
+	rec = send(http.MethodPost, "/admin/api-keys/"+issued.ID+"/rotate", "ApiKey admin-key", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for rotate, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rotated := decode(rec)
+	if rotated.Key == issued.Key || rotated.ID != issued.ID {
+		t.Fatalf("unexpected rotate response: %s", rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "/webhooks", "ApiKey "+issued.Key, nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected old key to be rejected after rotation, got %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/webhooks", "ApiKey "+rotated.Key, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected rotated key to authenticate, got %d", rec.Code)
+	}
+
+	if rec := send(http.MethodDelete, "/admin/api-keys/"+issued.ID, "ApiKey admin-key", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for revoke, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "/webhooks", "ApiKey "+rotated.Key, nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked key to be rejected, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/admin/api-keys/"+issued.ID+"/rotate", "ApiKey admin-key", nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 rotating a revoked key, got %d", rec.Code)
+	}
+
+	// 带 admin scope 的数据库 Key 也可以访问管理端点
+	rec = send(http.MethodPost, "/admin/api-keys", "ApiKey admin-key", []byte(`{"name":"ops","scopes":["admin"]}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	operator := decode(rec)
+	if rec := send(http.MethodGet, "/admin/api-keys/"+operator.ID, "ApiKey "+operator.Key, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected admin-scoped key to reach /admin, got %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/webhooks", "ApiKey "+operator.Key, nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected admin-only key to lack files:read, got %d", rec.Code)
+	}
+
+	// 限制网络后只接受经可信代理转发、来自允许网段的请求
+	rec = send(http.MethodPatch, "/admin/api-keys/"+operator.ID, "ApiKey admin-key", []byte(`{"allowed_cidrs":["10.1.2.3/8"]}`))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"allowed_cidrs":["10.0.0.0/8"]`)) {
+		t.Fatalf("expected normalized allowed_cidrs, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "/admin/api-keys/"+operator.ID, "ApiKey "+operator.Key, nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected request outside allowed networks to be rejected, got %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin/api-keys/"+operator.ID, nil)
+	req.Header.Set("Authorization", "ApiKey "+operator.Key)
+	req.Header.Set("X-Forwarded-For", "10.4.5.6")
+	if rec := serveValidated(t, router, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected forwarded client in allowed network to pass, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPatch, "/admin/api-keys/"+operator.ID, "ApiKey admin-key", []byte(`{"denied_cidrs":["bogus"]}`)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid CIDR, got %d", rec.Code)
+	}
+
+	rec = send(http.MethodGet, "/admin/api-keys?include_revoked=true", "ApiKey admin-key", nil)
+	if rec.Code != http.StatusOK || bytes.Contains(rec.Body.Bytes(), []byte(`"key"`)) {
+		t.Fatalf("expected listing without plaintext keys, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// AuditHandler 提供审计日志的查询与导出端点。
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(s *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+func (h *AuditHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/audit-events", func(r chi.Router) {
+		r.Get("/", h.ListAuditEvents)
+		r.Get("/export", h.ExportAuditEvents)
+	})
+}
+
+// ListAuditEvents 按时间倒序返回审计记录，用上一页最后一条的 id 作为 before 翻页。
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	params, err := parseAuditParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	events, err := h.service.List(r.Context(), params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if events == nil {
+		events = []repository.AuditEvent{}
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: events})
+}
+
+// ExportAuditEvents 以 NDJSON 流式导出满足条件的全部审计记录，limit 可限制总条数。
+func (h *AuditHandler) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	params, err := parseAuditParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 响应头在第一条记录之前发出，此前的错误仍可以返回错误体
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit-events.ndjson"`)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	encoder := json.NewEncoder(w)
+	err = h.service.Export(r.Context(), params, func(event repository.AuditEvent) error {
+		start()
+		return encoder.Encode(event)
+	})
+	if err != nil {
+		if !started {
+			writeError(w, r, err)
+			return
+		}
+		log.Printf("[%s] export audit events: %v", chimiddleware.GetReqID(r.Context()), err)
+		return
+	}
+	start()
+}
+
+// parseAuditParams 解析查询参数，时间使用 RFC 3339。
+func parseAuditParams(r *http.Request) (repository.ListAuditEventsParams, error) {
+	query := r.URL.Query()
+	params := repository.ListAuditEventsParams{
+		Actor:   query.Get("actor"),
+		KeyID:   query.Get("key_id"),
+		Action:  query.Get("action"),
+		FileID:  query.Get("file_id"),
+		Outcome: query.Get("outcome"),
+	}
+	for name, target := range map[string]**time.Time{"since": &params.Since, "until": &params.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return params, service.NewError(service.KindValidation, "invalid "+name+": expected RFC 3339 timestamp")
+		}
+		*target = &t
+	}
+	if raw := query.Get("before"); raw != "" {
+		before, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return params, service.NewError(service.KindValidation, "invalid before: expected event id")
+		}
+		params.BeforeID = before
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return params, service.NewError(service.KindValidation, "invalid limit")
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"droplite/internal/auth"
+	"droplite/internal/config"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+)
+
+type handlerAuditRepo struct {
+	mu     sync.Mutex
+	events []repository.AuditEvent
+}
+
+func (m *handlerAuditRepo) Append(ctx context.Context, event *repository.AuditEvent) (*repository.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *event
+	stored.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, stored)
+	return &stored, nil
+}
+
+func (m *handlerAuditRepo) List(ctx context.Context, params repository.ListAuditEventsParams) ([]repository.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []repository.AuditEvent
+	for i := len(m.events) - 1; i >= 0 && len(result) < params.Limit; i-- {
+		e := m.events[i]
+		if (params.Actor != "" && e.Actor != params.Actor) ||
+			(params.Action != "" && e.Action != params.Action) ||
+			(params.FileID != "" && e.FileID != params.FileID) ||
+			(params.Outcome != "" && e.Outcome != params.Outcome) ||
+			(params.BeforeID > 0 && e.ID >= params.BeforeID) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func TestAuditHandler_RecordsAndQueriesThroughRouter(t *testing.T) {
+	audit := &handlerAuditRepo{}
+	keys := service.NewAPIKeyService(&handlerAPIKeyRepo{}, nil)
+	router := NewRouter(&config.Config{
+		AuthEnabled:  true,
+		APIKeys:      []string{"static-key"},
+		AdminAPIKeys: []string{"admin-key"},
+	}, Handlers{
+		Files:   NewFileHandler(service.NewFileService(&handlerRepo{getResult: &repository.FileRecord{ID: "abc", OwnerID: "alice"}}, nil), 1024),
+		APIKeys: NewAPIKeyHandler(keys),
+		Audit:   NewAuditHandler(service.NewAuditService(audit)),
+	})
+	issued, err := keys.Create(context.Background(), service.CreateAPIKeyInput{Name: "reader", OwnerID: "alice"})
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	send := func(method, path, authorization string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "203.0.113.9:4000"
+		req.Header.Set("User-Agent", "audit-test/1.0")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return serveValidated(t, router, req)
+	}
+
+	if rec := send(http.MethodDelete, "/files/abc", "ApiKey "+issued.Key); rec.Code != http.StatusOK {
+		t.Fatalf("delete: got %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/files", "ApiKey wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	// 未标注审计动作的成功请求不记录
+	if rec := send(http.MethodGet, "/files", "ApiKey "+issued.Key); rec.Code != http.StatusOK {
+		t.Fatalf("list: got %d", rec.Code)
+	}
+	if rec := send(http.MethodDelete, "/files/abc", "ApiKey static-key"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another owner's file, got %d", rec.Code)
+	}
+
+	rec := send(http.MethodGet, "/admin/audit-events", "ApiKey admin-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list audit events: got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data []repository.AuditEvent `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Data) != 3 {
+		t.Fatalf("expected 3 audit events, got %+v", resp.Data)
+	}
+	static, failure, deleted := resp.Data[0], resp.Data[1], resp.Data[2]
+	if failure.Action != "auth.failure" || failure.Outcome != "denied" || failure.Actor != "" || failure.Status != http.StatusUnauthorized {
+		t.Fatalf("unexpected auth failure event: %+v", failure)
+	}
+	if deleted.Action != "file.delete" || deleted.Outcome != "success" || deleted.FileID != "abc" ||
+		deleted.Actor != "alice" || deleted.KeyID != issued.ID || deleted.IP != "203.0.113.9" ||
+		deleted.UserAgent != "audit-test/1.0" || deleted.RequestID == "" {
+		t.Fatalf("unexpected delete event: %+v", deleted)
+	}
+	// 静态 Key 以原值作为 owner ID，审计记录只保存固定标识与派生的 Key ID
+	if keyID, _ := auth.DeriveKeyCredentials("static-key"); static.Actor != auth.StaticKeyActor ||
+		static.KeyID != keyID || static.Outcome != "failure" {
+		t.Fatalf("unexpected static key event: %+v", static)
+	}
+	if strings.Contains(rec.Body.String(), "static-key\"") || strings.Contains(rec.Body.String(), "admin-key\"") {
+		t.Fatalf("audit events must not contain raw keys: %s", rec.Body.String())
+	}
+
+	rec = send(http.MethodGet, "/admin/audit-events?action=file.delete&actor=alice", "ApiKey admin-key")
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 {
+		t.Fatalf("expected filtered result, got %s", rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "/admin/audit-events?since=2026-01-02T00:00:00Z&until=2026-01-01T00:00:00Z", "ApiKey admin-key"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty time range, got %d", rec.Code)
+	}
+}
+
+func TestAuditHandler_ExportsNDJSON(t *testing.T) {
+	audit := &handlerAuditRepo{}
+	svc := service.NewAuditService(audit)
+	for _, action := range []string{"file.create", "file.download", "file.delete"} {
+		_, _ = audit.Append(context.Background(), &repository.AuditEvent{Action: action, Outcome: "success"})
+	}
+	router := NewRouter(&config.Config{}, Handlers{Audit: NewAuditHandler(svc)})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/audit-events/export?limit=2", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected response %d %v", rec.Code, rec.Header())
+	}
+
+	var actions []string
+	scanner := bufio.NewScanner(bytes.NewReader(rec.Body.Bytes()))
+	for scanner.Scan() {
+		var event repository.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		actions = append(actions, event.Action)
+	}
+	if len(actions) != 2 || actions[0] != "file.delete" || actions[1] != "file.download" {
+		t.Fatalf("expected the two newest events, got %v", actions)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"droplite/internal/service"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// errorBody 是所有错误响应的统一结构。
+type errorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+// statusForKind 将服务层错误码映射为 HTTP 状态码。
+func statusForKind(kind service.ErrorKind) int {
+	switch kind {
+	case service.KindValidation:
+		return http.StatusBadRequest
+	case service.KindUnauthorized:
+		return http.StatusUnauthorized
+	case service.KindForbidden, service.KindQuotaExceeded:
+		return http.StatusForbidden
+	case service.KindNotFound:
+		return http.StatusNotFound
+	case service.KindConflict:
+		return http.StatusConflict
+	case service.KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case service.KindRateLimited:
+		return http.StatusTooManyRequests
+	case service.KindStorageUnavailable, service.KindDatabaseUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError 将 err 归一为类型化错误，按错误码输出状态码与统一错误体。
+// 5xx 错误只向客户端返回概要信息，底层原因写入日志。
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	svcErr := service.AsError(err)
+	status := statusForKind(svcErr.Kind)
+	requestID := chimiddleware.GetReqID(r.Context())
+
+	if status >= http.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", requestID, r.Method, r.URL.Path, err)
+	}
+
+	writeJSON(w, status, errorEnvelope{Error: errorBody{
+		Code:      string(svcErr.Kind),
+		Message:   svcErr.Message,
+		Details:   svcErr.Details,
+		RequestID: requestID,
+	}})
+}
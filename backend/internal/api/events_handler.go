@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	eventsBatchSize         = 100
+	eventsHeartbeat         = 15 * time.Second
+	eventsRetryMilliseconds = 3000
+)
+
+// EventsHandler 以 Server-Sent Events 推送调用方的文件事件。
+type EventsHandler struct {
+	stream *service.EventStream
+}
+
+func NewEventsHandler(stream *service.EventStream) *EventsHandler {
+	return &EventsHandler{stream: stream}
+}
+
+func (h *EventsHandler) RegisterRoutes(r chi.Router) {
+	r.With(dlmiddleware.RequireScope(auth.ScopeFilesRead)).Get("/events", h.StreamEvents)
+}
+
+// StreamEvents 建立 SSE 连接：带 Last-Event-ID（或 last_event_id 查询参数）时从该序号之后续传，
+// 否则只推送连接建立之后的事件。
+func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	ctx := r.Context()
+	ownerID := dlmiddleware.GetOwnerID(ctx)
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	// 先订阅再确定起点，避免两者之间产生的事件被漏掉
+	wake, cancel := h.stream.Subscribe(ownerID)
+	defer cancel()
+
+	var after int64
+	if lastID != "" {
+		seq, err := strconv.ParseInt(strings.TrimSpace(lastID), 10, 64)
+		if err != nil || seq < 0 {
+			writeError(w, r, service.NewError(service.KindValidation, "invalid Last-Event-ID"))
+			return
+		}
+		after = seq
+	} else {
+		seq, err := h.stream.LatestSeq(ctx)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		after = seq
+	}
+
+	rc := http.NewResponseController(w)
+	// 长连接不受服务器 WriteTimeout 限制
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetryMilliseconds)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		events, err := h.stream.Since(ctx, ownerID, after, eventsBatchSize)
+		if err != nil {
+			// 响应头已发送，只能断开让客户端携带 Last-Event-ID 重连
+			return
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, event.Payload)
+			after = event.Seq
+		}
+		if len(events) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+		if len(events) == eventsBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
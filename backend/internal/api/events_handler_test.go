@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+)
+
+type handlerEventRepo struct {
+	mu     sync.Mutex
+	events []repository.EventRecord
+}
+
+func (m *handlerEventRepo) Append(ctx context.Context, event *repository.EventRecord) (*repository.EventRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *event
+	stored.Seq = int64(len(m.events) + 1)
+	stored.CreatedAt = time.Now().UTC()
+	m.events = append(m.events, stored)
+	return &stored, nil
+}
+
+func (m *handlerEventRepo) ListSince(ctx context.Context, ownerID string, afterSeq int64, limit int) ([]repository.EventRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.EventRecord
+	for _, e := range m.events {
+		if e.OwnerID == ownerID && e.Seq > afterSeq && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (m *handlerEventRepo) LatestSeq(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.events)), nil
+}
+
+func (m *handlerEventRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// sseReader 逐条读取 SSE 事件，返回 id 与 event 字段。
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *sseReader) next(t *testing.T) (id, event string) {
+	t.Helper()
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		switch {
+		case line == "" && id != "":
+			return id, event
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		}
+	}
+	t.Fatalf("stream ended: %v", r.scanner.Err())
+	return "", ""
+}
+
+func openStream(t *testing.T, url, ownerID, lastEventID string) *sseReader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("X-Owner", ownerID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return &sseReader{scanner: bufio.NewScanner(resp.Body)}
+}
+
+func TestEventsHandler_StreamsOwnerEventsAndResumes(t *testing.T) {
+	stream := service.NewEventStream(&handlerEventRepo{})
+	handler := NewEventsHandler(stream)
+
+	// 用请求头模拟鉴权中间件注入的 owner
+	srv := httptest.NewServer(dlmiddleware.Metrics()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.StreamEvents(w, r.WithContext(dlmiddleware.WithOwnerID(r.Context(), r.Header.Get("X-Owner"))))
+	})))
+	// Cleanup 后进先出：先断开各个事件流，再关闭服务器
+	t.Cleanup(srv.Close)
+
+	publish := func(owner string, eventType service.EventType) {
+		t.Helper()
+		err := stream.Publish(context.Background(), service.Event{
+			ID:         string(eventType),
+			Type:       eventType,
+			OccurredAt: time.Now().UTC(),
+			OwnerID:    owner,
+			Data:       &repository.FileRecord{ID: "file-1"},
+		})
+		if err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	publish("owner-a", service.EventFileCreated)
+
+	live := openStream(t, srv.URL, "owner-a", "")
+	publish("owner-b", service.EventFileCreated)
+	publish("owner-a", service.EventFileUpdated)
+	publish("owner-a", service.EventFileDeleted)
+
+	// 新连接不回放历史，也看不到其他 owner 的事件
+	if id, event := live.next(t); id != "3" || event != "file.updated" {
+		t.Fatalf("unexpected first live event %s %s", id, event)
+	}
+	if id, event := live.next(t); id != "4" || event != "file.deleted" {
+		t.Fatalf("unexpected second live event %s %s", id, event)
+	}
+
+	resumed := openStream(t, srv.URL, "owner-a", "1")
+	if id, event := resumed.next(t); id != "3" || event != "file.updated" {
+		t.Fatalf("unexpected resumed event %s %s", id, event)
+	}
+}
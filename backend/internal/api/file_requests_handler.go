@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FileRequestHandler 管理 owner 的文件请求，并接收持有令牌的匿名上传。
+type FileRequestHandler struct {
+	requests      *service.FileRequests
+	maxUploadSize int64
+	baseURL       string
+	transfers     *dlmiddleware.TransferLimiter
+}
+
+// NewFileRequestHandler 创建文件请求 handler，baseURL 为空时按请求的 Host 生成链接。
+func NewFileRequestHandler(requests *service.FileRequests, maxUploadSize int64, baseURL string) *FileRequestHandler {
+	return &FileRequestHandler{requests: requests, maxUploadSize: maxUploadSize, baseURL: baseURL}
+}
+
+// SetTransferLimiter 设置上传准入与按调用方限速，须在注册路由之前调用；匿名上传按客户端 IP 计算。
+func (h *FileRequestHandler) SetTransferLimiter(l *dlmiddleware.TransferLimiter) {
+	h.transfers = l
+}
+
+// RegisterRoutes 注册需要鉴权的管理端点，文件请求会向 owner 名下写入文件，要求 files:write。
+func (h *FileRequestHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/file-requests", func(r chi.Router) {
+		r.Use(dlmiddleware.RequireScope(auth.ScopeFilesWrite))
+		r.Get("/", h.ListFileRequests)
+		r.Post("/", h.CreateFileRequest)
+		r.Delete("/{id}", h.RevokeFileRequest)
+	})
+}
+
+// RegisterPublicRoutes 注册无需鉴权的端点，令牌本身即凭证，只能查看限制与上传。
+func (h *FileRequestHandler) RegisterPublicRoutes(r chi.Router) {
+	r.Get("/r/{token}", h.GetFileRequest)
+	r.With(dlmiddleware.AuditAction(auth.AuditFileCreate), h.transfers.AdmitUploads(h.maxUploadSize+multipartMemoryBudget)).
+		Post("/r/{token}", h.UploadToFileRequest)
+}
+
+type createFileRequestRequest struct {
+	Name             string   `json:"name"`
+	Folder           string   `json:"folder"`
+	Tags             []string `json:"tags"`
+	MaxFileSize      int64    `json:"max_file_size"`
+	MaxFiles         int      `json:"max_files"`
+	AllowedMimeTypes []string `json:"allowed_mime_types"`
+	ExpiresIn        int64    `json:"expires_in"` // 秒，为 0 时使用默认有效期
+}
+
+// CreateFileRequest 签发文件请求，响应中包含仅返回一次的令牌与上传地址。
+func (h *FileRequestHandler) CreateFileRequest(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	var req createFileRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+	if req.ExpiresIn < 0 {
+		writeError(w, r, service.NewError(service.KindValidation, "expires_in must not be negative"))
+		return
+	}
+
+	issued, err := h.requests.Create(r.Context(), service.CreateFileRequestInput{
+		OwnerID:          dlmiddleware.GetOwnerID(r.Context()),
+		Name:             req.Name,
+		Folder:           req.Folder,
+		Tags:             req.Tags,
+		MaxFileSize:      req.MaxFileSize,
+		MaxFiles:         req.MaxFiles,
+		AllowedMimeTypes: req.AllowedMimeTypes,
+		TTL:              time.Duration(req.ExpiresIn) * time.Second,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	issued.URL = publicURL(r, h.baseURL) + "/r/" + url.PathEscape(issued.Token)
+
+	writeJSON(w, http.StatusCreated, envelope{Data: issued})
+}
+
+// ListFileRequests 返回调用方的全部文件请求，包括已过期与已吊销的。
+func (h *FileRequestHandler) ListFileRequests(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	requests, err := h.requests.List(r.Context(), dlmiddleware.GetOwnerID(r.Context()))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if requests == nil {
+		requests = []repository.FileRequest{}
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: requests})
+}
+
+// RevokeFileRequest 吊销文件请求，已上传的文件不受影响。
+func (h *FileRequestHandler) RevokeFileRequest(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	request, err := h.requests.Revoke(r.Context(), dlmiddleware.GetOwnerID(r.Context()), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: request})
+}
+
+// publicFileRequest 是匿名访问者可以看到的限制，不包含 owner 与已上传的文件。
+type publicFileRequest struct {
+	Name             string    `json:"name"`
+	MaxFileSize      int64     `json:"max_file_size"`
+	RemainingFiles   *int      `json:"remaining_files,omitempty"`
+	AllowedMimeTypes []string  `json:"allowed_mime_types"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// GetFileRequest 返回文件请求的上传限制，供上传页面展示。
+func (h *FileRequestHandler) GetFileRequest(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	request, err := h.requests.Resolve(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	view := publicFileRequest{
+		Name:             request.Name,
+		MaxFileSize:      request.MaxFileSize,
+		AllowedMimeTypes: request.AllowedMimeTypes,
+		ExpiresAt:        request.ExpiresAt,
+	}
+	if view.MaxFileSize <= 0 {
+		view.MaxFileSize = h.maxUploadSize
+	}
+	if request.MaxFiles > 0 {
+		remaining := request.MaxFiles - request.UploadCount
+		view.RemainingFiles = &remaining
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: view})
+}
+
+// fileRequestReceipt 是匿名上传的回执，不暴露 owner 与 metadata。
+type fileRequestReceipt struct {
+	ID           string `json:"id"`
+	OriginalName string `json:"original_name"`
+	SizeBytes    int64  `json:"size_bytes"`
+}
+
+// UploadToFileRequest 接受匿名 multipart 上传，文件归属创建请求的 owner。
+func (h *FileRequestHandler) UploadToFileRequest(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	// 先校验令牌，避免为无效链接接收整个请求体；请求体按该请求自己的大小限制截断
+	token := chi.URLParam(r, "token")
+	request, err := h.requests.Resolve(r.Context(), token)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	limit := h.maxUploadSize
+	if request.MaxFileSize > 0 {
+		limit = min(request.MaxFileSize, h.maxUploadSize)
+	}
+
+	upload, cleanup, err := readMultipartUpload(w, r, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer cleanup()
+
+	record, err := h.requests.Upload(r.Context(), token, service.FileRequestUpload{
+		OriginalName: upload.name,
+		MimeType:     upload.mimeType,
+		SizeBytes:    upload.size,
+		Reader:       upload.file,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	dlmiddleware.SetAuditFileID(r.Context(), record.ID)
+
+	writeJSON(w, http.StatusCreated, envelope{Data: fileRequestReceipt{
+		ID:           record.ID,
+		OriginalName: record.OriginalName,
+		SizeBytes:    record.SizeBytes,
+	}})
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type handlerFileRequestRepo struct {
+	mu      sync.Mutex
+	request *repository.FileRequest
+}
+
+func (m *handlerFileRequestRepo) Create(ctx context.Context, request *repository.FileRequest) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *request
+	stored.CreatedAt, stored.UpdatedAt = time.Now(), time.Now()
+	m.request = &stored
+	copied := stored
+	return &copied, nil
+}
+
+func (m *handlerFileRequestRepo) get(match func(*repository.FileRequest) bool) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.request == nil || !match(m.request) {
+		return nil, repository.ErrNotFound
+	}
+	copied := *m.request
+	return &copied, nil
+}
+
+func (m *handlerFileRequestRepo) GetByID(ctx context.Context, id string) (*repository.FileRequest, error) {
+	return m.get(func(r *repository.FileRequest) bool { return r.ID == id })
+}
+
+func (m *handlerFileRequestRepo) GetByPrefix(ctx context.Context, prefix string) (*repository.FileRequest, error) {
+	return m.get(func(r *repository.FileRequest) bool { return r.Prefix == prefix })
+}
+
+func (m *handlerFileRequestRepo) ListByOwner(ctx context.Context, ownerID string) ([]repository.FileRequest, error) {
+	request, err := m.get(func(r *repository.FileRequest) bool { return r.OwnerID == ownerID })
+	if err != nil {
+		return nil, nil
+	}
+	return []repository.FileRequest{*request}, nil
+}
+
+func (m *handlerFileRequestRepo) Revoke(ctx context.Context, id string, at time.Time) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	if m.request != nil && m.request.ID == id && m.request.RevokedAt == nil {
+		m.request.RevokedAt = &at
+	}
+	m.mu.Unlock()
+	return m.GetByID(ctx, id)
+}
+
+func (m *handlerFileRequestRepo) ReserveUpload(ctx context.Context, id string, now time.Time) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	if m.request != nil && m.request.ID == id {
+		m.request.UploadCount++
+	}
+	m.mu.Unlock()
+	return m.GetByID(ctx, id)
+}
+
+func (m *handlerFileRequestRepo) ReleaseUpload(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestFileRequestHandler_CreateInspectAndUpload(t *testing.T) {
+	files := &handlerRepo{}
+	requests := service.NewFileRequests(&handlerFileRequestRepo{}, service.NewFileService(files, &handlerWriter{}), 1024)
+	handler := NewFileRequestHandler(requests, 1024, "https://files.example.com")
+
+	router := chi.NewRouter()
+	router.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(dlmiddleware.WithOwnerID(r.Context(), r.Header.Get("X-Owner"))))
+			})
+		})
+		handler.RegisterRoutes(r)
+	})
+	handler.RegisterPublicRoutes(router)
+
+	req := httptest.NewRequest(http.MethodPost, "/file-requests", bytes.NewReader([]byte(`{"name":"Scans","folder":"inbox","max_files":2,"max_file_size":16,"allowed_mime_types":["application/octet-stream"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", "owner-a")
+	rec := serveValidated(t, router, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Data service.IssuedFileRequest `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	link, err := url.Parse(created.Data.URL)
+	if err != nil || link.Host != "files.example.com" || link.Path != "/r/"+created.Data.Token {
+		t.Fatalf("unexpected file request url %q", created.Data.URL)
+	}
+
+	rec = serveValidated(t, router, httptest.NewRequest(http.MethodGet, link.Path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var view struct {
+		Data publicFileRequest `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil || view.Data.RemainingFiles == nil || *view.Data.RemainingFiles != 2 || view.Data.MaxFileSize != 16 {
+		t.Fatalf("unexpected public view %s", rec.Body.String())
+	}
+
+	// 请求自身的 max_file_size 比全局上限更严
+	upload := newMultipartRequest(t, nil, "file", "big.bin", bytes.Repeat([]byte{0x00}, 32))
+	upload.URL.Path = link.Path
+	if rec := serveValidated(t, router, upload); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 above the request's max_file_size, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// 内容嗅探出的类型同样要在允许列表内
+	upload = newMultipartRequest(t, nil, "file", "page.bin", []byte("<html>hi</html>"))
+	upload.URL.Path = link.Path
+	if rec := serveValidated(t, router, upload); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for html content, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	upload = newMultipartRequest(t, nil, "file", "scan.bin", []byte{0x00, 0x01, 0x02, 0x03})
+	upload.URL.Path = link.Path
+	rec = serveValidated(t, router, upload)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if files.createRecord == nil || files.createRecord.OwnerID != "owner-a" || files.createRecord.OriginalName != "inbox/scan.bin" {
+		t.Fatalf("unexpected stored file %+v", files.createRecord)
+	}
+
+	rec = serveValidated(t, router, httptest.NewRequest(http.MethodGet, "/r/fr_unknown_secret", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown token, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/file-requests/"+created.Data.ID, nil)
+	req.Header.Set("X-Owner", "owner-a")
+	if rec := serveValidated(t, router, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 on revoke, got %d: %s", rec.Code, rec.Body.String())
+	}
+	upload = newMultipartRequest(t, nil, "file", "late.txt", []byte("late"))
+	upload.URL.Path = link.Path
+	if rec := serveValidated(t, router, upload); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after revoke, got %d", rec.Code)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
 	"droplite/internal/repository"
 	"droplite/internal/service"
 
@@ -20,6 +22,7 @@ import (
 type FileHandler struct {
 	service       *service.FileService
 	maxUploadSize int64
+	transfers     *dlmiddleware.TransferLimiter
 }
 
 func NewFileHandler(s *service.FileService, maxUploadSize int64) *FileHandler {
@@ -29,13 +32,28 @@ func NewFileHandler(s *service.FileService, maxUploadSize int64) *FileHandler {
 	}
 }
 
+// SetTransferLimiter 设置上传准入与按 owner 限速，须在 RegisterRoutes 之前调用；未设置时不做限制。
+func (h *FileHandler) SetTransferLimiter(l *dlmiddleware.TransferLimiter) {
+	h.transfers = l
+}
+
+// RegisterRoutes 注册文件端点，每个端点要求对应的 scope，缺少时返回 403。
+// 上传、下载与删除写入审计日志，批量删除在 BatchFiles 中逐项记录。
 func (h *FileHandler) RegisterRoutes(r chi.Router) {
+	read := dlmiddleware.RequireScope(auth.ScopeFilesRead)
+	write := dlmiddleware.RequireScope(auth.ScopeFilesWrite)
+	remove := dlmiddleware.RequireScope(auth.ScopeFilesDelete)
+	audit := dlmiddleware.AuditAction
+
 	r.Route("/files", func(r chi.Router) {
-		r.Get("/", h.ListFiles)
-		r.Post("/", h.CreateFile)
-		r.Get("/{id}", h.GetFile)
-		r.Get("/{id}/download", h.DownloadFile)
-		r.Delete("/{id}", h.DeleteFile)
+		r.With(read).Get("/", h.ListFiles)
+		r.With(audit(auth.AuditFileCreate), write, h.transfers.AdmitUploads(h.maxUploadSize+multipartMemoryBudget)).Post("/", h.CreateFile)
+		// 包含 delete 操作的批量请求还需要 files:delete，在 BatchFiles 中检查
+		r.With(write).Post("/batch", h.BatchFiles)
+		r.With(read).Get("/{id}", h.GetFile)
+		r.With(write).Patch("/{id}", h.UpdateFile)
+		r.With(audit(auth.AuditFileDownload), read, h.transfers.ShapeDownloads()).Get("/{id}/download", h.DownloadFile)
+		r.With(audit(auth.AuditFileDelete), remove).Delete("/{id}", h.DeleteFile)
 	})
 }
 
@@ -43,10 +61,6 @@ type envelope struct {
 	Data any `json:"data"`
 }
 
-type errorEnvelope struct {
-	Error string `json:"error"`
-}
-
 const (
 	multipartMemoryBudget int64 = 16 * 1024 * 1024
 )
@@ -54,88 +68,49 @@ const (
 // CreateFile 接受 multipart/form-data 上传并登记文件元数据。
 func (h *FileHandler) CreateFile(w http.ResponseWriter, r *http.Request) {
 	if h == nil {
-		writeError(w, http.StatusInternalServerError, "handler not initialized")
-		return
-	}
-	if r.Body == nil {
-		writeError(w, http.StatusBadRequest, "request body is empty")
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize+multipartMemoryBudget)
-	defer r.Body.Close()
-
-	if err := r.ParseMultipartForm(multipartMemoryBudget); err != nil {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart form: %v", err))
-		return
-	}
-	defer func() {
-		if r.MultipartForm != nil {
-			_ = r.MultipartForm.RemoveAll()
-		}
-	}()
-
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "file field is required")
-		return
-	}
-	defer file.Close()
-
-	sizeBytes, err := determineFileSize(file, header)
+	upload, cleanup, err := readMultipartUpload(w, r, h.maxUploadSize)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-	if sizeBytes <= 0 {
-		writeError(w, http.StatusBadRequest, "file must not be empty")
-		return
-	}
-	if sizeBytes > h.maxUploadSize {
-		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds size limit (%d bytes)", h.maxUploadSize))
+		writeError(w, r, err)
 		return
 	}
+	defer cleanup()
 
-	mimeType, err := resolveMimeType(header, file)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-	if err := rewindFile(file); err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to read uploaded file")
-		return
-	}
-
-	originalName := header.Filename
+	originalName := upload.name
 	if override := strings.TrimSpace(r.FormValue("original_name")); override != "" {
 		originalName = override
 	}
 
 	metadata, err := parseMetadataField(r.FormValue("metadata"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid metadata: "+err.Error())
+		writeError(w, r, service.NewError(service.KindValidation, "invalid metadata: "+err.Error()))
 		return
 	}
 
 	expiresAt, err := parseExpiresAt(r.FormValue("expires_at"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid expires_at: "+err.Error())
+		writeError(w, r, service.NewError(service.KindValidation, "invalid expires_at: "+err.Error()))
 		return
 	}
 
 	record, err := h.service.RegisterFile(r.Context(), service.RegisterFileInput{
+		OwnerID:      dlmiddleware.GetOwnerID(r.Context()),
 		OriginalName: originalName,
-		MimeType:     mimeType,
-		SizeBytes:    sizeBytes,
+		MimeType:     upload.mimeType,
+		SizeBytes:    upload.size,
 		Checksum:     optionalString(r.FormValue("checksum")),
 		Metadata:     metadata,
 		ExpiresAt:    expiresAt,
-		Reader:       file,
+		Reader:       upload.file,
 	})
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, r, err)
 		return
 	}
+	dlmiddleware.SetAuditFileID(r.Context(), record.ID)
 
 	writeJSON(w, http.StatusCreated, envelope{Data: record})
 }
@@ -143,11 +118,11 @@ func (h *FileHandler) CreateFile(w http.ResponseWriter, r *http.Request) {
 // ListFiles 返回文件集合。
 func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	if h == nil {
-		writeError(w, http.StatusInternalServerError, "handler not initialized")
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
 		return
 	}
 
-	params := repository.ListFilesParams{}
+	params := repository.ListFilesParams{OwnerID: dlmiddleware.GetOwnerID(r.Context())}
 
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil {
@@ -177,9 +152,12 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 
 	files, err := h.service.ListFiles(r.Context(), params)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, err)
 		return
 	}
+	if files == nil {
+		files = []repository.FileRecord{}
+	}
 
 	writeJSON(w, http.StatusOK, envelope{Data: files})
 }
@@ -187,30 +165,35 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 // DownloadFile 返回文件内容以供下载。
 func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	if h == nil {
-		writeError(w, http.StatusInternalServerError, "handler not initialized")
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		writeError(w, http.StatusBadRequest, "file id is required")
+		writeError(w, r, service.NewError(service.KindValidation, "file id is required"))
 		return
 	}
 
-	file, err := h.service.GetFile(r.Context(), id)
+	file, err := h.service.GetOwnedFile(r.Context(), id, dlmiddleware.GetOwnerID(r.Context()))
 	if err != nil {
-		writeError(w, http.StatusNotFound, "file not found")
+		writeError(w, r, err)
 		return
 	}
 
 	if file.Status != "stored" {
-		writeError(w, http.StatusNotFound, "file not available for download")
+		writeError(w, r, service.NewError(service.KindNotFound, "file not available for download"))
 		return
 	}
 
-	content, err := h.service.GetFileContent(r.Context(), file.StoragePath)
+	writeFileContent(w, r, h.service, file)
+}
+
+// writeFileContent 以附件形式输出文件内容，供下载与分享链接共用。
+func writeFileContent(w http.ResponseWriter, r *http.Request, files *service.FileService, file *repository.FileRecord) {
+	content, err := files.GetFileContent(r.Context(), file.StoragePath)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to read file")
+		writeError(w, r, err)
 		return
 	}
 	defer content.Close()
@@ -228,19 +211,55 @@ func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 // GetFile 返回单个文件的元数据。
 func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 	if h == nil {
-		writeError(w, http.StatusInternalServerError, "handler not initialized")
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeError(w, r, service.NewError(service.KindValidation, "file id is required"))
+		return
+	}
+
+	file, err := h.service.GetOwnedFile(r.Context(), id, dlmiddleware.GetOwnerID(r.Context()))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: file})
+}
+
+type updateFileRequest struct {
+	Metadata map[string]any `json:"metadata"`
+}
+
+// UpdateFile 以 merge patch 语义更新文件 metadata。
+func (h *FileHandler) UpdateFile(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		writeError(w, http.StatusBadRequest, "file id is required")
+		writeError(w, r, service.NewError(service.KindValidation, "file id is required"))
+		return
+	}
+
+	var req updateFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+	if req.Metadata == nil {
+		writeError(w, r, service.NewError(service.KindValidation, "metadata is required"))
 		return
 	}
 
-	file, err := h.service.GetFile(r.Context(), id)
+	file, err := h.service.UpdateMetadata(r.Context(), id, dlmiddleware.GetOwnerID(r.Context()), req.Metadata)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "file not found")
+		writeError(w, r, err)
 		return
 	}
 
@@ -250,32 +269,134 @@ func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 // DeleteFile 软删除指定文件。
 func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	if h == nil {
-		writeError(w, http.StatusInternalServerError, "handler not initialized")
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		writeError(w, http.StatusBadRequest, "file id is required")
+		writeError(w, r, service.NewError(service.KindValidation, "file id is required"))
 		return
 	}
 
-	if err := h.service.DeleteFile(r.Context(), id); err != nil {
-		writeError(w, http.StatusNotFound, "file not found")
+	if err := h.service.DeleteOwnedFile(r.Context(), id, dlmiddleware.GetOwnerID(r.Context())); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, envelope{Data: map[string]any{"id": id, "deleted": true}})
 }
 
+// maxBatchBodyBytes 限制批量请求体大小，足以容纳 service.MaxBatchItems 个操作。
+const maxBatchBodyBytes int64 = 1 << 20
+
+type batchRequest struct {
+	Atomic     bool                     `json:"atomic"`
+	Operations []service.BatchOperation `json:"operations"`
+}
+
+// BatchFiles 在一次请求中对多个文件执行删除、恢复、打标签、更新 metadata 与设置过期时间，返回逐项结果。
+func (h *FileHandler) BatchFiles(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+
+	for _, op := range req.Operations {
+		if op.Op == service.BatchOpDelete && !dlmiddleware.HasScope(r.Context(), auth.ScopeFilesDelete) {
+			writeError(w, r, service.NewError(service.KindForbidden, "missing required scope "+auth.ScopeFilesDelete))
+			return
+		}
+	}
+
+	result, err := h.service.ApplyBatch(r.Context(), dlmiddleware.GetOwnerID(r.Context()), req.Operations, req.Atomic)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	for _, item := range result.Results {
+		if item.Op != service.BatchOpDelete || item.Status == service.BatchItemSkipped {
+			continue
+		}
+		outcome := auth.AuditSuccess
+		if item.Status != service.BatchItemOK {
+			outcome = auth.AuditFailure
+		}
+		dlmiddleware.AddAuditEvent(r.Context(), auth.AuditFileDelete, item.ID, outcome)
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: result})
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, errorEnvelope{Error: message})
+// multipartUpload 是 multipart 请求中 file 字段的内容与基本信息。
+type multipartUpload struct {
+	file     multipart.File
+	name     string
+	mimeType string
+	size     int64
+}
+
+// readMultipartUpload 解析 multipart 请求并取出 file 字段，成功时调用方须在处理完成后调用 cleanup 释放临时文件。
+func readMultipartUpload(w http.ResponseWriter, r *http.Request, maxUploadSize int64) (*multipartUpload, func(), error) {
+	if r.Body == nil {
+		return nil, nil, service.NewError(service.KindValidation, "request body is empty")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+multipartMemoryBudget)
+
+	if err := r.ParseMultipartForm(multipartMemoryBudget); err != nil {
+		return nil, nil, service.NewError(service.KindValidation, fmt.Sprintf("invalid multipart form: %v", err))
+	}
+	removeForm := func() {
+		if r.MultipartForm != nil {
+			_ = r.MultipartForm.RemoveAll()
+		}
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		removeForm()
+		return nil, nil, service.NewError(service.KindValidation, "file field is required")
+	}
+	cleanup := func() {
+		_ = file.Close()
+		removeForm()
+	}
+	fail := func(err error) (*multipartUpload, func(), error) {
+		cleanup()
+		return nil, nil, err
+	}
+
+	sizeBytes, err := determineFileSize(file, header)
+	if err != nil {
+		return fail(service.NewError(service.KindValidation, err.Error()))
+	}
+	if sizeBytes <= 0 {
+		return fail(service.NewError(service.KindValidation, "file must not be empty"))
+	}
+	if sizeBytes > maxUploadSize {
+		return fail(service.NewError(service.KindPayloadTooLarge, fmt.Sprintf("file exceeds size limit (%d bytes)", maxUploadSize)))
+	}
+
+	mimeType, err := resolveMimeType(header, file)
+	if err != nil {
+		return fail(service.NewError(service.KindValidation, err.Error()))
+	}
+	if err := rewindFile(file); err != nil {
+		return fail(service.WrapError(service.KindInternal, "unable to read uploaded file", err))
+	}
+	return &multipartUpload{file: file, name: header.Filename, mimeType: mimeType, size: sizeBytes}, cleanup, nil
 }
 
 func determineFileSize(file multipart.File, header *multipart.FileHeader) (int64, error) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -11,14 +12,22 @@ import (
 	"testing"
 	"time"
 
+	"droplite/internal/config"
 	"droplite/internal/repository"
 	"droplite/internal/service"
 	"droplite/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type handlerRepo struct {
 	createRecord *repository.FileRecord
+	getResult    *repository.FileRecord
 	listResult   []repository.FileRecord
+	listParams   repository.ListFilesParams
+	updateErr    error
 }
 
 func (m *handlerRepo) Create(ctx context.Context, record *repository.FileRecord) (*repository.FileRecord, error) {
@@ -27,15 +36,35 @@ func (m *handlerRepo) Create(ctx context.Context, record *repository.FileRecord)
 }
 
 func (m *handlerRepo) GetByID(ctx context.Context, id string) (*repository.FileRecord, error) {
+	if m.getResult != nil && m.getResult.ID == id {
+		return m.getResult, nil
+	}
 	return nil, repository.ErrNotFound
 }
 
 func (m *handlerRepo) List(ctx context.Context, params repository.ListFilesParams) ([]repository.FileRecord, error) {
+	m.listParams = params
 	return m.listResult, nil
 }
 
 func (m *handlerRepo) UpdateStatus(ctx context.Context, id string, status repository.FileStatus) error {
-	return nil
+	return m.updateErr
+}
+
+func (m *handlerRepo) UpdateMetadata(ctx context.Context, id string, metadata map[string]any) (*repository.FileRecord, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerRepo) Rename(ctx context.Context, id, name string) (*repository.FileRecord, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerRepo) UpdateExpiry(ctx context.Context, id string, expiresAt *time.Time) (*repository.FileRecord, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerRepo) WithTx(ctx context.Context, fn func(repo repository.FileRepository) error) error {
+	return fn(m)
 }
 
 type handlerWriter struct {
@@ -62,9 +91,7 @@ func TestFileHandler_CreateFile(t *testing.T) {
 		"metadata": `{"env":"test"}`,
 		"checksum": "abc123",
 	}, "file", "hello.txt", []byte("hello world"))
-	rec := httptest.NewRecorder()
-
-	handler.CreateFile(rec, req)
+	rec := serveValidated(t, http.HandlerFunc(handler.CreateFile), req)
 
 	if rec.Code != http.StatusCreated {
 		t.Fatalf("expected status 201, got %d", rec.Code)
@@ -91,6 +118,10 @@ func TestFileHandler_ListFiles(t *testing.T) {
 		listResult: []repository.FileRecord{{
 			ID:           "1",
 			OriginalName: "a",
+			MimeType:     "text/plain",
+			SizeBytes:    1,
+			StoragePath:  "uploads/a",
+			Status:       repository.FileStatusStored,
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 		}},
@@ -99,9 +130,7 @@ func TestFileHandler_ListFiles(t *testing.T) {
 	handler := NewFileHandler(svc, 1024*1024*100)
 
 	req := httptest.NewRequest(http.MethodGet, "/files?limit=1", nil)
-	rec := httptest.NewRecorder()
-
-	handler.ListFiles(rec, req)
+	rec := serveValidated(t, http.HandlerFunc(handler.ListFiles), req)
 
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rec.Code)
@@ -119,6 +148,46 @@ func TestFileHandler_ListFiles(t *testing.T) {
 	}
 }
 
+func TestFileHandler_ErrorsCarryCodeAndRequestID(t *testing.T) {
+	repo := &handlerRepo{getResult: &repository.FileRecord{ID: "abc"}, updateErr: errors.New("connection refused")}
+	handler := NewFileHandler(service.NewFileService(repo, nil), 1024)
+
+	router := chi.NewRouter()
+	router.Use(chimiddleware.RequestID)
+	handler.RegisterRoutes(router)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"missing file", http.MethodGet, "/files/missing", http.StatusNotFound, "not_found"},
+		{"database outage on delete", http.MethodDelete, "/files/abc", http.StatusServiceUnavailable, "database_unavailable"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveValidated(t, router, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			var resp errorEnvelope
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Error.Code != tc.code {
+				t.Fatalf("expected code %s, got %+v", tc.code, resp.Error)
+			}
+			if resp.Error.RequestID == "" {
+				t.Fatal("expected request_id in error body")
+			}
+		})
+	}
+}
+
 func newMultipartRequest(t *testing.T, fields map[string]string, fieldName, filename string, content []byte) *http.Request {
 	t.Helper()
 
@@ -147,3 +216,226 @@ func newMultipartRequest(t *testing.T, fields map[string]string, fieldName, file
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	return req
 }
+
+func TestFileHandler_BatchFiles(t *testing.T) {
+	handler := NewFileHandler(service.NewFileService(&handlerRepo{}, nil), 1024*1024)
+
+	body := `{"operations":[{"op":"delete","ids":["a","b"]},{"op":"tag","ids":["a"],"tags":["archive"]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/files/batch", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := serveValidated(t, http.HandlerFunc(handler.BatchFiles), req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data service.BatchResult `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Data.Failed != 3 || len(resp.Data.Results) != 3 {
+		t.Fatalf("expected three failed items, got %+v", resp.Data)
+	}
+	if item := resp.Data.Results[2]; item.Op != service.BatchOpTag || item.Error == nil || item.Error.Code != service.KindNotFound {
+		t.Fatalf("unexpected item result %+v", item)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/files/batch", bytes.NewReader([]byte(`{"operations":[{"op":"purge","ids":["a"]}]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.BatchFiles(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown op, got %d", rec.Code)
+	}
+}
+
+func TestFileRoutes_EnforceScopes(t *testing.T) {
+	const secret = "test-jwt-secret"
+	router := NewRouter(&config.Config{AuthEnabled: true, AuthProvider: "supabase", SupabaseJWTSecret: secret}, Handlers{
+		Files: NewFileHandler(service.NewFileService(&handlerRepo{getResult: &repository.FileRecord{ID: "abc", OwnerID: "user-1"}}, nil), 1024),
+	})
+	bearer := func(claims jwt.MapClaims) string {
+		t.Helper()
+		claims["sub"] = "user-1"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	send := func(method, path, authorization, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		return serveValidated(t, router, req).Code
+	}
+
+	viewer := bearer(jwt.MapClaims{"app_metadata": map[string]any{"roles": []string{"viewer"}}})
+	if code := send(http.MethodGet, "/files", viewer, ""); code != http.StatusOK {
+		t.Fatalf("expected viewer to list files, got %d", code)
+	}
+	if code := send(http.MethodDelete, "/files/abc", viewer, ""); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for viewer delete, got %d", code)
+	}
+	if code := send(http.MethodPatch, "/files/abc", viewer, `{"metadata":{}}`); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for viewer update, got %d", code)
+	}
+
+	// 没有声明角色或 scope 的 token 保持原有的完整文件权限
+	if code := send(http.MethodDelete, "/files/abc", bearer(jwt.MapClaims{}), ""); code != http.StatusOK {
+		t.Fatalf("expected default scopes to allow delete, got %d", code)
+	}
+
+	// 显式的 scope 声明优先于角色
+	writer := bearer(jwt.MapClaims{
+		"scope":        "files:read files:write",
+		"app_metadata": map[string]any{"role": "admin"},
+	})
+	if code := send(http.MethodPost, "/files/batch", writer, `{"operations":[{"op":"tag","ids":["a"],"tags":["x"]}]}`); code != http.StatusOK {
+		t.Fatalf("expected batch tag to be allowed, got %d", code)
+	}
+	if code := send(http.MethodPost, "/files/batch", writer, `{"operations":[{"op":"delete","ids":["a"]}]}`); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for batch delete without files:delete, got %d", code)
+	}
+}
+
+func TestFileRoutes_ScopedToOwner(t *testing.T) {
+	repo := &handlerRepo{getResult: &repository.FileRecord{
+		ID:           "abc",
+		OwnerID:      "key-a",
+		OriginalName: "a.txt",
+		MimeType:     "text/plain",
+		StoragePath:  "uploads/a.txt",
+		SizeBytes:    12,
+		Status:       repository.FileStatusStored,
+	}}
+	router := NewRouter(&config.Config{AuthEnabled: true, APIKeys: []string{"key-a", "key-b"}}, Handlers{
+		Files: NewFileHandler(service.NewFileService(repo, &handlerWriter{}), 1024),
+	})
+	send := func(method, path, key string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "ApiKey "+key)
+		return serveValidated(t, router, req).Code
+	}
+
+	// 其他 owner 的文件与不存在的文件一样返回 404
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/files/abc"},
+		{http.MethodGet, "/files/abc/download"},
+		{http.MethodDelete, "/files/abc"},
+	} {
+		if code := send(tc.method, tc.path, "key-b"); code != http.StatusNotFound {
+			t.Fatalf("%s %s by another owner: expected 404, got %d", tc.method, tc.path, code)
+		}
+	}
+	if code := send(http.MethodGet, "/files/abc", "key-a"); code != http.StatusOK {
+		t.Fatalf("expected owner to read file, got %d", code)
+	}
+
+	if code := send(http.MethodGet, "/files", "key-b"); code != http.StatusOK {
+		t.Fatalf("list: got %d", code)
+	}
+	if repo.listParams.OwnerID != "key-b" {
+		t.Fatalf("expected list to be scoped to key-b, got %q", repo.listParams.OwnerID)
+	}
+}
+
+func TestFileRoutes_RateLimitedPerOwner(t *testing.T) {
+	router := NewRouter(&config.Config{
+		AuthEnabled:       true,
+		APIKeys:           []string{"key-a", "key-b"},
+		RateLimitRequests: 2,
+		RateLimitWindow:   time.Minute,
+		RateLimitRoutes:   map[string]config.RateLimitRule{"DELETE /files/{id}": {Requests: 1, Window: time.Minute}},
+		RateLimitKeys:     map[string]config.RateLimitRule{"owner:key-b": {Requests: 5, Window: time.Minute}},
+	}, Handlers{
+		Files: NewFileHandler(service.NewFileService(&handlerRepo{getResult: &repository.FileRecord{ID: "abc", OwnerID: "key-a"}}, nil), 1024),
+	})
+	send := func(method, path, key, forwardedFor string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "ApiKey "+key)
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return serveValidated(t, router, req)
+	}
+
+	rec := send(http.MethodGet, "/files", "key-a", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("unexpected first response %d %v", rec.Code, rec.Header())
+	}
+	if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Fatalf("RateLimit-Policy = %q", got)
+	}
+	// 伪造的 X-Forwarded-For 不会让同一个 owner 获得新的额度
+	send(http.MethodGet, "/files", "key-a", "198.51.100.1")
+	rec = send(http.MethodGet, "/files", "key-a", "198.51.100.2")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+
+	// 路由规则使用独立的令牌桶
+	if rec := send(http.MethodDelete, "/files/abc", "key-a", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("expected delete to use its own bucket, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := send(http.MethodDelete, "/files/abc", "key-a", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second delete to be limited, got %d", rec.Code)
+	}
+
+	// 另一个 owner 有自己的额度，且按键覆盖了默认限额
+	for i := 0; i < 5; i++ {
+		if rec := send(http.MethodGet, "/files", "key-b", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d for key-b: got %d", i, rec.Code)
+		}
+	}
+	if rec := send(http.MethodGet, "/files", "key-b", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected key-b to be limited after 5 requests, got %d", rec.Code)
+	}
+}
+
+func TestRouter_LimitsAuthFailuresPerIP(t *testing.T) {
+	router := NewRouter(&config.Config{
+		AuthEnabled:      true,
+		APIKeys:          []string{"key-a"},
+		AdminAPIKeys:     []string{"admin-key"},
+		AuthFailureLimit: config.RateLimitRule{Requests: 3, Window: time.Minute},
+	}, Handlers{
+		Files:   NewFileHandler(service.NewFileService(&handlerRepo{}, nil), 1024),
+		Schemas: NewSchemaHandler(service.NewSchemaService(nil)),
+	})
+	send := func(path, key, remoteAddr string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "ApiKey "+key)
+		req.RemoteAddr = remoteAddr
+		return serveValidated(t, router, req)
+	}
+
+	// 成功的请求不消耗额度
+	for i := 0; i < 5; i++ {
+		if rec := send("/files", "key-a", "198.51.100.1:1000"); rec.Code != http.StatusOK {
+			t.Fatalf("valid request %d: got %d", i, rec.Code)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if rec := send("/files", "guess", "198.51.100.1:1000"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("bad credential %d: expected 401, got %d", i, rec.Code)
+		}
+	}
+	// 额度用尽后在校验凭证前拒绝，管理端点共用同一份额度
+	rec := send("/files", "guess", "198.51.100.1:1000")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After after repeated failures, got %d", rec.Code)
+	}
+	if rec := send("/admin/metadata-schemas", "guess", "198.51.100.1:1000"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected admin endpoints to share the failure budget, got %d", rec.Code)
+	}
+	if rec := send("/files", "guess", "203.0.113.9:1000"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected other IPs to keep their own budget, got %d", rec.Code)
+	}
+}
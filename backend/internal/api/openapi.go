@@ -0,0 +1,22 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec 是对外发布的 OpenAPI 3 文档，新增或修改路由时需同步更新。
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec 返回内嵌的 OpenAPI 文档原文。
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// ServeOpenAPI 输出 OpenAPI 文档。
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
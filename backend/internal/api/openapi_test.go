@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"droplite/internal/config"
+	"droplite/internal/service"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/go-chi/chi/v5"
+)
+
+// 不在 OpenAPI 文档中描述的路由（WebDAV 不是 REST 接口）。
+var undocumentedRoutes = map[string]bool{
+	"/metrics": true,
+	"/dav":     true,
+	"/dav/*":   true,
+}
+
+func loadSpecRouter(t *testing.T) (*openapi3.T, routers.Router) {
+	t.Helper()
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(OpenAPISpec())
+	if err != nil {
+		t.Fatalf("load openapi spec: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("openapi spec is invalid: %v", err)
+	}
+	// 校验时忽略 servers，使任意 host 的测试请求都能匹配
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("build spec router: %v", err)
+	}
+	return doc, router
+}
+
+// serveValidated 依据 OpenAPI 文档校验请求，调用 handler 后再校验响应。
+func serveValidated(t *testing.T, handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	_, specRouter := loadSpecRouter(t)
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("read request body: %v", err)
+		}
+	}
+
+	route, pathParams, err := specRouter.FindRoute(req)
+	if err != nil {
+		t.Fatalf("route %s %s not documented: %v", req.Method, req.URL.Path, err)
+	}
+
+	reqInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err := openapi3filter.ValidateRequest(req.Context(), reqInput); err != nil {
+		t.Fatalf("request does not match spec: %v", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	respInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: reqInput,
+		Status:                 rec.Code,
+		Header:                 rec.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	}
+	if err := openapi3filter.ValidateResponse(req.Context(), respInput); err != nil {
+		t.Fatalf("response does not match spec: %v\nbody: %s", err, rec.Body.String())
+	}
+	return rec
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	doc, _ := loadSpecRouter(t)
+
+	cfg := &config.Config{AuthEnabled: false}
+	router := NewRouter(cfg, Handlers{
+		Files:    NewFileHandler(service.NewFileService(&handlerRepo{}, nil), 1024),
+		Shares:   NewShareHandler(nil, nil, ""),
+		Events:   NewEventsHandler(service.NewEventStream(nil)),
+		Webhooks: NewWebhookHandler(service.NewWebhookService(nil)),
+		Schemas:  NewSchemaHandler(service.NewSchemaService(nil)),
+		APIKeys:  NewAPIKeyHandler(service.NewAPIKeyService(nil, nil)),
+		Audit:    NewAuditHandler(service.NewAuditService(nil)),
+		Requests: NewFileRequestHandler(service.NewFileRequests(nil, nil, 1024), 1024, ""),
+		DAV:      http.NotFoundHandler(),
+	})
+
+	routes, ok := router.(chi.Routes)
+	if !ok {
+		t.Fatal("router does not expose chi routes")
+	}
+
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if undocumentedRoutes[route] {
+			return nil
+		}
+		item := doc.Paths.Find(route)
+		if item == nil || item.GetOperation(method) == nil {
+			t.Errorf("route %s %s is missing from openapi.json", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+}
+
+func TestOpenAPI_ServedAndHealthzMatchesSpec(t *testing.T) {
+	router := NewRouter(&config.Config{}, Handlers{})
+
+	rec := serveValidated(t, router, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if !bytes.Equal(rec.Body.Bytes(), OpenAPISpec()) {
+		t.Fatal("served document differs from embedded spec")
+	}
+
+	serveValidated(t, router, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+}
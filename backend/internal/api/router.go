@@ -3,7 +3,9 @@ package api
 import (
 	"net/http"
 
+	"droplite/internal/auth"
 	"droplite/internal/config"
+	"droplite/internal/dav"
 	dlmiddleware "droplite/internal/middleware"
 
 	"github.com/go-chi/chi/v5"
@@ -11,16 +13,68 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+func init() {
+	// chi 默认只识别标准方法，WebDAV 扩展方法需在注册路由前声明，否则会被直接返回 405
+	for _, method := range dav.Methods {
+		chi.RegisterMethod(method)
+	}
+}
+
+// Handlers 汇总需要挂载到路由上的各个 handler，为 nil 的字段不会注册对应端点。
+type Handlers struct {
+	Files    *FileHandler
+	Shares   *ShareHandler
+	Requests *FileRequestHandler
+	Events   *EventsHandler
+	Webhooks *WebhookHandler
+	Schemas  *SchemaHandler
+	APIKeys  *APIKeyHandler
+	Audit    *AuditHandler
+	DAV      http.Handler
+	// RateLimiter 为 nil 时使用进程内限流，多副本部署时应传入共享实现。
+	RateLimiter auth.RateLimiter
+	// Nonces 记录请求签名已使用的 nonce，为 nil 时使用进程内记录，多副本部署时应传入共享实现。
+	Nonces dlmiddleware.NonceStore
+}
+
 // NewRouter 构建 HTTP 路由，集中注册所有对外服务的端点。
-func NewRouter(cfg *config.Config, fileHandler *FileHandler) http.Handler {
+func NewRouter(cfg *config.Config, handlers Handlers) http.Handler {
 	r := chi.NewRouter()
 
+	// 配置了 API Key 管理时，除 API_KEYS 外也接受数据库签发的 Key（包括用它签名的请求）
+	var keys dlmiddleware.APIKeyVerifier
+	if handlers.APIKeys != nil && handlers.APIKeys.service != nil {
+		keys = handlers.APIKeys.service
+	}
+	var authenticator dlmiddleware.Authenticator
+	if cfg.AuthEnabled {
+		var err error
+		if authenticator, err = dlmiddleware.NewAuthenticator(cfg, keys, handlers.Nonces); err != nil {
+			panic(err)
+		}
+	}
+
+	// 限流挂在各路由组内的鉴权之后，才能按 owner 计数
+	limiter := handlers.RateLimiter
+	if limiter == nil {
+		limiter = dlmiddleware.NewMemoryRateLimiter()
+	}
+	limit := dlmiddleware.RateLimit(limiter, dlmiddleware.NewRateLimitPolicy(cfg))
+	// 鉴权失败在鉴权之前按 IP 计数，防止猜测凭证和刷写鉴权失败的审计记录
+	limitAuthFailures := dlmiddleware.LimitAuthFailures(limiter, cfg.AuthFailureLimit)
+
+	// 配置了审计日志时记录文件操作与鉴权失败
+	var audit auth.AuditRecorder
+	if handlers.Audit != nil && handlers.Audit.service != nil {
+		audit = handlers.Audit.service
+	}
+
 	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
+	r.Use(dlmiddleware.RealIP(cfg.TrustedProxies))
+	r.Use(dlmiddleware.AuditTrail(audit))
 	r.Use(chimiddleware.Logger)
 	r.Use(chimiddleware.Recoverer)
 	r.Use(dlmiddleware.CORS(cfg.CORSAllowedOrigins))
-	r.Use(dlmiddleware.RateLimit(cfg.RateLimitRequests, cfg.RateLimitWindow))
 	r.Use(dlmiddleware.Metrics())
 
 	// 健康检查不需要鉴权
@@ -29,29 +83,101 @@ func NewRouter(cfg *config.Config, fileHandler *FileHandler) http.Handler {
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	})
 
+	// OpenAPI 文档，供前端与 SDK 生成类型
+	r.Get("/openapi.json", ServeOpenAPI)
+
 	// Prometheus 指标端点
 	r.Handle("/metrics", promhttp.Handler())
 
-	if fileHandler != nil {
+	// 分享链接与文件请求的令牌本身即凭证，不经过鉴权
+	if handlers.Shares != nil || handlers.Requests != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(limit)
+			if handlers.Shares != nil {
+				handlers.Shares.RegisterPublicRoutes(r)
+			}
+			if handlers.Requests != nil {
+				handlers.Requests.RegisterPublicRoutes(r)
+			}
+		})
+	}
+
+	// 面向 owner 的业务端点，开启鉴权时统一要求认证
+	r.Group(func(r chi.Router) {
 		if cfg.AuthEnabled {
-			// 需要鉴权的路由组
-			r.Group(func(r chi.Router) {
-				if cfg.AuthProvider == "supabase" {
-					if cfg.SupabaseJWTSecret == "" && cfg.SupabaseURL == "" {
-						panic("Supabase JWT Secret OR Supabase URL is required")
-					}
-					r.Use(dlmiddleware.SupabaseAuth(cfg.SupabaseURL, cfg.SupabaseAnonKey, cfg.SupabaseJWTSecret))
-				} else {
-					// 默认使用 API Key
-					r.Use(dlmiddleware.APIKeyAuth(cfg.APIKeys))
-				}
-				fileHandler.RegisterRoutes(r)
-			})
-		} else {
-			// 无需鉴权（开发模式）
-			fileHandler.RegisterRoutes(r)
+			r.Use(limitAuthFailures)
+			r.Use(dlmiddleware.RequireAuth(authenticator))
+		}
+		r.Use(limit)
+		if handlers.Files != nil {
+			handlers.Files.RegisterRoutes(r)
+		}
+		if handlers.Shares != nil {
+			handlers.Shares.RegisterRoutes(r)
+		}
+		if handlers.Requests != nil {
+			handlers.Requests.RegisterRoutes(r)
+		}
+		if handlers.Events != nil {
+			handlers.Events.RegisterRoutes(r)
+		}
+		if handlers.Webhooks != nil {
+			handlers.Webhooks.RegisterRoutes(r)
 		}
+	})
+
+	if handlers.DAV != nil {
+		// WebDAV 客户端只支持 Basic 认证，密码即 API Key
+		r.Route("/dav", func(r chi.Router) {
+			if cfg.AuthEnabled {
+				r.Use(limitAuthFailures)
+				r.Use(dlmiddleware.BasicAuth(dlmiddleware.NewStoredAPIKeyAuthenticator(cfg.APIKeys, keys), "DropLite WebDAV"))
+			}
+			r.Use(dav.AuditMethods)
+			if cfg.AuthEnabled {
+				r.Use(dlmiddleware.RequireMethodScopes(davScopes))
+			}
+			r.Use(limit)
+			r.Handle("/", handlers.DAV)
+			r.Handle("/*", handlers.DAV)
+		})
+	}
+
+	if handlers.Schemas != nil || handlers.APIKeys != nil || handlers.Audit != nil {
+		// 管理端点统一挂载在 /admin 下，使用独立的管理员 API Key
+		r.Route("/admin", func(r chi.Router) {
+			if cfg.AuthEnabled {
+				// 除 ADMIN_API_KEYS 外，带 admin scope 的 API Key 或 JWT 也可以访问
+				r.Use(limitAuthFailures)
+				r.Use(dlmiddleware.RequireAuth(dlmiddleware.NewAdminAuthenticator(cfg.AdminAPIKeys, authenticator)))
+				r.Use(dlmiddleware.RequireScope(auth.ScopeAdmin))
+			}
+			r.Use(limit)
+			if handlers.Schemas != nil {
+				handlers.Schemas.RegisterRoutes(r)
+			}
+			if handlers.APIKeys != nil {
+				handlers.APIKeys.RegisterRoutes(r)
+			}
+			if handlers.Audit != nil {
+				handlers.Audit.RegisterRoutes(r)
+			}
+		})
 	}
 
 	return r
 }
+
+// davScopes 返回 WebDAV 方法需要的 scope：MOVE 会删除源文件，因此同时需要 files:write 与 files:delete。
+func davScopes(method string) []string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return []string{auth.ScopeFilesRead}
+	case http.MethodDelete:
+		return []string{auth.ScopeFilesDelete}
+	case "MOVE":
+		return []string{auth.ScopeFilesWrite, auth.ScopeFilesDelete}
+	default:
+		return []string{auth.ScopeFilesWrite}
+	}
+}
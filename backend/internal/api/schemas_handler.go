@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SchemaHandler 提供元数据 Schema 的管理端点。
+type SchemaHandler struct {
+	service *service.SchemaService
+}
+
+func NewSchemaHandler(s *service.SchemaService) *SchemaHandler {
+	return &SchemaHandler{service: s}
+}
+
+func (h *SchemaHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/metadata-schemas", func(r chi.Router) {
+		r.Get("/", h.ListSchemas)
+		r.Post("/", h.PutSchema)
+		r.Delete("/{id}", h.DeleteSchema)
+	})
+}
+
+type putSchemaRequest struct {
+	Scope       repository.SchemaScope `json:"scope"`
+	ScopeValue  string                 `json:"scope_value"`
+	Schema      json.RawMessage        `json:"schema"`
+	Description string                 `json:"description"`
+}
+
+// ListSchemas 返回全部已登记的 Schema。
+func (h *SchemaHandler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	schemas, err := h.service.ListSchemas(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: schemas})
+}
+
+// PutSchema 创建或替换某个维度上的 Schema。
+func (h *SchemaHandler) PutSchema(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	var req putSchemaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+
+	schema, err := h.service.RegisterSchema(r.Context(), service.RegisterSchemaInput{
+		Scope:       req.Scope,
+		ScopeValue:  req.ScopeValue,
+		Schema:      req.Schema,
+		Description: req.Description,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: schema})
+}
+
+// DeleteSchema 删除指定 Schema。
+func (h *SchemaHandler) DeleteSchema(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := h.service.DeleteSchema(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: map[string]any{"id": id, "deleted": true}})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ShareHandler 签发分享链接，并为持有链接的匿名访问者提供下载。
+type ShareHandler struct {
+	files     *service.FileService
+	links     *service.ShareLinks
+	baseURL   string
+	transfers *dlmiddleware.TransferLimiter
+}
+
+// NewShareHandler 创建分享链接 handler，baseURL 为空时按请求的 Host 生成链接。
+func NewShareHandler(files *service.FileService, links *service.ShareLinks, baseURL string) *ShareHandler {
+	return &ShareHandler{files: files, links: links, baseURL: baseURL}
+}
+
+// SetTransferLimiter 设置下载限速，须在注册路由之前调用；匿名下载按客户端 IP 计算。
+func (h *ShareHandler) SetTransferLimiter(l *dlmiddleware.TransferLimiter) {
+	h.transfers = l
+}
+
+// RegisterRoutes 注册需要鉴权的签发端点，分享链接等同于下载权限，要求 files:read。
+func (h *ShareHandler) RegisterRoutes(r chi.Router) {
+	r.With(dlmiddleware.AuditAction(auth.AuditFileShare), dlmiddleware.RequireScope(auth.ScopeFilesRead)).Post("/files/{id}/share", h.CreateShare)
+}
+
+// RegisterPublicRoutes 注册无需鉴权的下载端点，令牌本身即凭证。
+func (h *ShareHandler) RegisterPublicRoutes(r chi.Router) {
+	r.With(dlmiddleware.AuditAction(auth.AuditShareDownload), h.transfers.ShapeDownloads()).Get("/s/{token}", h.OpenShare)
+}
+
+type createShareRequest struct {
+	ExpiresIn int64 `json:"expires_in"` // 秒，为 0 时使用默认有效期
+	// AllowedCIDRs 与 DeniedCIDRs 限制链接可以从哪些网络打开
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	DeniedCIDRs  []string `json:"denied_cidrs"`
+}
+
+// CreateShare 为 owner 的文件签发限时下载链接。
+func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeError(w, r, service.NewError(service.KindValidation, "file id is required"))
+		return
+	}
+
+	// 请求体可省略
+	var req createShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+	if req.ExpiresIn < 0 {
+		writeError(w, r, service.NewError(service.KindValidation, "expires_in must not be negative"))
+		return
+	}
+
+	link, err := h.links.Create(r.Context(), dlmiddleware.GetOwnerID(r.Context()), id, time.Duration(req.ExpiresIn)*time.Second, service.ShareNetworks{
+		AllowedCIDRs: req.AllowedCIDRs,
+		DeniedCIDRs:  req.DeniedCIDRs,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	link.URL = publicURL(r, h.baseURL) + "/s/" + url.PathEscape(link.Token)
+
+	writeJSON(w, http.StatusCreated, envelope{Data: link})
+}
+
+// OpenShare 校验分享令牌并返回文件内容，客户端地址取 RealIP 处理后的 RemoteAddr。
+func (h *ShareHandler) OpenShare(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	file, err := h.links.Resolve(r.Context(), chi.URLParam(r, "token"), dlmiddleware.ClientAddr(r), time.Now())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	dlmiddleware.SetAuditFileID(r.Context(), file.ID)
+
+	writeFileContent(w, r, h.files, file)
+}
+
+// publicURL 返回对外地址，baseURL 为空时按请求的 Host 与协议推导。
+func publicURL(r *http.Request, baseURL string) string {
+	if baseURL != "" {
+		return baseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
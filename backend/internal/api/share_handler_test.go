@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestShareHandler_CreateAndOpen(t *testing.T) {
+	repo := &handlerRepo{getResult: &repository.FileRecord{
+		ID:           "file-1",
+		OwnerID:      "owner-a",
+		OriginalName: "report.txt",
+		MimeType:     "text/plain",
+		SizeBytes:    int64(len("mock content")),
+		StoragePath:  "uploads/file-1",
+		Status:       repository.FileStatusStored,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}}
+	files := service.NewFileService(repo, &handlerWriter{})
+	handler := NewShareHandler(files, service.NewShareLinks(files, "secret"), "https://files.example.com")
+
+	router := chi.NewRouter()
+	router.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(dlmiddleware.WithOwnerID(r.Context(), r.Header.Get("X-Owner"))))
+			})
+		})
+		handler.RegisterRoutes(r)
+	})
+	handler.RegisterPublicRoutes(router)
+
+	req := httptest.NewRequest(http.MethodPost, "/files/file-1/share", bytes.NewReader([]byte(`{"expires_in":3600}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", "owner-a")
+	rec := serveValidated(t, router, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Data service.ShareLink `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	link, err := url.Parse(created.Data.URL)
+	if err != nil || link.Host != "files.example.com" {
+		t.Fatalf("unexpected share url %q", created.Data.URL)
+	}
+
+	rec = serveValidated(t, router, httptest.NewRequest(http.MethodGet, link.Path, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "mock content" {
+		t.Fatalf("expected shared content, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/files/file-1/share", nil)
+	req.Header.Set("X-Owner", "owner-b")
+	rec = serveValidated(t, router, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when sharing another owner's file, got %d", rec.Code)
+	}
+
+	// 限制网络的链接从其他地址打开返回 403（httptest 请求的对端地址为 192.0.2.1）
+	req = httptest.NewRequest(http.MethodPost, "/files/file-1/share", bytes.NewReader([]byte(`{"allowed_cidrs":["10.0.0.0/8"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", "owner-a")
+	rec = serveValidated(t, router, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	link, _ = url.Parse(created.Data.URL)
+	rec = serveValidated(t, router, httptest.NewRequest(http.MethodGet, link.Path, nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 outside allowed networks, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
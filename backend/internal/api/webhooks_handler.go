@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WebhookHandler 提供 owner 管理 webhook 与查看投递日志的端点。
+type WebhookHandler struct {
+	service *service.WebhookService
+}
+
+func NewWebhookHandler(s *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: s}
+}
+
+// RegisterRoutes 注册 webhook 端点：查看需要 files:read，登记、删除与重放需要 files:write。
+func (h *WebhookHandler) RegisterRoutes(r chi.Router) {
+	read := dlmiddleware.RequireScope(auth.ScopeFilesRead)
+	write := dlmiddleware.RequireScope(auth.ScopeFilesWrite)
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.With(read).Get("/", h.ListWebhooks)
+		r.With(write).Post("/", h.CreateWebhook)
+		r.With(write).Delete("/{id}", h.DeleteWebhook)
+		r.With(read).Get("/{id}/deliveries", h.ListDeliveries)
+		r.With(write).Post("/{id}/deliveries/{deliveryID}/replay", h.ReplayDelivery)
+	})
+}
+
+type createWebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+}
+
+// CreateWebhook 登记 webhook，响应中包含仅返回一次的签名密钥。
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewError(service.KindValidation, "invalid request body: "+err.Error()))
+		return
+	}
+
+	webhook, err := h.service.RegisterWebhook(r.Context(), service.RegisterWebhookInput{
+		OwnerID:     dlmiddleware.GetOwnerID(r.Context()),
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, envelope{Data: webhook})
+}
+
+// ListWebhooks 返回调用方登记的 webhook。
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	webhooks, err := h.service.ListWebhooks(r.Context(), dlmiddleware.GetOwnerID(r.Context()))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if webhooks == nil {
+		webhooks = []repository.Webhook{}
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: webhooks})
+}
+
+// DeleteWebhook 删除 webhook 及其投递日志。
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := h.service.DeleteWebhook(r.Context(), dlmiddleware.GetOwnerID(r.Context()), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: map[string]any{"id": id, "deleted": true}})
+}
+
+// ListDeliveries 分页返回 webhook 的投递日志，可按 status 过滤。
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	params := repository.ListDeliveriesParams{
+		WebhookID: chi.URLParam(r, "id"),
+		Status:    repository.DeliveryStatus(r.URL.Query().Get("status")),
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		params.Limit = limit
+	}
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && offset > 0 {
+		params.Offset = offset
+	}
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), dlmiddleware.GetOwnerID(r.Context()), params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []repository.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: deliveries})
+}
+
+// ReplayDelivery 以原事件内容重新排队一次投递。
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		writeError(w, r, service.NewError(service.KindInternal, "handler not initialized"))
+		return
+	}
+
+	delivery, err := h.service.ReplayDelivery(
+		r.Context(),
+		dlmiddleware.GetOwnerID(r.Context()),
+		chi.URLParam(r, "id"),
+		chi.URLParam(r, "deliveryID"),
+	)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, envelope{Data: delivery})
+}
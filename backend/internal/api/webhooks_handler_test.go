@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/service"
+)
+
+type handlerWebhookRepo struct {
+	webhooks []repository.Webhook
+}
+
+func (m *handlerWebhookRepo) Create(ctx context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+	webhook.CreatedAt = time.Now().UTC()
+	webhook.UpdatedAt = webhook.CreatedAt
+	m.webhooks = append(m.webhooks, *webhook)
+	return webhook, nil
+}
+
+func (m *handlerWebhookRepo) GetByID(ctx context.Context, id string) (*repository.Webhook, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerWebhookRepo) ListByOwner(ctx context.Context, ownerID string) ([]repository.Webhook, error) {
+	return append([]repository.Webhook(nil), m.webhooks...), nil
+}
+
+func (m *handlerWebhookRepo) Delete(ctx context.Context, id string) error {
+	return repository.ErrNotFound
+}
+
+func (m *handlerWebhookRepo) ListSubscribers(ctx context.Context, ownerID, eventType string) ([]repository.Webhook, error) {
+	return nil, nil
+}
+
+func (m *handlerWebhookRepo) EnqueueDeliveries(ctx context.Context, deliveries []repository.WebhookDelivery) error {
+	return nil
+}
+
+func (m *handlerWebhookRepo) GetDelivery(ctx context.Context, id string) (*repository.WebhookDelivery, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (m *handlerWebhookRepo) ListDeliveries(ctx context.Context, params repository.ListDeliveriesParams) ([]repository.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *handlerWebhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *handlerWebhookRepo) UpdateDelivery(ctx context.Context, delivery *repository.WebhookDelivery, claim repository.DeliveryClaim) error {
+	return nil
+}
+
+func TestWebhookHandler_CreateAndList(t *testing.T) {
+	handler := NewWebhookHandler(service.NewWebhookService(&handlerWebhookRepo{}))
+
+	body, _ := json.Marshal(map[string]any{
+		"url":    "https://hooks.example.com/droplite",
+		"events": []string{"file.created", "file.expired"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := serveValidated(t, http.HandlerFunc(handler.CreateWebhook), req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Data repository.Webhook `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if created.Data.Secret == "" {
+		t.Fatal("expected secret in create response")
+	}
+
+	rec = serveValidated(t, http.HandlerFunc(handler.ListWebhooks), httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+	if rec.Code != http.StatusOK || bytes.Contains(rec.Body.Bytes(), []byte(created.Data.Secret)) {
+		t.Fatalf("expected listing without secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader([]byte(`{"url":"not a url","events":["file.created"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.CreateWebhook(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid url, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// 审计动作。
+const (
+	AuditFileCreate    = "file.create"
+	AuditFileDownload  = "file.download"
+	AuditFileDelete    = "file.delete"
+	AuditFileShare     = "file.share"
+	AuditShareDownload = "share.download"
+	AuditAuthFailure   = "auth.failure"
+	// AuditNetworkDenied 记录凭证有效但客户端地址不在其网络策略内的请求。
+	AuditNetworkDenied = "auth.network_denied"
+)
+
+// 审计结果：401/403 记为 denied，其余 4xx/5xx 记为 failure。
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied"
+	AuditFailure = "failure"
+)
+
+// AuditEvent 是一条待写入的审计记录。
+type AuditEvent struct {
+	OccurredAt time.Time
+	// Actor 是调用方（见 Principal.Actor），KeyID 是 API Key 的 ID；鉴权失败时两者为空。
+	Actor     string
+	KeyID     string
+	Action    string
+	FileID    string
+	IP        string
+	UserAgent string
+	RequestID string
+	Outcome   string
+	Status    int
+}
+
+// AuditRecorder 持久化审计记录，由 service.AuditService 实现。写入失败由实现方自行记录，不影响请求。
+type AuditRecorder interface {
+	RecordAudit(ctx context.Context, event AuditEvent)
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SigningKey 是请求签名的共享密钥及其对应的调用方。
+type SigningKey struct {
+	Secret    string
+	Principal Principal
+}
+
+// DeriveKeyCredentials 由 API Key 确定性地派生一组共享密钥凭证，S3 网关与请求签名共用：
+// Key ID 为 "DL" 加 SHA-256(key) 前 18 位十六进制（大写），
+// secret 为 HMAC-SHA256(key, "droplite-s3") 的十六进制。客户端持有 API Key 即可在本地算出，无需传输 Key 本身。
+func DeriveKeyCredentials(apiKey string) (keyID, secret string) {
+	sum := sha256.Sum256([]byte(apiKey))
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte("droplite-s3"))
+	return "DL" + strings.ToUpper(hex.EncodeToString(sum[:])[:18]), hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// NetworkPolicy 限制凭证可以从哪些客户端地址使用：命中 Deny 的地址一律拒绝；Allow 非空时只接受其中的地址。
+// 零值不做任何限制。
+type NetworkPolicy struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// ParseNetworkPolicy 解析 CIDR 列表，单个地址按 /32 或 /128 处理。
+func ParseNetworkPolicy(allow, deny []string) (NetworkPolicy, error) {
+	var (
+		policy NetworkPolicy
+		err    error
+	)
+	if policy.Allow, err = parseNetworks(allow); err != nil {
+		return NetworkPolicy{}, err
+	}
+	if policy.Deny, err = parseNetworks(deny); err != nil {
+		return NetworkPolicy{}, err
+	}
+	return policy, nil
+}
+
+func parseNetworks(raw []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range raw {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", item)
+			}
+			addr = addr.Unmap()
+			item = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// IsZero 判断策略是否不做任何限制。
+func (p NetworkPolicy) IsZero() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+// Permits 判断 addr 是否可以使用该凭证。有限制时无法解析的地址（无效的 netip.Addr）一律拒绝。
+func (p NetworkPolicy) Permits(addr netip.Addr) bool {
+	if p.IsZero() {
+		return true
+	}
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.Deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, prefix := range p.Allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkDeniedMessage 是客户端地址不被凭证接受时返回给客户端的错误信息。
+func NetworkDeniedMessage(addr netip.Addr) string {
+	return fmt.Sprintf("client IP %s is not allowed to use this credential", addr)
+}
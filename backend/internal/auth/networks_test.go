@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestNetworkPolicy_Permits(t *testing.T) {
+	policy, err := ParseNetworkPolicy([]string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.9.0.0/16", "10.1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"10.2.3.4":        true,
+		"::ffff:10.2.3.4": true,
+		"2001:db8::1":     true,
+		"10.9.1.1":        false,
+		"10.1.2.3":        false,
+		"192.0.2.1":       false,
+	}
+	for ip, want := range cases {
+		if got := policy.Permits(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("%s: expected %v, got %v", ip, want, got)
+		}
+	}
+	if policy.Permits(netip.Addr{}) {
+		t.Error("expected unknown address to be rejected when restricted")
+	}
+	if !(NetworkPolicy{}).Permits(netip.Addr{}) {
+		t.Error("expected zero policy to permit everything")
+	}
+
+	denyOnly, _ := ParseNetworkPolicy(nil, []string{"192.0.2.0/24"})
+	if denyOnly.Permits(netip.MustParseAddr("192.0.2.1")) || !denyOnly.Permits(netip.MustParseAddr("198.51.100.1")) {
+		t.Error("expected deny-only policy to reject only denied networks")
+	}
+	if _, err := ParseNetworkPolicy([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("expected invalid CIDR to be rejected")
+	}
+}
@@ -0,0 +1,50 @@
+// Package auth 定义鉴权相关的共享类型：调用方、权限范围、网络策略、审计记录与限流结果。
+// HTTP 中间件、gRPC、service 与数据访问层都依赖本包，本包不依赖任何传输层。
+package auth
+
+import "slices"
+
+// 凭证可携带的权限范围。
+const (
+	ScopeFilesRead   = "files:read"
+	ScopeFilesWrite  = "files:write"
+	ScopeFilesDelete = "files:delete"
+	ScopeAdmin       = "admin"
+)
+
+// Scopes 列出所有已知的权限范围。
+var Scopes = []string{ScopeFilesRead, ScopeFilesWrite, ScopeFilesDelete, ScopeAdmin}
+
+// DefaultScopes 是没有声明 scopes 的凭证获得的权限：owner 可以完整管理自己的文件，但不能访问管理端点。
+var DefaultScopes = []string{ScopeFilesRead, ScopeFilesWrite, ScopeFilesDelete}
+
+// 静态 Key 在审计记录中的固定调用方标识。静态 Key 以原值作为 owner ID，
+// 而审计日志只允许追加并可由管理员导出，原值一旦写入便无法清除。
+const (
+	StaticKeyActor = "static-api-key"
+	AdminKeyActor  = "admin-api-key"
+)
+
+// Principal 是通过鉴权的调用方。
+type Principal struct {
+	OwnerID string
+	// Actor 是审计记录中的调用方，为空时使用 OwnerID。
+	Actor string
+	// KeyID 是数据库签发的 API Key 的 ID，或静态 Key 由 DeriveKeyCredentials 派生的 Key ID；其他凭证为空。
+	KeyID  string
+	Scopes []string
+	// Networks 限制凭证可以从哪些客户端地址使用，零值不限制。
+	Networks NetworkPolicy
+}
+
+// StaticKeyPrincipal 返回静态 Key 对应的调用方：以 Key 原值作为 owner ID 并获得 DefaultScopes，
+// 审计记录中以 StaticKeyActor 与派生的 Key ID 代替原值。
+func StaticKeyPrincipal(key string) Principal {
+	keyID, _ := DeriveKeyCredentials(key)
+	return Principal{OwnerID: key, Actor: StaticKeyActor, KeyID: keyID, Scopes: DefaultScopes}
+}
+
+// HasScope 判断调用方是否拥有 scope。
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"droplite/internal/config"
+)
+
+// RateLimiter 对同一个键的请求计数。实现须保证多个副本共享同一份状态时结果一致。
+type RateLimiter interface {
+	// Take 尝试为 key 消耗一个令牌。
+	Take(ctx context.Context, key string, rule config.RateLimitRule) (RateLimitResult, error)
+	// Peek 返回此刻 Take 的结果但不消耗令牌。
+	Peek(ctx context.Context, key string, rule config.RateLimitRule) (RateLimitResult, error)
+}
+
+// RateLimitResult 是一次 Take 的结果。
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset 是令牌桶回满所需的时间。
+	Reset time.Duration
+	// RetryAfter 是被拒绝时距离下一个可用令牌的时间。
+	RetryAfter time.Duration
+}
+
+// NewRateLimitResult 按 GCRA（令牌桶的等价形式）由理论到达时间 tat 计算结果。
+// 每个令牌的间隔为 Window/Requests，桶容量为 Requests；allowed 为 true 时 tat 是消耗令牌后的新值。
+func NewRateLimitResult(rule config.RateLimitRule, tat, now time.Time, allowed bool) RateLimitResult {
+	interval := rule.Window / time.Duration(rule.Requests)
+	reset := tat.Sub(now)
+	if reset < 0 {
+		reset = 0
+	}
+	result := RateLimitResult{Allowed: allowed, Reset: reset}
+	if allowed {
+		result.Remaining = int((rule.Window - reset) / interval)
+		return result
+	}
+	// 下一个令牌在 tat + interval - window 时可用
+	result.RetryAfter = reset + interval - rule.Window
+	return result
+}
@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Writer 是备份的输出目标，条目按写入顺序保存。
+type Writer interface {
+	// WriteEntry 写入一个条目，r 的长度必须恰好为 size。
+	WriteEntry(name string, size int64, r io.Reader) error
+	Close() error
+}
+
+// Reader 按备份时的顺序逐个读取条目，读完后返回 io.EOF。
+type Reader interface {
+	Next() (name string, r io.Reader, err error)
+	Close() error
+}
+
+// validEntryName 拒绝绝对路径与包含 .. 的名称，避免恢复时写出目标目录。
+func validEntryName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || path.Clean(name) != name {
+		return fmt.Errorf("invalid entry name %q", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return fmt.Errorf("invalid entry name %q", name)
+		}
+	}
+	return nil
+}
+
+type tarWriter struct {
+	tw  *tar.Writer
+	gz  *gzip.Writer
+	now time.Time
+}
+
+// NewTarWriter 以 tar 格式写入 w，compress 为 true 时外层再做 gzip。
+func NewTarWriter(w io.Writer, compress bool) Writer {
+	t := &tarWriter{now: time.Now().UTC()}
+	if compress {
+		t.gz = gzip.NewWriter(w)
+		w = t.gz
+	}
+	t.tw = tar.NewWriter(w)
+	return t
+}
+
+func (t *tarWriter) WriteEntry(name string, size int64, r io.Reader) error {
+	if err := validEntryName(name); err != nil {
+		return err
+	}
+	if err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  t.now,
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	n, err := io.Copy(t.tw, r)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if n != size {
+		return fmt.Errorf("write %s: got %d bytes, expected %d", name, n, size)
+	}
+	return nil
+}
+
+func (t *tarWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
+
+type tarReader struct {
+	tr *tar.Reader
+	gz *gzip.Reader
+}
+
+// NewTarReader 读取 tar 备份，根据魔数自动识别 gzip。
+func NewTarReader(r io.Reader) (Reader, error) {
+	br := bufio.NewReader(r)
+	t := &tarReader{}
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if t.gz, err = gzip.NewReader(br); err != nil {
+			return nil, err
+		}
+		t.tr = tar.NewReader(t.gz)
+	} else {
+		t.tr = tar.NewReader(br)
+	}
+	return t, nil
+}
+
+func (t *tarReader) Next() (string, io.Reader, error) {
+	for {
+		hdr, err := t.tr.Next()
+		if err != nil {
+			return "", nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := validEntryName(hdr.Name); err != nil {
+			return "", nil, err
+		}
+		return hdr.Name, t.tr, nil
+	}
+}
+
+func (t *tarReader) Close() error {
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
+
+type dirWriter struct {
+	dir string
+}
+
+// NewDirWriter 将条目写为 dir 下的普通文件，dir 必须不存在或为空。
+func NewDirWriter(dir string) (Writer, error) {
+	entries, err := os.ReadDir(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case len(entries) > 0:
+		return nil, fmt.Errorf("backup directory %s is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirWriter{dir: dir}, nil
+}
+
+func (d *dirWriter) WriteEntry(name string, size int64, r io.Reader) error {
+	if err := validEntryName(name); err != nil {
+		return err
+	}
+	target := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if n != size {
+		return fmt.Errorf("write %s: got %d bytes, expected %d", name, n, size)
+	}
+	return nil
+}
+
+func (d *dirWriter) Close() error { return nil }
+
+type dirReader struct {
+	dir     string
+	names   []string
+	current *os.File
+}
+
+// NewDirReader 读取 NewDirWriter 写出的目录，条目顺序为
+// manifest.json、files.ndjson、objects/ 下按名称排序的对象，最后是 SHA256SUMS。
+func NewDirReader(dir string) (Reader, error) {
+	var objects []string
+	root := filepath.Join(dir, "objects")
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	sort.Strings(objects)
+
+	names := append([]string{manifestName, recordsName}, objects...)
+	names = append(names, checksumsName)
+	return &dirReader{dir: dir, names: names}, nil
+}
+
+func (d *dirReader) Next() (string, io.Reader, error) {
+	if d.current != nil {
+		_ = d.current.Close()
+		d.current = nil
+	}
+	if len(d.names) == 0 {
+		return "", nil, io.EOF
+	}
+	name := d.names[0]
+	d.names = d.names[1:]
+	f, err := os.Open(filepath.Join(d.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", nil, err
+	}
+	d.current = f
+	return name, f, nil
+}
+
+func (d *dirReader) Close() error {
+	if d.current != nil {
+		return d.current.Close()
+	}
+	return nil
+}
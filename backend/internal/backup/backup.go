@@ -0,0 +1,411 @@
+// Package backup 生成与恢复包含文件记录和存储对象的完整备份。
+//
+// 备份由以下条目按顺序组成，可以是 tar(.gz) 或目录：
+//
+//	manifest.json   版本、时间与数量统计
+//	files.ndjson    files 表快照，格式与 droplite-admin export 相同
+//	objects/<key>   记录引用的存储对象，key 即 storage_path
+//	SHA256SUMS      各对象的 SHA-256，格式与 sha256sum 兼容
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/service"
+	"droplite/internal/storage"
+)
+
+// FormatVersion 是当前备份格式版本。
+const FormatVersion = 1
+
+const (
+	manifestName  = "manifest.json"
+	recordsName   = "files.ndjson"
+	checksumsName = "SHA256SUMS"
+	objectsPrefix = "objects/"
+)
+
+// Manifest 描述一份备份。
+type Manifest struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	Records     int       `json:"records"`
+	Objects     int       `json:"objects"`
+	ObjectBytes int64     `json:"object_bytes"`
+	// MissingObjects 是 stored 状态记录引用、但备份时在存储中不存在的对象。
+	MissingObjects []string `json:"missing_objects,omitempty"`
+}
+
+type objectRef struct {
+	key    string
+	stored bool
+	size   int64
+}
+
+// Create 生成备份并写入 out，调用方负责关闭 out。
+//
+// 记录来自一次 Each 遍历（Postgres 中即单条 SELECT，天然是一致快照）；对象写入后不会被修改，
+// 快照之后才写入的对象不会被引用，因此无需锁表。非 stored 状态（pending、failed）的记录
+// 可能没有对象，缺失时直接忽略；stored 记录的对象缺失会记入 Manifest.MissingObjects。
+func Create(ctx context.Context, records repository.FileRecordStore, objects storage.Reader, out Writer) (*Manifest, error) {
+	if records == nil || objects == nil {
+		return nil, errors.New("backup source not initialized")
+	}
+
+	spool, err := os.CreateTemp("", "droplite-backup-*.ndjson")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	manifest := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC()}
+	var refs []*objectRef
+	byKey := map[string]*objectRef{}
+
+	enc := json.NewEncoder(spool)
+	err = records.Each(ctx, func(record repository.FileRecord) error {
+		if err := enc.Encode(service.ExportedFile{FileRecord: record, OwnerID: record.OwnerID}); err != nil {
+			return err
+		}
+		manifest.Records++
+		ref, ok := byKey[record.StoragePath]
+		if !ok {
+			ref = &objectRef{key: record.StoragePath}
+			byKey[record.StoragePath] = ref
+			refs = append(refs, ref)
+		}
+		ref.stored = ref.stored || record.Status == repository.FileStatusStored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snapshot records: %w", err)
+	}
+
+	var present []*objectRef
+	for _, ref := range refs {
+		if err := validEntryName(objectsPrefix + ref.key); err != nil {
+			return nil, err
+		}
+		size, err := objectSize(ctx, objects, ref.key)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			if ref.stored {
+				manifest.MissingObjects = append(manifest.MissingObjects, ref.key)
+			}
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("stat object %s: %w", ref.key, err)
+		}
+		ref.size = size
+		manifest.Objects++
+		manifest.ObjectBytes += size
+		present = append(present, ref)
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteEntry(manifestName, int64(len(manifestBytes)), strings.NewReader(string(manifestBytes))); err != nil {
+		return nil, err
+	}
+
+	spoolSize, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := out.WriteEntry(recordsName, spoolSize, spool); err != nil {
+		return nil, err
+	}
+
+	var sums strings.Builder
+	for _, ref := range present {
+		sum, err := copyObject(ctx, objects, ref, out)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&sums, "%s  %s%s\n", sum, objectsPrefix, ref.key)
+	}
+	if err := out.WriteEntry(checksumsName, int64(sums.Len()), strings.NewReader(sums.String())); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func copyObject(ctx context.Context, objects storage.Reader, ref *objectRef, out Writer) (string, error) {
+	body, err := objects.Read(ctx, ref.key)
+	if err != nil {
+		return "", fmt.Errorf("read object %s: %w", ref.key, err)
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if err := out.WriteEntry(objectsPrefix+ref.key, ref.size, io.TeeReader(body, h)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// objectSize 优先使用 storage.Statter，否则读取整个对象计算大小。
+func objectSize(ctx context.Context, objects storage.Reader, key string) (int64, error) {
+	if statter, ok := objects.(storage.Statter); ok {
+		return statter.Stat(ctx, key)
+	}
+	body, err := objects.Read(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(io.Discard, body)
+}
+
+// RestoreOptions 控制恢复行为。
+type RestoreOptions struct {
+	// Overwrite 允许恢复到已有文件记录的库，同 ID 的记录被备份覆盖；
+	// 默认要求目标 files 表为空。
+	Overwrite bool
+}
+
+// RestoreResult 汇总恢复结果。
+type RestoreResult struct {
+	Manifest Manifest
+	Objects  int
+	Records  service.ImportSummary
+}
+
+// Restore 从备份恢复存储对象与文件记录。
+//
+// 备份按顺序读取且 SHA256SUMS 在最后，因此对象先暂存到本地临时目录并在写入时计算 SHA-256。
+// 全部条目与 SHA256SUMS、manifest 核对一致，且记录以 dry run 校验通过之后，才把对象写入目标存储并导入记录；
+// 校验失败时目标存储与数据库都保持不变。暂存需要与备份中对象总大小相当的本地磁盘空间。
+func Restore(ctx context.Context, in Reader, records repository.FileRecordStore, objects storage.Writer, opts RestoreOptions) (*RestoreResult, error) {
+	if records == nil || objects == nil {
+		return nil, errors.New("restore target not initialized")
+	}
+	if !opts.Overwrite {
+		if err := ensureEmpty(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &RestoreResult{}
+	if err := readManifest(in, &result.Manifest); err != nil {
+		return nil, err
+	}
+
+	name, r, err := in.Next()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", recordsName, err)
+	}
+	if name != recordsName {
+		return nil, fmt.Errorf("expected %s, found %s", recordsName, name)
+	}
+	spool, err := os.CreateTemp("", "droplite-restore-*.ndjson")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := io.Copy(spool, r); err != nil {
+		return nil, fmt.Errorf("read %s: %w", recordsName, err)
+	}
+
+	staging, err := os.MkdirTemp("", "droplite-restore-objects-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	var staged []stagedObject
+	actual := map[string]string{}
+	var expected map[string]string
+	for {
+		name, r, err := in.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(name, objectsPrefix):
+			object := stagedObject{key: strings.TrimPrefix(name, objectsPrefix), path: filepath.Join(staging, strconv.Itoa(len(staged)))}
+			sum, err := stageObject(object.path, r)
+			if err != nil {
+				return nil, fmt.Errorf("stage object %s: %w", object.key, err)
+			}
+			actual[name] = sum
+			staged = append(staged, object)
+		case name == checksumsName:
+			if expected, err = parseChecksums(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected entry %s", name)
+		}
+	}
+	if err := verifyChecksums(result.Manifest, expected, actual); err != nil {
+		return nil, err
+	}
+
+	importOpts := service.ImportOptions{OnConflict: service.ConflictFail}
+	if opts.Overwrite {
+		importOpts.OnConflict = service.ConflictOverwrite
+	}
+	dryRun := importOpts
+	dryRun.DryRun = true
+	if result.Records, err = importRecords(ctx, records, spool, dryRun); err != nil {
+		return result, err
+	}
+
+	for _, object := range staged {
+		if err := commitObject(ctx, objects, object); err != nil {
+			return result, err
+		}
+		result.Objects++
+	}
+	result.Records, err = importRecords(ctx, records, spool, importOpts)
+	return result, err
+}
+
+// stagedObject 是暂存在本地、校验通过后才写入目标存储的对象。
+type stagedObject struct {
+	key  string
+	path string
+}
+
+// stageObject 把对象写入本地文件 path，返回内容的 SHA-256。
+func stageObject(path string, r io.Reader) (string, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, h), r); err != nil {
+		_ = file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func commitObject(ctx context.Context, objects storage.Writer, object stagedObject) error {
+	file, err := os.Open(object.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := objects.Write(ctx, object.key, file); err != nil {
+		return fmt.Errorf("write object %s: %w", object.key, err)
+	}
+	return nil
+}
+
+// importRecords 从头导入 spool 中的记录，存在无效行时返回错误。
+func importRecords(ctx context.Context, records repository.FileRecordStore, spool *os.File, opts service.ImportOptions) (service.ImportSummary, error) {
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return service.ImportSummary{}, err
+	}
+	summary, err := service.ImportFiles(ctx, records, spool, opts, nil)
+	if err != nil {
+		return summary, fmt.Errorf("import records: %w", err)
+	}
+	if summary.Invalid > 0 {
+		return summary, fmt.Errorf("import records: %d invalid lines", summary.Invalid)
+	}
+	return summary, nil
+}
+
+var errNotEmpty = errors.New("not empty")
+
+func ensureEmpty(ctx context.Context, records repository.FileRecordStore) error {
+	err := records.Each(ctx, func(repository.FileRecord) error { return errNotEmpty })
+	if errors.Is(err, errNotEmpty) {
+		return errors.New("target already has file records; restore into an empty database or allow overwrite")
+	}
+	return err
+}
+
+func readManifest(in Reader, manifest *Manifest) error {
+	name, r, err := in.Next()
+	if err != nil {
+		return fmt.Errorf("read %s: %w", manifestName, err)
+	}
+	if name != manifestName {
+		return fmt.Errorf("not a droplite backup: first entry is %s", name)
+	}
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return fmt.Errorf("decode %s: %w", manifestName, err)
+	}
+	if manifest.Version != FormatVersion {
+		return fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	return nil
+}
+
+// parseChecksums 解析 sha256sum 格式：<hex>  <name>。
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("malformed %s line %q", checksumsName, line)
+		}
+		sums[name] = sum
+	}
+	return sums, scanner.Err()
+}
+
+func verifyChecksums(manifest Manifest, expected, actual map[string]string) error {
+	if expected == nil {
+		return fmt.Errorf("backup is truncated: %s missing", checksumsName)
+	}
+	var problems []string
+	for name, sum := range expected {
+		got, ok := actual[name]
+		switch {
+		case !ok:
+			problems = append(problems, name+": missing")
+		case got != sum:
+			problems = append(problems, name+": checksum mismatch")
+		}
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			problems = append(problems, name+": not listed in "+checksumsName)
+		}
+	}
+	if len(actual) != manifest.Objects {
+		problems = append(problems, fmt.Sprintf("manifest lists %d objects, backup contains %d", manifest.Objects, len(actual)))
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("backup verification failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
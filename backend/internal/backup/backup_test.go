@@ -0,0 +1,275 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/storage"
+)
+
+type memoryRecords struct {
+	records map[string]repository.FileRecord
+}
+
+func newMemoryRecords(records ...repository.FileRecord) *memoryRecords {
+	m := &memoryRecords{records: map[string]repository.FileRecord{}}
+	for _, rec := range records {
+		m.records[rec.ID] = rec
+	}
+	return m
+}
+
+func (m *memoryRecords) GetByID(ctx context.Context, id string) (*repository.FileRecord, error) {
+	rec, ok := m.records[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (m *memoryRecords) Each(ctx context.Context, fn func(record repository.FileRecord) error) error {
+	ids := make([]string, 0, len(m.records))
+	for id := range m.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := fn(m.records[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryRecords) Upsert(ctx context.Context, record *repository.FileRecord) error {
+	m.records[record.ID] = *record
+	return nil
+}
+
+// memoryObjects 不实现 storage.Statter，覆盖读取整个对象计算大小的分支。
+type memoryObjects map[string][]byte
+
+func (m memoryObjects) Write(ctx context.Context, key string, r io.Reader) (storage.Location, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return storage.Location{}, err
+	}
+	m[key] = data
+	return storage.Location{Path: key}, nil
+}
+
+func (m memoryObjects) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func fixture() (*memoryRecords, memoryObjects) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	record := func(id string, status repository.FileStatus, size int64) repository.FileRecord {
+		return repository.FileRecord{
+			ID:           id,
+			OwnerID:      "alice",
+			OriginalName: id + ".txt",
+			MimeType:     "text/plain",
+			SizeBytes:    size,
+			StoragePath:  "2026/03/04/" + id,
+			Status:       status,
+			Metadata:     map[string]any{"k": "v"},
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+	}
+	records := newMemoryRecords(
+		record("a", repository.FileStatusStored, 5),
+		record("b", repository.FileStatusDeleted, 3),
+		record("pending", repository.FileStatusPending, 0),
+		record("lost", repository.FileStatusStored, 4),
+	)
+	objects := memoryObjects{
+		"2026/03/04/a": []byte("hello"),
+		"2026/03/04/b": []byte("bye"),
+	}
+	return records, objects
+}
+
+func TestBackup_TarRoundTrip(t *testing.T) {
+	records, objects := fixture()
+
+	var archive bytes.Buffer
+	w := NewTarWriter(&archive, true)
+	manifest, err := Create(context.Background(), records, objects, w)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Records != 4 || manifest.Objects != 2 || manifest.ObjectBytes != 8 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	// pending 记录没有对象属于正常情况，只有 stored 记录的缺失对象会被报告
+	if len(manifest.MissingObjects) != 1 || manifest.MissingObjects[0] != "2026/03/04/lost" {
+		t.Fatalf("missing objects = %v", manifest.MissingObjects)
+	}
+
+	r, err := NewTarReader(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetRecords, targetObjects := newMemoryRecords(), memoryObjects{}
+	result, err := Restore(context.Background(), r, targetRecords, targetObjects, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if result.Objects != 2 || result.Records.Inserted != 4 {
+		t.Fatalf("result = %+v", result)
+	}
+	if string(targetObjects["2026/03/04/a"]) != "hello" || string(targetObjects["2026/03/04/b"]) != "bye" {
+		t.Fatalf("objects = %v", targetObjects)
+	}
+	if got := targetRecords.records["b"]; got.Status != repository.FileStatusDeleted || got.OwnerID != "alice" {
+		t.Fatalf("record b = %+v", got)
+	}
+}
+
+func TestBackup_DirectoryDetectsTampering(t *testing.T) {
+	records, objects := fixture()
+	dir := filepath.Join(t.TempDir(), "backup")
+
+	w, err := NewDirWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(context.Background(), records, objects, w); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	sums, err := os.ReadFile(filepath.Join(dir, checksumsName))
+	if err != nil || !strings.Contains(string(sums), "  objects/2026/03/04/a\n") {
+		t.Fatalf("SHA256SUMS = %q, %v", sums, err)
+	}
+	if _, err := NewDirWriter(dir); err == nil {
+		t.Fatal("writing into a non-empty directory should fail")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "objects", "2026", "03", "04", "a"), []byte("HELLO"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewDirReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	targetRecords := newMemoryRecords()
+	targetObjects := memoryObjects{}
+	_, err = Restore(context.Background(), r, targetRecords, targetObjects, RestoreOptions{})
+	if err == nil || !strings.Contains(err.Error(), "objects/2026/03/04/a: checksum mismatch") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(targetRecords.records) != 0 || len(targetObjects) != 0 {
+		t.Fatalf("nothing may be restored when verification fails, got records %v and objects %v", targetRecords.records, targetObjects)
+	}
+
+	// 覆盖恢复时被篡改的备份同样不能改写现有对象
+	r, err = NewDirReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	live := memoryObjects{"2026/03/04/a": []byte("live!")}
+	if _, err := Restore(context.Background(), r, records, live, RestoreOptions{Overwrite: true}); err == nil {
+		t.Fatal("expected verification failure")
+	}
+	if string(live["2026/03/04/a"]) != "live!" || len(live) != 1 {
+		t.Fatalf("live objects were modified: %v", live)
+	}
+}
+
+func TestRestore_ValidatesRecordsBeforeWritingObjects(t *testing.T) {
+	records, objects := fixture()
+	bogus := records.records["a"]
+	bogus.ID, bogus.Status = "bogus", "bogus"
+	records.records["bogus"] = bogus
+	ownerless := records.records["b"]
+	ownerless.ID, ownerless.OwnerID = "legacy", ""
+	records.records["legacy"] = ownerless
+
+	var archive bytes.Buffer
+	w := NewTarWriter(&archive, false)
+	if _, err := Create(context.Background(), records, objects, w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := NewTarReader(bytes.NewReader(archive.Bytes()))
+	target, targetObjects := newMemoryRecords(), memoryObjects{}
+	_, err := Restore(context.Background(), r, target, targetObjects, RestoreOptions{})
+	if err == nil || !strings.Contains(err.Error(), "1 invalid lines") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(target.records) != 0 || len(targetObjects) != 0 {
+		t.Fatalf("nothing may be restored when a record is invalid, got records %v and objects %v", target.records, targetObjects)
+	}
+
+	// 没有 owner 的记录是合法的
+	delete(records.records, "bogus")
+	archive.Reset()
+	w = NewTarWriter(&archive, false)
+	if _, err := Create(context.Background(), records, objects, w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = NewTarReader(bytes.NewReader(archive.Bytes()))
+	if _, err := Restore(context.Background(), r, target, targetObjects, RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if rec, ok := target.records["legacy"]; !ok || rec.OwnerID != "" || len(targetObjects) != 2 {
+		t.Fatalf("unexpected restore result: records %v, objects %v", target.records, targetObjects)
+	}
+}
+
+func TestRestore_RequiresEmptyTarget(t *testing.T) {
+	records, objects := fixture()
+	var archive bytes.Buffer
+	w := NewTarWriter(&archive, false)
+	if _, err := Create(context.Background(), records, objects, w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := archive.Bytes()
+
+	existing := records.records["a"]
+	existing.OriginalName = "renamed.txt"
+	target := newMemoryRecords(existing)
+
+	r, _ := NewTarReader(bytes.NewReader(data))
+	if _, err := Restore(context.Background(), r, target, memoryObjects{}, RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "already has file records") {
+		t.Fatalf("err = %v", err)
+	}
+
+	r, _ = NewTarReader(bytes.NewReader(data))
+	result, err := Restore(context.Background(), r, target, memoryObjects{}, RestoreOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("restore with overwrite: %v", err)
+	}
+	if result.Records.Updated != 1 || target.records["a"].OriginalName != "a.txt" {
+		t.Fatalf("result = %+v, record = %+v", result.Records, target.records["a"])
+	}
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/netip"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,26 +16,72 @@ import (
 // Config 聚合服务启动需要的关键配置。
 type Config struct {
 	HTTPPort           string
+	GRPCPort           string // 为空时不启动 gRPC 服务
+	S3GatewayPort      string // 为空时不启动 S3 兼容网关
+	S3GatewayRegion    string // S3 网关对外声明的区域
 	StorageDir         string
 	CORSAllowedOrigins []string
 	RateLimitRequests  int
 	RateLimitWindow    time.Duration
-	DBHost             string
-	DBPort             int
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	DBSSLMode          string
+	RateLimitBackend   string                   // "memory"（进程内）或 "postgres"（多副本共享）
+	RateLimitRoutes    map[string]RateLimitRule // 按路由覆盖默认限额，键为 "METHOD /pattern"
+	RateLimitKeys      map[string]RateLimitRule // 按限流键覆盖默认限额，键为 "owner:<id>" 或 "ip:<addr>"
+	AuthFailureLimit   RateLimitRule            // 同一 IP 鉴权失败的次数上限，用尽后在校验凭证前直接返回 429
+	TrustedProxies     []netip.Prefix           // 只信任来自这些地址的 X-Forwarded-For
+	// TLS，证书与 Key 都为空时以明文 HTTP 监听
+	TLSCertFile          string
+	TLSKeyFile           string
+	TLSClientCAFile      string            // 客户端证书的 CA bundle，为空时不接受客户端证书
+	TLSRequireClientCert bool              // 为 false 时客户端可以不出示证书，改用其他凭证
+	TLSClientIdentities  map[string]string // 客户端证书到 owner ID 的映射，键为 "cn:"、"subject:"、"dns:"、"uri:" 或 "email:" 加对应的值
+	TLSReloadInterval    time.Duration     // 检查证书文件是否变化的间隔
+	// 后台任务
+	WebhookPollInterval time.Duration // webhook 投递队列的轮询间隔
+	ExpirySweepInterval time.Duration // 过期文件清理间隔
+	EventRetention      time.Duration // SSE 事件日志的保留时长
+	DBHost              string
+	DBPort              int
+	DBUser              string
+	DBPassword          string
+	DBName              string
+	DBSSLMode           string
 	// 鉴权配置
-	AuthProvider string   // "apikey" 或 "supabase"
+	AuthProvider string   // "apikey"、"supabase" 或 "oidc"
 	AuthEnabled  bool     // 是否启用鉴权
 	APIKeys      []string // API Key 模式下有效的 Keys 列表
+	AdminAPIKeys []string // 访问 /admin 管理端点的 Keys 列表
+	// 请求签名（仅 API Key 模式）
+	RequestSigningEnabled      bool          // 是否接受 DL-HMAC-SHA256 签名的请求
+	RequestSigningWindow       time.Duration // 签名时间戳与服务端时钟允许的最大偏差，也是 nonce 的保留时长
+	RequestSigningNonceBackend string        // "memory"（进程内）或 "postgres"（多副本共享）
 	// Supabase 配置
-	SupabaseURL       string // Supabase 项目 URL
-	SupabaseAnonKey   string // Supabase anon key（前端用）
-	SupabaseJWTSecret string // Supabase JWT Secret（后端验证用）
+	SupabaseURL            string        // Supabase 项目 URL
+	SupabaseAnonKey        string        // Supabase anon key（前端用）
+	SupabaseJWTSecret      string        // Supabase JWT Secret（后端验证用）
+	SupabaseJWTIssuer      string        // 接受的 iss，默认 <SUPABASE_URL>/auth/v1
+	SupabaseJWTAudiences   []string      // 接受的 aud，默认 authenticated
+	SupabaseRemoteTimeout  time.Duration // 请求 JWKS 与 /auth/v1/user 的超时
+	SupabaseRemoteCacheTTL time.Duration // 远程校验结果的缓存时间
+	// OIDC 配置
+	OIDCIssuerURL  string        // 签发方地址，启动时从 <issuer>/.well-known/openid-configuration 发现 JWKS
+	OIDCAudiences  []string      // 接受的 aud，token 至少包含其中之一；设置签发方时必填
+	OIDCOwnerClaim string        // 作为 owner ID 的声明，默认 sub
+	OIDCClockSkew  time.Duration // 校验 exp、nbf、iat 时容忍的时钟偏差
 	// Upload
 	MaxUploadSize int64
+	// 上传准入与限速，为 0 表示不限制
+	UploadMaxConcurrent            int
+	UploadMaxConcurrentPerOwner    int
+	UploadMaxInFlightBytes         int64
+	UploadMaxInFlightBytesPerOwner int64
+	UploadQueueTimeout             time.Duration // 超出限制时排队等待的最长时间，为 0 时不排队
+	TransferTimeout                time.Duration // 获准的上传与下载可持续的最长时间，取代服务器读写超时，为 0 时不限制
+	OwnerBytesPerSecond            int64         // 每个 owner 上传与下载的总吞吐上限
+	// 分享链接
+	ShareLinkSecret string // 签名分享链接的 HMAC 密钥，更换后已签发的链接全部失效
+	PublicBaseURL   string // 分享链接使用的对外地址，为空时按请求的 Host 生成
+	// CredentialEncryptionKey 是加密数据库中 S3 secret 等可还原凭证的 32 字节密钥，为空时以明文保存
+	CredentialEncryptionKey []byte
 	// 存储配置
 	StorageDriver string // "local" 或 "s3"
 	S3Endpoint    string // S3/MinIO 端点，不含协议
@@ -43,6 +93,12 @@ type Config struct {
 	S3PathStyle   bool // 是否使用路径风格访问（MinIO 需要设为 true）
 }
 
+// RateLimitRule 表示每 Window 最多 Requests 个请求，允许一次性用完。
+type RateLimitRule struct {
+	Requests int
+	Window   time.Duration
+}
+
 // Load 从环境变量加载配置，并提供默认值。
 func Load() (*Config, error) {
 	port := os.Getenv("PORT")
@@ -74,6 +130,70 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	rateLimitBackend := envOrDefault("RATE_LIMIT_BACKEND", "memory")
+	if rateLimitBackend != "memory" && rateLimitBackend != "postgres" {
+		return nil, fmt.Errorf("RATE_LIMIT_BACKEND 只能是 memory 或 postgres: %s", rateLimitBackend)
+	}
+	rateLimitRoutes, err := parseRateLimitRules("RATE_LIMIT_ROUTES")
+	if err != nil {
+		return nil, err
+	}
+	rateLimitKeys, err := parseRateLimitRules("RATE_LIMIT_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	authFailures, err := parseIntEnv("AUTH_FAILURE_LIMIT", 10)
+	if err != nil {
+		return nil, err
+	}
+	authFailureWindow, err := parseDurationEnv("AUTH_FAILURE_WINDOW", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := parsePrefixes("TRUSTED_PROXIES")
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCertFile, tlsKeyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE 与 TLS_KEY_FILE 必须同时设置")
+	}
+	tlsClientCAFile := os.Getenv("TLS_CLIENT_CA_FILE")
+	if tlsClientCAFile != "" && tlsCertFile == "" {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE 需要同时设置 TLS_CERT_FILE 与 TLS_KEY_FILE")
+	}
+	tlsClientAuth := envOrDefault("TLS_CLIENT_AUTH", "optional")
+	if tlsClientAuth != "optional" && tlsClientAuth != "require" {
+		return nil, fmt.Errorf("TLS_CLIENT_AUTH 只能是 optional 或 require: %s", tlsClientAuth)
+	}
+	tlsClientIdentities, err := parseClientIdentities("TLS_CLIENT_IDENTITIES")
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsClientIdentities) > 0 && tlsClientCAFile == "" {
+		return nil, fmt.Errorf("TLS_CLIENT_IDENTITIES 需要同时设置 TLS_CLIENT_CA_FILE")
+	}
+	tlsReloadInterval, err := parseDurationEnv("TLS_RELOAD_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookPollInterval, err := parseDurationEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	expirySweepInterval, err := parseDurationEnv("EXPIRY_SWEEP_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	eventRetention, err := parseDurationEnv("EVENT_RETENTION", 72*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
 	dbPort, err := parseIntEnv("DB_PORT", 5432)
 	if err != nil {
 		return nil, err
@@ -87,6 +207,56 @@ func Load() (*Config, error) {
 		apiKeys = []string{"dev-api-key-123456"}
 	}
 	authProvider := envOrDefault("AUTH_PROVIDER", "apikey")
+	// 管理员 Key 可以为任意 owner 签发凭证，没有默认值
+	adminAPIKeys := parseList(os.Getenv("ADMIN_API_KEYS"))
+
+	requestSigningWindow, err := parseDurationEnv("REQUEST_SIGNING_WINDOW", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	requestSigningNonceBackend := envOrDefault("REQUEST_SIGNING_NONCE_BACKEND", "memory")
+	if requestSigningNonceBackend != "memory" && requestSigningNonceBackend != "postgres" {
+		return nil, fmt.Errorf("REQUEST_SIGNING_NONCE_BACKEND 只能是 memory 或 postgres: %s", requestSigningNonceBackend)
+	}
+
+	supabaseAudiences := parseList(os.Getenv("SUPABASE_JWT_AUDIENCE"))
+	if len(supabaseAudiences) == 0 {
+		supabaseAudiences = []string{"authenticated"}
+	}
+	supabaseRemoteTimeout, err := parseDurationEnv("SUPABASE_REMOTE_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	supabaseRemoteCacheTTL, err := parseDurationEnv("SUPABASE_REMOTE_CACHE_TTL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcClockSkew, err := parseDurationEnv("OIDC_CLOCK_SKEW", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	// 共享的 IdP 会给其他客户端签发同一 iss 的 token，不校验 aud 时这些 token 也能登录，
+	// 其中的 admin scope 会被当作 DropLite 管理员
+	oidcIssuerURL := os.Getenv("OIDC_ISSUER_URL")
+	oidcAudiences := parseList(os.Getenv("OIDC_AUDIENCE"))
+	if oidcIssuerURL != "" && len(oidcAudiences) == 0 {
+		return nil, fmt.Errorf("设置 OIDC_ISSUER_URL 时必须同时设置 OIDC_AUDIENCE")
+	}
+
+	shareLinkSecret := os.Getenv("SHARE_LINK_SECRET")
+	if shareLinkSecret == "" && !authEnabled {
+		// 关闭鉴权的开发环境使用进程内随机密钥，重启后已签发的链接失效
+		shareLinkSecret = randomSecret()
+	}
+
+	var credentialEncryptionKey []byte
+	if raw := os.Getenv("CREDENTIAL_ENCRYPTION_KEY"); raw != "" {
+		credentialEncryptionKey, err = hex.DecodeString(raw)
+		if err != nil || len(credentialEncryptionKey) != 32 {
+			return nil, fmt.Errorf("CREDENTIAL_ENCRYPTION_KEY 必须是 32 字节密钥的十六进制（64 个字符）")
+		}
+	}
 
 	// 存储配置
 	storageDriver := envOrDefault("STORAGE_DRIVER", "local")
@@ -98,33 +268,102 @@ func Load() (*Config, error) {
 		}
 	}
 
+	uploadMaxConcurrent, err := parseLimitEnv("UPLOAD_MAX_CONCURRENT", 32)
+	if err != nil {
+		return nil, err
+	}
+	uploadMaxConcurrentPerOwner, err := parseLimitEnv("UPLOAD_MAX_CONCURRENT_PER_OWNER", 4)
+	if err != nil {
+		return nil, err
+	}
+	uploadMaxInFlightBytes, err := parseLimitEnv("UPLOAD_MAX_INFLIGHT_BYTES", 8<<30)
+	if err != nil {
+		return nil, err
+	}
+	uploadMaxInFlightBytesPerOwner, err := parseLimitEnv("UPLOAD_MAX_INFLIGHT_BYTES_PER_OWNER", 2<<30)
+	if err != nil {
+		return nil, err
+	}
+	// 为 0 时不排队，超出限制立即返回 503
+	uploadQueueTimeout, err := parseLimitDurationEnv("UPLOAD_QUEUE_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	transferTimeout, err := parseLimitDurationEnv("TRANSFER_TIMEOUT", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	ownerBytesPerSecond, err := parseLimitEnv("OWNER_BYTES_PER_SECOND", 0)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		HTTPPort:           port,
-		StorageDir:         storage,
-		CORSAllowedOrigins: corsOrigins,
-		RateLimitRequests:  rateLimitRequests,
-		RateLimitWindow:    rateLimitWindow,
-		DBHost:             envOrDefault("DB_HOST", "127.0.0.1"),
-		DBPort:             dbPort,
-		DBUser:             envOrDefault("DB_USER", "droplite"),
-		DBPassword:         envOrDefault("DB_PASSWORD", "droplite"),
-		DBName:             envOrDefault("DB_NAME", "droplite"),
-		DBSSLMode:          envOrDefault("DB_SSL_MODE", "disable"),
-		AuthEnabled:        authEnabled,
-		AuthProvider:       authProvider,
-		APIKeys:            apiKeys,
-		SupabaseURL:        os.Getenv("SUPABASE_URL"),
-		SupabaseAnonKey:    os.Getenv("SUPABASE_ANON_KEY"),
-		SupabaseJWTSecret:  os.Getenv("SUPABASE_JWT_SECRET"),
-		MaxUploadSize:      maxUploadSize,
-		StorageDriver:      storageDriver,
-		S3Endpoint:         envOrDefault("S3_ENDPOINT", "localhost:9000"),
-		S3AccessKey:        envOrDefault("S3_ACCESS_KEY", "minioadmin"),
-		S3SecretKey:        envOrDefault("S3_SECRET_KEY", "minioadmin"),
-		S3Bucket:           envOrDefault("S3_BUCKET", "droplite"),
-		S3Region:           envOrDefault("S3_REGION", "us-east-1"),
-		S3UseSSL:           parseBoolEnv("S3_USE_SSL", false),
-		S3PathStyle:        parseBoolEnv("S3_PATH_STYLE", true),
+		HTTPPort:                       port,
+		GRPCPort:                       os.Getenv("GRPC_PORT"),
+		S3GatewayPort:                  os.Getenv("S3_GATEWAY_PORT"),
+		S3GatewayRegion:                envOrDefault("S3_GATEWAY_REGION", "us-east-1"),
+		StorageDir:                     storage,
+		CORSAllowedOrigins:             corsOrigins,
+		RateLimitRequests:              rateLimitRequests,
+		RateLimitWindow:                rateLimitWindow,
+		RateLimitBackend:               rateLimitBackend,
+		RateLimitRoutes:                rateLimitRoutes,
+		RateLimitKeys:                  rateLimitKeys,
+		AuthFailureLimit:               RateLimitRule{Requests: authFailures, Window: authFailureWindow},
+		TrustedProxies:                 trustedProxies,
+		TLSCertFile:                    tlsCertFile,
+		TLSKeyFile:                     tlsKeyFile,
+		TLSClientCAFile:                tlsClientCAFile,
+		TLSRequireClientCert:           tlsClientAuth == "require",
+		TLSClientIdentities:            tlsClientIdentities,
+		TLSReloadInterval:              tlsReloadInterval,
+		WebhookPollInterval:            webhookPollInterval,
+		ExpirySweepInterval:            expirySweepInterval,
+		EventRetention:                 eventRetention,
+		DBHost:                         envOrDefault("DB_HOST", "127.0.0.1"),
+		DBPort:                         dbPort,
+		DBUser:                         envOrDefault("DB_USER", "droplite"),
+		DBPassword:                     envOrDefault("DB_PASSWORD", "droplite"),
+		DBName:                         envOrDefault("DB_NAME", "droplite"),
+		DBSSLMode:                      envOrDefault("DB_SSL_MODE", "disable"),
+		AuthEnabled:                    authEnabled,
+		AuthProvider:                   authProvider,
+		APIKeys:                        apiKeys,
+		AdminAPIKeys:                   adminAPIKeys,
+		RequestSigningEnabled:          parseBoolEnv("REQUEST_SIGNING_ENABLED", true),
+		RequestSigningWindow:           requestSigningWindow,
+		RequestSigningNonceBackend:     requestSigningNonceBackend,
+		SupabaseURL:                    os.Getenv("SUPABASE_URL"),
+		SupabaseAnonKey:                os.Getenv("SUPABASE_ANON_KEY"),
+		SupabaseJWTSecret:              os.Getenv("SUPABASE_JWT_SECRET"),
+		SupabaseJWTIssuer:              os.Getenv("SUPABASE_JWT_ISSUER"),
+		SupabaseJWTAudiences:           supabaseAudiences,
+		SupabaseRemoteTimeout:          supabaseRemoteTimeout,
+		SupabaseRemoteCacheTTL:         supabaseRemoteCacheTTL,
+		OIDCIssuerURL:                  oidcIssuerURL,
+		OIDCAudiences:                  oidcAudiences,
+		OIDCOwnerClaim:                 envOrDefault("OIDC_OWNER_CLAIM", "sub"),
+		OIDCClockSkew:                  oidcClockSkew,
+		MaxUploadSize:                  maxUploadSize,
+		UploadMaxConcurrent:            int(uploadMaxConcurrent),
+		UploadMaxConcurrentPerOwner:    int(uploadMaxConcurrentPerOwner),
+		UploadMaxInFlightBytes:         uploadMaxInFlightBytes,
+		UploadMaxInFlightBytesPerOwner: uploadMaxInFlightBytesPerOwner,
+		UploadQueueTimeout:             uploadQueueTimeout,
+		TransferTimeout:                transferTimeout,
+		OwnerBytesPerSecond:            ownerBytesPerSecond,
+		ShareLinkSecret:                shareLinkSecret,
+		PublicBaseURL:                  strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		CredentialEncryptionKey:        credentialEncryptionKey,
+		StorageDriver:                  storageDriver,
+		S3Endpoint:                     envOrDefault("S3_ENDPOINT", "localhost:9000"),
+		S3AccessKey:                    envOrDefault("S3_ACCESS_KEY", "minioadmin"),
+		S3SecretKey:                    envOrDefault("S3_SECRET_KEY", "minioadmin"),
+		S3Bucket:                       envOrDefault("S3_BUCKET", "droplite"),
+		S3Region:                       envOrDefault("S3_REGION", "us-east-1"),
+		S3UseSSL:                       parseBoolEnv("S3_USE_SSL", false),
+		S3PathStyle:                    parseBoolEnv("S3_PATH_STYLE", true),
 	}, nil
 }
 
@@ -161,6 +400,94 @@ func parseList(raw string) []string {
 	return out
 }
 
+// parseRateLimitRules 解析 "<键>=<请求数>/<窗口>" 的逗号分隔列表，例如 "POST /files=20/1m,owner:ci=600/1m"。
+func parseRateLimitRules(key string) (map[string]RateLimitRule, error) {
+	items := parseList(os.Getenv(key))
+	if len(items) == 0 {
+		return nil, nil
+	}
+	rules := make(map[string]RateLimitRule, len(items))
+	for _, item := range items {
+		// owner ID 中可能含有 "="，以最后一个 "=" 分隔
+		idx := strings.LastIndex(item, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("解析 %s 失败: %q 缺少 \"=\"", key, item)
+		}
+		name, spec := strings.TrimSpace(item[:idx]), strings.TrimSpace(item[idx+1:])
+		requests, window, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("解析 %s 失败: %q 应为 <请求数>/<窗口>", key, item)
+		}
+		n, err := strconv.Atoi(requests)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("解析 %s 失败: %q 的请求数无效", key, item)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("解析 %s 失败: %q 的窗口无效", key, item)
+		}
+		rules[name] = RateLimitRule{Requests: n, Window: d}
+	}
+	return rules, nil
+}
+
+// clientIdentityKinds 是 TLS_CLIENT_IDENTITIES 支持的匹配方式。
+var clientIdentityKinds = []string{"cn", "subject", "dns", "uri", "email"}
+
+// parseClientIdentities 解析 "<方式>:<值>=<owner ID>" 的分号分隔列表，例如
+// "cn:ci-runner=ci;subject:CN=deploy,O=Example=deploy;uri:spiffe://example.org/builder=ci"。
+// subject 中含有逗号，因此这里用分号分隔各项。
+func parseClientIdentities(key string) (map[string]string, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return nil, nil
+	}
+	identities := make(map[string]string)
+	for _, item := range strings.Split(raw, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		// subject 中含有 "="，以最后一个 "=" 分隔
+		idx := strings.LastIndex(item, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("解析 %s 失败: %q 缺少 \"=\"", key, item)
+		}
+		match, owner := strings.TrimSpace(item[:idx]), strings.TrimSpace(item[idx+1:])
+		kind, value, ok := strings.Cut(match, ":")
+		if !ok || value == "" || !slices.Contains(clientIdentityKinds, kind) {
+			return nil, fmt.Errorf("解析 %s 失败: %q 应以 cn:、subject:、dns:、uri: 或 email: 开头", key, item)
+		}
+		if owner == "" {
+			return nil, fmt.Errorf("解析 %s 失败: %q 缺少 owner ID", key, item)
+		}
+		identities[match] = owner
+	}
+	return identities, nil
+}
+
+// parsePrefixes 解析逗号分隔的 CIDR 列表，单个地址视为只包含该地址的网段。
+func parsePrefixes(key string) ([]netip.Prefix, error) {
+	items := parseList(os.Getenv(key))
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("解析 %s 失败: %w", key, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", key, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
 func parseIntEnv(key string, defaultValue int) (int, error) {
 	raw := os.Getenv(key)
 	if raw == "" {
@@ -177,6 +504,40 @@ func parseIntEnv(key string, defaultValue int) (int, error) {
 	return value, nil
 }
 
+// parseLimitEnv 解析非负整数上限，显式设置为 0 表示不限制。
+func parseLimitEnv(key string, defaultValue int64) (int64, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析 %s 失败: %w", key, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("解析 %s 失败: 不能为负数", key)
+	}
+	return value, nil
+}
+
+// parseLimitDurationEnv 与 parseDurationEnv 相同，但 0 是有效取值（表示不等待或不限制），负数报错。
+func parseLimitDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("解析 %s 失败: %w", key, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("解析 %s 失败: 不能为负数", key)
+	}
+	return value, nil
+}
+
 func parseDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
 	raw := os.Getenv(key)
 	if raw == "" {
@@ -202,6 +563,34 @@ func parseBoolEnv(key string, defaultValue bool) bool {
 	return lower == "true" || lower == "1" || lower == "yes"
 }
 
+// MinSecretLength 是 SHARE_LINK_SECRET 与 ADMIN_API_KEYS 等服务端密钥的最小长度（字节）。
+const MinSecretLength = 32
+
+// ValidateSecrets 检查 HTTP 服务必需的密钥，由服务进程在启动时调用；迁移等命令行工具不需要这些密钥。
+// 分享链接是对文件 ID 的 HMAC 签名，文件 ID 并不保密，密钥可被猜到时任何人都能伪造下载链接；
+// 管理员 Key 可以为任意 owner 签发凭证；数据库中的 S3 secret 可以直接用来签名请求。
+func (c *Config) ValidateSecrets() error {
+	if !c.AuthEnabled {
+		return nil
+	}
+	if len(c.ShareLinkSecret) < MinSecretLength {
+		return fmt.Errorf("开启鉴权时必须设置 SHARE_LINK_SECRET，且长度至少 %d 字节", MinSecretLength)
+	}
+	// 管理员 Key 可以为任意 owner 签发 API Key，同样不能使用弱值
+	if len(c.AdminAPIKeys) == 0 {
+		return fmt.Errorf("开启鉴权时必须设置 ADMIN_API_KEYS")
+	}
+	for _, key := range c.AdminAPIKeys {
+		if len(key) < MinSecretLength {
+			return fmt.Errorf("ADMIN_API_KEYS 中的每个 Key 长度至少 %d 字节", MinSecretLength)
+		}
+	}
+	if len(c.CredentialEncryptionKey) == 0 {
+		return fmt.Errorf("开启鉴权时必须设置 CREDENTIAL_ENCRYPTION_KEY")
+	}
+	return nil
+}
+
 // PostgresDSN 生成标准 postgres:// 连接串，供数据访问层直接使用。
 func (c *Config) PostgresDSN() string {
 	u := &url.URL{
@@ -220,6 +609,15 @@ func (c *Config) PostgresDSN() string {
 	return u.String()
 }
 
+// randomSecret 返回 32 字节随机数的十六进制。
+func randomSecret() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
 func envOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
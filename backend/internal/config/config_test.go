@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSecrets(t *testing.T) {
+	strong := strings.Repeat("s", MinSecretLength)
+	valid := func() Config {
+		return Config{
+			AuthEnabled:             true,
+			ShareLinkSecret:         strong,
+			AdminAPIKeys:            []string{strong},
+			CredentialEncryptionKey: make([]byte, 32),
+		}
+	}
+
+	cases := map[string]struct {
+		mutate func(*Config)
+		want   string
+	}{
+		"valid":                      {mutate: func(*Config) {}},
+		"auth disabled":              {mutate: func(c *Config) { *c = Config{} }},
+		"missing share link secret":  {mutate: func(c *Config) { c.ShareLinkSecret = "" }, want: "SHARE_LINK_SECRET"},
+		"short share link secret":    {mutate: func(c *Config) { c.ShareLinkSecret = strong[1:] }, want: "SHARE_LINK_SECRET"},
+		"missing admin keys":         {mutate: func(c *Config) { c.AdminAPIKeys = nil }, want: "ADMIN_API_KEYS"},
+		"short admin key":            {mutate: func(c *Config) { c.AdminAPIKeys = []string{strong, "dev-admin-key-123456"} }, want: "ADMIN_API_KEYS"},
+		"missing credential key":     {mutate: func(c *Config) { c.CredentialEncryptionKey = nil }, want: "CREDENTIAL_ENCRYPTION_KEY"},
+		"auth disabled without keys": {mutate: func(c *Config) { c.AuthEnabled, c.ShareLinkSecret, c.AdminAPIKeys = false, "", nil }},
+	}
+	for name, tc := range cases {
+		cfg := valid()
+		tc.mutate(&cfg)
+		err := cfg.ValidateSecrets()
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", name, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%s: expected an error mentioning %s, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	t.Setenv("STORAGE_DIR", t.TempDir())
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("SHARE_LINK_SECRET", "")
+	t.Setenv("ADMIN_API_KEYS", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// 开启鉴权时没有任何默认密钥
+	if err := cfg.ValidateSecrets(); err == nil {
+		t.Fatal("expected missing secrets to be rejected")
+	}
+
+	t.Setenv("CREDENTIAL_ENCRYPTION_KEY", "not-hex")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CREDENTIAL_ENCRYPTION_KEY") {
+		t.Fatalf("expected invalid CREDENTIAL_ENCRYPTION_KEY to be rejected, got %v", err)
+	}
+	t.Setenv("CREDENTIAL_ENCRYPTION_KEY", strings.Repeat("ab", 16))
+	if _, err := Load(); err == nil {
+		t.Fatal("expected a 16-byte CREDENTIAL_ENCRYPTION_KEY to be rejected")
+	}
+
+	t.Setenv("CREDENTIAL_ENCRYPTION_KEY", strings.Repeat("ab", 32))
+	t.Setenv("SHARE_LINK_SECRET", strings.Repeat("s", MinSecretLength))
+	t.Setenv("ADMIN_API_KEYS", strings.Repeat("a", MinSecretLength)+","+strings.Repeat("b", MinSecretLength))
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := cfg.ValidateSecrets(); err != nil {
+		t.Fatalf("expected configured secrets to pass, got %v", err)
+	}
+
+	// 关闭鉴权时未设置 SHARE_LINK_SECRET 使用随机密钥
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("SHARE_LINK_SECRET", "")
+	if cfg, err = Load(); err != nil || len(cfg.ShareLinkSecret) < MinSecretLength {
+		t.Fatalf("expected a random share link secret, got %q, %v", cfg.ShareLinkSecret, err)
+	}
+}
+
+func TestLoad_OIDCRequiresAudience(t *testing.T) {
+	t.Setenv("STORAGE_DIR", t.TempDir())
+	t.Setenv("OIDC_ISSUER_URL", "https://idp.example.com")
+	t.Setenv("OIDC_AUDIENCE", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OIDC_AUDIENCE") {
+		t.Fatalf("expected missing OIDC_AUDIENCE to be rejected, got %v", err)
+	}
+
+	t.Setenv("OIDC_AUDIENCE", "droplite, droplite-cli")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.OIDCAudiences) != 2 {
+		t.Fatalf("audiences = %v, want two entries", cfg.OIDCAudiences)
+	}
+}
@@ -0,0 +1,577 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"golang.org/x/net/webdav"
+)
+
+// listPageSize 是列目录时每次向仓储请求的记录数。
+const listPageSize = 500
+
+// FileSystem 将 FileService 适配为 webdav.FileSystem。
+// 文件的 original_name 即其在 WebDAV 中的相对路径，目录由路径前缀隐式推导；
+// 通过 MKCOL 创建的空目录仅保存在进程内存中，写入文件后自然持久化。
+type FileSystem struct {
+	files         *service.FileService
+	maxUploadSize int64
+
+	mu   sync.Mutex
+	dirs map[string]map[string]struct{}
+}
+
+func NewFileSystem(files *service.FileService, maxUploadSize int64) *FileSystem {
+	return &FileSystem{
+		files:         files,
+		maxUploadSize: maxUploadSize,
+		dirs:          make(map[string]map[string]struct{}),
+	}
+}
+
+// Mkdir 创建空目录。
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	key := cleanKey(name)
+	if key == "" {
+		return os.ErrExist
+	}
+	if _, err := fs.Stat(ctx, key); err == nil {
+		return os.ErrExist
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := fs.requireParent(ctx, key); err != nil {
+		return err
+	}
+
+	owner := dlmiddleware.GetOwnerID(ctx)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.dirs[owner] == nil {
+		fs.dirs[owner] = make(map[string]struct{})
+	}
+	fs.dirs[owner][key] = struct{}{}
+	return nil
+}
+
+// OpenFile 以只读方式打开文件或目录；带写标志时返回缓冲上传内容的文件，Close 时登记到 FileService。
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	key := cleanKey(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if key == "" {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+		}
+		isDir, _, err := fs.dirStat(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if isDir {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+		}
+		if err := fs.requireParent(ctx, key); err != nil {
+			return nil, err
+		}
+		return fs.newUploadFile(ctx, key)
+	}
+
+	info, err := fs.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{fs: fs, ctx: ctx, key: key, info: info}, nil
+	}
+	record := info.(*fileInfo).record
+	dlmiddleware.SetAuditFileID(ctx, record.ID)
+	return &readFile{ReadSeekCloser: fs.files.OpenContent(ctx, record), record: record}, nil
+}
+
+// RemoveAll 软删除文件，或删除目录下的全部文件。
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	key := cleanKey(name)
+	if key == "" {
+		return os.ErrPermission
+	}
+
+	records, err := fs.list(ctx, repository.ListFilesParams{Name: key})
+	if err != nil {
+		return err
+	}
+	children, err := fs.list(ctx, repository.ListFilesParams{NamePrefix: key + "/"})
+	if err != nil {
+		return err
+	}
+	// 删除目录时第一个文件作为本次请求审计记录的文件，其余逐个追加记录
+	removed := append(records, children...)
+	if len(removed) > 0 {
+		dlmiddleware.SetAuditFileID(ctx, removed[0].ID)
+	}
+	for i, record := range removed {
+		if err := fs.files.DeleteFile(ctx, record.ID); err != nil {
+			return toOSError(err)
+		}
+		if i > 0 {
+			dlmiddleware.AddAuditEvent(ctx, auth.AuditFileDelete, record.ID, auth.AuditSuccess)
+		}
+	}
+
+	owner := dlmiddleware.GetOwnerID(ctx)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for dir := range fs.dirs[owner] {
+		if dir == key || strings.HasPrefix(dir, key+"/") {
+			delete(fs.dirs[owner], dir)
+		}
+	}
+	return nil
+}
+
+// Rename 移动文件或目录，目录移动会逐个改写其下文件的路径。
+func (fs *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldKey, newKey := cleanKey(oldName), cleanKey(newName)
+	if oldKey == "" || newKey == "" {
+		return os.ErrPermission
+	}
+	if strings.HasPrefix(newKey, oldKey+"/") {
+		return os.ErrInvalid
+	}
+
+	record, err := fs.find(ctx, oldKey)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		_, err := fs.files.RenameFile(ctx, record.ID, newKey)
+		return toOSError(err)
+	}
+
+	children, err := fs.list(ctx, repository.ListFilesParams{NamePrefix: oldKey + "/"})
+	if err != nil {
+		return err
+	}
+
+	owner := dlmiddleware.GetOwnerID(ctx)
+	fs.mu.Lock()
+	var movedDirs []string
+	for dir := range fs.dirs[owner] {
+		if dir == oldKey || strings.HasPrefix(dir, oldKey+"/") {
+			movedDirs = append(movedDirs, dir)
+		}
+	}
+	fs.mu.Unlock()
+
+	if len(children) == 0 && len(movedDirs) == 0 {
+		return os.ErrNotExist
+	}
+	for _, child := range children {
+		target := newKey + strings.TrimPrefix(child.OriginalName, oldKey)
+		if _, err := fs.files.RenameFile(ctx, child.ID, target); err != nil {
+			return toOSError(err)
+		}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, dir := range movedDirs {
+		delete(fs.dirs[owner], dir)
+		fs.dirs[owner][newKey+strings.TrimPrefix(dir, oldKey)] = struct{}{}
+	}
+	return nil
+}
+
+// Stat 返回文件或目录信息，路径不存在时返回 os.ErrNotExist。
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	key := cleanKey(name)
+	if key == "" {
+		return &dirInfo{name: "/"}, nil
+	}
+
+	record, err := fs.find(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		return &fileInfo{record: record}, nil
+	}
+
+	isDir, modTime, err := fs.dirStat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		return &dirInfo{name: path.Base(key), modTime: modTime}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// find 返回给定路径上最新的已存储文件，不存在时返回 nil。
+func (fs *FileSystem) find(ctx context.Context, key string) (*repository.FileRecord, error) {
+	records, err := fs.files.ListFiles(ctx, repository.ListFilesParams{
+		OwnerID:  dlmiddleware.GetOwnerID(ctx),
+		Name:     key,
+		Statuses: []repository.FileStatus{repository.FileStatusStored},
+		Limit:    1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+// list 翻页取回当前 owner 下满足条件的全部已存储文件。
+func (fs *FileSystem) list(ctx context.Context, params repository.ListFilesParams) ([]repository.FileRecord, error) {
+	params.OwnerID = dlmiddleware.GetOwnerID(ctx)
+	params.Statuses = []repository.FileStatus{repository.FileStatusStored}
+	params.Limit = listPageSize
+
+	var all []repository.FileRecord
+	for {
+		page, err := fs.files.ListFiles(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < listPageSize {
+			return all, nil
+		}
+		params.Offset += len(page)
+	}
+}
+
+// dirStat 判断 key 是否为目录（存在以其为前缀的文件，或是内存中的空目录）。
+func (fs *FileSystem) dirStat(ctx context.Context, key string) (bool, time.Time, error) {
+	if key == "" {
+		return true, time.Time{}, nil
+	}
+
+	records, err := fs.files.ListFiles(ctx, repository.ListFilesParams{
+		OwnerID:    dlmiddleware.GetOwnerID(ctx),
+		NamePrefix: key + "/",
+		Statuses:   []repository.FileStatus{repository.FileStatusStored},
+		Limit:      1,
+	})
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if len(records) > 0 {
+		return true, records[0].UpdatedAt, nil
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	_, ok := fs.dirs[dlmiddleware.GetOwnerID(ctx)][key]
+	return ok, time.Time{}, nil
+}
+
+func (fs *FileSystem) requireParent(ctx context.Context, key string) error {
+	parent := path.Dir(key)
+	if parent == "." {
+		return nil
+	}
+	isDir, _, err := fs.dirStat(ctx, parent)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// children 列出目录下的直接子项，同名文件只保留最新的一条。
+func (fs *FileSystem) children(ctx context.Context, key string) ([]os.FileInfo, error) {
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+	records, err := fs.list(ctx, repository.ListFilesParams{NamePrefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]os.FileInfo)
+	dirs := make(map[string]*dirInfo)
+	for i := range records {
+		rel := strings.TrimPrefix(records[i].OriginalName, prefix)
+		if rel == "" {
+			continue
+		}
+		if idx := strings.Index(rel, "/"); idx >= 0 {
+			name := rel[:idx]
+			if name == "" {
+				continue
+			}
+			if d, ok := dirs[name]; !ok {
+				dirs[name] = &dirInfo{name: name, modTime: records[i].UpdatedAt}
+			} else if records[i].UpdatedAt.After(d.modTime) {
+				d.modTime = records[i].UpdatedAt
+			}
+			continue
+		}
+		if _, ok := files[rel]; !ok {
+			files[rel] = &fileInfo{record: &records[i]}
+		}
+	}
+
+	fs.mu.Lock()
+	for dir := range fs.dirs[dlmiddleware.GetOwnerID(ctx)] {
+		parent := path.Dir(dir)
+		if parent == "." {
+			parent = ""
+		}
+		if parent == key {
+			name := path.Base(dir)
+			if _, ok := dirs[name]; !ok {
+				dirs[name] = &dirInfo{name: name}
+			}
+		}
+	}
+	fs.mu.Unlock()
+
+	infos := make([]os.FileInfo, 0, len(files)+len(dirs))
+	for name, d := range dirs {
+		if _, clash := files[name]; clash {
+			continue
+		}
+		infos = append(infos, d)
+	}
+	for _, f := range files {
+		infos = append(infos, f)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (fs *FileSystem) newUploadFile(ctx context.Context, key string) (*uploadFile, error) {
+	tmp, err := os.CreateTemp("", "droplite-dav-*")
+	if err != nil {
+		return nil, fmt.Errorf("create upload buffer: %w", err)
+	}
+	return &uploadFile{fs: fs, ctx: ctx, key: key, tmp: tmp, modTime: time.Now().UTC()}, nil
+}
+
+// cleanKey 将 WebDAV 路径规范化为不带前导斜杠的文件名，根目录返回空串。
+func cleanKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// toOSError 将服务层的 not_found 转为 os.ErrNotExist，webdav 据此返回 404。
+func toOSError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if service.ErrorKindOf(err) == service.KindNotFound {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// fileInfo 描述一个已存储的文件，并向 webdav 提供 Content-Type 与 ETag。
+type fileInfo struct {
+	record *repository.FileRecord
+}
+
+func (fi *fileInfo) Name() string       { return path.Base(fi.record.OriginalName) }
+func (fi *fileInfo) Size() int64        { return fi.record.SizeBytes }
+func (fi *fileInfo) Mode() os.FileMode  { return 0o644 }
+func (fi *fileInfo) ModTime() time.Time { return fi.record.UpdatedAt }
+func (fi *fileInfo) IsDir() bool        { return false }
+func (fi *fileInfo) Sys() any           { return nil }
+
+func (fi *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.record.MimeType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return fi.record.MimeType, nil
+}
+
+// ETag 使用文件 ID：覆盖写入总会生成新记录，因此内容变化时 ETag 必然变化。
+func (fi *fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.record.ID == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return fmt.Sprintf("%q", fi.record.ID), nil
+}
+
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (di *dirInfo) Name() string       { return di.name }
+func (di *dirInfo) Size() int64        { return 0 }
+func (di *dirInfo) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (di *dirInfo) ModTime() time.Time { return di.modTime }
+func (di *dirInfo) IsDir() bool        { return true }
+func (di *dirInfo) Sys() any           { return nil }
+
+var errNotSupported = errors.New("dav: operation not supported")
+
+// dirFile 是只读打开的目录。
+type dirFile struct {
+	fs      *FileSystem
+	ctx     context.Context
+	key     string
+	info    os.FileInfo
+	entries []os.FileInfo
+	loaded  bool
+}
+
+func (d *dirFile) Close() error                                 { return nil }
+func (d *dirFile) Read(p []byte) (int, error)                   { return 0, errNotSupported }
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) { return 0, errNotSupported }
+func (d *dirFile) Write(p []byte) (int, error)                  { return 0, errNotSupported }
+func (d *dirFile) Stat() (os.FileInfo, error)                   { return d.info, nil }
+
+// Readdir 遵循 os.File.Readdir 的 count 语义。
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.loaded {
+		entries, err := d.fs.children(d.ctx, d.key)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// readFile 是只读打开的文件，内容流由 FileService.OpenContent 延迟打开并支持 Seek。
+type readFile struct {
+	io.ReadSeekCloser
+	record *repository.FileRecord
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	n, err := f.ReadSeekCloser.Read(p)
+	return n, toOSError(err)
+}
+
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) { return nil, errNotSupported }
+func (f *readFile) Stat() (os.FileInfo, error)               { return &fileInfo{record: f.record}, nil }
+func (f *readFile) Write(p []byte) (int, error)              { return 0, errNotSupported }
+
+// uploadFile 将 PUT 内容缓冲到临时文件，Close 时一次性交给 FileService 登记。
+// 覆盖已有路径时先登记新记录，再软删除旧记录。
+type uploadFile struct {
+	fs      *FileSystem
+	ctx     context.Context
+	key     string
+	tmp     *os.File
+	size    int64
+	modTime time.Time
+}
+
+func (f *uploadFile) Write(p []byte) (int, error) {
+	if f.size+int64(len(p)) > f.fs.maxUploadSize {
+		return 0, service.NewError(service.KindPayloadTooLarge, fmt.Sprintf("file exceeds size limit (%d bytes)", f.fs.maxUploadSize))
+	}
+	n, err := f.tmp.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *uploadFile) Close() error {
+	defer func() {
+		_ = f.tmp.Close()
+		_ = os.Remove(f.tmp.Name())
+	}()
+
+	if f.size == 0 {
+		return service.NewError(service.KindValidation, "empty files are not supported")
+	}
+	mimeType, err := detectMimeType(f.key, f.tmp)
+	if err != nil {
+		return err
+	}
+
+	previous, err := f.fs.list(f.ctx, repository.ListFilesParams{Name: f.key})
+	if err != nil {
+		return err
+	}
+
+	created, err := f.fs.files.RegisterFile(f.ctx, service.RegisterFileInput{
+		OwnerID:      dlmiddleware.GetOwnerID(f.ctx),
+		OriginalName: f.key,
+		MimeType:     mimeType,
+		SizeBytes:    f.size,
+		Reader:       f.tmp,
+	})
+	if err != nil {
+		return err
+	}
+	dlmiddleware.SetAuditFileID(f.ctx, created.ID)
+
+	for _, record := range previous {
+		if err := f.fs.files.DeleteFile(f.ctx, record.ID); err != nil {
+			return err
+		}
+		dlmiddleware.AddAuditEvent(f.ctx, auth.AuditFileDelete, record.ID, auth.AuditSuccess)
+	}
+	return nil
+}
+
+func (f *uploadFile) Stat() (os.FileInfo, error) {
+	return &fileInfo{record: &repository.FileRecord{
+		OriginalName: f.key,
+		SizeBytes:    f.size,
+		UpdatedAt:    f.modTime,
+	}}, nil
+}
+
+func (f *uploadFile) Read(p []byte) (int, error)                   { return 0, errNotSupported }
+func (f *uploadFile) Seek(offset int64, whence int) (int64, error) { return 0, errNotSupported }
+func (f *uploadFile) Readdir(count int) ([]os.FileInfo, error)     { return nil, errNotSupported }
+
+// detectMimeType 优先按扩展名判断类型，否则与 REST 上传一致地探测内容，结束后将文件指针归零。
+func detectMimeType(name string, file *os.File) (string, error) {
+	if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+		_, err := file.Seek(0, io.SeekStart)
+		return byExt, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
@@ -0,0 +1,69 @@
+package dav
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/service"
+
+	"golang.org/x/net/webdav"
+)
+
+// Methods 是 WebDAV 在标准 HTTP 方法之外使用的扩展方法，路由需要预先注册它们。
+var Methods = []string{"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"}
+
+// methodAuditActions 是与 REST 文件端点对应的 WebDAV 方法的审计动作。
+var methodAuditActions = map[string]string{
+	http.MethodPut:    auth.AuditFileCreate,
+	http.MethodGet:    auth.AuditFileDownload,
+	http.MethodDelete: auth.AuditFileDelete,
+}
+
+// AuditMethods 按请求方法标注审计动作，文件 ID 由 FileSystem 在打开、登记或删除文件时写入。
+// 与 REST 路由上的 AuditAction 一样，应挂在鉴权之后、scope 检查之前。
+func AuditMethods(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if action, ok := methodAuditActions[r.Method]; ok {
+			dlmiddleware.SetAuditAction(r.Context(), action)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewHandler 返回挂载在 prefix 下的 WebDAV handler，文件按 context 中的 owner 隔离。
+// PUT 与 GET 经 transfers 做上传准入与按 owner 限速，transfers 为 nil 时不做限制。
+func NewHandler(files *service.FileService, maxUploadSize int64, prefix string, transfers *dlmiddleware.TransferLimiter) http.Handler {
+	h := &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: NewFileSystem(files, maxUploadSize),
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			// 客户端会频繁探测不存在的路径（如 ._ 资源文件），不记录 404
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("[dav] %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+
+	upload := transfers.AdmitUploads(maxUploadSize)(h)
+	download := transfers.ShapeDownloads()(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			if r.ContentLength > maxUploadSize {
+				http.Error(w, fmt.Sprintf("file exceeds size limit (%d bytes)", maxUploadSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+			upload.ServeHTTP(w, r)
+		case http.MethodGet:
+			download.ServeHTTP(w, r)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package dav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/repository/memrepo"
+	"droplite/internal/service"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *memrepo.FileRepository) {
+	t.Helper()
+
+	repo := memrepo.NewFileRepository()
+	files := service.NewFileService(repo, memrepo.NewStorage())
+	basicAuth := dlmiddleware.BasicAuth(dlmiddleware.NewAPIKeyAuthenticator([]string{"key-a", "key-b"}), "DropLite WebDAV")
+
+	srv := httptest.NewServer(basicAuth(NewHandler(files, 1024, "/dav", nil)))
+	t.Cleanup(srv.Close)
+	return srv, repo
+}
+
+func do(t *testing.T, srv *httptest.Server, key, method, path string, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	if key != "" {
+		req.SetBasicAuth("user", key)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func expectStatus(t *testing.T, resp *http.Response, body string, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		t.Fatalf("%s %s: expected %d, got %d: %s", resp.Request.Method, resp.Request.URL.Path, want, resp.StatusCode, body)
+	}
+}
+
+func TestHandler_FileLifecycle(t *testing.T) {
+	srv, repo := newTestServer(t)
+
+	resp, body := do(t, srv, "key-a", http.MethodPut, "/dav/docs/a.txt", "hello")
+	expectStatus(t, resp, body, http.StatusConflict)
+
+	resp, body = do(t, srv, "key-a", "MKCOL", "/dav/docs", "")
+	expectStatus(t, resp, body, http.StatusCreated)
+
+	resp, body = do(t, srv, "key-a", http.MethodPut, "/dav/docs/a.txt", "hello world")
+	expectStatus(t, resp, body, http.StatusCreated)
+	records := repo.Records()
+	if len(records) != 1 || records[0].OwnerID != "key-a" || records[0].OriginalName != "docs/a.txt" {
+		t.Fatalf("unexpected stored record: %+v", records)
+	}
+	if records[0].MimeType != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected mime type %q", records[0].MimeType)
+	}
+
+	resp, body = do(t, srv, "key-a", "PROPFIND", "/dav/", "", "Depth", "1")
+	expectStatus(t, resp, body, http.StatusMultiStatus)
+	if !strings.Contains(body, "<D:href>/dav/docs/</D:href>") {
+		t.Fatalf("expected docs collection in listing: %s", body)
+	}
+
+	resp, body = do(t, srv, "key-a", "PROPFIND", "/dav/docs/", "", "Depth", "1")
+	expectStatus(t, resp, body, http.StatusMultiStatus)
+	if !strings.Contains(body, "/dav/docs/a.txt") || !strings.Contains(body, "<D:getcontentlength>11</D:getcontentlength>") {
+		t.Fatalf("expected a.txt in listing: %s", body)
+	}
+
+	resp, body = do(t, srv, "key-a", http.MethodGet, "/dav/docs/a.txt", "", "Range", "bytes=6-")
+	expectStatus(t, resp, body, http.StatusPartialContent)
+	if body != "world" {
+		t.Fatalf("unexpected range body %q", body)
+	}
+
+	resp, body = do(t, srv, "key-a", "MOVE", "/dav/docs/a.txt", "", "Destination", srv.URL+"/dav/docs/b.txt")
+	expectStatus(t, resp, body, http.StatusCreated)
+	resp, body = do(t, srv, "key-a", http.MethodGet, "/dav/docs/a.txt", "")
+	expectStatus(t, resp, body, http.StatusNotFound)
+	resp, body = do(t, srv, "key-a", http.MethodGet, "/dav/docs/b.txt", "")
+	expectStatus(t, resp, body, http.StatusOK)
+	if body != "hello world" {
+		t.Fatalf("unexpected body after move %q", body)
+	}
+
+	// 覆盖写入生成新记录，旧记录被软删除
+	resp, body = do(t, srv, "key-a", http.MethodPut, "/dav/docs/b.txt", "replaced")
+	expectStatus(t, resp, body, http.StatusCreated)
+	if first := repo.Records()[0]; first.Status != repository.FileStatusDeleted {
+		t.Fatalf("expected overwritten record to be deleted, got %s", first.Status)
+	}
+
+	resp, body = do(t, srv, "key-a", http.MethodDelete, "/dav/docs", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+	resp, body = do(t, srv, "key-a", "PROPFIND", "/dav/docs/", "", "Depth", "0")
+	expectStatus(t, resp, body, http.StatusNotFound)
+}
+
+// recordingAuditor 保存写入的审计记录。
+type recordingAuditor struct {
+	mu     sync.Mutex
+	events []auth.AuditEvent
+}
+
+func (a *recordingAuditor) RecordAudit(ctx context.Context, event auth.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, event)
+}
+
+func TestHandler_AuditsFileOperations(t *testing.T) {
+	repo := memrepo.NewFileRepository()
+	audit := &recordingAuditor{}
+	files := service.NewFileService(repo, memrepo.NewStorage())
+	basicAuth := dlmiddleware.BasicAuth(dlmiddleware.NewAPIKeyAuthenticator([]string{"key-a"}), "DropLite WebDAV")
+	srv := httptest.NewServer(dlmiddleware.AuditTrail(audit)(basicAuth(AuditMethods(NewHandler(files, 1024, "/dav", nil)))))
+	t.Cleanup(srv.Close)
+
+	resp, body := do(t, srv, "key-a", "MKCOL", "/dav/docs", "")
+	expectStatus(t, resp, body, http.StatusCreated)
+	for _, content := range []string{"v1", "v2"} {
+		resp, body = do(t, srv, "key-a", http.MethodPut, "/dav/docs/a.txt", content)
+		expectStatus(t, resp, body, http.StatusCreated)
+	}
+	resp, body = do(t, srv, "key-a", http.MethodGet, "/dav/docs/a.txt", "")
+	expectStatus(t, resp, body, http.StatusOK)
+	resp, body = do(t, srv, "key-a", http.MethodPut, "/dav/docs/b.txt", "b")
+	expectStatus(t, resp, body, http.StatusCreated)
+	resp, body = do(t, srv, "key-a", http.MethodDelete, "/dav/docs", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+	resp, body = do(t, srv, "wrong", http.MethodGet, "/dav/docs/a.txt", "")
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+
+	records := repo.Records()
+	a1, a2, b := records[0].ID, records[1].ID, records[2].ID
+	want := []auth.AuditEvent{
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileCreate, FileID: a1, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileCreate, FileID: a2, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: a1, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDownload, FileID: a2, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileCreate, FileID: b, Outcome: auth.AuditSuccess},
+		// 删除目录时逐个记录其下的文件
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: b, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: a2, Outcome: auth.AuditSuccess},
+		{Action: auth.AuditAuthFailure, Outcome: auth.AuditDenied},
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if len(audit.events) != len(want) {
+		t.Fatalf("expected %d audit events, got %+v", len(want), audit.events)
+	}
+	for i, got := range audit.events {
+		if got.Actor != want[i].Actor || got.Action != want[i].Action || got.FileID != want[i].FileID || got.Outcome != want[i].Outcome {
+			t.Fatalf("event %d: expected %+v, got %+v", i, want[i], got)
+		}
+		// 静态 Key 以派生的 Key ID 记录，不写入原值
+		if keyID, _ := auth.DeriveKeyCredentials("key-a"); want[i].Actor != "" && got.KeyID != keyID {
+			t.Fatalf("event %d: expected key id %s, got %+v", i, keyID, got)
+		}
+	}
+}
+
+func TestHandler_ScopedByOwner(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp, body := do(t, srv, "key-a", http.MethodPut, "/dav/private.txt", "secret")
+	expectStatus(t, resp, body, http.StatusCreated)
+
+	resp, body = do(t, srv, "key-b", http.MethodGet, "/dav/private.txt", "")
+	expectStatus(t, resp, body, http.StatusNotFound)
+
+	resp, body = do(t, srv, "key-b", "PROPFIND", "/dav/", "", "Depth", "1")
+	expectStatus(t, resp, body, http.StatusMultiStatus)
+	if strings.Contains(body, "private.txt") {
+		t.Fatalf("other owner's file leaked into listing: %s", body)
+	}
+}
+
+func TestHandler_RequiresBasicAuthAndSizeLimit(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp, body := do(t, srv, "", "PROPFIND", "/dav/", "", "Depth", "0")
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+	if !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Basic ") {
+		t.Fatalf("expected Basic challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+	}
+
+	resp, body = do(t, srv, "wrong", http.MethodGet, "/dav/", "")
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+
+	resp, body = do(t, srv, "key-a", http.MethodPut, "/dav/big.bin", strings.Repeat("x", 2048))
+	expectStatus(t, resp, body, http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,49 @@
+package grpcapi
+
+import (
+	"log"
+
+	"droplite/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codeForKind 将服务层错误码映射为 gRPC 状态码。
+func codeForKind(kind service.ErrorKind) codes.Code {
+	switch kind {
+	case service.KindValidation:
+		return codes.InvalidArgument
+	case service.KindUnauthorized:
+		return codes.Unauthenticated
+	case service.KindForbidden:
+		return codes.PermissionDenied
+	case service.KindNotFound:
+		return codes.NotFound
+	case service.KindConflict:
+		return codes.AlreadyExists
+	case service.KindPayloadTooLarge, service.KindQuotaExceeded, service.KindRateLimited:
+		return codes.ResourceExhausted
+	case service.KindStorageUnavailable, service.KindDatabaseUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// toStatus 将服务层错误转换为 gRPC status，服务端错误只返回概要信息。
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	svcErr := service.AsError(err)
+	code := codeForKind(svcErr.Kind)
+	if code == codes.Internal || code == codes.Unavailable {
+		log.Printf("[grpc] %s: %v", svcErr.Kind, err)
+	}
+	return status.Error(code, svcErr.Message)
+}
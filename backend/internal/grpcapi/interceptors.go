@@ -0,0 +1,177 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	droplitev1 "droplite/pkg/pb/droplite/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes 是各 RPC 需要的 scope，未列出的方法一律拒绝。
+var methodScopes = map[string]string{
+	droplitev1.FileService_Upload_FullMethodName:   auth.ScopeFilesWrite,
+	droplitev1.FileService_Download_FullMethodName: auth.ScopeFilesRead,
+	droplitev1.FileService_Get_FullMethodName:      auth.ScopeFilesRead,
+	droplitev1.FileService_List_FullMethodName:     auth.ScopeFilesRead,
+	droplitev1.FileService_Delete_FullMethodName:   auth.ScopeFilesDelete,
+}
+
+// methodAuditActions 是各 RPC 的审计动作，与 REST 端点一致；未列出的方法只记录鉴权失败。
+var methodAuditActions = map[string]string{
+	droplitev1.FileService_Upload_FullMethodName:   auth.AuditFileCreate,
+	droplitev1.FileService_Download_FullMethodName: auth.AuditFileDownload,
+	droplitev1.FileService_Delete_FullMethodName:   auth.AuditFileDelete,
+}
+
+// UnaryAuditInterceptor 在调用结束后写入审计记录，须排在鉴权拦截器之前。
+func UnaryAuditInterceptor(recorder auth.AuditRecorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		auditCtx, finish := dlmiddleware.StartAudit(ctx, recorder, auditBase(ctx, info.FullMethod))
+		resp, err := handler(auditCtx, req)
+		finish(httpStatusForCode(status.Code(err)))
+		return resp, err
+	}
+}
+
+// StreamAuditInterceptor 对流式调用写入审计记录。
+func StreamAuditInterceptor(recorder auth.AuditRecorder) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		auditCtx, finish := dlmiddleware.StartAudit(ss.Context(), recorder, auditBase(ss.Context(), info.FullMethod))
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: auditCtx})
+		finish(httpStatusForCode(status.Code(err)))
+		return err
+	}
+}
+
+// auditBase 从连接与 metadata 中取出审计记录的公共字段，请求 ID 取自客户端传入的 "x-request-id"。
+func auditBase(ctx context.Context, method string) auth.AuditEvent {
+	base := auth.AuditEvent{Action: methodAuditActions[method]}
+	if addr := peerAddr(ctx); addr.IsValid() {
+		base.IP = addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			base.UserAgent = values[0]
+		}
+		if values := md.Get("x-request-id"); len(values) > 0 {
+			base.RequestID = values[0]
+		}
+	}
+	return base
+}
+
+// httpStatusForCode 将 gRPC 状态码映射为审计记录使用的 HTTP 状态码。
+func httpStatusForCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled, codes.DeadlineExceeded:
+		return http.StatusRequestTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// UnaryAuthInterceptor 对一元调用执行与 HTTP 中间件相同的鉴权与 scope 检查，并将 owner ID 写入 context。
+func UnaryAuthInterceptor(authenticator dlmiddleware.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		authCtx, err := authenticate(ctx, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(authCtx, req)
+	}
+}
+
+// StreamAuthInterceptor 对流式调用执行鉴权。
+func StreamAuthInterceptor(authenticator dlmiddleware.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		authCtx, err := authenticate(ss.Context(), authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: authCtx})
+	}
+}
+
+// authenticate 从 metadata "authorization" 读取凭证，格式与 HTTP Authorization 头一致。
+func authenticate(ctx context.Context, authenticator dlmiddleware.Authenticator, method string) (context.Context, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	principal, err := authenticator.Authenticate(ctx, header)
+	if err != nil {
+		message := "unauthorized"
+		var authErr *dlmiddleware.AuthError
+		if errors.As(err, &authErr) {
+			message = authErr.Message
+		}
+		return nil, status.Error(codes.Unauthenticated, message)
+	}
+	// gRPC 端口不经过反向代理，直接使用连接的对端地址
+	if addr := peerAddr(ctx); !principal.Networks.Permits(addr) {
+		dlmiddleware.DenyNetwork(ctx, principal, "grpc")
+		return nil, status.Error(codes.PermissionDenied, auth.NetworkDeniedMessage(addr))
+	}
+	scope, ok := methodScopes[method]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "method not permitted")
+	}
+	if !principal.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "missing required scope "+scope)
+	}
+	return dlmiddleware.WithPrincipal(ctx, principal), nil
+}
+
+// peerAddr 返回调用方的 IP，无法确定时返回无效地址。
+func peerAddr(ctx context.Context) netip.Addr {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}
+	}
+	addrPort, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+// contextStream 以 ctx 替换流的 context。
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
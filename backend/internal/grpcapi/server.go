@@ -0,0 +1,344 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+	droplitev1 "droplite/pkg/pb/droplite/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// downloadChunkSize 是 Download 每条流消息携带的最大字节数。
+const downloadChunkSize = 64 * 1024
+
+// Server 基于 service.FileService 实现 droplite.v1.FileService。
+type Server struct {
+	droplitev1.UnimplementedFileServiceServer
+
+	files         *service.FileService
+	maxUploadSize int64
+	transfers     *dlmiddleware.TransferLimiter
+	audit         auth.AuditRecorder
+}
+
+func NewServer(files *service.FileService, maxUploadSize int64) *Server {
+	return &Server{files: files, maxUploadSize: maxUploadSize}
+}
+
+// SetTransferLimiter 设置与 HTTP 入口共享的上传准入与按 owner 限速，未设置时不做限制。
+func (s *Server) SetTransferLimiter(l *dlmiddleware.TransferLimiter) {
+	s.transfers = l
+}
+
+// SetAuditRecorder 设置审计日志，文件的上传、下载、删除与鉴权失败按 HTTP 入口的格式记录，未设置时不记录。
+// 须在 NewGRPCServer 之前调用。
+func (s *Server) SetAuditRecorder(r auth.AuditRecorder) {
+	s.audit = r
+}
+
+// NewGRPCServer 创建注册了文件服务的 *grpc.Server，auth 为 nil 时不做鉴权（开发模式）。
+func NewGRPCServer(srv *Server, auth dlmiddleware.Authenticator, opts ...grpc.ServerOption) *grpc.Server {
+	// 审计在鉴权之外，鉴权失败同样记录
+	if srv.audit != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryAuditInterceptor(srv.audit)),
+			grpc.ChainStreamInterceptor(StreamAuditInterceptor(srv.audit)),
+		)
+	}
+	if auth != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryAuthInterceptor(auth)),
+			grpc.ChainStreamInterceptor(StreamAuthInterceptor(auth)),
+		)
+	}
+	gs := grpc.NewServer(opts...)
+	droplitev1.RegisterFileServiceServer(gs, srv)
+	return gs
+}
+
+// Upload 接收首条元数据消息与后续分片，流式写入存储后登记文件。
+func (s *Server) Upload(stream grpc.ClientStreamingServer[droplitev1.UploadRequest, droplitev1.UploadResponse]) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return toStatus(service.NewError(service.KindValidation, "upload metadata is required"))
+		}
+		return err
+	}
+	meta := first.GetMetadata()
+	if meta == nil {
+		return toStatus(service.NewError(service.KindValidation, "first upload message must carry metadata"))
+	}
+	if meta.GetSizeBytes() <= 0 {
+		return toStatus(service.NewError(service.KindValidation, "size_bytes must be positive"))
+	}
+	if meta.GetSizeBytes() > s.maxUploadSize {
+		return toStatus(service.NewError(service.KindPayloadTooLarge, fmt.Sprintf("file exceeds size limit (%d bytes)", s.maxUploadSize)))
+	}
+
+	// 与 HTTP 上传共用准入名额，按声明的 size_bytes 预留
+	key := dlmiddleware.TransferKey(ctx, peerAddr(ctx))
+	release, err := s.transfers.Acquire(ctx, key, meta.GetSizeBytes())
+	if err != nil {
+		if errors.Is(err, dlmiddleware.ErrUploadsBusy) {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return status.FromContextError(err).Err()
+	}
+	defer release()
+
+	reader := &uploadReader{stream: stream, remaining: meta.GetSizeBytes()}
+
+	mimeType := strings.TrimSpace(meta.GetMimeType())
+	if mimeType == "" {
+		// 与 REST 上传一致，根据首个分片探测 MIME
+		head, err := reader.peek(512)
+		if err != nil {
+			return toStatus(err)
+		}
+		mimeType = http.DetectContentType(head)
+	}
+	body, doneReading := s.transfers.ShapeReader(ctx, key, io.NopCloser(reader))
+	defer doneReading()
+
+	input := service.RegisterFileInput{
+		OwnerID:      dlmiddleware.GetOwnerID(ctx),
+		OriginalName: strings.TrimSpace(meta.GetOriginalName()),
+		MimeType:     mimeType,
+		SizeBytes:    meta.GetSizeBytes(),
+		Checksum:     meta.Checksum,
+		Metadata:     meta.GetMetadata().AsMap(),
+		Reader:       body,
+	}
+	if meta.GetExpiresAt() != nil {
+		expiresAt := meta.GetExpiresAt().AsTime()
+		input.ExpiresAt = &expiresAt
+	}
+
+	record, err := s.files.RegisterFile(ctx, input)
+	if err != nil {
+		return toStatus(err)
+	}
+	dlmiddleware.SetAuditFileID(ctx, record.ID)
+
+	file, err := toProtoFile(record)
+	if err != nil {
+		return toStatus(err)
+	}
+	return stream.SendAndClose(&droplitev1.UploadResponse{File: file})
+}
+
+// Download 先发送文件元数据，再按块发送文件内容。
+func (s *Server) Download(req *droplitev1.DownloadRequest, stream grpc.ServerStreamingServer[droplitev1.DownloadResponse]) error {
+	ctx := stream.Context()
+	dlmiddleware.SetAuditFileID(ctx, req.GetId())
+
+	record, err := s.files.GetOwnedFile(ctx, req.GetId(), dlmiddleware.GetOwnerID(ctx))
+	if err != nil {
+		return toStatus(err)
+	}
+	if record.Status != repository.FileStatusStored {
+		return toStatus(service.NewError(service.KindNotFound, "file not available for download"))
+	}
+
+	content, err := s.files.GetFileContent(ctx, record.StoragePath)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer content.Close()
+
+	file, err := toProtoFile(record)
+	if err != nil {
+		return toStatus(err)
+	}
+	if err := stream.Send(&droplitev1.DownloadResponse{Payload: &droplitev1.DownloadResponse_File{File: file}}); err != nil {
+		return err
+	}
+
+	out, doneWriting := s.transfers.ShapeWriter(ctx, dlmiddleware.TransferKey(ctx, peerAddr(ctx)), chunkSender{stream: stream})
+	defer doneWriting()
+
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			if _, sendErr := out.Write(buf[:n]); sendErr != nil {
+				return sendErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return toStatus(service.WrapError(service.KindStorageUnavailable, "failed to read file", err))
+		}
+	}
+}
+
+// Get 返回单个文件的元数据。
+func (s *Server) Get(ctx context.Context, req *droplitev1.GetRequest) (*droplitev1.GetResponse, error) {
+	record, err := s.files.GetOwnedFile(ctx, req.GetId(), dlmiddleware.GetOwnerID(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	file, err := toProtoFile(record)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &droplitev1.GetResponse{File: file}, nil
+}
+
+// List 分页列出调用方 owner 的文件。
+func (s *Server) List(ctx context.Context, req *droplitev1.ListRequest) (*droplitev1.ListResponse, error) {
+	params := repository.ListFilesParams{
+		OwnerID: dlmiddleware.GetOwnerID(ctx),
+		Limit:   int(req.GetLimit()),
+		Offset:  int(req.GetOffset()),
+	}
+	for _, raw := range req.GetStatuses() {
+		if trimmed := strings.TrimSpace(raw); trimmed != "" {
+			params.Statuses = append(params.Statuses, repository.FileStatus(trimmed))
+		}
+	}
+
+	records, err := s.files.ListFiles(ctx, params)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &droplitev1.ListResponse{Files: make([]*droplitev1.File, 0, len(records))}
+	for i := range records {
+		file, err := toProtoFile(&records[i])
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		resp.Files = append(resp.Files, file)
+	}
+	return resp, nil
+}
+
+// Delete 软删除文件。
+func (s *Server) Delete(ctx context.Context, req *droplitev1.DeleteRequest) (*droplitev1.DeleteResponse, error) {
+	if req.GetId() == "" {
+		return nil, toStatus(service.NewError(service.KindValidation, "file id is required"))
+	}
+	dlmiddleware.SetAuditFileID(ctx, req.GetId())
+	if err := s.files.DeleteOwnedFile(ctx, req.GetId(), dlmiddleware.GetOwnerID(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &droplitev1.DeleteResponse{Id: req.GetId(), Deleted: true}, nil
+}
+
+// uploadReader 将客户端流中的分片适配为 io.Reader，并校验总字节数与声明一致。
+// chunkSender 把每次写入作为一条 Download 分片消息发送。
+type chunkSender struct {
+	stream grpc.ServerStreamingServer[droplitev1.DownloadResponse]
+}
+
+func (c chunkSender) Write(p []byte) (int, error) {
+	chunk := bytes.Clone(p)
+	if err := c.stream.Send(&droplitev1.DownloadResponse{Payload: &droplitev1.DownloadResponse_Chunk{Chunk: chunk}}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type uploadReader struct {
+	stream    grpc.ClientStreamingServer[droplitev1.UploadRequest, droplitev1.UploadResponse]
+	pending   []byte
+	remaining int64
+	done      bool
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			if r.remaining > 0 {
+				return 0, service.NewError(service.KindValidation, fmt.Sprintf("upload ended %d bytes short of size_bytes", r.remaining))
+			}
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// peek 返回至多 n 个尚未读取的字节，不会消费它们。
+func (r *uploadReader) peek(n int) ([]byte, error) {
+	for len(r.pending) < n && !r.done {
+		if err := r.fill(); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.pending) < n {
+		n = len(r.pending)
+	}
+	return bytes.Clone(r.pending[:n]), nil
+}
+
+func (r *uploadReader) fill() error {
+	msg, err := r.stream.Recv()
+	if errors.Is(err, io.EOF) {
+		r.done = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	chunk := msg.GetChunk()
+	if msg.GetMetadata() != nil {
+		return service.NewError(service.KindValidation, "metadata may only be sent in the first upload message")
+	}
+	r.remaining -= int64(len(chunk))
+	if r.remaining < 0 {
+		return service.NewError(service.KindValidation, "upload exceeds declared size_bytes")
+	}
+	r.pending = append(r.pending, chunk...)
+	return nil
+}
+
+func toProtoFile(record *repository.FileRecord) (*droplitev1.File, error) {
+	metadata, err := structpb.NewStruct(record.Metadata)
+	if err != nil {
+		return nil, service.WrapError(service.KindInternal, "encode metadata", err)
+	}
+
+	file := &droplitev1.File{
+		Id:           record.ID,
+		OriginalName: record.OriginalName,
+		MimeType:     record.MimeType,
+		SizeBytes:    record.SizeBytes,
+		StoragePath:  record.StoragePath,
+		Checksum:     record.Checksum,
+		Status:       string(record.Status),
+		Metadata:     metadata,
+		CreatedAt:    timestamppb.New(record.CreatedAt),
+		UpdatedAt:    timestamppb.New(record.UpdatedAt),
+	}
+	if record.ExpiresAt != nil {
+		file.ExpiresAt = timestamppb.New(*record.ExpiresAt)
+	}
+	return file, nil
+}
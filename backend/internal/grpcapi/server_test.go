@@ -0,0 +1,353 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/repository/memrepo"
+	"droplite/internal/service"
+	droplitev1 "droplite/pkg/pb/droplite/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, auth dlmiddleware.Authenticator) (droplitev1.FileServiceClient, *memrepo.FileRepository) {
+	return newTestClientWith(t, auth, nil)
+}
+
+// newTestClientWith 与 newTestClient 相同，configure 不为 nil 时在创建 gRPC 服务前调整服务端设置。
+func newTestClientWith(t *testing.T, auth dlmiddleware.Authenticator, configure func(*Server)) (droplitev1.FileServiceClient, *memrepo.FileRepository) {
+	t.Helper()
+
+	repo := memrepo.NewFileRepository()
+	store := memrepo.NewStorage()
+	srv := NewServer(service.NewFileService(repo, store), 1024*1024)
+	if configure != nil {
+		configure(srv)
+	}
+	gs := NewGRPCServer(srv, auth)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return droplitev1.NewFileServiceClient(conn), repo
+}
+
+func upload(ctx context.Context, client droplitev1.FileServiceClient, declared int64, chunks ...[]byte) (*droplitev1.File, error) {
+	stream, err := client.Upload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&droplitev1.UploadRequest{Payload: &droplitev1.UploadRequest_Metadata{
+		Metadata: &droplitev1.UploadMetadata{OriginalName: "hello.txt", SizeBytes: declared},
+	}}); err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		if err := stream.Send(&droplitev1.UploadRequest{Payload: &droplitev1.UploadRequest_Chunk{Chunk: chunk}}); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetFile(), nil
+}
+
+func TestServer_UploadDownloadRoundTrip(t *testing.T) {
+	auth := dlmiddleware.NewAPIKeyAuthenticator([]string{"secret"})
+	client, repo := newTestClient(t, auth)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey secret")
+
+	file, err := upload(ctx, client, 11, []byte("hello "), []byte("world"))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if file.GetStatus() != string(repository.FileStatusStored) || file.GetSizeBytes() != 11 {
+		t.Fatalf("unexpected uploaded file: %+v", file)
+	}
+	if file.GetMimeType() != "text/plain; charset=utf-8" {
+		t.Fatalf("expected sniffed mime type, got %q", file.GetMimeType())
+	}
+	if owner := repo.Records()[0].OwnerID; owner != "secret" {
+		t.Fatalf("expected owner from interceptor, got %q", owner)
+	}
+
+	stream, err := client.Download(ctx, &droplitev1.DownloadRequest{Id: file.GetId()})
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	first, err := stream.Recv()
+	if err != nil || first.GetFile().GetId() != file.GetId() {
+		t.Fatalf("expected file header first, got %+v (%v)", first, err)
+	}
+	var content bytes.Buffer
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv chunk: %v", err)
+		}
+		content.Write(msg.GetChunk())
+	}
+	if content.String() != "hello world" {
+		t.Fatalf("unexpected content %q", content.String())
+	}
+
+	if _, err := client.Delete(ctx, &droplitev1.DeleteRequest{Id: file.GetId()}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	list, err := client.List(ctx, &droplitev1.ListRequest{})
+	if err != nil || len(list.GetFiles()) != 0 {
+		t.Fatalf("expected empty list after delete, got %+v (%v)", list, err)
+	}
+}
+
+func TestServer_UploadRejectsSizeMismatch(t *testing.T) {
+	client, _ := newTestClient(t, nil)
+
+	_, err := upload(context.Background(), client, 4, []byte("too long"))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestServer_UploadSharesAdmissionLimits(t *testing.T) {
+	transfers := dlmiddleware.NewTransferLimiter(dlmiddleware.TransferLimits{MaxConcurrent: 1})
+	client, repo := newTestClientWith(t, nil, func(s *Server) { s.SetTransferLimiter(transfers) })
+
+	// 名额被其他入口的上传占用时立即拒绝
+	release, err := transfers.Acquire(context.Background(), "owner:other", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload(context.Background(), client, 5, []byte("hello")); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	release()
+	if _, err := upload(context.Background(), client, 5, []byte("hello")); err != nil {
+		t.Fatalf("expected upload after release to succeed, got %v", err)
+	}
+	if records := repo.Records(); len(records) != 1 {
+		t.Fatalf("expected one stored file, got %d", len(records))
+	}
+}
+
+func TestServer_ScopedToOwner(t *testing.T) {
+	client, repo := newTestClient(t, dlmiddleware.NewAPIKeyAuthenticator([]string{"secret", "other"}))
+	ownerCtx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey secret")
+	otherCtx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey other")
+
+	file, err := upload(ownerCtx, client, 5, []byte("hello"))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	// 其他 owner 的文件与不存在的文件一样返回 NotFound
+	if _, err := client.Get(otherCtx, &droplitev1.GetRequest{Id: file.GetId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for get, got %v", err)
+	}
+	stream, err := client.Download(otherCtx, &droplitev1.DownloadRequest{Id: file.GetId()})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for download, got %v", err)
+	}
+	if _, err := client.Delete(otherCtx, &droplitev1.DeleteRequest{Id: file.GetId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for delete, got %v", err)
+	}
+	if rec, err := repo.GetByID(context.Background(), file.GetId()); err != nil || rec.Status != repository.FileStatusStored {
+		t.Fatalf("expected file to survive another owner's delete, got %+v, %v", rec, err)
+	}
+	list, err := client.List(otherCtx, &droplitev1.ListRequest{})
+	if err != nil || len(list.GetFiles()) != 0 {
+		t.Fatalf("expected another owner's list to be empty, got %+v (%v)", list, err)
+	}
+
+	if _, err := client.Get(ownerCtx, &droplitev1.GetRequest{Id: file.GetId()}); err != nil {
+		t.Fatalf("expected owner to read file, got %v", err)
+	}
+}
+
+// recordingAuditor 保存写入的审计记录。
+type recordingAuditor struct {
+	mu     sync.Mutex
+	events []auth.AuditEvent
+}
+
+func (a *recordingAuditor) RecordAudit(ctx context.Context, event auth.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, event)
+}
+
+func TestServer_AuditsFileOperations(t *testing.T) {
+	audit := &recordingAuditor{}
+	client, _ := newTestClientWith(t, dlmiddleware.NewAPIKeyAuthenticator([]string{"secret"}), func(s *Server) { s.SetAuditRecorder(audit) })
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey secret", "x-request-id", "req-1")
+
+	file, err := upload(ctx, client, 5, []byte("hello"))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	stream, err := client.Download(ctx, &droplitev1.DownloadRequest{Id: file.GetId()})
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+	}
+	// 列表与查看不记录
+	if _, err := client.Get(ctx, &droplitev1.GetRequest{Id: file.GetId()}); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err := client.Delete(ctx, &droplitev1.DeleteRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	if _, err := client.Delete(ctx, &droplitev1.DeleteRequest{Id: file.GetId()}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := client.List(context.Background(), &droplitev1.ListRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	want := []auth.AuditEvent{
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileCreate, FileID: file.GetId(), Status: http.StatusOK, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDownload, FileID: file.GetId(), Status: http.StatusOK, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: "missing", Status: http.StatusNotFound, Outcome: auth.AuditFailure},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: file.GetId(), Status: http.StatusOK, Outcome: auth.AuditSuccess},
+		{Action: auth.AuditAuthFailure, Status: http.StatusUnauthorized, Outcome: auth.AuditDenied},
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if len(audit.events) != len(want) {
+		t.Fatalf("expected %d audit events, got %+v", len(want), audit.events)
+	}
+	for i, got := range audit.events {
+		if got.Actor != want[i].Actor || got.Action != want[i].Action || got.FileID != want[i].FileID ||
+			got.Status != want[i].Status || got.Outcome != want[i].Outcome {
+			t.Fatalf("event %d: expected %+v, got %+v", i, want[i], got)
+		}
+		// 静态 Key 以派生的 Key ID 记录，不写入原值
+		if keyID, _ := auth.DeriveKeyCredentials("secret"); want[i].Actor != "" && got.KeyID != keyID {
+			t.Fatalf("event %d: expected key id %s, got %+v", i, keyID, got)
+		}
+		// bufconn 的对端地址不是 IP，这里只检查 User-Agent
+		if !strings.HasPrefix(got.UserAgent, "grpc-go/") {
+			t.Fatalf("event %d: missing user agent: %+v", i, got)
+		}
+	}
+	if audit.events[0].RequestID != "req-1" {
+		t.Fatalf("expected request id from metadata, got %q", audit.events[0].RequestID)
+	}
+}
+
+// networkVerifier 模拟只允许 10.0.0.0/8 使用的数据库 Key。
+type networkVerifier struct{}
+
+func (networkVerifier) VerifyAPIKey(ctx context.Context, key string) (auth.Principal, error) {
+	if key != "dl_ci_key" {
+		return auth.Principal{}, errors.New("unknown key")
+	}
+	return auth.Principal{
+		OwnerID:  "ci",
+		KeyID:    "key-1",
+		Scopes:   []string{auth.ScopeFilesRead},
+		Networks: auth.NetworkPolicy{Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+	}, nil
+}
+
+func TestServer_AuditsNetworkDenials(t *testing.T) {
+	audit := &recordingAuditor{}
+	client, _ := newTestClientWith(t, dlmiddleware.NewStoredAPIKeyAuthenticator(nil, networkVerifier{}), func(s *Server) { s.SetAuditRecorder(audit) })
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey dl_ci_key")
+
+	if _, err := client.List(ctx, &droplitev1.ListRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if len(audit.events) != 1 {
+		t.Fatalf("expected one audit event, got %+v", audit.events)
+	}
+	got := audit.events[0]
+	if got.Action != auth.AuditNetworkDenied || got.Actor != "ci" || got.KeyID != "key-1" ||
+		got.Outcome != auth.AuditDenied || got.Status != http.StatusForbidden {
+		t.Fatalf("unexpected audit event %+v", got)
+	}
+}
+
+func TestServer_AuthInterceptorAndErrorMapping(t *testing.T) {
+	client, _ := newTestClient(t, dlmiddleware.NewAPIKeyAuthenticator([]string{"secret"}))
+
+	_, err := client.Get(context.Background(), &droplitev1.GetRequest{Id: "x"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without credentials, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey secret")
+	_, err = client.Get(ctx, &droplitev1.GetRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+// readOnlyVerifier 模拟只有 files:read 的数据库 Key。
+type readOnlyVerifier struct{}
+
+func (readOnlyVerifier) VerifyAPIKey(ctx context.Context, key string) (auth.Principal, error) {
+	if key != "dl_reader_key" {
+		return auth.Principal{}, errors.New("unknown key")
+	}
+	return auth.Principal{OwnerID: "reader", Scopes: []string{auth.ScopeFilesRead}}, nil
+}
+
+func TestServer_AuthInterceptorEnforcesScopes(t *testing.T) {
+	client, _ := newTestClient(t, dlmiddleware.NewStoredAPIKeyAuthenticator(nil, readOnlyVerifier{}))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey dl_reader_key")
+
+	if _, err := client.List(ctx, &droplitev1.ListRequest{}); err != nil {
+		t.Fatalf("expected list to be allowed, got %v", err)
+	}
+	if _, err := client.Delete(ctx, &droplitev1.DeleteRequest{Id: "x"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for delete, got %v", err)
+	}
+	if _, err := upload(ctx, client, 2, []byte("hi")); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for upload, got %v", err)
+	}
+}
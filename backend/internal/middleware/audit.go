@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"droplite/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+type auditContextKey struct{}
+
+// auditState 在一次请求内收集审计信息：鉴权成功后由 WithPrincipal 写入调用方，路由上的 AuditAction 写入动作。
+type auditState struct {
+	mu     sync.Mutex
+	actor  string
+	keyID  string
+	action string
+	fileID string
+	extra  []auth.AuditEvent
+}
+
+// AuditTrail 在响应结束后写入审计记录：标注了 AuditAction 的端点每次请求记录一条，
+// 其余端点只记录 401/403。须挂在 RequestID 与 RealIP 之后；recorder 为 nil 时不记录。
+func AuditTrail(recorder auth.AuditRecorder) func(http.Handler) http.Handler {
+	if recorder == nil {
+		return passthrough
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := &auditState{}
+			rw := newResponseWriter(w)
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, state)))
+
+			state.emit(r.Context(), recorder, auth.AuditEvent{
+				OccurredAt: time.Now().UTC(),
+				IP:         clientIP(r),
+				UserAgent:  r.UserAgent(),
+				RequestID:  chimiddleware.GetReqID(r.Context()),
+			}, rw.statusCode)
+		})
+	}
+}
+
+// StartAudit 供不经过 HTTP 中间件的入口（gRPC）使用，与 AuditTrail 记录相同的审计信息：
+// 返回携带审计状态的 context，调用结束后以对应的 HTTP 状态码调用 finish 写入记录。
+// base 提供客户端地址、User-Agent、请求 ID 与初始动作；recorder 为 nil 时不记录。
+func StartAudit(ctx context.Context, recorder auth.AuditRecorder, base auth.AuditEvent) (context.Context, func(status int)) {
+	if recorder == nil {
+		return ctx, func(int) {}
+	}
+	state := &auditState{action: base.Action}
+	return context.WithValue(ctx, auditContextKey{}, state), func(status int) {
+		base.OccurredAt = time.Now().UTC()
+		state.emit(ctx, recorder, base, status)
+	}
+}
+
+// emit 按收集到的信息写入审计记录：有动作时记录一条，没有动作的 401/403 记为鉴权失败，另加追加的记录。
+func (s *auditState) emit(ctx context.Context, recorder auth.AuditRecorder, base auth.AuditEvent, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base.Actor = s.actor
+	base.KeyID = s.keyID
+	action := s.action
+	if action == "" && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+		action = auth.AuditAuthFailure
+	}
+	if action != "" {
+		event := base
+		event.Action = action
+		event.FileID = s.fileID
+		event.Status = status
+		event.Outcome = auditOutcome(status)
+		recorder.RecordAudit(ctx, event)
+	}
+	for _, extra := range s.extra {
+		event := base
+		event.Action = extra.Action
+		event.FileID = extra.FileID
+		event.Status = status
+		event.Outcome = extra.Outcome
+		recorder.RecordAudit(ctx, event)
+	}
+}
+
+// AuditAction 标注端点的审计动作，路径参数 id 作为文件 ID。应放在 scope 检查之前，使缺少 scope 的请求也以该动作记录。
+func AuditAction(action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if state := auditStateFrom(r.Context()); state != nil {
+				state.mu.Lock()
+				state.action = action
+				state.fileID = chi.URLParam(r, "id")
+				state.mu.Unlock()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetAuditFileID 设置本次请求审计记录的文件 ID，供路径中不含文件 ID 的端点（上传、分享下载）
+// 与其他入口使用。
+func SetAuditFileID(ctx context.Context, fileID string) {
+	if state := auditStateFrom(ctx); state != nil {
+		state.mu.Lock()
+		state.fileID = fileID
+		state.mu.Unlock()
+	}
+}
+
+// AddAuditEvent 为本次请求追加一条审计记录，供批量操作逐项记录。
+func AddAuditEvent(ctx context.Context, action, fileID, outcome string) {
+	if state := auditStateFrom(ctx); state != nil {
+		state.mu.Lock()
+		state.extra = append(state.extra, auth.AuditEvent{Action: action, FileID: fileID, Outcome: outcome})
+		state.mu.Unlock()
+	}
+}
+
+// SetAuditAction 设置本次请求的审计动作，供没有路由级 AuditAction 的入口（S3 网关、WebDAV）
+// 与在 AuditAction 之前结束的请求使用。
+func SetAuditAction(ctx context.Context, action string) {
+	if state := auditStateFrom(ctx); state != nil {
+		state.mu.Lock()
+		state.action = action
+		state.mu.Unlock()
+	}
+}
+
+// setAuditPrincipal 记录通过鉴权的调用方。
+func setAuditPrincipal(ctx context.Context, p auth.Principal) {
+	if state := auditStateFrom(ctx); state != nil {
+		state.mu.Lock()
+		state.actor = p.Actor
+		if state.actor == "" {
+			state.actor = p.OwnerID
+		}
+		state.keyID = p.KeyID
+		state.mu.Unlock()
+	}
+}
+
+func auditStateFrom(ctx context.Context) *auditState {
+	state, _ := ctx.Value(auditContextKey{}).(*auditState)
+	return state
+}
+
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return auth.AuditDenied
+	case status >= http.StatusBadRequest:
+		return auth.AuditFailure
+	default:
+		return auth.AuditSuccess
+	}
+}
+
+// clientIP 返回 RemoteAddr 中的地址部分。
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -2,18 +2,89 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+
+	"droplite/internal/auth"
 )
 
 // OwnerContextKey 是存储在 context 中的 owner ID 的键。
 type OwnerContextKey struct{}
 
+// Authenticator 校验 Authorization 凭证并返回对应的调用方（owner ID 与 scopes）。
+// HTTP 中间件与 gRPC 拦截器共用同一套实现。
+type Authenticator interface {
+	Authenticate(ctx context.Context, authorization string) (auth.Principal, error)
+}
+
+// AuthError 表示凭证缺失或无效，Message 可直接返回给客户端。
+type AuthError struct {
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// RequireAuth 使用给定的 Authenticator 保护后续 handler，验证成功后将 owner ID 与 scopes 存入 context。
+// authenticator 实现 RequestAuthenticator 时（如请求签名）交给它校验整个请求。客户端地址不在凭证的网络策略内时返回 403，
+// 因此须挂在 RealIP 之后。
+func RequireAuth(authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				principal auth.Principal
+				err       error
+			)
+			if requests, ok := authenticator.(RequestAuthenticator); ok {
+				principal, r, err = requests.AuthenticateRequest(r)
+			} else {
+				principal, err = authenticator.Authenticate(r.Context(), r.Header.Get("Authorization"))
+			}
+			if err != nil {
+				message := "unauthorized"
+				var authErr *AuthError
+				if errors.As(err, &authErr) {
+					message = authErr.Message
+				}
+				writeAuthError(w, r, http.StatusUnauthorized, message)
+				return
+			}
+			// 签名请求的 Body 可能是校验时写入的临时文件，服务器只会关闭原始 Body
+			if r.Body != nil {
+				defer r.Body.Close()
+			}
+			if !requireNetwork(w, r, principal) {
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
 // APIKeyAuth 创建 API Key 鉴权中间件。
 // 期望请求头格式：Authorization: ApiKey <token>
-// 验证成功后将 API Key 作为 owner_id 存入 context。
+// 验证成功后将 API Key 作为 owner_id、DefaultScopes 作为 scopes 存入 context。
 func APIKeyAuth(validKeys []string) func(http.Handler) http.Handler {
+	return RequireAuth(NewAPIKeyAuthenticator(validKeys))
+}
+
+// APIKeyVerifier 校验数据库签发的 API Key 并返回对应的调用方（owner ID、Key ID 与 scopes），由 service.APIKeyService 实现。
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// NewAPIKeyAuthenticator 创建基于静态 Key 列表的 Authenticator。
+func NewAPIKeyAuthenticator(validKeys []string) Authenticator {
+	return NewStoredAPIKeyAuthenticator(validKeys, nil)
+}
+
+// NewStoredAPIKeyAuthenticator 创建同时接受静态 Key 与数据库 Key 的 Authenticator。
+// 静态 Key 没有独立的 owner，以 Key 原值作为 owner ID 并获得 DefaultScopes；
+// verifier 为 nil 时只接受静态 Key。
+func NewStoredAPIKeyAuthenticator(validKeys []string, verifier APIKeyVerifier) Authenticator {
 	keySet := make(map[string]struct{}, len(validKeys))
 	for _, key := range validKeys {
 		trimmed := strings.TrimSpace(key)
@@ -21,39 +92,88 @@ func APIKeyAuth(validKeys []string) func(http.Handler) http.Handler {
 			keySet[trimmed] = struct{}{}
 		}
 	}
+	return &apiKeyAuthenticator{keys: keySet, verifier: verifier}
+}
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
+type apiKeyAuthenticator struct {
+	keys     map[string]struct{}
+	verifier APIKeyVerifier
+}
 
-			if authHeader == "" {
-				writeAuthError(w, http.StatusUnauthorized, "missing Authorization header")
-				return
-			}
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	if authHeader == "" {
+		return auth.Principal{}, &AuthError{Message: "missing Authorization header"}
+	}
 
-			// 期望格式: "ApiKey <token>"
-			const prefix = "ApiKey "
-			if !strings.HasPrefix(authHeader, prefix) {
-				writeAuthError(w, http.StatusUnauthorized, "invalid Authorization format, expected: ApiKey <token>")
-				return
-			}
+	// 期望格式: "ApiKey <token>"
+	const prefix = "ApiKey "
+	if !strings.HasPrefix(authHeader, prefix) {
+		return auth.Principal{}, &AuthError{Message: "invalid Authorization format, expected: ApiKey <token>"}
+	}
 
-			apiKey := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
-			if apiKey == "" {
-				writeAuthError(w, http.StatusUnauthorized, "empty API key")
-				return
-			}
+	apiKey := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
+	if apiKey == "" {
+		return auth.Principal{}, &AuthError{Message: "empty API key"}
+	}
 
-			if _, valid := keySet[apiKey]; !valid {
-				writeAuthError(w, http.StatusUnauthorized, "invalid API key")
-				return
-			}
+	if _, valid := a.keys[apiKey]; valid {
+		// 静态 Key 直接作为 owner_id
+		return auth.StaticKeyPrincipal(apiKey), nil
+	}
+	if a.verifier != nil {
+		if principal, err := a.verifier.VerifyAPIKey(ctx, apiKey); err == nil {
+			return principal, nil
+		}
+		// 不区分 Key 不存在、已吊销或数据库故障，避免向客户端泄露 Key 的状态
+	}
+	return auth.Principal{}, &AuthError{Message: "invalid API key"}
+}
 
-			// 将 API Key 作为 owner_id 存入 context
-			ctx := context.WithValue(r.Context(), OwnerContextKey{}, apiKey)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+// NewAdminAuthenticator 创建 /admin 端点使用的 Authenticator：ADMIN_API_KEYS 中的 Key 拥有全部 scopes，
+// 其余凭证交给 next 校验（next 为 nil 时直接拒绝），是否具备 admin scope 由 RequireScope 判断。
+func NewAdminAuthenticator(adminKeys []string, next Authenticator) Authenticator {
+	return &adminAuthenticator{admins: NewAPIKeyAuthenticator(adminKeys), next: next}
+}
+
+type adminAuthenticator struct {
+	admins Authenticator
+	next   Authenticator
+}
+
+func (a *adminAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	principal, err := a.admins.Authenticate(ctx, authHeader)
+	if err == nil {
+		return adminPrincipal(principal), nil
+	}
+	if a.next == nil {
+		return auth.Principal{}, err
+	}
+	return a.next.Authenticate(ctx, authHeader)
+}
+
+// AuthenticateRequest 在 next 支持时把非管理员 Key 的请求交给它校验整个请求，使管理端点也接受签名请求。
+func (a *adminAuthenticator) AuthenticateRequest(r *http.Request) (auth.Principal, *http.Request, error) {
+	if requests, ok := a.next.(RequestAuthenticator); ok {
+		principal, err := a.admins.Authenticate(r.Context(), r.Header.Get("Authorization"))
+		if err == nil {
+			return adminPrincipal(principal), r, nil
+		}
+		return requests.AuthenticateRequest(r)
 	}
+	principal, err := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+	return principal, r, err
+}
+
+// adminPrincipal 赋予 ADMIN_API_KEYS 中的 Key 全部 scopes，并在审计记录中标记为管理员 Key。
+func adminPrincipal(p auth.Principal) auth.Principal {
+	p.Actor = auth.AdminKeyActor
+	p.Scopes = auth.Scopes
+	return p
+}
+
+// WithOwnerID 返回携带 owner ID 的 context。
+func WithOwnerID(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, OwnerContextKey{}, ownerID)
 }
 
 // GetOwnerID 从 context 中获取经过鉴权的 owner ID。
@@ -64,9 +184,7 @@ func GetOwnerID(ctx context.Context) string {
 	return ""
 }
 
-func writeAuthError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
+func writeAuthError(w http.ResponseWriter, r *http.Request, status int, message string) {
 	w.Header().Set("WWW-Authenticate", `ApiKey realm="DropLite API"`)
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+	writeError(w, r, status, "unauthorized", message)
 }
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// BasicAuth 供只支持 HTTP Basic 的客户端（如系统自带的 WebDAV 挂载）使用：
+// 用户名被忽略，密码按 API Key 交给 auth 校验，成功后将 owner ID 与 scopes 存入 context。
+func BasicAuth(auth Authenticator, realm string) func(http.Handler) http.Handler {
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, password, ok := r.BasicAuth()
+			if !ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				writeError(w, r, http.StatusUnauthorized, "unauthorized", "missing Basic credentials")
+				return
+			}
+
+			principal, err := auth.Authenticate(r.Context(), "ApiKey "+password)
+			if err != nil {
+				message := "unauthorized"
+				var authErr *AuthError
+				if errors.As(err, &authErr) {
+					message = authErr.Message
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
+				writeError(w, r, http.StatusUnauthorized, "unauthorized", message)
+				return
+			}
+			if !requireNetwork(w, r, principal) {
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"droplite/internal/auth"
+)
+
+// NewClientCertAuthenticator 在 next 之外接受 mTLS 客户端证书：没有 Authorization 头、证书已通过客户端 CA 校验
+// 且能在 identities 中找到对应的 owner 时，以该 owner 与 DefaultScopes 通过鉴权，其余请求交给 next。
+//
+// identities 的键为 "subject:<DN>"、"cn:<CommonName>"、"dns:<SAN>"、"uri:<SAN>" 或 "email:<SAN>"，
+// 依次按 URI、DNS、email SAN、完整 subject、CommonName 的顺序匹配，取第一个命中的映射。
+func NewClientCertAuthenticator(identities map[string]string, next Authenticator) RequestAuthenticator {
+	return &clientCertAuthenticator{identities: identities, next: next}
+}
+
+type clientCertAuthenticator struct {
+	identities map[string]string
+	next       Authenticator
+}
+
+func (a *clientCertAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	if a.next == nil {
+		return auth.Principal{}, &AuthError{Message: "missing Authorization header"}
+	}
+	return a.next.Authenticate(ctx, authHeader)
+}
+
+func (a *clientCertAuthenticator) AuthenticateRequest(r *http.Request) (auth.Principal, *http.Request, error) {
+	if r.Header.Get("Authorization") == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if owner, ok := a.identify(r.TLS.VerifiedChains[0][0]); ok {
+			return auth.Principal{OwnerID: owner, Scopes: auth.DefaultScopes}, r, nil
+		}
+		return auth.Principal{}, r, &AuthError{Message: "client certificate is not mapped to an owner"}
+	}
+	if requests, ok := a.next.(RequestAuthenticator); ok {
+		return requests.AuthenticateRequest(r)
+	}
+	principal, err := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+	return principal, r, err
+}
+
+// identify 返回证书对应的 owner。
+func (a *clientCertAuthenticator) identify(cert *x509.Certificate) (string, bool) {
+	candidates := make([]string, 0, len(cert.URIs)+len(cert.DNSNames)+len(cert.EmailAddresses)+2)
+	for _, uri := range cert.URIs {
+		candidates = append(candidates, "uri:"+uri.String())
+	}
+	for _, name := range cert.DNSNames {
+		candidates = append(candidates, "dns:"+name)
+	}
+	for _, email := range cert.EmailAddresses {
+		candidates = append(candidates, "email:"+email)
+	}
+	candidates = append(candidates, "subject:"+cert.Subject.String())
+	if cert.Subject.CommonName != "" {
+		candidates = append(candidates, "cn:"+cert.Subject.CommonName)
+	}
+	for _, candidate := range candidates {
+		if owner, ok := a.identities[candidate]; ok {
+			return owner, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientCertAuthenticator_MapsVerifiedCertificates(t *testing.T) {
+	authenticator := NewClientCertAuthenticator(map[string]string{
+		"uri:spiffe://example.org/builder": "ci",
+		"subject:CN=deploy,O=Example":      "deploy",
+	}, NewAPIKeyAuthenticator([]string{"key-a"}))
+
+	var owner string
+	handler := RequireAuth(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner = GetOwnerID(r.Context())
+	}))
+	serve := func(cert *x509.Certificate, authHeader string) int {
+		owner = ""
+		req := httptest.NewRequest(http.MethodGet, "/files", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.org/builder")
+	builder := &x509.Certificate{Subject: pkix.Name{CommonName: "builder"}, URIs: []*url.URL{spiffe}}
+	if code := serve(builder, ""); code != http.StatusOK || owner != "ci" {
+		t.Fatalf("expected URI SAN to map to ci, got %d owner=%q", code, owner)
+	}
+	deploy := &x509.Certificate{Subject: pkix.Name{CommonName: "deploy", Organization: []string{"Example"}}}
+	if code := serve(deploy, ""); code != http.StatusOK || owner != "deploy" {
+		t.Fatalf("expected subject to map to deploy, got %d owner=%q", code, owner)
+	}
+	unknown := &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}
+	if code := serve(unknown, ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected unmapped certificate to be rejected, got %d", code)
+	}
+
+	// 显式的 Authorization 头优先于证书
+	if code := serve(builder, "ApiKey key-a"); code != http.StatusOK || owner != "key-a" {
+		t.Fatalf("expected API key to take precedence, got %d owner=%q", code, owner)
+	}
+	if code := serve(nil, ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected request without credentials to be rejected, got %d", code)
+	}
+}
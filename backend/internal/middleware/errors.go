@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// writeError 输出与 API handler 一致的错误结构：{"error": {code, message, request_id}}。
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{
+			"code":       code,
+			"message":    message,
+			"request_id": chimiddleware.GetReqID(r.Context()),
+		},
+	})
+}
@@ -79,6 +79,11 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap 暴露底层 ResponseWriter，使 http.ResponseController 能够 Flush（SSE 需要）。
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Metrics 创建 Prometheus 指标收集中间件
 func Metrics() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
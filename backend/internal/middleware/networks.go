@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+
+	"droplite/internal/auth"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// networkDenials 按入口（http、grpc、s3）统计因网络策略被拒绝的请求。
+var networkDenials = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_network_denied_total",
+	Help: "Requests with valid credentials rejected because the client IP is outside the credential's network policy",
+}, []string{"transport"})
+
+// ClientAddr 返回 RemoteAddr 中的客户端地址，须在 RealIP 之后调用才能得到代理背后的真实地址。
+func ClientAddr(r *http.Request) netip.Addr {
+	addr, err := netip.ParseAddr(clientIP(r))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// requireNetwork 在客户端地址不在调用方的网络策略内时返回 403 并以 AuditNetworkDenied 记录审计，返回是否放行。
+func requireNetwork(w http.ResponseWriter, r *http.Request, principal auth.Principal) bool {
+	addr := ClientAddr(r)
+	if principal.Networks.Permits(addr) {
+		return true
+	}
+	DenyNetwork(r.Context(), principal, "http")
+	writeError(w, r, http.StatusForbidden, "forbidden", auth.NetworkDeniedMessage(addr))
+	return false
+}
+
+// DenyNetwork 登记一次网络策略拒绝：以 AuditNetworkDenied 写入本次请求的审计记录，并按 transport 计数。
+// 响应由调用方返回，供 HTTP 之外的入口（gRPC、S3 网关）与 HTTP 中间件共用。
+func DenyNetwork(ctx context.Context, principal auth.Principal, transport string) {
+	setAuditPrincipal(ctx, principal)
+	SetAuditAction(ctx, auth.AuditNetworkDenied)
+	networkDenials.WithLabelValues(transport).Inc()
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"droplite/internal/auth"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type recordingAuditor struct {
+	events []auth.AuditEvent
+}
+
+func (r *recordingAuditor) RecordAudit(ctx context.Context, event auth.AuditEvent) {
+	r.events = append(r.events, event)
+}
+
+type networkAuthenticator struct {
+	principal auth.Principal
+}
+
+func (a networkAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	return a.principal, nil
+}
+
+func TestRequireAuth_EnforcesNetworksAfterRealIP(t *testing.T) {
+	networks, _ := auth.ParseNetworkPolicy([]string{"10.0.0.0/8"}, nil)
+	auditor := &recordingAuditor{}
+	authenticator := networkAuthenticator{principal: auth.Principal{OwnerID: "ci", KeyID: "key-1", Scopes: auth.DefaultScopes, Networks: networks}}
+	handler := RealIP([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})(
+		AuditTrail(auditor)(RequireAuth(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	serve := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/files", nil)
+		req.RemoteAddr = "192.0.2.10:4321"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("10.1.1.1"); code != http.StatusOK {
+		t.Fatalf("expected client behind trusted proxy to pass, got %d", code)
+	}
+	denied := testutil.ToFloat64(networkDenials.WithLabelValues("http"))
+	if code := serve("203.0.113.7"); code != http.StatusForbidden {
+		t.Fatalf("expected client outside allowed networks to be rejected, got %d", code)
+	}
+	if got := testutil.ToFloat64(networkDenials.WithLabelValues("http")); got != denied+1 {
+		t.Fatalf("expected network denial to be counted, got %v -> %v", denied, got)
+	}
+	if len(auditor.events) != 1 {
+		t.Fatalf("expected one audit event, got %d", len(auditor.events))
+	}
+	event := auditor.events[0]
+	if event.Action != auth.AuditNetworkDenied || event.Outcome != auth.AuditDenied || event.KeyID != "key-1" || event.IP != "203.0.113.7" {
+		t.Fatalf("unexpected audit event %+v", event)
+	}
+}
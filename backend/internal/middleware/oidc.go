@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"droplite/internal/auth"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcSigningMethods 是接受的签名算法。只允许非对称算法，避免把公钥当作 HMAC 密钥的攻击。
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// OIDCConfig 描述一个 OIDC 签发方。
+type OIDCConfig struct {
+	// IssuerURL 必须与 discovery 文档及 token 中的 iss 完全一致。
+	IssuerURL string
+	// Audiences 不能为空，token 的 aud 至少包含其中之一。
+	Audiences []string
+	// OwnerClaim 是作为 owner ID 的字符串声明，为空时使用 sub。
+	OwnerClaim string
+	// ClockSkew 是校验 exp、nbf 与 iat 时容忍的时钟偏差。
+	ClockSkew time.Duration
+	// HTTPClient 用于获取 discovery 文档与 JWKS，为 nil 时使用带超时的默认客户端。
+	HTTPClient *http.Client
+}
+
+type oidcAuthenticator struct {
+	ownerClaim string
+	jwks       *keyfunc.JWKS
+	parser     *jwt.Parser
+}
+
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// NewOIDCAuthenticator 通过 <issuer>/.well-known/openid-configuration 发现 JWKS 地址并加载公钥，
+// 之后校验 Bearer token 的签名、iss、aud、exp 与 nbf。JWKS 每小时刷新，遇到未知 kid 时立即刷新。
+// ctx 结束时停止后台刷新。
+func NewOIDCAuthenticator(ctx context.Context, cfg OIDCConfig) (Authenticator, error) {
+	issuer := strings.TrimSpace(cfg.IssuerURL)
+	if issuer == "" {
+		return nil, errors.New("oidc provider requires OIDC_ISSUER_URL")
+	}
+	// 不校验 aud 时，同一签发方发给其他客户端的 token 也会被接受
+	if len(cfg.Audiences) == 0 {
+		return nil, errors.New("oidc provider requires OIDC_AUDIENCE")
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	discovery, err := discoverOIDC(ctx, client, issuer)
+	if err != nil {
+		return nil, err
+	}
+	jwks, err := keyfunc.Get(discovery.JWKSURI, keyfunc.Options{
+		Client:            client,
+		Ctx:               ctx,
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  time.Minute,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			log.Printf("[oidc] refresh JWKS from %s: %v", discovery.JWKSURI, err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load oidc JWKS from %s: %w", discovery.JWKSURI, err)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithLeeway(cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithAudience(cfg.Audiences...),
+	}
+	ownerClaim := cfg.OwnerClaim
+	if ownerClaim == "" {
+		ownerClaim = "sub"
+	}
+	return &oidcAuthenticator{
+		ownerClaim: ownerClaim,
+		jwks:       jwks,
+		parser:     jwt.NewParser(options...),
+	}, nil
+}
+
+func discoverOIDC(ctx context.Context, client *http.Client, issuer string) (*oidcDiscovery, error) {
+	endpoint := strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: %s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	var doc oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: decode %s: %w", endpoint, err)
+	}
+	// OpenID Connect Discovery 1.0 第 4.3 节要求 issuer 与请求使用的地址完全一致
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match configured %q", doc.Issuer, issuer)
+	}
+	if doc.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery: %s has no jwks_uri", endpoint)
+	}
+	return &doc, nil
+}
+
+func (a *oidcAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	if authHeader == "" {
+		return auth.Principal{}, &AuthError{Message: "missing Authorization header"}
+	}
+	const prefix = "Bearer "
+	if !strings.HasPrefix(authHeader, prefix) {
+		return auth.Principal{}, &AuthError{Message: "invalid Authorization format, expected: Bearer <token>"}
+	}
+	tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
+	if tokenString == "" {
+		return auth.Principal{}, &AuthError{Message: "empty token"}
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(tokenString, claims, a.jwks.Keyfunc); err != nil {
+		return auth.Principal{}, &AuthError{Message: tokenErrorMessage(err)}
+	}
+	owner, _ := claims[a.ownerClaim].(string)
+	if owner == "" {
+		return auth.Principal{}, &AuthError{Message: fmt.Sprintf("token has no %s claim", a.ownerClaim)}
+	}
+	return auth.Principal{OwnerID: owner, Scopes: scopesFromClaims(claims)}, nil
+}
+
+// tokenErrorMessage 将 jwt 校验错误归纳为可返回给客户端的说明，不暴露签名细节。
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token has expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "token issuer is not accepted"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "token audience is not accepted"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "token is missing a required claim"
+	default:
+		return "invalid token"
+	}
+}
@@ -0,0 +1,294 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"droplite/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer 是一个本地 OIDC 签发方，同时提供 RS256 与 ES256 公钥。
+type testIssuer struct {
+	server *httptest.Server
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	iss := &testIssuer{rsa: rsaKey, ec: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   iss.server.URL,
+			"jwks_uri": iss.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec-1", "alg": "ES256", "use": "sig", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		}})
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (i *testIssuer) sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	var (
+		kid string
+		key crypto.Signer
+	)
+	switch method {
+	case jwt.SigningMethodRS256:
+		kid, key = "rsa-1", i.rsa
+	case jwt.SigningMethodES256:
+		kid, key = "ec-1", i.ec
+	default:
+		t.Fatalf("unsupported method %s", method.Alg())
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func (i *testIssuer) claims(overrides jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": i.server.URL,
+		"aud": "droplite",
+		"sub": "user-1",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	iss := newTestIssuer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	authenticator, err := NewOIDCAuthenticator(ctx, OIDCConfig{
+		IssuerURL: iss.server.URL,
+		Audiences: []string{"droplite"},
+		ClockSkew: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCAuthenticator: %v", err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		claims  jwt.MapClaims
+		owner   string
+		scopes  []string
+		wantErr string
+	}{
+		{name: "rs256", method: jwt.SigningMethodRS256, owner: "user-1", scopes: auth.DefaultScopes},
+		{name: "es256", method: jwt.SigningMethodES256, owner: "user-1", scopes: auth.DefaultScopes},
+		{
+			name:   "scope claim",
+			method: jwt.SigningMethodES256,
+			claims: jwt.MapClaims{"scope": "openid files:read"},
+			owner:  "user-1",
+			scopes: []string{auth.ScopeFilesRead},
+		},
+		{
+			name:   "scp array claim",
+			method: jwt.SigningMethodRS256,
+			claims: jwt.MapClaims{"scp": []string{"openid", "files:read", "files:write"}},
+			owner:  "user-1",
+			scopes: []string{auth.ScopeFilesRead, auth.ScopeFilesWrite},
+		},
+		{
+			name:   "scp string claim",
+			method: jwt.SigningMethodRS256,
+			claims: jwt.MapClaims{"scp": "files:delete"},
+			owner:  "user-1",
+			scopes: []string{auth.ScopeFilesDelete},
+		},
+		{
+			// 只有标准 OIDC scope 时视为未声明本服务的 scope
+			name:   "only oidc scopes",
+			method: jwt.SigningMethodES256,
+			claims: jwt.MapClaims{"scope": "openid profile email"},
+			owner:  "user-1",
+			scopes: auth.DefaultScopes,
+		},
+		{
+			name:   "expired within skew",
+			method: jwt.SigningMethodRS256,
+			claims: jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()},
+			owner:  "user-1",
+			scopes: auth.DefaultScopes,
+		},
+		{
+			name:   "nbf within skew",
+			method: jwt.SigningMethodRS256,
+			claims: jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()},
+			owner:  "user-1",
+			scopes: auth.DefaultScopes,
+		},
+		{
+			name:    "expired",
+			method:  jwt.SigningMethodRS256,
+			claims:  jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()},
+			wantErr: "token has expired",
+		},
+		{
+			name:    "not yet valid",
+			method:  jwt.SigningMethodES256,
+			claims:  jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()},
+			wantErr: "token is not valid yet",
+		},
+		{
+			name:    "missing exp",
+			method:  jwt.SigningMethodRS256,
+			claims:  jwt.MapClaims{"exp": nil},
+			wantErr: "token is missing a required claim",
+		},
+		{
+			name:    "wrong issuer",
+			method:  jwt.SigningMethodRS256,
+			claims:  jwt.MapClaims{"iss": "https://evil.example"},
+			wantErr: "token issuer is not accepted",
+		},
+		{
+			name:    "wrong audience",
+			method:  jwt.SigningMethodES256,
+			claims:  jwt.MapClaims{"aud": []string{"other"}},
+			wantErr: "token audience is not accepted",
+		},
+		{
+			name:    "missing owner",
+			method:  jwt.SigningMethodRS256,
+			claims:  jwt.MapClaims{"sub": nil},
+			wantErr: "token has no sub claim",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			token := iss.sign(t, tc.method, iss.claims(tc.claims))
+			p, err := authenticator.Authenticate(ctx, "Bearer "+token)
+			if tc.wantErr != "" {
+				var authErr *AuthError
+				if !errors.As(err, &authErr) || authErr.Message != tc.wantErr {
+					t.Fatalf("err = %v, want AuthError %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if p.OwnerID != tc.owner {
+				t.Fatalf("owner = %q, want %q", p.OwnerID, tc.owner)
+			}
+			if len(p.Scopes) != len(tc.scopes) {
+				t.Fatalf("scopes = %v, want %v", p.Scopes, tc.scopes)
+			}
+			for i := range tc.scopes {
+				if p.Scopes[i] != tc.scopes[i] {
+					t.Fatalf("scopes = %v, want %v", p.Scopes, tc.scopes)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCAuthenticator_OwnerClaimAndAlgorithms(t *testing.T) {
+	iss := newTestIssuer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	authenticator, err := NewOIDCAuthenticator(ctx, OIDCConfig{IssuerURL: iss.server.URL, Audiences: []string{"droplite"}, OwnerClaim: "email"})
+	if err != nil {
+		t.Fatalf("NewOIDCAuthenticator: %v", err)
+	}
+
+	token := iss.sign(t, jwt.SigningMethodRS256, iss.claims(jwt.MapClaims{"email": "a@example.com", "aud": "droplite"}))
+	p, err := authenticator.Authenticate(ctx, "Bearer "+token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if p.OwnerID != "a@example.com" {
+		t.Fatalf("owner = %q, want email claim", p.OwnerID)
+	}
+
+	// HS256 即使带有合法 kid 也必须拒绝
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, iss.claims(jwt.MapClaims{"email": "a@example.com"}))
+	hs.Header["kid"] = "rsa-1"
+	signed, err := hs.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := authenticator.Authenticate(ctx, "Bearer "+signed); err == nil {
+		t.Fatal("expected HS256 token to be rejected")
+	}
+	if _, err := authenticator.Authenticate(ctx, "ApiKey "+token); err == nil {
+		t.Fatal("expected non-bearer credentials to be rejected")
+	}
+}
+
+func TestNewOIDCAuthenticator_IssuerMismatch(t *testing.T) {
+	iss := newTestIssuer(t)
+	_, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{IssuerURL: iss.server.URL + "/", Audiences: []string{"droplite"}})
+	if err == nil {
+		t.Fatal("expected discovery to reject issuer that differs from the configured URL")
+	}
+}
+
+func TestNewOIDCAuthenticator_RequiresAudience(t *testing.T) {
+	iss := newTestIssuer(t)
+	if _, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{IssuerURL: iss.server.URL}); err == nil {
+		t.Fatal("expected missing audience to be rejected")
+	}
+}
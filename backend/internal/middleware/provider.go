@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"droplite/internal/config"
+)
+
+// signedMultipartOverhead 是签名上传在文件大小之外为 multipart 表单字段与分隔符预留的字节数，
+// 与 REST 上传的请求体上限一致。
+const signedMultipartOverhead int64 = 16 << 20
+
+// NewAuthenticator 根据 AUTH_PROVIDER 构建对应的 Authenticator，keys 为 nil 时 API Key 模式只接受 API_KEYS。
+// API Key 模式下默认同时接受请求签名，keys 实现 SigningKeyLookup 时数据库签发的 Key 也可以签名；
+// nonces 为 nil 时使用进程内记录。配置了 TLS_CLIENT_IDENTITIES 时，任一模式都接受映射过的客户端证书。
+func NewAuthenticator(cfg *config.Config, keys APIKeyVerifier, nonces NonceStore) (Authenticator, error) {
+	auth, err := newProviderAuthenticator(cfg, keys, nonces)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.TLSClientIdentities) > 0 {
+		return NewClientCertAuthenticator(cfg.TLSClientIdentities, auth), nil
+	}
+	return auth, nil
+}
+
+func newProviderAuthenticator(cfg *config.Config, keys APIKeyVerifier, nonces NonceStore) (Authenticator, error) {
+	switch cfg.AuthProvider {
+	case "supabase":
+		if cfg.SupabaseJWTSecret == "" && cfg.SupabaseURL == "" {
+			return nil, fmt.Errorf("supabase provider requires SUPABASE_JWT_SECRET or SUPABASE_URL")
+		}
+		return NewSupabaseAuthenticator(SupabaseConfig{
+			ProjectURL:    cfg.SupabaseURL,
+			AnonKey:       cfg.SupabaseAnonKey,
+			JWTSecret:     cfg.SupabaseJWTSecret,
+			Issuer:        cfg.SupabaseJWTIssuer,
+			Audiences:     cfg.SupabaseJWTAudiences,
+			RemoteTimeout: cfg.SupabaseRemoteTimeout,
+			CacheTTL:      cfg.SupabaseRemoteCacheTTL,
+		}), nil
+	case "oidc":
+		// JWKS 的后台刷新随进程存活
+		return NewOIDCAuthenticator(context.Background(), OIDCConfig{
+			IssuerURL:  cfg.OIDCIssuerURL,
+			Audiences:  cfg.OIDCAudiences,
+			OwnerClaim: cfg.OIDCOwnerClaim,
+			ClockSkew:  cfg.OIDCClockSkew,
+		})
+	default:
+		// 默认使用 API Key
+		auth := NewStoredAPIKeyAuthenticator(cfg.APIKeys, keys)
+		if !cfg.RequestSigningEnabled {
+			return auth, nil
+		}
+		lookup, _ := keys.(SigningKeyLookup)
+		// 签名请求体在鉴权时整体校验，上限为上传大小加上 multipart 表单字段的余量
+		maxBody := cfg.MaxUploadSize + signedMultipartOverhead
+		return NewSignedRequestAuthenticator(NewSigningKeyStore(cfg.APIKeys, lookup), nonces, cfg.RequestSigningWindow, maxBody, auth), nil
+	}
+}
@@ -1,32 +1,86 @@
 package middleware
 
 import (
-	"net"
+	"context"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
+
+	"droplite/internal/auth"
+	"droplite/internal/config"
+
+	"github.com/go-chi/chi/v5"
 )
 
-// RateLimit 限制同一来源在指定窗口内的请求数量。
-func RateLimit(maxRequests int, window time.Duration) func(http.Handler) http.Handler {
-	if maxRequests <= 0 || window <= 0 {
-		return passthrough
+// RateLimitPolicy 决定每个请求使用的限额。
+type RateLimitPolicy struct {
+	// Default 为 0 请求数时不限制没有单独配置的请求。
+	Default config.RateLimitRule
+	// Routes 按 "METHOD /pattern" 覆盖限额，pattern 与 chi 注册的路由一致，如 "POST /files"。
+	// 命中路由规则的请求使用独立的令牌桶，不占用默认额度。
+	Routes map[string]config.RateLimitRule
+	// Keys 按限流键（"owner:<id>" 或 "ip:<addr>"）覆盖默认限额。
+	Keys map[string]config.RateLimitRule
+}
+
+// NewRateLimitPolicy 从配置构建限流策略。
+func NewRateLimitPolicy(cfg *config.Config) RateLimitPolicy {
+	return RateLimitPolicy{
+		Default: config.RateLimitRule{Requests: cfg.RateLimitRequests, Window: cfg.RateLimitWindow},
+		Routes:  cfg.RateLimitRoutes,
+		Keys:    cfg.RateLimitKeys,
 	}
+}
+
+func (p RateLimitPolicy) empty() bool {
+	return !validRule(p.Default) && len(p.Routes) == 0 && len(p.Keys) == 0
+}
 
-	limiter := &ipRateLimiter{
-		maxRequests: maxRequests,
-		window:      window,
-		clients:     make(map[string]*clientCounter),
+// RateLimit 按调用方限流：已鉴权的请求以 owner ID 为键，其余请求以客户端 IP 为键
+// （IP 由 RealIP 根据可信代理确定）。须挂在 RequireAuth 之后。
+//
+// 响应携带 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 与 RateLimit-Policy 头，
+// 超限时返回 429 与 Retry-After。限流器出错时放行请求，避免数据库故障导致全站不可用。
+func RateLimit(limiter auth.RateLimiter, policy RateLimitPolicy) func(http.Handler) http.Handler {
+	if limiter == nil || policy.empty() {
+		return passthrough
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !limiter.Allow(clientKey(r)) {
-				retryAfter := strconv.Itoa(int(window.Seconds()))
-				w.Header().Set("Retry-After", retryAfter)
-				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			identity := rateLimitIdentity(r)
+			key, rule := identity, policy.Default
+			if override, ok := policy.Keys[identity]; ok {
+				rule = override
+			}
+			if route := routeKey(r); route != "" {
+				if override, ok := policy.Routes[route]; ok {
+					key, rule = identity+"|"+route, override
+				}
+			}
+			if !validRule(rule) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := limiter.Take(r.Context(), key, rule)
+			if err != nil {
+				log.Printf("[ratelimit] take %s: %v", key, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(rule.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+			h.Set("RateLimit-Policy", strconv.Itoa(rule.Requests)+";w="+ceilSeconds(rule.Window))
+			if !result.Allowed {
+				h.Set("Retry-After", ceilSeconds(result.RetryAfter))
+				writeError(w, r, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -34,68 +88,128 @@ func RateLimit(maxRequests int, window time.Duration) func(http.Handler) http.Ha
 	}
 }
 
+// LimitAuthFailures 按客户端 IP 限制鉴权失败的次数，须挂在 RealIP 之后、鉴权中间件之前。
+// 只有返回 401 的请求消耗额度，额度用尽后该 IP 的请求在校验凭证前直接返回 429，
+// 因此无法继续猜测凭证，也不会再写入鉴权失败的审计记录。额度恢复前同一 IP 的有效凭证也会被拒绝，
+// 共用出口 IP 的可信网络可以用 AUTH_FAILURE_LIMIT 调高上限。
+func LimitAuthFailures(limiter auth.RateLimiter, rule config.RateLimitRule) func(http.Handler) http.Handler {
+	if limiter == nil || !validRule(rule) {
+		return passthrough
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "auth-failure|ip:" + clientIP(r)
+			if result, err := limiter.Peek(r.Context(), key, rule); err != nil {
+				log.Printf("[ratelimit] peek %s: %v", key, err)
+			} else if !result.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				writeError(w, r, http.StatusTooManyRequests, "rate_limited", "too many failed authentication attempts")
+				return
+			}
+
+			rw := newResponseWriter(w)
+			next.ServeHTTP(rw, r)
+			if rw.statusCode == http.StatusUnauthorized {
+				if _, err := limiter.Take(r.Context(), key, rule); err != nil {
+					log.Printf("[ratelimit] take %s: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
 func passthrough(next http.Handler) http.Handler {
 	return next
 }
 
-type ipRateLimiter struct {
-	mu          sync.Mutex
-	clients     map[string]*clientCounter
-	maxRequests int
-	window      time.Duration
+func validRule(rule config.RateLimitRule) bool {
+	return rule.Requests > 0 && rule.Window > 0
+}
+
+// rateLimitIdentity 返回请求的限流键。
+func rateLimitIdentity(r *http.Request) string {
+	if owner := GetOwnerID(r.Context()); owner != "" {
+		return "owner:" + owner
+	}
+	return "ip:" + clientIP(r)
+}
+
+// routeKey 返回 "METHOD /pattern"。子路由在中间件执行时尚未完成匹配，因此从根路由重新查找完整模式。
+func routeKey(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	pattern := rctx.Routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+	if pattern == "" {
+		return ""
+	}
+	return r.Method + " " + pattern
+}
+
+func ceilSeconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// MemoryRateLimiter 是进程内的令牌桶限流器，只在单副本部署时准确。
+type MemoryRateLimiter struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+	now  func() time.Time
 }
 
-type clientCounter struct {
-	count   int
-	expires time.Time
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{tats: make(map[string]time.Time), now: time.Now}
 }
 
-func (l *ipRateLimiter) Allow(key string) bool {
-	now := time.Now()
+// Take 实现 RateLimiter。
+func (l *MemoryRateLimiter) Take(_ context.Context, key string, rule config.RateLimitRule) (auth.RateLimitResult, error) {
+	now := l.now()
+	interval := rule.Window / time.Duration(rule.Requests)
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	entry, ok := l.clients[key]
-	if !ok || now.After(entry.expires) {
-		l.clients[key] = &clientCounter{
-			count:   1,
-			expires: now.Add(l.window),
-		}
-		return true
+	tat, ok := l.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
 	}
-
-	if entry.count >= l.maxRequests {
-		return false
+	next := tat.Add(interval)
+	if next.Sub(now) > rule.Window {
+		return auth.NewRateLimitResult(rule, tat, now, false), nil
 	}
+	l.tats[key] = next
 
-	entry.count++
-
-	if len(l.clients) > 1024 {
+	if len(l.tats) > 1024 {
 		l.cleanupLocked(now)
 	}
-
-	return true
+	return auth.NewRateLimitResult(rule, next, now, true), nil
 }
 
-func (l *ipRateLimiter) cleanupLocked(now time.Time) {
-	for key, entry := range l.clients {
-		if now.After(entry.expires) {
-			delete(l.clients, key)
-		}
-	}
-}
+// Peek 实现 RateLimiter。
+func (l *MemoryRateLimiter) Peek(_ context.Context, key string, rule config.RateLimitRule) (auth.RateLimitResult, error) {
+	now := l.now()
+	interval := rule.Window / time.Duration(rule.Requests)
+
+	l.mu.Lock()
+	tat, ok := l.tats[key]
+	l.mu.Unlock()
 
-func clientKey(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
+	if !ok || tat.Before(now) {
+		tat = now
 	}
+	return auth.NewRateLimitResult(rule, tat, now, tat.Add(interval).Sub(now) <= rule.Window), nil
+}
 
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+// cleanupLocked 删除已回满的桶，它们与不存在的桶等价。
+func (l *MemoryRateLimiter) cleanupLocked(now time.Time) {
+	for key, tat := range l.tats {
+		if !tat.After(now) {
+			delete(l.tats, key)
+		}
 	}
-	return host
 }
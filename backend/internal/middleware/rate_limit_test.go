@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"droplite/internal/config"
+)
+
+func TestMemoryRateLimiter_TokenBucket(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+	now := time.Unix(1_700_000_000, 0)
+	limiter.now = func() time.Time { return now }
+	rule := config.RateLimitRule{Requests: 3, Window: 3 * time.Second}
+	ctx := context.Background()
+
+	for want := 2; want >= 0; want-- {
+		res, err := limiter.Take(ctx, "k", rule)
+		if err != nil || !res.Allowed || res.Remaining != want {
+			t.Fatalf("expected allowed with %d remaining, got %+v %v", want, res, err)
+		}
+	}
+	res, _ := limiter.Take(ctx, "k", rule)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Fatalf("expected burst to be exhausted, got %+v", res)
+	}
+	if other, _ := limiter.Take(ctx, "other", rule); !other.Allowed {
+		t.Fatal("expected buckets to be independent per key")
+	}
+
+	// 每过 Window/Requests 补充一个令牌
+	now = now.Add(time.Second)
+	if res, _ := limiter.Take(ctx, "k", rule); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected one refilled token, got %+v", res)
+	}
+	now = now.Add(time.Hour)
+	if res, _ := limiter.Take(ctx, "k", rule); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("expected bucket to refill to capacity, got %+v", res)
+	}
+}
+
+func TestRealIP_OnlyTrustsConfiguredProxies(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	var got string
+	handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.RemoteAddr
+	}))
+
+	tests := []struct {
+		remote, xff, want string
+	}{
+		// 直连客户端伪造的请求头被忽略
+		{"203.0.113.7:5000", "1.2.3.4", "203.0.113.7:5000"},
+		// 经过可信代理时取最右侧的非可信地址，左侧由客户端伪造的部分被忽略
+		{"10.0.0.2:5000", "1.2.3.4, 198.51.100.9, 10.0.0.3", "198.51.100.9:0"},
+		{"10.0.0.2:5000", "", "10.0.0.2:5000"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tc.want {
+			t.Fatalf("remote %s xff %q: got %s, want %s", tc.remote, tc.xff, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP 在请求来自可信代理时，用 X-Forwarded-For 中最右侧的非可信地址替换 RemoteAddr。
+// 直接连接的客户端可以任意伪造该请求头，因此 trusted 为空时不读取它。
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	if len(trusted) == 0 {
+		return passthrough
+	}
+
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err != nil || !isTrusted(peer.Addr()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 从右向左跳过可信代理追加的地址，第一个非可信地址即客户端
+			hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				if !isTrusted(addr) || i == 0 {
+					r.RemoteAddr = net.JoinHostPort(addr.Unmap().String(), "0")
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
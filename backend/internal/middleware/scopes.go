@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"droplite/internal/auth"
+)
+
+// roleScopes 将 Supabase app_metadata 中的角色映射为权限范围。
+var roleScopes = map[string][]string{
+	"admin":    auth.Scopes,
+	"editor":   auth.DefaultScopes,
+	"uploader": {auth.ScopeFilesRead, auth.ScopeFilesWrite},
+	"viewer":   {auth.ScopeFilesRead},
+}
+
+type scopesContextKey struct{}
+
+// WithPrincipal 返回携带 owner ID 与 scopes 的 context，并将调用方登记到本次请求的审计记录。
+func WithPrincipal(ctx context.Context, p auth.Principal) context.Context {
+	setAuditPrincipal(ctx, p)
+	ctx = WithOwnerID(ctx, p.OwnerID)
+	return context.WithValue(ctx, scopesContextKey{}, p.Scopes)
+}
+
+// HasScope 判断 context 中的调用方是否拥有 scope。未经鉴权的 context（AUTH_ENABLED=false）不受限制。
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesContextKey{}).([]string)
+	if !ok {
+		return true
+	}
+	return slices.Contains(scopes, scope)
+}
+
+// MissingScope 返回 scopes 中第一个调用方不具备的 scope，全部具备时返回空字符串。
+func MissingScope(ctx context.Context, scopes ...string) string {
+	for _, scope := range scopes {
+		if !HasScope(ctx, scope) {
+			return scope
+		}
+	}
+	return ""
+}
+
+// RequireScope 要求调用方拥有全部给定的 scope，否则返回 403。须挂在 RequireAuth 之后。
+func RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if missing := MissingScope(r.Context(), scopes...); missing != "" {
+				writeForbidden(w, r, missing)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireMethodScopes 按 HTTP 方法决定需要的 scope，用于 WebDAV 这类由单个 handler 处理全部方法的端点。
+func RequireMethodScopes(scopesFor func(method string) []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if missing := MissingScope(r.Context(), scopesFor(r.Method)...); missing != "" {
+				writeForbidden(w, r, missing)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeForbidden(w http.ResponseWriter, r *http.Request, scope string) {
+	writeError(w, r, http.StatusForbidden, "forbidden", "missing required scope "+scope)
+}
+
+// scopesFromClaims 从 JWT claims 中提取 scopes：
+// 优先使用 "scope"、"scp"（OAuth/OIDC 风格）或 "scopes" 声明，三者均可为空格分隔的字符串或字符串数组；
+// 其次将 app_metadata.roles / app_metadata.role 按角色映射，都没有时使用 DefaultScopes。
+// 未知的 scope 与角色被忽略；只含 openid、profile 等与本服务无关的 scope 时视为未声明。
+func scopesFromClaims(claims map[string]any) []string {
+	var declared []string
+	for _, name := range []string{"scope", "scp", "scopes"} {
+		declared = append(declared, claimStrings(claims[name])...)
+	}
+	if scopes := knownScopes(declared); len(scopes) > 0 {
+		return scopes
+	}
+
+	appMetadata, _ := claims["app_metadata"].(map[string]any)
+	var roles []string
+	if raw, ok := appMetadata["roles"].([]any); ok {
+		roles = stringsOf(raw)
+	}
+	if role, ok := appMetadata["role"].(string); ok && role != "" {
+		roles = append(roles, role)
+	}
+	if len(roles) == 0 {
+		return auth.DefaultScopes
+	}
+	var scopes []string
+	for _, role := range roles {
+		for _, scope := range roleScopes[role] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// claimStrings 把空格分隔的字符串或字符串数组形式的 claim 展开为单个取值。
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []any:
+		var out []string
+		for _, item := range stringsOf(v) {
+			out = append(out, strings.Fields(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+func knownScopes(raw []string) []string {
+	var scopes []string
+	for _, scope := range raw {
+		if slices.Contains(auth.Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func stringsOf(raw []any) []string {
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
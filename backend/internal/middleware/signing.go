@@ -0,0 +1,388 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"droplite/internal/auth"
+)
+
+// 请求签名使用的 Authorization 方案与请求头。
+const (
+	SignatureScheme        = "DL-HMAC-SHA256"
+	HeaderSignatureTime    = "X-DL-Timestamp"
+	HeaderSignatureNonce   = "X-DL-Nonce"
+	HeaderSignatureContent = "X-DL-Content-SHA256"
+
+	// DefaultSignatureWindow 是签名时间戳允许与服务端时钟相差的最大时长。
+	DefaultSignatureWindow = 5 * time.Minute
+	maxSignatureNonceLen   = 128
+	// maxBufferedSignedBody 以内的请求体在内存中校验，更大的请求体先写入临时文件校验。
+	maxBufferedSignedBody = 1 << 20
+)
+
+// RequestAuthenticator 是需要读取整个请求而不只是 Authorization 头的 Authenticator，如请求签名。
+// 返回的请求可能替换了 Body，后续 handler 须使用它。
+type RequestAuthenticator interface {
+	Authenticator
+	AuthenticateRequest(r *http.Request) (auth.Principal, *http.Request, error)
+}
+
+// SigningKeyLookup 按 Key ID 查找数据库签发的 API Key 的签名密钥，由 service.APIKeyService 实现。
+type SigningKeyLookup interface {
+	LookupSigningKey(ctx context.Context, keyID string) (auth.SigningKey, error)
+}
+
+// NonceStore 记录已使用的签名 nonce。实现须保证多个副本共享同一份状态时同一个 nonce 只能被领取一次。
+type NonceStore interface {
+	// Claim 登记 nonce，直到 expiresAt 之前再次登记返回 false。
+	Claim(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// CanonicalRequest 返回被签名的字符串，各部分以换行分隔：
+// 方案、方法、转义后的路径、查询串（解析后按 url.Values.Encode 重新编码，即按键排序）、
+// 时间戳（Unix 秒）、nonce 与请求体的 SHA-256（小写十六进制）。
+func CanonicalRequest(method, escapedPath, rawQuery, timestamp, nonce, bodySHA256 string) string {
+	query := ""
+	if rawQuery != "" {
+		if values, err := url.ParseQuery(rawQuery); err == nil {
+			query = values.Encode()
+		} else {
+			query = rawQuery
+		}
+	}
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+	return strings.Join([]string{SignatureScheme, strings.ToUpper(method), escapedPath, query, timestamp, nonce, bodySHA256}, "\n")
+}
+
+// SignCanonicalRequest 计算签名：HMAC-SHA256(secret, canonical) 的小写十六进制。
+func SignCanonicalRequest(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSigningKeyStore 合并 API_KEYS 派生的签名密钥与数据库签发的 Key；keys 为 nil 时只使用静态列表。
+// 静态 Key 与 Key 原值鉴权一样以 Key 原值作为 owner ID 并获得 DefaultScopes。
+func NewSigningKeyStore(apiKeys []string, keys SigningKeyLookup) SigningKeyLookup {
+	static := make(staticSigningKeys, len(apiKeys))
+	for _, key := range apiKeys {
+		trimmed := strings.TrimSpace(key)
+		if trimmed == "" {
+			continue
+		}
+		keyID, secret := auth.DeriveKeyCredentials(trimmed)
+		static[keyID] = auth.SigningKey{Secret: secret, Principal: auth.StaticKeyPrincipal(trimmed)}
+	}
+	return &signingKeyStore{static: static, keys: keys}
+}
+
+type staticSigningKeys map[string]auth.SigningKey
+
+type signingKeyStore struct {
+	static staticSigningKeys
+	keys   SigningKeyLookup
+}
+
+func (s *signingKeyStore) LookupSigningKey(ctx context.Context, keyID string) (auth.SigningKey, error) {
+	if key, ok := s.static[keyID]; ok {
+		return key, nil
+	}
+	if s.keys == nil {
+		return auth.SigningKey{}, errors.New("unknown signing key")
+	}
+	return s.keys.LookupSigningKey(ctx, keyID)
+}
+
+// NewSignedRequestAuthenticator 在 next 之外接受 "DL-HMAC-SHA256" 签名的请求，其余凭证交给 next 校验。
+//
+// 签名请求的 Authorization 为 "DL-HMAC-SHA256 Credential=<key id>, Signature=<hex>"，
+// 并携带 X-DL-Timestamp、X-DL-Nonce 与 X-DL-Content-SHA256。时间戳与服务端相差超过 window、
+// nonce 在有效期内重复使用或请求体与声明的哈希不符时拒绝。nonces 为 nil 时使用进程内实现。
+//
+// 请求体校验通过之前 handler 读不到任何内容，校验失败的内容不会写入存储。较大的请求体在 AdmitUploads
+// 获准后才读取校验，受上传准入与限速约束。超过 maxBody 的请求体直接拒绝，maxBody 不大于 0 时不限制。
+func NewSignedRequestAuthenticator(keys SigningKeyLookup, nonces NonceStore, window time.Duration, maxBody int64, next Authenticator) RequestAuthenticator {
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	if window <= 0 {
+		window = DefaultSignatureWindow
+	}
+	return &signedRequestAuthenticator{keys: keys, nonces: nonces, window: window, maxBody: maxBody, next: next, now: time.Now}
+}
+
+type signedRequestAuthenticator struct {
+	keys    SigningKeyLookup
+	nonces  NonceStore
+	window  time.Duration
+	maxBody int64
+	next    Authenticator
+	now     func() time.Time
+}
+
+// Authenticate 只能校验 Authorization 头，签名请求需要完整的请求，因此在 gRPC 等场景下拒绝。
+func (a *signedRequestAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	if isSignedAuthorization(authHeader) {
+		return auth.Principal{}, &AuthError{Message: "signed requests are only supported over HTTP"}
+	}
+	if a.next == nil {
+		return auth.Principal{}, &AuthError{Message: "invalid Authorization format, expected: " + SignatureScheme}
+	}
+	return a.next.Authenticate(ctx, authHeader)
+}
+
+func (a *signedRequestAuthenticator) AuthenticateRequest(r *http.Request) (auth.Principal, *http.Request, error) {
+	authHeader := r.Header.Get("Authorization")
+	if !isSignedAuthorization(authHeader) {
+		principal, err := a.Authenticate(r.Context(), authHeader)
+		return principal, r, err
+	}
+
+	keyID, signature, err := parseSignedAuthorization(authHeader)
+	if err != nil {
+		return auth.Principal{}, r, err
+	}
+	timestamp := r.Header.Get(HeaderSignatureTime)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return auth.Principal{}, r, &AuthError{Message: "missing or invalid " + HeaderSignatureTime + " header"}
+	}
+	signedAt := time.Unix(seconds, 0)
+	if skew := a.now().Sub(signedAt); skew > a.window || skew < -a.window {
+		return auth.Principal{}, r, &AuthError{Message: "request timestamp is outside the allowed window"}
+	}
+	nonce := r.Header.Get(HeaderSignatureNonce)
+	if nonce == "" || len(nonce) > maxSignatureNonceLen {
+		return auth.Principal{}, r, &AuthError{Message: "missing or invalid " + HeaderSignatureNonce + " header"}
+	}
+	bodyHash := strings.ToLower(r.Header.Get(HeaderSignatureContent))
+	declared, err := hex.DecodeString(bodyHash)
+	if err != nil || len(declared) != sha256.Size {
+		return auth.Principal{}, r, &AuthError{Message: "missing or invalid " + HeaderSignatureContent + " header"}
+	}
+
+	key, err := a.keys.LookupSigningKey(r.Context(), keyID)
+	if err != nil {
+		// 不区分 Key 不存在、已吊销或数据库故障，与 API Key 鉴权一致
+		return auth.Principal{}, r, &AuthError{Message: "invalid request signature"}
+	}
+	canonical := CanonicalRequest(r.Method, r.URL.EscapedPath(), r.URL.RawQuery, timestamp, nonce, bodyHash)
+	expected := SignCanonicalRequest(key.Secret, canonical)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(signature))) != 1 {
+		return auth.Principal{}, r, &AuthError{Message: "invalid request signature"}
+	}
+
+	// 签名通过后才登记 nonce，伪造的请求无法占用合法客户端的 nonce；
+	// 超过时间窗口的请求已被拒绝，nonce 只需保留到窗口结束
+	fresh, err := a.nonces.Claim(r.Context(), keyID+":"+nonce, signedAt.Add(a.window))
+	if err != nil {
+		return auth.Principal{}, r, err
+	}
+	if !fresh {
+		return auth.Principal{}, r, &AuthError{Message: "request nonce has already been used"}
+	}
+
+	body, err := verifiedBody(r, declared, a.maxBody)
+	if err != nil {
+		return auth.Principal{}, r, err
+	}
+	r = r.Clone(r.Context())
+	r.Body = body
+	return key.Principal, r, nil
+}
+
+// verifiedBody 返回校验哈希后供 handler 读取的 Body。已知长度的小请求体在鉴权时读入内存校验；
+// 其余的返回 pendingBody，推迟到获准上传后再读取，避免在 ReadTimeout 内、上传准入之前读完大文件。
+// 声明的长度超过 maxBody（大于 0 时）或小请求体与声明的哈希不符时返回错误。
+func verifiedBody(r *http.Request, declared []byte, maxBody int64) (io.ReadCloser, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		if sum := sha256.Sum256(nil); !bytes.Equal(sum[:], declared) {
+			return nil, &AuthError{Message: "request body does not match " + HeaderSignatureContent}
+		}
+		return http.NoBody, nil
+	}
+	if maxBody > 0 && r.ContentLength > maxBody {
+		return nil, signedBodyTooLarge(maxBody)
+	}
+	if r.ContentLength < 0 || r.ContentLength > maxBufferedSignedBody {
+		return &pendingBody{src: r.Body, declared: declared, maxBody: maxBody}, nil
+	}
+
+	defer r.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxBufferedSignedBody+1))
+	if err != nil {
+		return nil, &AuthError{Message: "unable to read request body"}
+	}
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], declared) {
+		return nil, &AuthError{Message: "request body does not match " + HeaderSignatureContent}
+	}
+	return io.NopCloser(bytes.NewReader(payload)), nil
+}
+
+// pendingBody 是推迟校验的签名请求体。AdmitUploads 在获准上传并推迟截止时间后调用 verify，
+// 没有经过 AdmitUploads 的路由在第一次 Read 时校验；校验通过之前不返回任何内容。
+type pendingBody struct {
+	src      io.ReadCloser
+	declared []byte
+	maxBody  int64
+	verified io.ReadCloser
+	err      error
+}
+
+// verify 把请求体写入临时文件并校验哈希，只执行一次。
+func (b *pendingBody) verify() error {
+	if b.verified == nil && b.err == nil {
+		b.verified, b.err = spoolBody(b.src, b.declared, b.maxBody)
+	}
+	return b.err
+}
+
+func (b *pendingBody) Read(p []byte) (int, error) {
+	if err := b.verify(); err != nil {
+		return 0, err
+	}
+	return b.verified.Read(p)
+}
+
+func (b *pendingBody) Close() error {
+	err := b.src.Close()
+	if b.verified != nil {
+		if closeErr := b.verified.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// verifyPendingBody 校验 RequireAuth 推迟的签名请求体，r 不是这类请求时返回 nil。
+func verifyPendingBody(r *http.Request) error {
+	if pending, ok := r.Body.(*pendingBody); ok {
+		return pending.verify()
+	}
+	return nil
+}
+
+// spoolBody 把 src 写入临时文件并计算哈希，返回从文件开头读取、关闭时删除文件的 Body。
+// 超过 maxBody（大于 0 时）或与声明的哈希不符时返回 AuthError。
+func spoolBody(src io.Reader, declared []byte, maxBody int64) (io.ReadCloser, error) {
+	tmp, err := os.CreateTemp("", "droplite-signed-*")
+	if err != nil {
+		return nil, fmt.Errorf("create signed body buffer: %w", err)
+	}
+	body := &spooledBody{File: tmp}
+	if maxBody > 0 {
+		src = io.LimitReader(src, maxBody+1)
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	switch {
+	case err != nil:
+		_ = body.Close()
+		return nil, &AuthError{Message: "unable to read request body"}
+	case maxBody > 0 && size > maxBody:
+		_ = body.Close()
+		return nil, signedBodyTooLarge(maxBody)
+	case !bytes.Equal(hasher.Sum(nil), declared):
+		_ = body.Close()
+		return nil, &AuthError{Message: "request body does not match " + HeaderSignatureContent}
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		_ = body.Close()
+		return nil, fmt.Errorf("rewind signed body buffer: %w", err)
+	}
+	return body, nil
+}
+
+func signedBodyTooLarge(maxBody int64) error {
+	return &AuthError{Message: fmt.Sprintf("signed request body exceeds %d bytes", maxBody)}
+}
+
+// spooledBody 是写入临时文件的请求体，Close 时删除文件。
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	if removeErr := os.Remove(b.File.Name()); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+		return removeErr
+	}
+	return err
+}
+
+func isSignedAuthorization(authHeader string) bool {
+	return strings.HasPrefix(authHeader, SignatureScheme+" ")
+}
+
+// parseSignedAuthorization 解析 "DL-HMAC-SHA256 Credential=<key id>, Signature=<hex>"。
+func parseSignedAuthorization(authHeader string) (keyID, signature string, err error) {
+	params := strings.TrimPrefix(authHeader, SignatureScheme+" ")
+	for _, part := range strings.Split(params, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "Credential":
+			keyID = value
+		case "Signature":
+			signature = value
+		}
+	}
+	if keyID == "" || signature == "" {
+		return "", "", &AuthError{Message: "invalid Authorization format, expected: " + SignatureScheme + " Credential=<key id>, Signature=<signature>"}
+	}
+	return keyID, signature, nil
+}
+
+// MemoryNonceStore 是进程内的 NonceStore，多副本部署时各副本的记录互不可见。
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore 创建进程内 nonce 记录。
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryNonceStore) Claim(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	// 每分钟最多清理一次过期记录，避免每次都遍历
+	if now.Sub(s.lastSweep) >= time.Minute {
+		for key, expiry := range s.nonces {
+			if !now.Before(expiry) {
+				delete(s.nonces, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	if expiry, ok := s.nonces[nonce]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"droplite/internal/auth"
+)
+
+// signedRequest 按客户端的方式构造签名请求。
+func signedRequest(apiKey, method, target, nonce string, at time.Time, body []byte) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	keyID, secret := auth.DeriveKeyCredentials(apiKey)
+	sum := sha256.Sum256(body)
+	bodyHash := hex.EncodeToString(sum[:])
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	signature := SignCanonicalRequest(secret, CanonicalRequest(method, req.URL.EscapedPath(), req.URL.RawQuery, timestamp, nonce, bodyHash))
+
+	req.Header.Set("Authorization", SignatureScheme+" Credential="+keyID+", Signature="+signature)
+	req.Header.Set(HeaderSignatureTime, timestamp)
+	req.Header.Set(HeaderSignatureNonce, nonce)
+	req.Header.Set(HeaderSignatureContent, bodyHash)
+	return req
+}
+
+func TestSignedRequestAuthenticator_VerifiesSignatureAndReplays(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	nonces := NewMemoryNonceStore()
+	nonces.now = func() time.Time { return now }
+	authenticator := NewSignedRequestAuthenticator(NewSigningKeyStore([]string{"key-a"}, nil), nonces, time.Minute, 0, NewAPIKeyAuthenticator([]string{"key-a"}))
+	authenticator.(*signedRequestAuthenticator).now = func() time.Time { return now }
+
+	var owner, body string
+	handler := RequireAuth(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner = GetOwnerID(r.Context())
+		payload, _ := io.ReadAll(r.Body)
+		body = string(payload)
+	}))
+	serve := func(req *http.Request) int {
+		owner, body = "", ""
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	req := signedRequest("key-a", http.MethodPost, "/files?b=2&a=1", "n-1", now, []byte(`{"name":"x"}`))
+	if code := serve(req); code != http.StatusOK || owner != "key-a" || body != `{"name":"x"}` {
+		t.Fatalf("expected signed request to pass, got %d owner=%q body=%q", code, owner, body)
+	}
+	// 同一个 nonce 在窗口内不能再次使用
+	if code := serve(signedRequest("key-a", http.MethodPost, "/files?b=2&a=1", "n-1", now, []byte(`{"name":"x"}`))); code != http.StatusUnauthorized {
+		t.Fatalf("expected replay to be rejected, got %d", code)
+	}
+
+	cases := map[string]*http.Request{
+		"stale timestamp": signedRequest("key-a", http.MethodGet, "/files", "n-2", now.Add(-2*time.Minute), nil),
+		"unknown key":     signedRequest("key-b", http.MethodGet, "/files", "n-3", now, nil),
+	}
+	tampered := signedRequest("key-a", http.MethodPost, "/files", "n-4", now, []byte("original"))
+	tampered.Body = io.NopCloser(strings.NewReader("modified"))
+	cases["tampered body"] = tampered
+	retargeted := signedRequest("key-a", http.MethodGet, "/files/a", "n-5", now, nil)
+	retargeted.URL.Path = "/files/b"
+	cases["tampered path"] = retargeted
+	for name, req := range cases {
+		if code := serve(req); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, code)
+		}
+	}
+
+	// 其余凭证仍交给 API Key 鉴权
+	req = httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set("Authorization", "ApiKey key-a")
+	if code := serve(req); code != http.StatusOK || owner != "key-a" {
+		t.Fatalf("expected API key fallback, got %d", code)
+	}
+}
+
+func TestSignedRequestAuthenticator_VerifiesLargeBodyBeforeHandler(t *testing.T) {
+	now := time.Now()
+	large := bytes.Repeat([]byte("a"), maxBufferedSignedBody+1)
+	authenticator := NewSignedRequestAuthenticator(NewSigningKeyStore([]string{"key-a"}, nil), nil, time.Minute, int64(len(large)), nil)
+	limiter := NewTransferLimiter(TransferLimits{MaxConcurrent: 1})
+
+	var (
+		called  bool
+		body    []byte
+		spooled string
+	)
+	handler := RequireAuth(authenticator)(limiter.AdmitUploads(int64(len(large)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if pending, ok := r.Body.(*pendingBody); ok {
+			if f, ok := pending.verified.(*spooledBody); ok {
+				spooled = f.Name()
+			}
+		}
+		body, _ = io.ReadAll(r.Body)
+	})))
+	serve := func(req *http.Request) int {
+		called, body, spooled = false, nil, ""
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(signedRequest("key-a", http.MethodPost, "/files", "n-1", now, large)); code != http.StatusOK || !bytes.Equal(body, large) {
+		t.Fatalf("expected matching large body to reach handler intact, got %d (%d bytes)", code, len(body))
+	}
+	if spooled == "" {
+		t.Fatal("expected large body to be spooled to a temporary file")
+	}
+	if _, err := os.Stat(spooled); !os.IsNotExist(err) {
+		t.Fatalf("expected spooled body to be removed after the request, got %v", err)
+	}
+
+	// 请求体被篡改时在进入 handler 之前拒绝，长度未知的流式请求体同样如此
+	modified := signedRequest("key-a", http.MethodPost, "/files", "n-2", now, large)
+	modified.Body = io.NopCloser(bytes.NewReader(bytes.Repeat([]byte("b"), len(large))))
+	streamed := signedRequest("key-a", http.MethodPost, "/files", "n-3", now, large)
+	streamed.Body = io.NopCloser(bytes.NewReader(append(bytes.Repeat([]byte("b"), len(large)-1), 'a')))
+	streamed.ContentLength = -1
+	tooLarge := signedRequest("key-a", http.MethodPost, "/files", "n-4", now, append(large, 'a'))
+	tooLarge.ContentLength = -1
+	for name, req := range map[string]*http.Request{"modified": modified, "streamed": streamed, "too large": tooLarge} {
+		if code := serve(req); code != http.StatusUnauthorized || called {
+			t.Errorf("%s: expected 401 before the handler runs, got %d (handler called: %v)", name, code, called)
+		}
+	}
+
+	// 较大的请求体在获准上传之后才读取，名额用完时不会先被读入临时文件
+	release, err := limiter.Acquire(context.Background(), "other", 1)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+	queued := signedRequest("key-a", http.MethodPost, "/files", "n-5", now, large)
+	reads := &countingBody{ReadCloser: queued.Body}
+	queued.Body = reads
+	if code := serve(queued); code != http.StatusServiceUnavailable || called || reads.n != 0 {
+		t.Fatalf("expected 503 without reading the body, got %d (handler called: %v, %d bytes read)", code, called, reads.n)
+	}
+}
+
+func TestSignedRequestAuthenticator_VerifiesPendingBodyOnFirstRead(t *testing.T) {
+	now := time.Now()
+	large := bytes.Repeat([]byte("a"), maxBufferedSignedBody+1)
+	authenticator := NewSignedRequestAuthenticator(NewSigningKeyStore([]string{"key-a"}, nil), nil, time.Minute, 0, nil)
+
+	var (
+		read []byte
+		err  error
+	)
+	handler := RequireAuth(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, err = io.ReadAll(r.Body)
+	}))
+	// 没有经过 AdmitUploads 的路由在第一次读取时校验，不符时读不到任何内容
+	modified := signedRequest("key-a", http.MethodPut, "/files/abc/metadata", "n-1", now, large)
+	modified.Body = io.NopCloser(bytes.NewReader(bytes.Repeat([]byte("b"), len(large))))
+	handler.ServeHTTP(httptest.NewRecorder(), modified)
+	if err == nil || len(read) != 0 {
+		t.Fatalf("expected the read to fail without returning content, got %d bytes, err %v", len(read), err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), signedRequest("key-a", http.MethodPut, "/files/abc/metadata", "n-2", now, large))
+	if err != nil || !bytes.Equal(read, large) {
+		t.Fatalf("expected the verified body, got %d bytes, err %v", len(read), err)
+	}
+}
+
+// countingBody 记录从请求体读取的字节数。
+type countingBody struct {
+	io.ReadCloser
+	n int
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += n
+	return n, err
+}
+
+func TestMemoryNonceStore_ExpiresClaims(t *testing.T) {
+	store := NewMemoryNonceStore()
+	now := time.Unix(1_700_000_000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if ok, _ := store.Claim(ctx, "n", now.Add(time.Minute)); !ok {
+		t.Fatal("expected first claim to succeed")
+	}
+	if ok, _ := store.Claim(ctx, "n", now.Add(time.Minute)); ok {
+		t.Fatal("expected second claim to fail")
+	}
+	now = now.Add(2 * time.Minute)
+	if ok, _ := store.Claim(ctx, "n", now.Add(time.Minute)); !ok {
+		t.Fatal("expected claim to succeed after expiry")
+	}
+}
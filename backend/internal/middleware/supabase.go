@@ -1,17 +1,38 @@
 package middleware
 
 import (
+	"container/list"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"droplite/internal/auth"
+
 	"github.com/MicahParks/keyfunc/v2"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	defaultSupabaseRemoteTimeout = 5 * time.Second
+	defaultSupabaseCacheTTL      = time.Minute
+	defaultSupabaseCacheSize     = 10000
+	defaultSupabaseClockSkew     = time.Minute
+	// 连续 supabaseBreakerThreshold 次远程校验失败后，在 supabaseBreakerCooldown 内不再请求 Supabase。
+	supabaseBreakerThreshold = 5
+	supabaseBreakerCooldown  = 30 * time.Second
+)
+
+// errNoVerificationKey 表示本地没有可用于校验签名的密钥，此时才回退到远程校验。
+var errNoVerificationKey = errors.New("no suitable verification key")
+
 type headerTransport struct {
 	T   http.RoundTripper
 	Key string
@@ -25,144 +46,391 @@ func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.T.RoundTrip(req)
 }
 
-// validateRemotely 通过调用 Supabase API 验证 Token
-func validateRemotely(ctx context.Context, token, projectURL, anonKey string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/auth/v1/user", strings.TrimRight(projectURL, "/")), nil)
+// SupabaseConfig 描述 Supabase 鉴权所需的配置。
+type SupabaseConfig struct {
+	ProjectURL string
+	AnonKey    string
+	JWTSecret  string
+	// Issuer 为空时使用 <ProjectURL>/auth/v1；两者都为空时不校验 iss。
+	Issuer string
+	// Audiences 非空时，token 的 aud 至少包含其中之一。Supabase 用户 token 的 aud 为 "authenticated"。
+	Audiences []string
+	// ClockSkew 是校验 exp、nbf 与 iat 时容忍的时钟偏差，为 0 时使用 1 分钟。
+	ClockSkew time.Duration
+	// RemoteTimeout 是请求 JWKS 与 /auth/v1/user 的超时，为 0 时使用 5 秒。
+	RemoteTimeout time.Duration
+	// CacheTTL 是远程校验结果的缓存时间，为 0 时使用 1 分钟；CacheSize 为缓存条目上限，为 0 时使用 10000。
+	CacheTTL  time.Duration
+	CacheSize int
+	// HTTPClient 为 nil 时使用带 RemoteTimeout 的默认客户端。
+	HTTPClient *http.Client
+}
+
+// SupabaseAuth 创建 JWT 鉴权中间件。
+// 支持 HMAC (本地), JWKS (远程公钥), 和 Remote User API (直接验证)。
+func SupabaseAuth(cfg SupabaseConfig) func(http.Handler) http.Handler {
+	return RequireAuth(NewSupabaseAuthenticator(cfg))
+}
+
+type supabaseAuthenticator struct {
+	projectURL string
+	anonKey    string
+	jwtSecret  string
+	audiences  []string
+	jwks       *keyfunc.JWKS
+	parser     *jwt.Parser
+	client     *http.Client
+	cache      *remoteResultCache
+	breaker    *circuitBreaker
+	now        func() time.Time
+}
+
+// NewSupabaseAuthenticator 创建校验 Supabase JWT 的 Authenticator。
+//
+// HS256 token 用 JWTSecret 校验，RS256/ES256 token 用项目的 JWKS 校验，二者都会检查 iss、aud 与有效期。
+// 只有本地没有可用密钥（未配置 secret、JWKS 不可用或 kid 未知）时才调用 /auth/v1/user，
+// 结果按 token 哈希缓存；Supabase 连续失败时熔断一段时间，期间远程校验直接失败。
+func NewSupabaseAuthenticator(cfg SupabaseConfig) Authenticator {
+	projectURL := strings.TrimRight(cfg.ProjectURL, "/")
+	timeout := cfg.RemoteTimeout
+	if timeout <= 0 {
+		timeout = defaultSupabaseRemoteTimeout
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+
+	var jwks *keyfunc.JWKS
+	if projectURL != "" && cfg.AnonKey != "" {
+		jwksURL := projectURL + "/auth/v1/jwks"
+		jwksClient := &http.Client{
+			Transport: &headerTransport{T: client.Transport, Key: cfg.AnonKey},
+			Timeout:   timeout,
+		}
+
+		// 初始化 JWKS，包含自动刷新
+		var err error
+		jwks, err = keyfunc.Get(jwksURL, keyfunc.Options{
+			Client:            jwksClient,
+			RefreshInterval:   time.Hour,
+			RefreshRateLimit:  time.Minute,
+			RefreshUnknownKID: true,
+			RefreshErrorHandler: func(err error) {
+				log.Printf("[supabase] refresh JWKS: %v", err)
+			},
+		})
+		if err != nil {
+			log.Printf("[supabase] load JWKS from %s: %v; asymmetric tokens fall back to remote validation", jwksURL, err)
+			jwks = nil
+		}
+	}
+
+	issuer := cfg.Issuer
+	if issuer == "" && projectURL != "" {
+		issuer = projectURL + "/auth/v1"
+	}
+	skew := cfg.ClockSkew
+	if skew <= 0 {
+		skew = defaultSupabaseClockSkew
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "ES256"}),
+		jwt.WithLeeway(skew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if len(cfg.Audiences) > 0 {
+		options = append(options, jwt.WithAudience(cfg.Audiences...))
+	}
+
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultSupabaseCacheTTL
+	}
+	size := cfg.CacheSize
+	if size <= 0 {
+		size = defaultSupabaseCacheSize
+	}
+	return &supabaseAuthenticator{
+		projectURL: projectURL,
+		anonKey:    cfg.AnonKey,
+		jwtSecret:  cfg.JWTSecret,
+		audiences:  cfg.Audiences,
+		jwks:       jwks,
+		parser:     jwt.NewParser(options...),
+		client:     client,
+		cache:      newRemoteResultCache(ttl, size),
+		breaker:    &circuitBreaker{threshold: supabaseBreakerThreshold, cooldown: supabaseBreakerCooldown},
+		now:        time.Now,
+	}
+}
+
+func (a *supabaseAuthenticator) Authenticate(ctx context.Context, authHeader string) (auth.Principal, error) {
+	if authHeader == "" {
+		return auth.Principal{}, &AuthError{Message: "missing Authorization header"}
+	}
+
+	const prefix = "Bearer "
+	if !strings.HasPrefix(authHeader, prefix) {
+		return auth.Principal{}, &AuthError{Message: "invalid Authorization format, expected: Bearer <token>"}
+	}
+
+	tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
+	if tokenString == "" {
+		return auth.Principal{}, &AuthError{Message: "empty token"}
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(tokenString, claims, a.keyfunc)
+	if err == nil {
+		sub, _ := claims["sub"].(string)
+		if sub == "" {
+			return auth.Principal{}, &AuthError{Message: "token has no sub claim"}
+		}
+		return auth.Principal{OwnerID: sub, Scopes: scopesFromClaims(claims)}, nil
+	}
+	// 签名、有效期、iss 或 aud 不符的 token 直接拒绝，不再交给 Supabase
+	if !errors.Is(err, errNoVerificationKey) && !errors.Is(err, keyfunc.ErrKIDNotFound) {
+		return auth.Principal{}, &AuthError{Message: tokenErrorMessage(err)}
+	}
+
+	if a.projectURL == "" || a.anonKey == "" {
+		return auth.Principal{}, &AuthError{Message: "token verification failed and remote validation not configured"}
+	}
+	return a.authenticateRemotely(ctx, tokenString)
+}
+
+func (a *supabaseAuthenticator) keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if a.jwtSecret == "" {
+			return nil, errNoVerificationKey
+		}
+		return []byte(a.jwtSecret), nil
+	}
+	if a.jwks == nil {
+		return nil, errNoVerificationKey
+	}
+	return a.jwks.Keyfunc(token)
+}
+
+func (a *supabaseAuthenticator) authenticateRemotely(ctx context.Context, token string) (auth.Principal, error) {
+	key := sha256.Sum256([]byte(token))
+	now := a.now()
+	if result, ok := a.cache.get(key, now); ok {
+		if result.err != nil {
+			return auth.Principal{}, result.err
+		}
+		return result.principal, nil
+	}
+
+	if !a.breaker.allow(now) {
+		return auth.Principal{}, &AuthError{Message: "token verification temporarily unavailable"}
+	}
+	principal, rejected, err := a.validateRemotely(ctx, token)
+	if err != nil {
+		// 客户端断开导致的取消不代表 Supabase 不可用
+		if ctx.Err() != nil {
+			a.breaker.abort()
+			return auth.Principal{}, &AuthError{Message: "token verification canceled"}
+		}
+		if a.breaker.failure(a.now()) {
+			log.Printf("[supabase] remote validation failing, pausing for %s: %v", a.breaker.cooldown, err)
+		}
+		return auth.Principal{}, &AuthError{Message: "token verification temporarily unavailable"}
+	}
+	a.breaker.success()
+
+	// Supabase 明确拒绝的 token 同样缓存，避免同一个无效 token 反复触发远程请求
+	result := remoteResult{principal: principal}
+	if rejected != "" {
+		result = remoteResult{err: &AuthError{Message: rejected}}
+	}
+	a.cache.put(key, result, now, tokenExpiry(token))
+	return result.principal, result.err
+}
+
+// validateRemotely 通过调用 Supabase API 验证 Token。
+// rejected 非空表示 Supabase 给出了明确的拒绝结论；err 表示请求失败，结论未知。
+func (a *supabaseAuthenticator) validateRemotely(ctx context.Context, token string) (principal auth.Principal, rejected string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.projectURL+"/auth/v1/user", nil)
 	if err != nil {
-		return "", err
+		return auth.Principal{}, "", err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("apikey", anonKey)
+	req.Header.Set("apikey", a.anonKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := a.client.Do(req)
 	if err != nil {
-		return "", err
+		return auth.Principal{}, "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("remote validation failed with status: %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return auth.Principal{}, "invalid token", nil
+	case resp.StatusCode != http.StatusOK:
+		return auth.Principal{}, "", fmt.Errorf("remote validation failed with status: %d", resp.StatusCode)
 	}
 
-	// 解析简单的 User 结构
+	// 解析简单的 User 结构，app_metadata 与 JWT 中的同名声明一致
 	var user struct {
-		ID string `json:"id"`
+		ID          string         `json:"id"`
+		Aud         string         `json:"aud"`
+		AppMetadata map[string]any `json:"app_metadata"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return "", fmt.Errorf("failed to decode user: %v", err)
+		return auth.Principal{}, "", fmt.Errorf("failed to decode user: %v", err)
+	}
+	if user.ID == "" {
+		return auth.Principal{}, "invalid token", nil
+	}
+	if len(a.audiences) > 0 && user.Aud != "" && !slices.Contains(a.audiences, user.Aud) {
+		return auth.Principal{}, "token audience is not accepted", nil
 	}
-	return user.ID, nil
+	return auth.Principal{
+		OwnerID: user.ID,
+		Scopes:  scopesFromClaims(map[string]any{"app_metadata": user.AppMetadata}),
+	}, "", nil
 }
 
-// SupabaseAuth 创建 JWT 鉴权中间件。
-// 支持 HMAC (本地), JWKS (远程公钥), 和 Remote User API (直接验证)。
-func SupabaseAuth(projectURL, anonKey, jwtSecret string) func(http.Handler) http.Handler {
-	var jwks *keyfunc.JWKS
-	var err error
+// tokenExpiry 读取未经校验的 exp，只用于让缓存条目不晚于 token 本身失效。
+func tokenExpiry(token string) time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return time.Time{}
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
 
-	if projectURL != "" && anonKey != "" {
-		jwksURL := fmt.Sprintf("%s/auth/v1/jwks", strings.TrimRight(projectURL, "/"))
-		client := &http.Client{
-			Transport: &headerTransport{Key: anonKey},
-		}
+type remoteResult struct {
+	principal auth.Principal
+	err       error
+}
 
-		// 初始化 JWKS，包含自动刷新
-		jwks, err = keyfunc.Get(jwksURL, keyfunc.Options{
-			Client:          client,
-			RefreshInterval: time.Hour,
-			RefreshErrorHandler: func(err error) {
-				fmt.Printf("[AuthError] JWKS refresh failed: %v\n", err)
-			},
-		})
-		if err != nil {
-			fmt.Printf("[AuthWarning] JWKS init failed (%s): %v. Will fall back to remote validation for ES256.\n", jwksURL, err)
-		} else {
-			fmt.Println("[AuthInfo] JWKS initialized successfully.")
-		}
+type remoteCacheEntry struct {
+	key     [sha256.Size]byte
+	result  remoteResult
+	expires time.Time
+}
+
+// remoteResultCache 是有容量上限的 TTL 缓存，满了以后淘汰最早写入的条目。
+// 键是 token 的 SHA-256，内存中不保留 token 原文。
+type remoteResultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+func newRemoteResultCache(ttl time.Duration, size int) *remoteResultCache {
+	return &remoteResultCache{
+		ttl:     ttl,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
 	}
+}
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				writeAuthError(w, http.StatusUnauthorized, "missing Authorization header")
-				return
-			}
-
-			const prefix = "Bearer "
-			if !strings.HasPrefix(authHeader, prefix) {
-				writeAuthError(w, http.StatusUnauthorized, "invalid Authorization format, expected: Bearer <token>")
-				return
-			}
-
-			tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
-			if tokenString == "" {
-				writeAuthError(w, http.StatusUnauthorized, "empty token")
-				return
-			}
-
-			// 验证逻辑：
-			// 1. 尝试本地 HMAC (如果 alg=HS256 且有 Secret)
-			// 2. 尝试 JWKS (如果 alg=ES256/RS256 且 JWKS 可用)
-			// 3. 回退到 Remote API (如果上述都失败)
-
-			var userID string
-
-			// 尝试解析获取 Header
-			parser := jwt.NewParser()
-			unverifiedToken, _, _ := parser.ParseUnverified(tokenString, jwt.MapClaims{})
-
-			var validatedLocally bool
-
-			if unverifiedToken != nil {
-				alg, _ := unverifiedToken.Header["alg"].(string)
-				// 尝试本地解析
-				token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-					if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
-						if jwtSecret != "" {
-							return []byte(jwtSecret), nil
-						}
-					}
-					// 只有当 JWKS 初始化成功时才尝试使用 keyfunc
-					if jwks != nil {
-						// 简单的优化：如果 alg 不是 HMAC，尝试 JWKS
-						return jwks.Keyfunc(token)
-					}
-					return nil, fmt.Errorf("no suitable verification method")
-				})
-
-				if err == nil && token.Valid {
-					if claims, ok := token.Claims.(jwt.MapClaims); ok {
-						if sub, ok := claims["sub"].(string); ok && sub != "" {
-							userID = sub
-							validatedLocally = true
-							// fmt.Printf("[AuthDebug] Local validation success via %s\n", alg)
-						}
-					}
-				} else {
-					fmt.Printf("[AuthDebug] Local validation failed (%s): %v. Trying remote...\n", alg, err)
-				}
-			}
-
-			// 如果本地验证失败，尝试远程验证
-			if !validatedLocally {
-				if projectURL == "" || anonKey == "" {
-					writeAuthError(w, http.StatusUnauthorized, "token verification failed and remote validation not configured")
-					return
-				}
-
-				uid, err := validateRemotely(r.Context(), tokenString, projectURL, anonKey)
-				if err != nil {
-					fmt.Printf("[AuthError] Remote validation failed: %v\n", err)
-					writeAuthError(w, http.StatusUnauthorized, "invalid token (remote)")
-					return
-				}
-				userID = uid
-				fmt.Printf("[AuthDebug] Remote validation success for user: %s\n", userID)
-			}
-
-			ctx := context.WithValue(r.Context(), OwnerContextKey{}, userID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+func (c *remoteResultCache) get(key [sha256.Size]byte, now time.Time) (remoteResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return remoteResult{}, false
+	}
+	entry := elem.Value.(*remoteCacheEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return remoteResult{}, false
+	}
+	return entry.result, true
+}
+
+// put 写入结果；tokenExpiry 早于 TTL 时以 tokenExpiry 为准，已过期的 token 不缓存。
+func (c *remoteResultCache) put(key [sha256.Size]byte, result remoteResult, now, tokenExpiry time.Time) {
+	expires := now.Add(c.ttl)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expires) {
+		expires = tokenExpiry
+	}
+	if !now.Before(expires) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	for c.order.Len() >= c.size {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*remoteCacheEntry).key)
+	}
+	c.entries[key] = c.order.PushBack(&remoteCacheEntry{key: key, result: result, expires: expires})
+}
+
+// circuitBreaker 在连续 threshold 次失败后断开 cooldown 时长；
+// 冷却结束后只放行一个试探请求，成功则恢复，失败则重新断开。
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// abort 放弃本次请求，既不算成功也不算失败。
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// failure 记录一次失败，返回本次失败是否使熔断器断开。
+func (b *circuitBreaker) failure(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold {
+		return false
 	}
+	b.openUntil = now.Add(b.cooldown)
+	return true
 }
@@ -0,0 +1,298 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"droplite/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testAnonKey = "anon-key"
+
+// fakeSupabase 模拟 Supabase 的 /auth/v1/jwks 与 /auth/v1/user。
+type fakeSupabase struct {
+	server    *httptest.Server
+	rsa       *rsa.PrivateKey
+	userCalls atomic.Int32
+
+	mu     sync.Mutex
+	status int           // /auth/v1/user 的状态码，0 表示按 token 正常应答
+	delay  time.Duration // /auth/v1/user 的响应延迟
+	users  map[string]string
+}
+
+func newFakeSupabase(t *testing.T) *fakeSupabase {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	fake := &fakeSupabase{rsa: key, users: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/v1/jwks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apikey") != testAnonKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig",
+			"n": b64(key.N.Bytes()),
+			"e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/auth/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		fake.userCalls.Add(1)
+		fake.mu.Lock()
+		status, delay := fake.status, fake.delay
+		user, ok := fake.users[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		fake.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		if !ok || r.Header.Get("apikey") != testAnonKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":           user,
+			"aud":          "authenticated",
+			"app_metadata": map[string]any{"role": "viewer"},
+		})
+	})
+	fake.server = httptest.NewServer(mux)
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func (f *fakeSupabase) set(status int, delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.delay = status, delay
+}
+
+func (f *fakeSupabase) claims(overrides jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": f.server.URL + "/auth/v1",
+		"aud": "authenticated",
+		"sub": "user-1",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+	return claims
+}
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func (f *fakeSupabase) signRS256(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(f.rsa)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func (f *fakeSupabase) authenticator(jwtSecret string) *supabaseAuthenticator {
+	return NewSupabaseAuthenticator(SupabaseConfig{
+		ProjectURL:    f.server.URL,
+		AnonKey:       testAnonKey,
+		JWTSecret:     jwtSecret,
+		Audiences:     []string{"authenticated"},
+		RemoteTimeout: 200 * time.Millisecond,
+	}).(*supabaseAuthenticator)
+}
+
+func authErrorMessage(t *testing.T, err error) string {
+	t.Helper()
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected AuthError, got %v", err)
+	}
+	return authErr.Message
+}
+
+func TestSupabaseAuthenticator_LocalValidation(t *testing.T) {
+	const secret = "jwt-secret"
+	fake := newFakeSupabase(t)
+	authenticator := fake.authenticator(secret)
+	ctx := context.Background()
+
+	p, err := authenticator.Authenticate(ctx, "Bearer "+signHS256(t, secret, fake.claims(nil)))
+	if err != nil || p.OwnerID != "user-1" {
+		t.Fatalf("HS256: principal %+v, err %v", p, err)
+	}
+	p, err = authenticator.Authenticate(ctx, "Bearer "+fake.signRS256(t, "rsa-1", fake.claims(jwt.MapClaims{"sub": "user-2"})))
+	if err != nil || p.OwnerID != "user-2" {
+		t.Fatalf("RS256: principal %+v, err %v", p, err)
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"wrong audience", signHS256(t, secret, fake.claims(jwt.MapClaims{"aud": "anon"})), "token audience is not accepted"},
+		{"wrong issuer", signHS256(t, secret, fake.claims(jwt.MapClaims{"iss": "supabase"})), "token issuer is not accepted"},
+		{"expired", signHS256(t, secret, fake.claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), "token has expired"},
+		{"bad signature", signHS256(t, "other-secret", fake.claims(nil)), "invalid token"},
+		{"missing sub", signHS256(t, secret, fake.claims(jwt.MapClaims{"sub": ""})), "token has no sub claim"},
+	}
+	for _, tc := range rejected {
+		if _, err := authenticator.Authenticate(ctx, "Bearer "+tc.token); authErrorMessage(t, err) != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.name, authErrorMessage(t, err), tc.want)
+		}
+	}
+	// 本地能够判定的 token 不应触发远程校验
+	if calls := fake.userCalls.Load(); calls != 0 {
+		t.Fatalf("expected no remote calls, got %d", calls)
+	}
+}
+
+func TestSupabaseAuthenticator_RemoteValidationIsCached(t *testing.T) {
+	fake := newFakeSupabase(t)
+	authenticator := fake.authenticator("")
+	ctx := context.Background()
+
+	// 未配置 JWT secret 时 HS256 token 交给 Supabase 校验
+	valid := signHS256(t, "unknown", fake.claims(nil))
+	fake.users[valid] = "remote-user"
+	for i := 0; i < 3; i++ {
+		p, err := authenticator.Authenticate(ctx, "Bearer "+valid)
+		if err != nil || p.OwnerID != "remote-user" {
+			t.Fatalf("remote: principal %+v, err %v", p, err)
+		}
+		if len(p.Scopes) != 1 || p.Scopes[0] != auth.ScopeFilesRead {
+			t.Fatalf("expected viewer scopes from app_metadata, got %v", p.Scopes)
+		}
+	}
+	if calls := fake.userCalls.Load(); calls != 1 {
+		t.Fatalf("expected one remote call for repeated token, got %d", calls)
+	}
+
+	// Supabase 明确拒绝的结果同样缓存
+	invalid := fake.signRS256(t, "unknown-kid", fake.claims(nil))
+	for i := 0; i < 2; i++ {
+		if _, err := authenticator.Authenticate(ctx, "Bearer "+invalid); authErrorMessage(t, err) != "invalid token" {
+			t.Fatalf("expected remote rejection, got %v", err)
+		}
+	}
+	if calls := fake.userCalls.Load(); calls != 2 {
+		t.Fatalf("expected rejection to be cached, got %d remote calls", calls)
+	}
+
+	// 条目过期后重新请求
+	authenticator.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := authenticator.Authenticate(ctx, "Bearer "+valid); err != nil {
+		t.Fatalf("remote after expiry: %v", err)
+	}
+	if calls := fake.userCalls.Load(); calls != 3 {
+		t.Fatalf("expected cache entry to expire, got %d remote calls", calls)
+	}
+}
+
+func TestSupabaseAuthenticator_CircuitBreaker(t *testing.T) {
+	fake := newFakeSupabase(t)
+	authenticator := fake.authenticator("")
+	ctx := context.Background()
+	fake.set(http.StatusBadGateway, 0)
+
+	token := func(i int) string {
+		return "Bearer " + signHS256(t, "unknown", fake.claims(jwt.MapClaims{"jti": string(rune('a' + i))}))
+	}
+	for i := 0; i < supabaseBreakerThreshold+3; i++ {
+		if _, err := authenticator.Authenticate(ctx, token(i)); authErrorMessage(t, err) != "token verification temporarily unavailable" {
+			t.Fatalf("call %d: unexpected error %v", i, err)
+		}
+	}
+	if calls := fake.userCalls.Load(); calls != supabaseBreakerThreshold {
+		t.Fatalf("expected breaker to stop after %d calls, got %d", supabaseBreakerThreshold, calls)
+	}
+
+	// 冷却结束后放行试探请求，成功则恢复
+	fake.set(0, 0)
+	later := time.Now().Add(supabaseBreakerCooldown + time.Second)
+	authenticator.now = func() time.Time { return later }
+	recovered := signHS256(t, "unknown", fake.claims(nil))
+	fake.users[recovered] = "user-1"
+	if _, err := authenticator.Authenticate(ctx, "Bearer "+recovered); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if !authenticator.breaker.allow(later) {
+		t.Fatal("expected breaker to close after successful probe")
+	}
+}
+
+func TestSupabaseAuthenticator_RemoteTimeout(t *testing.T) {
+	fake := newFakeSupabase(t)
+	authenticator := fake.authenticator("")
+	fake.set(0, 2*time.Second)
+
+	start := time.Now()
+	_, err := authenticator.Authenticate(context.Background(), "Bearer "+signHS256(t, "unknown", fake.claims(nil)))
+	if authErrorMessage(t, err) != "token verification temporarily unavailable" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected remote call to time out quickly, took %s", elapsed)
+	}
+}
+
+func TestRemoteResultCache_Bounded(t *testing.T) {
+	cache := newRemoteResultCache(time.Minute, 2)
+	now := time.Now()
+	keys := [][32]byte{{1}, {2}, {3}}
+	for i, key := range keys {
+		cache.put(key, remoteResult{principal: auth.Principal{OwnerID: string(rune('a' + i))}}, now, time.Time{})
+	}
+	if cache.order.Len() != 2 {
+		t.Fatalf("expected cache to hold 2 entries, got %d", cache.order.Len())
+	}
+	if _, ok := cache.get(keys[0], now); ok {
+		t.Fatal("expected oldest entry to be evicted")
+	}
+	if r, ok := cache.get(keys[2], now); !ok || r.principal.OwnerID != "c" {
+		t.Fatalf("expected newest entry, got %+v %v", r, ok)
+	}
+
+	// 不晚于 token 自身的过期时间
+	cache.put(keys[0], remoteResult{}, now, now.Add(time.Second))
+	if _, ok := cache.get(keys[0], now.Add(2*time.Second)); ok {
+		t.Fatal("expected entry to expire with the token")
+	}
+}
@@ -0,0 +1,479 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// uploadRetryAfter 是上传因容量不足被拒绝时建议的重试间隔。
+	uploadRetryAfter = 5 * time.Second
+	// queueResponseGrace 是排队结束后写出响应（包括 503）的余量。
+	queueResponseGrace = 10 * time.Second
+)
+
+var (
+	uploadsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "upload_active",
+		Help: "Number of uploads currently admitted",
+	})
+	uploadsInFlightBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "upload_inflight_bytes",
+		Help: "Bytes reserved by admitted uploads",
+	})
+	uploadsQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "upload_queued",
+		Help: "Number of uploads waiting for admission",
+	})
+	uploadsRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "upload_rejected_total",
+		Help: "Uploads rejected because admission limits were reached",
+	})
+	shapedStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "throughput_shaped_streams",
+		Help: "Number of upload and download streams subject to per-owner throughput shaping",
+	}, []string{"direction"})
+	shapedOwners = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "throughput_shaped_owners",
+		Help: "Number of owners with at least one shaped stream",
+	})
+	shapingDelay = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "throughput_throttled_seconds_total",
+		Help: "Time streams spent waiting for per-owner throughput budget",
+	}, []string{"direction"})
+)
+
+// ErrUploadsBusy 表示排队超时仍未获得上传名额。
+var ErrUploadsBusy = errors.New("too many uploads in progress")
+
+// TransferLimits 描述上传准入与按 owner 限速的配置，为 0 的字段表示不限制。
+type TransferLimits struct {
+	// MaxConcurrent 与 MaxConcurrentPerOwner 限制同时进行的上传数。
+	MaxConcurrent         int
+	MaxConcurrentPerOwner int
+	// MaxInFlightBytes 与 MaxInFlightBytesPerOwner 限制正在上传的字节数，按 Content-Length 预留。
+	MaxInFlightBytes         int64
+	MaxInFlightBytesPerOwner int64
+	// QueueTimeout 是超出限制时排队等待的最长时间，为 0 时立即返回 503。
+	QueueTimeout time.Duration
+	// OwnerBytesPerSecond 限制每个 owner 上传与下载的总吞吐，同一 owner 的所有流共享额度。
+	OwnerBytesPerSecond int64
+	// TransferTimeout 是获准的 HTTP 上传与下载可以持续的最长时间，取代服务器的 ReadTimeout 与 WriteTimeout；
+	// 为 0 时不设截止时间。
+	TransferTimeout time.Duration
+}
+
+// TransferLimiter 对上传做准入控制，并按 owner 对上传与下载限速。
+// 调用方以 owner ID 区分，未鉴权时以客户端 IP 区分，与限流使用相同的键。nil 值不做任何限制。
+type TransferLimiter struct {
+	limits TransferLimits
+
+	mu      sync.Mutex
+	active  int
+	bytes   int64
+	owners  map[string]*ownerUsage
+	wake    chan struct{}
+	buckets map[string]*byteBucket
+}
+
+type ownerUsage struct {
+	active int
+	bytes  int64
+}
+
+func NewTransferLimiter(limits TransferLimits) *TransferLimiter {
+	return &TransferLimiter{
+		limits:  limits,
+		owners:  make(map[string]*ownerUsage),
+		wake:    make(chan struct{}),
+		buckets: make(map[string]*byteBucket),
+	}
+}
+
+// AdmitUploads 在读取请求体之前为上传预留名额与字节数，按 Content-Length 预留，没有时按 unknownSize 预留。
+// 排队超时返回 503 与 Retry-After；获准后请求体按 owner 限速，连接的读写截止时间推迟 TransferTimeout。
+// 签名请求的较大请求体在获准后读取并校验哈希，不符时返回 401，handler 不会运行。
+func (l *TransferLimiter) AdmitUploads(unknownSize int64) func(http.Handler) http.Handler {
+	if l == nil {
+		return passthrough
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := rateLimitIdentity(r)
+			size := UploadReservation(r.ContentLength, unknownSize)
+
+			// 排队期间不受服务器 ReadTimeout/WriteTimeout 限制，并留出写 503 的时间
+			extendDeadlines(w, l.limits.QueueTimeout+queueResponseGrace)
+			release, err := l.Acquire(r.Context(), key, size)
+			if err != nil {
+				if errors.Is(err, ErrUploadsBusy) {
+					w.Header().Set("Retry-After", UploadRetryAfter())
+					writeError(w, r, http.StatusServiceUnavailable, "upload_capacity_exceeded", err.Error())
+				}
+				// 其余错误只可能是客户端取消，无需响应
+				return
+			}
+			defer release()
+			extendDeadlines(w, l.limits.TransferTimeout)
+
+			// 推迟校验的签名请求体在此时才读取，对网络读取限速后再校验
+			target := &r.Body
+			if pending, ok := r.Body.(*pendingBody); ok {
+				target = &pending.src
+			}
+			body, done := l.ShapeReader(r.Context(), key, *target)
+			defer done()
+			*target = body
+			if err := verifyPendingBody(r); err != nil {
+				var authErr *AuthError
+				if errors.As(err, &authErr) {
+					writeAuthError(w, r, http.StatusUnauthorized, authErr.Message)
+				} else {
+					writeError(w, r, http.StatusInternalServerError, "internal", "unable to read request body")
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ShapeDownloads 按 owner 对响应体限速，并把连接的写截止时间推迟 TransferTimeout。
+func (l *TransferLimiter) ShapeDownloads() func(http.Handler) http.Handler {
+	if l == nil {
+		return passthrough
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			extendDeadlines(w, l.limits.TransferTimeout)
+			shaped, done := l.ShapeResponseWriter(r.Context(), rateLimitIdentity(r), w)
+			defer done()
+			next.ServeHTTP(shaped, r)
+		})
+	}
+}
+
+// Acquire 为 key 的一次上传预留名额与 size 字节，超出限制时最多排队 QueueTimeout，
+// 仍未获准时返回 ErrUploadsBusy。供不经过 HTTP 中间件的入口（如 gRPC、S3 网关）使用。nil 值总是立即获准。
+func (l *TransferLimiter) Acquire(ctx context.Context, key string, size int64) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	return l.acquire(ctx, key, size)
+}
+
+// ShapeReader 按 key 对从 body 读取的数据限速，传输结束后须调用返回的 done；未配置限速时原样返回。
+func (l *TransferLimiter) ShapeReader(ctx context.Context, key string, body io.ReadCloser) (io.ReadCloser, func()) {
+	if l == nil {
+		return body, func() {}
+	}
+	bucket := l.bucket(key, "upload")
+	if bucket == nil {
+		return body, func() {}
+	}
+	return &shapedReader{ReadCloser: body, ctx: ctx, bucket: bucket}, func() { l.releaseBucket(key, "upload") }
+}
+
+// ShapeWriter 按 key 对写入 w 的数据限速，传输结束后须调用返回的 done；未配置限速时原样返回。
+func (l *TransferLimiter) ShapeWriter(ctx context.Context, key string, w io.Writer) (io.Writer, func()) {
+	if l == nil {
+		return w, func() {}
+	}
+	bucket := l.bucket(key, "download")
+	if bucket == nil {
+		return w, func() {}
+	}
+	return &shapedWriter{w: w, ctx: ctx, bucket: bucket}, func() { l.releaseBucket(key, "download") }
+}
+
+// ShapeResponseWriter 与 ShapeWriter 相同，但保留 http.ResponseWriter 的其余方法。
+func (l *TransferLimiter) ShapeResponseWriter(ctx context.Context, key string, w http.ResponseWriter) (http.ResponseWriter, func()) {
+	if l == nil {
+		return w, func() {}
+	}
+	bucket := l.bucket(key, "download")
+	if bucket == nil {
+		return w, func() {}
+	}
+	return &shapedResponseWriter{ResponseWriter: w, shaped: shapedWriter{w: w, ctx: ctx, bucket: bucket}}, func() { l.releaseBucket(key, "download") }
+}
+
+// UploadReservation 返回上传应预留的字节数：按声明的长度预留，未知或超过 limit 时按 limit 预留，
+// 超出 limit 的请求体会被截断，不必按声明的长度预留。
+func UploadReservation(declared, limit int64) int64 {
+	if declared < 0 || declared > limit {
+		return limit
+	}
+	return declared
+}
+
+// UploadRetryAfter 是上传被拒绝时 Retry-After 头的取值。
+func UploadRetryAfter() string {
+	return ceilSeconds(uploadRetryAfter)
+}
+
+// TransferKey 返回传输限制使用的调用方标识：已鉴权时为 owner，否则为客户端地址，与 HTTP 入口一致。
+func TransferKey(ctx context.Context, addr netip.Addr) string {
+	if owner := GetOwnerID(ctx); owner != "" {
+		return "owner:" + owner
+	}
+	return "ip:" + addr.String()
+}
+
+// extendDeadlines 把连接的读写截止时间推迟到 d 之后，d 为 0 时清除截止时间。
+// 不支持设置截止时间的 ResponseWriter（如测试中的 Recorder）会被忽略。
+func extendDeadlines(w http.ResponseWriter, d time.Duration) {
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+}
+
+// acquire 等待直到全局与该 owner 的并发数、字节数都有余量，返回释放函数。
+func (l *TransferLimiter) acquire(ctx context.Context, key string, size int64) (func(), error) {
+	var timeout <-chan time.Time
+	if l.limits.QueueTimeout > 0 {
+		timer := time.NewTimer(l.limits.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	queued := false
+	defer func() {
+		if queued {
+			uploadsQueued.Dec()
+		}
+	}()
+	for {
+		l.mu.Lock()
+		if l.tryAcquireLocked(key, size) {
+			l.mu.Unlock()
+			return func() { l.release(key, size) }, nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		if timeout == nil {
+			uploadsRejected.Inc()
+			return nil, ErrUploadsBusy
+		}
+		if !queued {
+			queued = true
+			uploadsQueued.Inc()
+		}
+		select {
+		case <-wake:
+		case <-timeout:
+			uploadsRejected.Inc()
+			return nil, ErrUploadsBusy
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAcquireLocked 检查并预留名额。单个超过字节上限的上传在没有其他上传时仍可进行，否则它永远无法获准。
+func (l *TransferLimiter) tryAcquireLocked(key string, size int64) bool {
+	usage := l.owners[key]
+	if usage == nil {
+		usage = &ownerUsage{}
+	}
+	if !fits(l.active, l.limits.MaxConcurrent) || !fits(usage.active, l.limits.MaxConcurrentPerOwner) {
+		return false
+	}
+	if !fitsBytes(l.bytes, size, l.limits.MaxInFlightBytes) || !fitsBytes(usage.bytes, size, l.limits.MaxInFlightBytesPerOwner) {
+		return false
+	}
+
+	l.active++
+	l.bytes += size
+	usage.active++
+	usage.bytes += size
+	l.owners[key] = usage
+	uploadsActive.Inc()
+	uploadsInFlightBytes.Add(float64(size))
+	return true
+}
+
+func fits(current, limit int) bool {
+	return limit <= 0 || current < limit
+}
+
+func fitsBytes(current, size, limit int64) bool {
+	return limit <= 0 || current == 0 || current+size <= limit
+}
+
+func (l *TransferLimiter) release(key string, size int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	l.bytes -= size
+	if usage := l.owners[key]; usage != nil {
+		usage.active--
+		usage.bytes -= size
+		if usage.active <= 0 {
+			delete(l.owners, key)
+		}
+	}
+	uploadsActive.Dec()
+	uploadsInFlightBytes.Sub(float64(size))
+
+	// 唤醒所有排队者重新检查
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// bucket 返回 owner 的限速桶并登记一个流，未配置限速时返回 nil。
+func (l *TransferLimiter) bucket(key, direction string) *byteBucket {
+	if l.limits.OwnerBytesPerSecond <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.buckets[key]
+	if b == nil {
+		b = newByteBucket(l.limits.OwnerBytesPerSecond)
+		l.buckets[key] = b
+		shapedOwners.Inc()
+	}
+	b.streams++
+	shapedStreams.WithLabelValues(direction).Inc()
+	return b
+}
+
+// releaseBucket 注销一个流，owner 没有活跃的流时删除其限速桶。
+func (l *TransferLimiter) releaseBucket(key, direction string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	shapedStreams.WithLabelValues(direction).Dec()
+	b := l.buckets[key]
+	if b == nil {
+		return
+	}
+	b.streams--
+	if b.streams <= 0 {
+		delete(l.buckets, key)
+		shapedOwners.Dec()
+	}
+}
+
+// byteBucket 是按字节计的令牌桶，容量为一秒的额度。
+type byteBucket struct {
+	mu      sync.Mutex
+	rate    int64
+	tat     time.Time
+	streams int // 由 TransferLimiter.mu 保护
+	// chunk 是单次读写的最大字节数，不超过一秒的额度
+	chunk int
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+}
+
+func newByteBucket(rate int64) *byteBucket {
+	chunk := 32 * 1024
+	if rate < int64(chunk) {
+		chunk = int(rate)
+	}
+	return &byteBucket{rate: rate, chunk: chunk, sleep: sleepContext, now: time.Now}
+}
+
+// wait 为 n 字节预留额度，额度不足时阻塞。n 不应超过 chunk。
+func (b *byteBucket) wait(ctx context.Context, n int, direction string) error {
+	cost := time.Duration(float64(n) / float64(b.rate) * float64(time.Second))
+
+	b.mu.Lock()
+	now := b.now()
+	if b.tat.Before(now) {
+		b.tat = now
+	}
+	b.tat = b.tat.Add(cost)
+	delay := b.tat.Sub(now) - time.Second
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	shapingDelay.WithLabelValues(direction).Add(delay.Seconds())
+	return b.sleep(ctx, delay)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type shapedReader struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *byteBucket
+}
+
+func (r *shapedReader) Read(p []byte) (int, error) {
+	if len(p) > r.bucket.chunk {
+		p = p[:r.bucket.chunk]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := r.bucket.wait(r.ctx, n, "upload"); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type shapedWriter struct {
+	w      io.Writer
+	ctx    context.Context
+	bucket *byteBucket
+}
+
+func (w *shapedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.bucket.chunk)
+		if err := w.bucket.wait(w.ctx, n, "download"); err != nil {
+			return written, err
+		}
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type shapedResponseWriter struct {
+	http.ResponseWriter
+	shaped shapedWriter
+}
+
+func (w *shapedResponseWriter) Write(p []byte) (int, error) {
+	return w.shaped.Write(p)
+}
+
+// Unwrap 暴露底层 ResponseWriter，供 http.ResponseController 使用。
+func (w *shapedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransferLimiter_AdmitUploads(t *testing.T) {
+	limiter := NewTransferLimiter(TransferLimits{
+		MaxConcurrent:            3,
+		MaxConcurrentPerOwner:    1,
+		MaxInFlightBytesPerOwner: 100,
+	})
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	handler := limiter.AdmitUploads(1000)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Block") != "" {
+			entered <- struct{}{}
+			<-unblock
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	upload := func(owner string, size int, block bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/files", strings.NewReader(strings.Repeat("x", size)))
+		req = req.WithContext(WithOwnerID(req.Context(), owner))
+		if block {
+			req.Header.Set("X-Block", "1")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- upload("alice", 10, true) }()
+	<-entered
+
+	// 同一 owner 已达并发上限，不排队时立即返回 503
+	rec := upload("alice", 10, false)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "5" {
+		t.Fatalf("expected 503 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+	// 其他 owner 不受影响
+	if rec := upload("bob", 10, false); rec.Code != http.StatusCreated {
+		t.Fatalf("expected other owner to be admitted, got %d", rec.Code)
+	}
+
+	close(unblock)
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Fatalf("blocked upload: got %d", rec.Code)
+	}
+	if rec := upload("alice", 10, false); rec.Code != http.StatusCreated {
+		t.Fatalf("expected slot to be released, got %d", rec.Code)
+	}
+	// 单个超过字节上限的上传在空闲时仍可进行
+	if rec := upload("alice", 500, false); rec.Code != http.StatusCreated {
+		t.Fatalf("expected oversized upload to be admitted when idle, got %d", rec.Code)
+	}
+	if len(limiter.owners) != 0 || limiter.active != 0 || limiter.bytes != 0 {
+		t.Fatalf("expected all reservations to be released, got %d %d %v", limiter.active, limiter.bytes, limiter.owners)
+	}
+}
+
+func TestTransferLimiter_QueuesUntilCapacity(t *testing.T) {
+	limiter := NewTransferLimiter(TransferLimits{MaxInFlightBytes: 100, QueueTimeout: 5 * time.Second})
+	ctx := context.Background()
+
+	release, err := limiter.acquire(ctx, "owner:a", 80)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	admitted := make(chan error, 1)
+	go func() {
+		release2, err := limiter.acquire(ctx, "owner:b", 50)
+		if err == nil {
+			release2()
+		}
+		admitted <- err
+	}()
+
+	select {
+	case err := <-admitted:
+		t.Fatalf("expected second upload to wait, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case err := <-admitted:
+		if err != nil {
+			t.Fatalf("expected queued upload to be admitted, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued upload was not woken up")
+	}
+
+	// 排队超时后返回 busy
+	limiter = NewTransferLimiter(TransferLimits{MaxConcurrent: 1, QueueTimeout: 20 * time.Millisecond})
+	release, _ = limiter.acquire(ctx, "owner:a", 1)
+	defer release()
+	if _, err := limiter.acquire(ctx, "owner:b", 1); err != ErrUploadsBusy {
+		t.Fatalf("expected busy after queue timeout, got %v", err)
+	}
+}
+
+func TestTransferLimiter_ShapesPerOwner(t *testing.T) {
+	limiter := NewTransferLimiter(TransferLimits{OwnerBytesPerSecond: 1000})
+
+	var slept time.Duration
+	now := time.Unix(1_700_000_000, 0)
+	bucket := limiter.bucket("owner:a", "download")
+	bucket.now = func() time.Time { return now }
+	bucket.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+
+	// 下载与上传共享同一个桶：首秒的额度可以立即使用，之后按速率等待
+	w, doneWriting := limiter.ShapeResponseWriter(context.Background(), "owner:a", httptest.NewRecorder())
+	if n, err := w.Write(make([]byte, 1500)); err != nil || n != 1500 {
+		t.Fatalf("write: %d %v", n, err)
+	}
+	r, doneReading := limiter.ShapeReader(context.Background(), "owner:a", io.NopCloser(strings.NewReader(strings.Repeat("x", 1500))))
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if slept != 2*time.Second {
+		t.Fatalf("expected 3000 bytes at 1000 B/s with 1s burst to wait 2s, waited %s", slept)
+	}
+
+	doneWriting()
+	doneReading()
+	limiter.releaseBucket("owner:a", "download")
+	if len(limiter.buckets) != 0 {
+		t.Fatal("expected bucket to be dropped when the owner has no streams")
+	}
+}
+
+func TestTransferLimiter_AdmittedUploadsOutliveServerTimeouts(t *testing.T) {
+	limiter := NewTransferLimiter(TransferLimits{MaxConcurrent: 1, QueueTimeout: time.Second, TransferTimeout: time.Minute})
+	srv := httptest.NewUnstartedServer(limiter.AdmitUploads(1 << 20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		time.Sleep(150 * time.Millisecond)
+		_, _ = w.Write(body)
+	})))
+	srv.Config.ReadTimeout = 100 * time.Millisecond
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	// 占住唯一的名额，请求排队的时间超过服务器的读写超时
+	release, err := limiter.Acquire(context.Background(), "owner:other", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(300*time.Millisecond, release)
+
+	resp, err := http.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("expected queued upload to complete, got %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+	}
+}
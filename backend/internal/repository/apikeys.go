@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// APIKey 是数据库中签发的 API Key，只保存哈希，明文只在签发与轮换时返回一次。
+type APIKey struct {
+	ID      string `json:"id"`
+	OwnerID string `json:"owner_id"`
+	Name    string `json:"name"`
+	// Prefix 是 Key 中公开的查找前缀，可用于在日志与界面中辨认 Key。
+	Prefix  string   `json:"prefix"`
+	KeyHash string   `json:"-"`
+	Scopes  []string `json:"scopes"`
+	// S3AccessKeyID 与 S3Secret 是由明文 Key 派生的 S3 网关凭证，SigV4 校验需要服务端持有 secret，
+	// 因此 S3Secret 由服务层加密后保存（见 service.SecretBox）。
+	S3AccessKeyID string `json:"s3_access_key_id"`
+	S3Secret      string `json:"-"`
+	// AllowedCIDRs 非空时 Key 只能从其中的网络使用，DeniedCIDRs 中的网络始终被拒绝。
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	DeniedCIDRs  []string   `json:"denied_cidrs"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// APIKeySecret 是轮换时整体替换的凭证字段。
+type APIKeySecret struct {
+	Prefix        string
+	KeyHash       string
+	S3AccessKeyID string
+	S3Secret      string
+}
+
+// ListAPIKeysParams 用于分页检索 API Key，OwnerID 为空时不过滤，默认不包含已吊销的 Key。
+type ListAPIKeysParams struct {
+	OwnerID        string
+	IncludeRevoked bool
+	Limit          int
+	Offset         int
+}
+
+// APIKeyRepository 统一 API Key 的持久层接口。
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) (*APIKey, error)
+	GetByID(ctx context.Context, id string) (*APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	GetByS3AccessKeyID(ctx context.Context, accessKeyID string) (*APIKey, error)
+	List(ctx context.Context, params ListAPIKeysParams) ([]APIKey, error)
+	// UpdateSecret 替换凭证字段，用于轮换；owner、名称与 scopes 保持不变。
+	UpdateSecret(ctx context.Context, id string, secret APIKeySecret) (*APIKey, error)
+	// UpdateNetworks 替换 Key 的 CIDR 允许与拒绝列表。
+	UpdateNetworks(ctx context.Context, id string, allowed, denied []string) (*APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) (*APIKey, error)
+	// TouchLastUsed 更新最近使用时间，距上次更新不足 minInterval 时跳过以减少写入。
+	TouchLastUsed(ctx context.Context, id string, at time.Time, minInterval time.Duration) error
+}
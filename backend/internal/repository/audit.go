@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// AuditEvent 是审计日志中的一条记录，写入后不可修改或删除。
+type AuditEvent struct {
+	ID         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Actor 是调用方的 owner ID，KeyID 是所用 API Key 的 ID；鉴权失败的记录两者为空。
+	Actor     string `json:"actor,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
+	Action    string `json:"action"`
+	FileID    string `json:"file_id,omitempty"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Outcome   string `json:"outcome"`
+	Status    int    `json:"status"`
+}
+
+// ListAuditEventsParams 用于检索审计记录，结果按 ID 降序（新的在前）；为空的条件不过滤。
+// BeforeID 大于 0 时只返回 ID 更小的记录，用于翻页。
+type ListAuditEventsParams struct {
+	Actor    string
+	KeyID    string
+	Action   string
+	FileID   string
+	Outcome  string
+	Since    *time.Time
+	Until    *time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// AuditRepository 统一审计日志的持久层接口，只支持追加与查询。
+type AuditRepository interface {
+	Append(ctx context.Context, event *AuditEvent) (*AuditEvent, error)
+	List(ctx context.Context, params ListAuditEventsParams) ([]AuditEvent, error)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// EventRecord 是事件日志中的一条文件事件，Seq 单调递增，用作 SSE 的事件 ID。
+type EventRecord struct {
+	Seq       int64
+	ID        string
+	OwnerID   string
+	Type      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// EventRepository 统一文件事件日志的持久层接口。
+type EventRepository interface {
+	Append(ctx context.Context, event *EventRecord) (*EventRecord, error)
+	// ListSince 按 Seq 升序返回 owner 在 afterSeq 之后的最多 limit 条事件。
+	ListSince(ctx context.Context, ownerID string, afterSeq int64, limit int) ([]EventRecord, error)
+	// LatestSeq 返回当前最大的 Seq，没有事件时为 0。
+	LatestSeq(ctx context.Context) (int64, error)
+	// DeleteBefore 删除早于 before 的事件，返回删除数量。
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// EventListener 订阅跨副本的新事件通知，收到通知时以事件的 Seq 与 owner 回调 notify。
+// Listen 阻塞直到 ctx 结束或连接出错。
+type EventListener interface {
+	Listen(ctx context.Context, notify func(seq int64, ownerID string)) error
+}
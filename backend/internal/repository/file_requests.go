@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// FileRequest 是一条只允许上传的文件请求链接，令牌只保存哈希，明文只在创建时返回一次。
+type FileRequest struct {
+	ID      string `json:"id"`
+	OwnerID string `json:"owner_id"`
+	Name    string `json:"name"`
+	// Prefix 是令牌中公开的查找前缀，也会写入上传文件的 metadata。
+	Prefix    string `json:"prefix"`
+	TokenHash string `json:"-"`
+	// Folder 与 Tags 决定上传文件的路径前缀与标签。
+	Folder string   `json:"folder,omitempty"`
+	Tags   []string `json:"tags"`
+	// MaxFileSize 为 0 时使用服务端的上传上限，MaxFiles 为 0 时不限制数量。
+	MaxFileSize      int64      `json:"max_file_size"`
+	MaxFiles         int        `json:"max_files"`
+	AllowedMimeTypes []string   `json:"allowed_mime_types"`
+	UploadCount      int        `json:"upload_count"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// FileRequestRepository 统一文件请求的持久层接口。
+type FileRequestRepository interface {
+	Create(ctx context.Context, request *FileRequest) (*FileRequest, error)
+	GetByID(ctx context.Context, id string) (*FileRequest, error)
+	GetByPrefix(ctx context.Context, prefix string) (*FileRequest, error)
+	// ListByOwner 按创建时间倒序返回 owner 的全部文件请求。
+	ListByOwner(ctx context.Context, ownerID string) ([]FileRequest, error)
+	Revoke(ctx context.Context, id string, at time.Time) (*FileRequest, error)
+	// ReserveUpload 在请求未吊销、未过期且未达到 MaxFiles 时将 UploadCount 加一，否则返回 ErrNotFound。
+	ReserveUpload(ctx context.Context, id string, now time.Time) (*FileRequest, error)
+	// ReleaseUpload 归还 ReserveUpload 占用的名额，用于上传失败时。
+	ReleaseUpload(ctx context.Context, id string) error
+}
@@ -18,6 +18,7 @@ const (
 // FileRecord 代表数据库中的文件元数据。
 type FileRecord struct {
 	ID           string         `json:"id"`
+	OwnerID      string         `json:"-"`
 	OriginalName string         `json:"original_name"`
 	MimeType     string         `json:"mime_type"`
 	SizeBytes    int64          `json:"size_bytes"`
@@ -31,10 +32,15 @@ type FileRecord struct {
 }
 
 // ListFilesParams 用于分页检索文件。
+// OwnerID、Name、NamePrefix 为空时不参与过滤；ExpiresBefore 非零时只返回在该时刻前过期的文件。
 type ListFilesParams struct {
-	Statuses []FileStatus
-	Limit    int
-	Offset   int
+	OwnerID       string
+	Name          string
+	NamePrefix    string
+	Statuses      []FileStatus
+	ExpiresBefore time.Time
+	Limit         int
+	Offset        int
 }
 
 // FileRepository 统一文件元数据持久层接口。
@@ -43,4 +49,19 @@ type FileRepository interface {
 	GetByID(ctx context.Context, id string) (*FileRecord, error)
 	List(ctx context.Context, params ListFilesParams) ([]FileRecord, error)
 	UpdateStatus(ctx context.Context, id string, status FileStatus) error
+	UpdateMetadata(ctx context.Context, id string, metadata map[string]any) (*FileRecord, error)
+	Rename(ctx context.Context, id, name string) (*FileRecord, error)
+	// UpdateExpiry 设置文件过期时间，expiresAt 为 nil 表示取消过期。
+	UpdateExpiry(ctx context.Context, id string, expiresAt *time.Time) (*FileRecord, error)
+	// WithTx 在同一事务中执行 fn，fn 返回错误时整体回滚。
+	WithTx(ctx context.Context, fn func(repo FileRepository) error) error
+}
+
+// FileRecordStore 是导出与导入使用的记录级接口，覆盖所有 owner 与状态。
+type FileRecordStore interface {
+	GetByID(ctx context.Context, id string) (*FileRecord, error)
+	// Each 按创建时间顺序逐条遍历全部记录，fn 返回错误时停止。
+	Each(ctx context.Context, fn func(record FileRecord) error) error
+	// Upsert 按 ID 插入或整体覆盖记录，包括时间戳。
+	Upsert(ctx context.Context, record *FileRecord) error
 }
@@ -0,0 +1,170 @@
+// Package memrepo 提供 repository.FileRepository 与 storage.Storage 的内存实现，
+// 供 gRPC、WebDAV、S3 网关与客户端 SDK 的测试共用，行为与 Postgres 实现保持一致。
+package memrepo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/storage"
+)
+
+// defaultListLimit 与 Postgres 实现相同：Limit 不大于 0 时最多返回 50 条。
+const defaultListLimit = 50
+
+// FileRepository 按插入顺序保存记录，List 与 Postgres 实现一样按创建时间倒序返回。
+// 读写的都是记录的副本，调用方修改返回值不会影响已保存的记录。
+type FileRepository struct {
+	mu      sync.Mutex
+	records []*repository.FileRecord
+}
+
+// NewFileRepository 创建空的内存文件仓库。
+func NewFileRepository() *FileRepository {
+	return &FileRepository{}
+}
+
+// Records 按插入顺序返回全部记录（包括已删除的）的副本，供测试断言。
+func (m *FileRepository) Records() []repository.FileRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]repository.FileRecord, len(m.records))
+	for i, rec := range m.records {
+		out[i] = *rec
+	}
+	return out
+}
+
+func (m *FileRepository) Create(ctx context.Context, record *repository.FileRecord) (*repository.FileRecord, error) {
+	stored := *record
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now().UTC()
+	}
+	if stored.UpdatedAt.IsZero() {
+		stored.UpdatedAt = stored.CreatedAt
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, &stored)
+	copied := stored
+	return &copied, nil
+}
+
+func (m *FileRepository) GetByID(ctx context.Context, id string) (*repository.FileRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range m.records {
+		if rec.ID == id {
+			copied := *rec
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *FileRepository) List(ctx context.Context, params repository.ListFilesParams) ([]repository.FileRecord, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []repository.FileRecord
+	for i := len(m.records) - 1; i >= 0; i-- {
+		rec := m.records[i]
+		switch {
+		case len(params.Statuses) > 0 && !slices.Contains(params.Statuses, rec.Status):
+		case len(params.Statuses) == 0 && rec.Status == repository.FileStatusDeleted:
+		case params.OwnerID != "" && rec.OwnerID != params.OwnerID:
+		case params.Name != "" && rec.OriginalName != params.Name:
+		case !strings.HasPrefix(rec.OriginalName, params.NamePrefix):
+		case !params.ExpiresBefore.IsZero() && (rec.ExpiresAt == nil || rec.ExpiresAt.After(params.ExpiresBefore)):
+		default:
+			out = append(out, *rec)
+		}
+	}
+	if params.Offset >= len(out) {
+		return nil, nil
+	}
+	out = out[params.Offset:]
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (m *FileRepository) UpdateStatus(ctx context.Context, id string, status repository.FileStatus) error {
+	_, err := m.update(id, func(rec *repository.FileRecord) { rec.Status = status })
+	return err
+}
+
+func (m *FileRepository) UpdateMetadata(ctx context.Context, id string, metadata map[string]any) (*repository.FileRecord, error) {
+	return m.update(id, func(rec *repository.FileRecord) { rec.Metadata = metadata })
+}
+
+func (m *FileRepository) Rename(ctx context.Context, id, name string) (*repository.FileRecord, error) {
+	return m.update(id, func(rec *repository.FileRecord) { rec.OriginalName = name })
+}
+
+func (m *FileRepository) UpdateExpiry(ctx context.Context, id string, expiresAt *time.Time) (*repository.FileRecord, error) {
+	return m.update(id, func(rec *repository.FileRecord) { rec.ExpiresAt = expiresAt })
+}
+
+// WithTx 直接在当前仓库上执行 fn，不支持回滚。
+func (m *FileRepository) WithTx(ctx context.Context, fn func(repo repository.FileRepository) error) error {
+	return fn(m)
+}
+
+// update 修改 id 对应的记录并刷新 UpdatedAt，返回修改后的副本。
+func (m *FileRepository) update(id string, apply func(rec *repository.FileRecord)) (*repository.FileRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range m.records {
+		if rec.ID == id {
+			apply(rec)
+			rec.UpdatedAt = time.Now().UTC()
+			copied := *rec
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// Storage 是保存在内存中的对象存储。
+type Storage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewStorage 创建空的内存对象存储。
+func NewStorage() *Storage {
+	return &Storage{objects: map[string][]byte{}}
+}
+
+func (s *Storage) Write(ctx context.Context, key string, r io.Reader) (storage.Location, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return storage.Location{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+	return storage.Location{Path: key}, nil
+}
+
+func (s *Storage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
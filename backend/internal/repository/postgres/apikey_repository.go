@@ -0,0 +1,292 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// NewAPIKeyRepository 返回基于 *sql.DB 的 API Key 仓储实现。
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// APIKeyRepository 实现 repository.APIKeyRepository。
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+var apiKeySelectColumns = []string{
+	"id",
+	"owner_id",
+	"name",
+	"prefix",
+	"key_hash",
+	"scopes",
+	"s3_access_key_id",
+	"s3_secret",
+	"allowed_cidrs",
+	"denied_cidrs",
+	"expires_at",
+	"last_used_at",
+	"revoked_at",
+	"created_at",
+	"updated_at",
+}
+
+// Create 插入 API Key 记录。
+func (r *APIKeyRepository) Create(ctx context.Context, key *repository.APIKey) (*repository.APIKey, error) {
+	if key == nil {
+		return nil, fmt.Errorf("api key is nil")
+	}
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	allowed, denied, err := marshalNetworks(key.AllowedCIDRs, key.DeniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	var expires sql.NullTime
+	if key.ExpiresAt != nil {
+		expires = sql.NullTime{Time: *key.ExpiresAt, Valid: true}
+	}
+
+	query := fmt.Sprintf(`INSERT INTO api_keys (id, owner_id, name, prefix, key_hash, scopes, s3_access_key_id, s3_secret, allowed_cidrs, denied_cidrs, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING %s`, strings.Join(apiKeySelectColumns, ","))
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		key.ID,
+		key.OwnerID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		scopes,
+		key.S3AccessKeyID,
+		key.S3Secret,
+		allowed,
+		denied,
+		expires,
+	)
+	return scanAPIKey(row)
+}
+
+// GetByID 通过主键查询 API Key。
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*repository.APIKey, error) {
+	return r.getBy(ctx, "id", id)
+}
+
+// GetByPrefix 通过公开前缀查询 API Key。
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*repository.APIKey, error) {
+	return r.getBy(ctx, "prefix", prefix)
+}
+
+// GetByS3AccessKeyID 通过派生的 S3 AccessKeyID 查询 API Key。
+func (r *APIKeyRepository) GetByS3AccessKeyID(ctx context.Context, accessKeyID string) (*repository.APIKey, error) {
+	return r.getBy(ctx, "s3_access_key_id", accessKeyID)
+}
+
+func (r *APIKeyRepository) getBy(ctx context.Context, column, value string) (*repository.APIKey, error) {
+	query := fmt.Sprintf(`SELECT %s FROM api_keys WHERE %s = $1`, strings.Join(apiKeySelectColumns, ","), column)
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, value))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// List 按创建时间倒序分页返回 API Key。
+func (r *APIKeyRepository) List(ctx context.Context, params repository.ListAPIKeysParams) ([]repository.APIKey, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var (
+		args       []any
+		conditions []string
+	)
+	if params.OwnerID != "" {
+		args = append(args, params.OwnerID)
+		conditions = append(conditions, fmt.Sprintf("owner_id = $%d", len(args)))
+	}
+	if !params.IncludeRevoked {
+		conditions = append(conditions, "revoked_at IS NULL")
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, params.Offset)
+
+	query := fmt.Sprintf(`SELECT %s FROM api_keys %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		strings.Join(apiKeySelectColumns, ","),
+		whereClause,
+		len(args)-1,
+		len(args),
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []repository.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *key)
+	}
+	return result, rows.Err()
+}
+
+// UpdateSecret 替换 Key 的凭证字段。
+func (r *APIKeyRepository) UpdateSecret(ctx context.Context, id string, secret repository.APIKeySecret) (*repository.APIKey, error) {
+	query := fmt.Sprintf(`UPDATE api_keys
+	SET prefix = $1, key_hash = $2, s3_access_key_id = $3, s3_secret = $4, updated_at = $5
+	WHERE id = $6
+	RETURNING %s`, strings.Join(apiKeySelectColumns, ","))
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query,
+		secret.Prefix, secret.KeyHash, secret.S3AccessKeyID, secret.S3Secret, time.Now().UTC(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// UpdateNetworks 替换 Key 的 CIDR 允许与拒绝列表。
+func (r *APIKeyRepository) UpdateNetworks(ctx context.Context, id string, allowedCIDRs, deniedCIDRs []string) (*repository.APIKey, error) {
+	allowed, denied, err := marshalNetworks(allowedCIDRs, deniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`UPDATE api_keys
+	SET allowed_cidrs = $1, denied_cidrs = $2, updated_at = $3
+	WHERE id = $4
+	RETURNING %s`, strings.Join(apiKeySelectColumns, ","))
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, allowed, denied, time.Now().UTC(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// Revoke 标记 Key 已吊销，重复吊销保留最初的时间。
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) (*repository.APIKey, error) {
+	query := fmt.Sprintf(`UPDATE api_keys
+	SET revoked_at = COALESCE(revoked_at, $1), updated_at = $1
+	WHERE id = $2
+	RETURNING %s`, strings.Join(apiKeySelectColumns, ","))
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, at, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// TouchLastUsed 只在距上次记录超过 minInterval 时写入，高频调用的 Key 不会每个请求都更新一行。
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id string, at time.Time, minInterval time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $1
+	WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)`, at, id, at.Add(-minInterval))
+	return err
+}
+
+func scanAPIKey(rs rowScanner) (*repository.APIKey, error) {
+	var (
+		key       repository.APIKey
+		scopes    []byte
+		allowed   []byte
+		denied    []byte
+		expiresAt sql.NullTime
+		lastUsed  sql.NullTime
+		revokedAt sql.NullTime
+	)
+	if err := rs.Scan(
+		&key.ID,
+		&key.OwnerID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&scopes,
+		&key.S3AccessKeyID,
+		&key.S3Secret,
+		&allowed,
+		&denied,
+		&expiresAt,
+		&lastUsed,
+		&revokedAt,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(scopes, &key.Scopes); err != nil {
+		return nil, err
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	if err := json.Unmarshal(allowed, &key.AllowedCIDRs); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(denied, &key.DeniedCIDRs); err != nil {
+		return nil, err
+	}
+	if key.AllowedCIDRs == nil {
+		key.AllowedCIDRs = []string{}
+	}
+	if key.DeniedCIDRs == nil {
+		key.DeniedCIDRs = []string{}
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// marshalNetworks 把 CIDR 列表编码为 JSONB，nil 按空数组保存。
+func marshalNetworks(allowedCIDRs, deniedCIDRs []string) (allowed, denied []byte, err error) {
+	if allowedCIDRs == nil {
+		allowedCIDRs = []string{}
+	}
+	if deniedCIDRs == nil {
+		deniedCIDRs = []string{}
+	}
+	if allowed, err = json.Marshal(allowedCIDRs); err != nil {
+		return nil, nil, err
+	}
+	if denied, err = json.Marshal(deniedCIDRs); err != nil {
+		return nil, nil, err
+	}
+	return allowed, denied, nil
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"droplite/internal/repository"
+)
+
+// NewAuditRepository 返回基于 *sql.DB 的审计日志实现。
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditRepository 实现 repository.AuditRepository。表上的触发器拒绝修改与删除，这里也只提供追加与查询。
+type AuditRepository struct {
+	db *sql.DB
+}
+
+var auditSelectColumns = []string{
+	"id",
+	"occurred_at",
+	"actor",
+	"key_id",
+	"action",
+	"file_id",
+	"ip",
+	"user_agent",
+	"request_id",
+	"outcome",
+	"status",
+}
+
+// Append 写入一条审计记录。
+func (r *AuditRepository) Append(ctx context.Context, event *repository.AuditEvent) (*repository.AuditEvent, error) {
+	if event == nil {
+		return nil, fmt.Errorf("audit event is nil")
+	}
+
+	query := fmt.Sprintf(`INSERT INTO audit_events (occurred_at, actor, key_id, action, file_id, ip, user_agent, request_id, outcome, status)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING %s`, strings.Join(auditSelectColumns, ","))
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		event.OccurredAt,
+		event.Actor,
+		event.KeyID,
+		event.Action,
+		event.FileID,
+		event.IP,
+		event.UserAgent,
+		event.RequestID,
+		event.Outcome,
+		event.Status,
+	)
+	return scanAuditEvent(row)
+}
+
+// List 按 id 降序返回满足条件的审计记录。
+func (r *AuditRepository) List(ctx context.Context, params repository.ListAuditEventsParams) ([]repository.AuditEvent, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var (
+		args       []any
+		conditions []string
+	)
+	for _, filter := range []struct {
+		column string
+		value  string
+	}{
+		{"actor", params.Actor},
+		{"key_id", params.KeyID},
+		{"action", params.Action},
+		{"file_id", params.FileID},
+		{"outcome", params.Outcome},
+	} {
+		if filter.value != "" {
+			args = append(args, filter.value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", filter.column, len(args)))
+		}
+	}
+	if params.Since != nil {
+		args = append(args, *params.Since)
+		conditions = append(conditions, fmt.Sprintf("occurred_at >= $%d", len(args)))
+	}
+	if params.Until != nil {
+		args = append(args, *params.Until)
+		conditions = append(conditions, fmt.Sprintf("occurred_at < $%d", len(args)))
+	}
+	if params.BeforeID > 0 {
+		args = append(args, params.BeforeID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`SELECT %s FROM audit_events %s ORDER BY id DESC LIMIT $%d`,
+		strings.Join(auditSelectColumns, ","),
+		whereClause,
+		len(args),
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []repository.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *event)
+	}
+	return result, rows.Err()
+}
+
+func scanAuditEvent(rs rowScanner) (*repository.AuditEvent, error) {
+	var event repository.AuditEvent
+	if err := rs.Scan(
+		&event.ID,
+		&event.OccurredAt,
+		&event.Actor,
+		&event.KeyID,
+		&event.Action,
+		&event.FileID,
+		&event.IP,
+		&event.UserAgent,
+		&event.RequestID,
+		&event.Outcome,
+		&event.Status,
+	); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
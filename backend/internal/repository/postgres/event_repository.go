@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	// fileEventsChannel 与迁移 0005 中触发器使用的通知通道一致。
+	fileEventsChannel = "file_events"
+	// fileEventsAppendLock 是串行化事件写入的 advisory lock 键。
+	fileEventsAppendLock int64 = 0x646c5f6576656e74 // "dl_event"
+)
+
+// NewEventRepository 返回基于 *sql.DB 的事件日志实现，同时实现 repository.EventListener。
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// EventRepository 实现 repository.EventRepository 与 repository.EventListener。
+type EventRepository struct {
+	db *sql.DB
+}
+
+// Append 写入一条事件，触发器会在提交后发出 NOTIFY。
+func (r *EventRepository) Append(ctx context.Context, event *repository.EventRecord) (*repository.EventRecord, error) {
+	if event == nil {
+		return nil, fmt.Errorf("event is nil")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored, err := appendEvent(ctx, tx, event)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// appendEvent 持有事务级 advisory lock 后插入事件，锁在 tx 提交后才释放。
+// seq 在 INSERT 时分配，若并发事务可以乱序提交，订阅者读到较大的 seq 后会永久跳过仍未提交的较小 seq；
+// 串行化写入保证 seq 按提交顺序可见。
+func appendEvent(ctx context.Context, tx *sql.Tx, event *repository.EventRecord) (*repository.EventRecord, error) {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, fileEventsAppendLock); err != nil {
+		return nil, err
+	}
+	stored := *event
+	row := tx.QueryRowContext(ctx, `INSERT INTO file_events (id, owner_id, type, payload)
+	VALUES ($1, $2, $3, $4)
+	RETURNING seq, created_at`, event.ID, event.OwnerID, event.Type, []byte(event.Payload))
+	if err := row.Scan(&stored.Seq, &stored.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// ListSince 按 seq 升序返回 owner 在 afterSeq 之后的事件。
+func (r *EventRepository) ListSince(ctx context.Context, ownerID string, afterSeq int64, limit int) ([]repository.EventRecord, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT seq, id, owner_id, type, payload, created_at
+	FROM file_events
+	WHERE owner_id = $1 AND seq > $2
+	ORDER BY seq
+	LIMIT $3`, ownerID, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []repository.EventRecord
+	for rows.Next() {
+		var (
+			event   repository.EventRecord
+			payload []byte
+		)
+		if err := rows.Scan(&event.Seq, &event.ID, &event.OwnerID, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		result = append(result, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LatestSeq 返回当前最大的 seq。
+func (r *EventRepository) LatestSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM file_events`).Scan(&seq)
+	return seq, err
+}
+
+// DeleteBefore 删除早于 before 的事件。
+func (r *EventRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM file_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Listen 占用连接池中的一个连接执行 LISTEN，直到 ctx 结束或连接出错。
+// 该连接带有 LISTEN 状态，结束后直接丢弃而不归还连接池。
+func (r *EventRepository) Listen(ctx context.Context, notify func(seq int64, ownerID string)) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var listenErr error
+	_ = conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+fileEventsChannel); err != nil {
+			listenErr = err
+			return driver.ErrBadConn
+		}
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				listenErr = err
+				return driver.ErrBadConn
+			}
+			seqPart, ownerID, ok := strings.Cut(n.Payload, ":")
+			seq, err := strconv.ParseInt(seqPart, 10, 64)
+			if !ok || err != nil {
+				continue
+			}
+			notify(seq, ownerID)
+		}
+	})
+	return listenErr
+}
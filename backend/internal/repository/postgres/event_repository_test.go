@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"droplite/internal/migrations"
+	"droplite/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// openTestDB 按 DB_* 环境变量（与 CI 相同）连接 Postgres 并执行迁移，未设置 DB_HOST 时跳过。
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	host := os.Getenv("DB_HOST")
+	if host == "" {
+		t.Skip("DB_HOST not set; skipping Postgres integration test")
+	}
+	port := os.Getenv("DB_PORT")
+	if port == "" {
+		port = "5432"
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD")),
+		Host:     fmt.Sprintf("%s:%s", host, port),
+		Path:     "/" + os.Getenv("DB_NAME"),
+		RawQuery: "sslmode=disable",
+	}
+	db, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if err := migrations.Apply(ctx, db); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestEventRepository_AppendsBecomeVisibleInSeqOrder(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewEventRepository(db)
+	ctx := context.Background()
+	owner := "events-" + uuid.NewString()
+	newEvent := func() *repository.EventRecord {
+		return &repository.EventRecord{ID: uuid.NewString(), OwnerID: owner, Type: "file.created", Payload: []byte(`{}`)}
+	}
+	after, err := repo.LatestSeq(ctx)
+	if err != nil {
+		t.Fatalf("latest seq: %v", err)
+	}
+
+	// 模拟一个已分配 seq 但尚未提交的写入
+	slow, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer slow.Rollback()
+	first, err := appendEvent(ctx, slow, newEvent())
+	if err != nil {
+		t.Fatalf("append in open transaction: %v", err)
+	}
+
+	done := make(chan *repository.EventRecord, 1)
+	errs := make(chan error, 1)
+	go func() {
+		second, err := repo.Append(ctx, newEvent())
+		if err != nil {
+			errs <- err
+			return
+		}
+		done <- second
+	}()
+
+	// 后来的写入不能先于较小的 seq 提交，否则订阅者会跳过 first
+	select {
+	case second := <-done:
+		t.Fatalf("append with seq %d committed while seq %d was still in flight", second.Seq, first.Seq)
+	case err := <-errs:
+		t.Fatalf("append: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if events, err := repo.ListSince(ctx, owner, after, 10); err != nil || len(events) != 0 {
+		t.Fatalf("expected no visible events yet, got %+v, %v", events, err)
+	}
+
+	if err := slow.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	var second *repository.EventRecord
+	select {
+	case second = <-done:
+	case err := <-errs:
+		t.Fatalf("append: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("append did not finish after the earlier transaction committed")
+	}
+	if second.Seq <= first.Seq {
+		t.Fatalf("expected seq after %d, got %d", first.Seq, second.Seq)
+	}
+	events, err := repo.ListSince(ctx, owner, after, 10)
+	if err != nil || len(events) != 2 || events[0].Seq != first.Seq || events[1].Seq != second.Seq {
+		t.Fatalf("expected both events in seq order, got %+v, %v", events, err)
+	}
+}
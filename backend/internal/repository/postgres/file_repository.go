@@ -13,16 +13,26 @@ import (
 
 // NewFileRepository 返回基于 *sql.DB 的 Postgres 实现。
 func NewFileRepository(db *sql.DB) *FileRepository {
-	return &FileRepository{db: db}
+	return &FileRepository{db: db, conn: db}
 }
 
 // FileRepository 实现 repository.FileRepository。
+// 事务内的实例 conn 为 nil，db 指向 *sql.Tx。
 type FileRepository struct {
-	db *sql.DB
+	db   queryer
+	conn *sql.DB
+}
+
+// queryer 是 *sql.DB 与 *sql.Tx 共有的查询方法。
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 var fileSelectColumns = []string{
 	"id",
+	"owner_id",
 	"original_name",
 	"mime_type",
 	"size_bytes",
@@ -37,6 +47,7 @@ var fileSelectColumns = []string{
 
 var fileInsertColumns = []string{
 	"id",
+	"owner_id",
 	"original_name",
 	"mime_type",
 	"size_bytes",
@@ -85,6 +96,7 @@ func (r *FileRepository) Create(ctx context.Context, record *repository.FileReco
 		ctx,
 		query,
 		record.ID,
+		record.OwnerID,
 		record.OriginalName,
 		record.MimeType,
 		record.SizeBytes,
@@ -119,20 +131,37 @@ func (r *FileRepository) List(ctx context.Context, params repository.ListFilesPa
 		limit = 50
 	}
 
-	args := make([]any, 0, len(params.Statuses)+2)
-	whereClause := ""
+	args := make([]any, 0, len(params.Statuses)+5)
+	var conditions []string
 	if len(params.Statuses) > 0 {
 		placeholders := make([]string, len(params.Statuses))
 		for i, status := range params.Statuses {
 			args = append(args, status)
 			placeholders[i] = fmt.Sprintf("$%d", len(args))
 		}
-		whereClause = "WHERE status IN (" + strings.Join(placeholders, ",") + ")"
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ",")+")")
 	} else {
 		// 默认排除已删除的文件
 		args = append(args, repository.FileStatusDeleted)
-		whereClause = fmt.Sprintf("WHERE status != $%d", len(args))
+		conditions = append(conditions, fmt.Sprintf("status != $%d", len(args)))
+	}
+	if params.OwnerID != "" {
+		args = append(args, params.OwnerID)
+		conditions = append(conditions, fmt.Sprintf("owner_id = $%d", len(args)))
+	}
+	if params.Name != "" {
+		args = append(args, params.Name)
+		conditions = append(conditions, fmt.Sprintf("original_name = $%d", len(args)))
 	}
+	if params.NamePrefix != "" {
+		args = append(args, escapeLike(params.NamePrefix)+"%")
+		conditions = append(conditions, fmt.Sprintf(`original_name LIKE $%d ESCAPE '\'`, len(args)))
+	}
+	if !params.ExpiresBefore.IsZero() {
+		args = append(args, params.ExpiresBefore)
+		conditions = append(conditions, fmt.Sprintf("expires_at IS NOT NULL AND expires_at <= $%d", len(args)))
+	}
+	whereClause := "WHERE " + strings.Join(conditions, " AND ")
 
 	args = append(args, limit)
 	limitPlaceholder := fmt.Sprintf("$%d", len(args))
@@ -184,6 +213,154 @@ func (r *FileRepository) UpdateStatus(ctx context.Context, id string, status rep
 	return nil
 }
 
+// UpdateMetadata 整体替换文件 metadata 并返回更新后的记录。
+func (r *FileRepository) UpdateMetadata(ctx context.Context, id string, metadata map[string]any) (*repository.FileRecord, error) {
+	metadataBytes, err := encodeMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`UPDATE files SET metadata = $1, updated_at = $2 WHERE id = $3 RETURNING %s`, strings.Join(fileSelectColumns, ","))
+	row := r.db.QueryRowContext(ctx, query, metadataBytes, time.Now().UTC(), id)
+	file, err := scanFileRecord(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// Rename 修改文件的 original_name 并返回更新后的记录。
+func (r *FileRepository) Rename(ctx context.Context, id, name string) (*repository.FileRecord, error) {
+	query := fmt.Sprintf(`UPDATE files SET original_name = $1, updated_at = $2 WHERE id = $3 RETURNING %s`, strings.Join(fileSelectColumns, ","))
+	row := r.db.QueryRowContext(ctx, query, name, time.Now().UTC(), id)
+	file, err := scanFileRecord(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// UpdateExpiry 设置或清除文件过期时间并返回更新后的记录。
+func (r *FileRepository) UpdateExpiry(ctx context.Context, id string, expiresAt *time.Time) (*repository.FileRecord, error) {
+	var expires sql.NullTime
+	if expiresAt != nil {
+		expires = sql.NullTime{Time: *expiresAt, Valid: true}
+	}
+
+	query := fmt.Sprintf(`UPDATE files SET expires_at = $1, updated_at = $2 WHERE id = $3 RETURNING %s`, strings.Join(fileSelectColumns, ","))
+	row := r.db.QueryRowContext(ctx, query, expires, time.Now().UTC(), id)
+	file, err := scanFileRecord(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// WithTx 开启事务并以事务内的仓储执行 fn；已在事务中时直接复用当前事务。
+func (r *FileRepository) WithTx(ctx context.Context, fn func(repo repository.FileRepository) error) error {
+	if r.conn == nil {
+		return fn(r)
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(&FileRepository{db: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Each 按创建时间顺序流式遍历全部记录，不区分 owner 与状态。
+func (r *FileRepository) Each(ctx context.Context, fn func(record repository.FileRecord) error) error {
+	query := fmt.Sprintf(`SELECT %s FROM files ORDER BY created_at, id`, strings.Join(fileSelectColumns, ","))
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec, err := scanFileRecord(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Upsert 按 ID 插入记录，已存在时覆盖全部字段（包括 created_at 与 updated_at）。
+func (r *FileRepository) Upsert(ctx context.Context, record *repository.FileRecord) error {
+	if record == nil {
+		return fmt.Errorf("file record is nil")
+	}
+
+	metadataBytes, err := encodeMetadata(record.Metadata)
+	if err != nil {
+		return err
+	}
+
+	var checksum sql.NullString
+	if record.Checksum != nil {
+		checksum = sql.NullString{String: *record.Checksum, Valid: true}
+	}
+
+	var expires sql.NullTime
+	if record.ExpiresAt != nil {
+		expires = sql.NullTime{Time: *record.ExpiresAt, Valid: true}
+	}
+
+	placeholders := make([]string, len(fileSelectColumns))
+	updates := make([]string, 0, len(fileSelectColumns)-1)
+	for i, column := range fileSelectColumns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		if column != "id" {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+
+	query := fmt.Sprintf(`INSERT INTO files (%s)
+	VALUES (%s)
+	ON CONFLICT (id) DO UPDATE SET %s`,
+		strings.Join(fileSelectColumns, ","),
+		strings.Join(placeholders, ","),
+		strings.Join(updates, ", "),
+	)
+
+	_, err = r.db.ExecContext(
+		ctx,
+		query,
+		record.ID,
+		record.OwnerID,
+		record.OriginalName,
+		record.MimeType,
+		record.SizeBytes,
+		record.StoragePath,
+		checksum,
+		record.Status,
+		metadataBytes,
+		record.CreatedAt,
+		record.UpdatedAt,
+		expires,
+	)
+	return err
+}
+
 type rowScanner interface {
 	Scan(dest ...any) error
 }
@@ -198,6 +375,7 @@ func scanFileRecord(rs rowScanner) (*repository.FileRecord, error) {
 
 	if err := rs.Scan(
 		&rec.ID,
+		&rec.OwnerID,
 		&rec.OriginalName,
 		&rec.MimeType,
 		&rec.SizeBytes,
@@ -236,3 +414,10 @@ func encodeMetadata(meta map[string]any) ([]byte, error) {
 	}
 	return json.Marshal(meta)
 }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike 转义 LIKE 模式中的通配符，使前缀按字面匹配。
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
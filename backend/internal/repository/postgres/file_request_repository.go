@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// NewFileRequestRepository 返回基于 *sql.DB 的文件请求仓储实现。
+func NewFileRequestRepository(db *sql.DB) *FileRequestRepository {
+	return &FileRequestRepository{db: db}
+}
+
+// FileRequestRepository 实现 repository.FileRequestRepository。
+type FileRequestRepository struct {
+	db *sql.DB
+}
+
+var fileRequestSelectColumns = []string{
+	"id",
+	"owner_id",
+	"name",
+	"prefix",
+	"token_hash",
+	"folder",
+	"tags",
+	"max_file_size",
+	"max_files",
+	"allowed_mime_types",
+	"upload_count",
+	"expires_at",
+	"revoked_at",
+	"created_at",
+	"updated_at",
+}
+
+// Create 插入文件请求记录。
+func (r *FileRequestRepository) Create(ctx context.Context, request *repository.FileRequest) (*repository.FileRequest, error) {
+	if request == nil {
+		return nil, fmt.Errorf("file request is nil")
+	}
+	tags, err := json.Marshal(request.Tags)
+	if err != nil {
+		return nil, err
+	}
+	mimeTypes, err := json.Marshal(request.AllowedMimeTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO file_requests (id, owner_id, name, prefix, token_hash, folder, tags, max_file_size, max_files, allowed_mime_types, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING %s`, strings.Join(fileRequestSelectColumns, ","))
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		request.ID,
+		request.OwnerID,
+		request.Name,
+		request.Prefix,
+		request.TokenHash,
+		request.Folder,
+		tags,
+		request.MaxFileSize,
+		request.MaxFiles,
+		mimeTypes,
+		request.ExpiresAt,
+	)
+	return scanFileRequest(row)
+}
+
+// GetByID 通过主键查询文件请求。
+func (r *FileRequestRepository) GetByID(ctx context.Context, id string) (*repository.FileRequest, error) {
+	return r.getBy(ctx, "id", id)
+}
+
+// GetByPrefix 通过令牌的公开前缀查询文件请求。
+func (r *FileRequestRepository) GetByPrefix(ctx context.Context, prefix string) (*repository.FileRequest, error) {
+	return r.getBy(ctx, "prefix", prefix)
+}
+
+func (r *FileRequestRepository) getBy(ctx context.Context, column, value string) (*repository.FileRequest, error) {
+	query := fmt.Sprintf(`SELECT %s FROM file_requests WHERE %s = $1`, strings.Join(fileRequestSelectColumns, ","), column)
+	return fileRequestOrNotFound(scanFileRequest(r.db.QueryRowContext(ctx, query, value)))
+}
+
+// ListByOwner 按创建时间倒序返回 owner 的文件请求。
+func (r *FileRequestRepository) ListByOwner(ctx context.Context, ownerID string) ([]repository.FileRequest, error) {
+	query := fmt.Sprintf(`SELECT %s FROM file_requests WHERE owner_id = $1 ORDER BY created_at DESC`,
+		strings.Join(fileRequestSelectColumns, ","))
+	rows, err := r.db.QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []repository.FileRequest
+	for rows.Next() {
+		request, err := scanFileRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *request)
+	}
+	return result, rows.Err()
+}
+
+// Revoke 标记文件请求已吊销，重复吊销保留最初的时间。
+func (r *FileRequestRepository) Revoke(ctx context.Context, id string, at time.Time) (*repository.FileRequest, error) {
+	query := fmt.Sprintf(`UPDATE file_requests
+	SET revoked_at = COALESCE(revoked_at, $1), updated_at = $1
+	WHERE id = $2
+	RETURNING %s`, strings.Join(fileRequestSelectColumns, ","))
+	return fileRequestOrNotFound(scanFileRequest(r.db.QueryRowContext(ctx, query, at, id)))
+}
+
+// ReserveUpload 在一条语句内检查并占用名额，并发上传不会超过 max_files。
+func (r *FileRequestRepository) ReserveUpload(ctx context.Context, id string, now time.Time) (*repository.FileRequest, error) {
+	query := fmt.Sprintf(`UPDATE file_requests
+	SET upload_count = upload_count + 1, updated_at = $2
+	WHERE id = $1 AND revoked_at IS NULL AND expires_at > $2 AND (max_files = 0 OR upload_count < max_files)
+	RETURNING %s`, strings.Join(fileRequestSelectColumns, ","))
+	return fileRequestOrNotFound(scanFileRequest(r.db.QueryRowContext(ctx, query, id, now)))
+}
+
+// ReleaseUpload 归还一个名额。
+func (r *FileRequestRepository) ReleaseUpload(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE file_requests
+	SET upload_count = GREATEST(upload_count - 1, 0), updated_at = $2
+	WHERE id = $1`, id, time.Now().UTC())
+	return err
+}
+
+// fileRequestOrNotFound 将 sql.ErrNoRows 映射为 repository.ErrNotFound。
+func fileRequestOrNotFound(request *repository.FileRequest, err error) (*repository.FileRequest, error) {
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	return request, err
+}
+
+func scanFileRequest(rs rowScanner) (*repository.FileRequest, error) {
+	var (
+		request   repository.FileRequest
+		tags      []byte
+		mimeTypes []byte
+		revokedAt sql.NullTime
+	)
+	if err := rs.Scan(
+		&request.ID,
+		&request.OwnerID,
+		&request.Name,
+		&request.Prefix,
+		&request.TokenHash,
+		&request.Folder,
+		&tags,
+		&request.MaxFileSize,
+		&request.MaxFiles,
+		&mimeTypes,
+		&request.UploadCount,
+		&request.ExpiresAt,
+		&revokedAt,
+		&request.CreatedAt,
+		&request.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(tags, &request.Tags); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(mimeTypes, &request.AllowedMimeTypes); err != nil {
+		return nil, err
+	}
+	if request.Tags == nil {
+		request.Tags = []string{}
+	}
+	if request.AllowedMimeTypes == nil {
+		request.AllowedMimeTypes = []string{}
+	}
+	if revokedAt.Valid {
+		request.RevokedAt = &revokedAt.Time
+	}
+	return &request, nil
+}
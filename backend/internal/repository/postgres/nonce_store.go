@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NewNonceStore 返回在 request_nonces 表中记录签名 nonce 的 NonceStore，多个副本共享同一份记录。
+func NewNonceStore(db *sql.DB) *NonceStore {
+	return &NonceStore{db: db}
+}
+
+// NonceStore 实现 middleware.NonceStore。
+type NonceStore struct {
+	db *sql.DB
+}
+
+// Claim 在一条语句内登记 nonce：不存在或已过期时写入并返回 true，并发的同一 nonce 由主键冲突串行化。
+func (s *NonceStore) Claim(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO request_nonces AS n (nonce, expires_at)
+	VALUES ($1, $2)
+	ON CONFLICT (nonce) DO UPDATE SET expires_at = EXCLUDED.expires_at
+	WHERE n.expires_at <= now()`, nonce, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Prune 删除已过期的 nonce，返回删除数量。
+func (s *NonceStore) Prune(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM request_nonces WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
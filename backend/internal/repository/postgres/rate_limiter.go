@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"droplite/internal/auth"
+	"droplite/internal/config"
+)
+
+// NewRateLimiter 返回在 rate_limit_buckets 表中保存令牌桶的限流器，多个副本共享同一份额度。
+func NewRateLimiter(db *sql.DB) *RateLimiter {
+	return &RateLimiter{db: db}
+}
+
+// RateLimiter 实现 auth.RateLimiter。时间取数据库的 now()，不受各副本时钟偏差影响。
+type RateLimiter struct {
+	db *sql.DB
+}
+
+// Take 在一条语句内完成判断与扣减：只有满足 GCRA 条件时才更新 tat，并发请求由行锁串行化。
+func (l *RateLimiter) Take(ctx context.Context, key string, rule config.RateLimitRule) (auth.RateLimitResult, error) {
+	interval := (rule.Window / time.Duration(rule.Requests)).Microseconds()
+	window := rule.Window.Microseconds()
+
+	var tat, now time.Time
+	err := l.db.QueryRowContext(ctx, `INSERT INTO rate_limit_buckets AS b (key, tat)
+	VALUES ($1, now() + $2::float8 * interval '1 microsecond')
+	ON CONFLICT (key) DO UPDATE SET tat = GREATEST(b.tat, now()) + $2::float8 * interval '1 microsecond'
+	WHERE GREATEST(b.tat, now()) + ($2::float8 - $3::float8) * interval '1 microsecond' <= now()
+	RETURNING tat, now()`, key, interval, window).Scan(&tat, &now)
+	if err == nil {
+		return auth.NewRateLimitResult(rule, tat, now, true), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return auth.RateLimitResult{}, err
+	}
+
+	// 条件不满足时没有返回行，再读一次 tat 计算重试时间
+	err = l.db.QueryRowContext(ctx, `SELECT tat, now() FROM rate_limit_buckets WHERE key = $1`, key).Scan(&tat, &now)
+	if err != nil {
+		return auth.RateLimitResult{}, err
+	}
+	return auth.NewRateLimitResult(rule, tat, now, false), nil
+}
+
+// Peek 读取 tat 判断此刻能否取得令牌，不做修改。
+func (l *RateLimiter) Peek(ctx context.Context, key string, rule config.RateLimitRule) (auth.RateLimitResult, error) {
+	var tat, now time.Time
+	err := l.db.QueryRowContext(ctx, `SELECT GREATEST(tat, now()), now() FROM rate_limit_buckets WHERE key = $1`, key).Scan(&tat, &now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.RateLimitResult{Allowed: true, Remaining: rule.Requests}, nil
+	}
+	if err != nil {
+		return auth.RateLimitResult{}, err
+	}
+	interval := rule.Window / time.Duration(rule.Requests)
+	return auth.NewRateLimitResult(rule, tat, now, tat.Add(interval).Sub(now) <= rule.Window), nil
+}
+
+// Prune 删除已回满的桶，返回删除数量。
+func (l *RateLimiter) Prune(ctx context.Context) (int64, error) {
+	res, err := l.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE tat < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
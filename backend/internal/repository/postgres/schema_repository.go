@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// NewMetadataSchemaRepository 返回基于 *sql.DB 的 Schema 仓储实现。
+func NewMetadataSchemaRepository(db *sql.DB) *MetadataSchemaRepository {
+	return &MetadataSchemaRepository{db: db}
+}
+
+// MetadataSchemaRepository 实现 repository.MetadataSchemaRepository。
+type MetadataSchemaRepository struct {
+	db *sql.DB
+}
+
+var schemaSelectColumns = []string{
+	"id",
+	"scope",
+	"scope_value",
+	"schema",
+	"description",
+	"created_at",
+	"updated_at",
+}
+
+// Upsert 按 (scope, scope_value) 插入或覆盖 Schema 定义。
+func (r *MetadataSchemaRepository) Upsert(ctx context.Context, schema *repository.MetadataSchema) (*repository.MetadataSchema, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("metadata schema is nil")
+	}
+
+	query := fmt.Sprintf(`INSERT INTO metadata_schemas (id, scope, scope_value, schema, description)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (scope, scope_value) DO UPDATE
+	SET schema = EXCLUDED.schema, description = EXCLUDED.description, updated_at = $6
+	RETURNING %s`, strings.Join(schemaSelectColumns, ","))
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		schema.ID,
+		schema.Scope,
+		schema.ScopeValue,
+		[]byte(schema.Schema),
+		schema.Description,
+		time.Now().UTC(),
+	)
+	return scanMetadataSchema(row)
+}
+
+// List 返回全部 Schema，按维度排序。
+func (r *MetadataSchemaRepository) List(ctx context.Context) ([]repository.MetadataSchema, error) {
+	query := fmt.Sprintf(`SELECT %s FROM metadata_schemas ORDER BY scope, scope_value`, strings.Join(schemaSelectColumns, ","))
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectMetadataSchemas(rows)
+}
+
+// Delete 删除指定 Schema。
+func (r *MetadataSchemaRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM metadata_schemas WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// FindMatching 查询与 type / owner 维度匹配的 Schema。
+func (r *MetadataSchemaRepository) FindMatching(ctx context.Context, match repository.SchemaMatch) ([]repository.MetadataSchema, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if match.Type != "" {
+		args = append(args, repository.SchemaScopeType, match.Type)
+		conds = append(conds, fmt.Sprintf("(scope = $%d AND scope_value = $%d)", len(args)-1, len(args)))
+	}
+	if match.OwnerID != "" {
+		args = append(args, repository.SchemaScopeOwner, match.OwnerID)
+		conds = append(conds, fmt.Sprintf("(scope = $%d AND scope_value = $%d)", len(args)-1, len(args)))
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM metadata_schemas WHERE %s ORDER BY scope, scope_value`,
+		strings.Join(schemaSelectColumns, ","),
+		strings.Join(conds, " OR "),
+	)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectMetadataSchemas(rows)
+}
+
+func collectMetadataSchemas(rows *sql.Rows) ([]repository.MetadataSchema, error) {
+	var result []repository.MetadataSchema
+	for rows.Next() {
+		schema, err := scanMetadataSchema(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *schema)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func scanMetadataSchema(rs rowScanner) (*repository.MetadataSchema, error) {
+	var (
+		schema repository.MetadataSchema
+		raw    []byte
+	)
+	if err := rs.Scan(
+		&schema.ID,
+		&schema.Scope,
+		&schema.ScopeValue,
+		&raw,
+		&schema.Description,
+		&schema.CreatedAt,
+		&schema.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	schema.Schema = raw
+	return &schema, nil
+}
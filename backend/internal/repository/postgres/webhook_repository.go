@@ -0,0 +1,346 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// NewWebhookRepository 返回基于 *sql.DB 的 webhook 仓储实现。
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WebhookRepository 实现 repository.WebhookRepository。
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+var webhookSelectColumns = []string{
+	"id",
+	"owner_id",
+	"url",
+	"secret",
+	"events",
+	"description",
+	"active",
+	"created_at",
+	"updated_at",
+}
+
+var deliverySelectColumns = []string{
+	"id",
+	"webhook_id",
+	"event_id",
+	"event_type",
+	"payload",
+	"status",
+	"attempts",
+	"next_attempt_at",
+	"last_attempt_at",
+	"response_status",
+	"last_error",
+	"created_at",
+	"updated_at",
+}
+
+// Create 插入 webhook 记录。
+func (r *WebhookRepository) Create(ctx context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+	if webhook == nil {
+		return nil, fmt.Errorf("webhook is nil")
+	}
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO webhooks (id, owner_id, url, secret, events, description, active)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING %s`, strings.Join(webhookSelectColumns, ","))
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		webhook.ID,
+		webhook.OwnerID,
+		webhook.URL,
+		webhook.Secret,
+		events,
+		webhook.Description,
+		webhook.Active,
+	)
+	return scanWebhook(row)
+}
+
+// GetByID 通过主键查询 webhook。
+func (r *WebhookRepository) GetByID(ctx context.Context, id string) (*repository.Webhook, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhooks WHERE id = $1`, strings.Join(webhookSelectColumns, ","))
+	webhook, err := scanWebhook(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// ListByOwner 返回 owner 登记的全部 webhook，按创建时间倒序。
+func (r *WebhookRepository) ListByOwner(ctx context.Context, ownerID string) ([]repository.Webhook, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhooks WHERE owner_id = $1 ORDER BY created_at DESC`, strings.Join(webhookSelectColumns, ","))
+	return r.queryWebhooks(ctx, query, ownerID)
+}
+
+// Delete 删除 webhook，其投递日志随外键级联删除。
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// ListSubscribers 查询 owner 下订阅了指定事件的启用状态 webhook。
+func (r *WebhookRepository) ListSubscribers(ctx context.Context, ownerID, eventType string) ([]repository.Webhook, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhooks
+	WHERE owner_id = $1 AND active AND events @> jsonb_build_array($2::text)`, strings.Join(webhookSelectColumns, ","))
+	return r.queryWebhooks(ctx, query, ownerID, eventType)
+}
+
+// EnqueueDeliveries 在同一事务中写入一批待投递记录。
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, deliveries []repository.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO webhook_deliveries
+	(id, webhook_id, event_id, event_type, payload, status, next_attempt_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range deliveries {
+		if _, err := stmt.ExecContext(ctx, d.ID, d.WebhookID, d.EventID, d.EventType, []byte(d.Payload), d.Status, d.NextAttemptAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDelivery 通过主键查询投递记录。
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id string) (*repository.WebhookDelivery, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhook_deliveries WHERE id = $1`, strings.Join(deliverySelectColumns, ","))
+	delivery, err := scanDelivery(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ListDeliveries 按创建时间倒序分页返回投递日志。
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, params repository.ListDeliveriesParams) ([]repository.WebhookDelivery, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	args := []any{params.WebhookID}
+	conditions := []string{"webhook_id = $1"}
+	if params.Status != "" {
+		args = append(args, params.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	args = append(args, limit, params.Offset)
+
+	query := fmt.Sprintf(`SELECT %s FROM webhook_deliveries WHERE %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		strings.Join(deliverySelectColumns, ","),
+		strings.Join(conditions, " AND "),
+		len(args)-1,
+		len(args),
+	)
+	return r.queryDeliveries(ctx, query, args...)
+}
+
+// ClaimDueDeliveries 以 FOR UPDATE SKIP LOCKED 领取到期记录并顺延 next_attempt_at，
+// 进程在投递中途崩溃时，记录会在租期结束后被重新领取。
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	query := fmt.Sprintf(`UPDATE webhook_deliveries SET next_attempt_at = $1, updated_at = $2
+	WHERE id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = $3 AND next_attempt_at <= $2
+		ORDER BY next_attempt_at
+		LIMIT $4
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING %s`, strings.Join(deliverySelectColumns, ","))
+	return r.queryDeliveries(ctx, query, now.Add(lease), now, repository.DeliveryStatusPending, limit)
+}
+
+// UpdateDelivery 记录一次投递尝试的结果，记录已被删除或已被重新领取时返回 ErrNotFound。
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *repository.WebhookDelivery, claim repository.DeliveryClaim) error {
+	if delivery == nil {
+		return fmt.Errorf("webhook delivery is nil")
+	}
+
+	var responseStatus sql.NullInt64
+	if delivery.ResponseStatus != nil {
+		responseStatus = sql.NullInt64{Int64: int64(*delivery.ResponseStatus), Valid: true}
+	}
+	var lastAttempt sql.NullTime
+	if delivery.LastAttemptAt != nil {
+		lastAttempt = sql.NullTime{Time: *delivery.LastAttemptAt, Valid: true}
+	}
+
+	res, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries
+	SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = $4,
+		response_status = $5, last_error = $6, updated_at = $7
+	WHERE id = $8 AND status = $9 AND attempts = $10 AND next_attempt_at = $11`,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		lastAttempt,
+		responseStatus,
+		delivery.LastError,
+		time.Now().UTC(),
+		delivery.ID,
+		repository.DeliveryStatusPending,
+		claim.Attempts,
+		claim.NextAttemptAt,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) queryWebhooks(ctx context.Context, query string, args ...any) ([]repository.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []repository.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *WebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]repository.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []repository.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func scanWebhook(rs rowScanner) (*repository.Webhook, error) {
+	var (
+		webhook repository.Webhook
+		events  []byte
+	)
+	if err := rs.Scan(
+		&webhook.ID,
+		&webhook.OwnerID,
+		&webhook.URL,
+		&webhook.Secret,
+		&events,
+		&webhook.Description,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(events, &webhook.Events); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func scanDelivery(rs rowScanner) (*repository.WebhookDelivery, error) {
+	var (
+		delivery       repository.WebhookDelivery
+		payload        []byte
+		lastAttempt    sql.NullTime
+		responseStatus sql.NullInt64
+	)
+	if err := rs.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&lastAttempt,
+		&responseStatus,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	if lastAttempt.Valid {
+		delivery.LastAttemptAt = &lastAttempt.Time
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// SchemaScope 描述元数据 Schema 的匹配维度。
+type SchemaScope string
+
+const (
+	// SchemaScopeType 按 metadata 中的 "type" 字段取值匹配。
+	SchemaScopeType SchemaScope = "type"
+	// SchemaScopeOwner 按上传者的 owner ID 匹配。
+	SchemaScopeOwner SchemaScope = "owner"
+)
+
+// MetadataSchema 代表管理员登记的一份 JSON Schema。
+type MetadataSchema struct {
+	ID          string          `json:"id"`
+	Scope       SchemaScope     `json:"scope"`
+	ScopeValue  string          `json:"scope_value"`
+	Schema      json.RawMessage `json:"schema"`
+	Description string          `json:"description,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// SchemaMatch 描述一次校验需要匹配的维度取值，空字符串表示不匹配该维度。
+type SchemaMatch struct {
+	Type    string
+	OwnerID string
+}
+
+// MetadataSchemaRepository 统一元数据 Schema 持久层接口。
+type MetadataSchemaRepository interface {
+	// Upsert 按 (scope, scope_value) 创建或替换 Schema。
+	Upsert(ctx context.Context, schema *MetadataSchema) (*MetadataSchema, error)
+	List(ctx context.Context) ([]MetadataSchema, error)
+	Delete(ctx context.Context, id string) error
+	// FindMatching 返回与给定维度匹配的全部 Schema。
+	FindMatching(ctx context.Context, match SchemaMatch) ([]MetadataSchema, error)
+}
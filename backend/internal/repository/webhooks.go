@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Webhook 代表 owner 登记的事件回调端点。
+type Webhook struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"-"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DeliveryStatus 描述一次投递的状态。
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery 是投递队列中的一条记录，同时作为投递日志保留。
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ListDeliveriesParams 用于分页检索某个 webhook 的投递日志，Status 为空时不过滤。
+type ListDeliveriesParams struct {
+	WebhookID string
+	Status    DeliveryStatus
+	Limit     int
+	Offset    int
+}
+
+// WebhookRepository 统一 webhook 与投递队列的持久层接口。
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *Webhook) (*Webhook, error)
+	GetByID(ctx context.Context, id string) (*Webhook, error)
+	ListByOwner(ctx context.Context, ownerID string) ([]Webhook, error)
+	Delete(ctx context.Context, id string) error
+	// ListSubscribers 返回 owner 下订阅了 eventType 的启用状态 webhook。
+	ListSubscribers(ctx context.Context, ownerID, eventType string) ([]Webhook, error)
+
+	EnqueueDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	GetDelivery(ctx context.Context, id string) (*WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, params ListDeliveriesParams) ([]WebhookDelivery, error)
+	// ClaimDueDeliveries 领取最多 limit 条到期的待投递记录，并把它们的 next_attempt_at
+	// 推迟 lease，避免多个实例重复投递；投递结束后须调用 UpdateDelivery 落库结果。
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	// UpdateDelivery 只在记录仍处于 claim 对应的那次领取时写入结果，否则返回 ErrNotFound，
+	// 以免租约过期后被其他实例重新领取的记录被旧结果覆盖。
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery, claim DeliveryClaim) error
+}
+
+// DeliveryClaim 标识一次领取，取值为 ClaimDueDeliveries 返回时记录的 attempts 与 next_attempt_at。
+type DeliveryClaim struct {
+	Attempts      int
+	NextAttemptAt time.Time
+}
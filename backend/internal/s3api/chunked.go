@@ -0,0 +1,143 @@
+package s3api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+)
+
+// chunkedReader 解码 aws-chunked 请求体：
+//
+//	<hex-size>[;chunk-signature=<sig>]\r\n<data>\r\n ... 0[;chunk-signature=<sig>]\r\n[trailers]\r\n
+//
+// sig 不为 nil 时逐块校验签名链；结尾的 trailer（如 x-amz-checksum-*）直接丢弃，
+// 数据完整性已由分块签名或调用方计算的 MD5 保证。
+type chunkedReader struct {
+	r         *bufio.Reader
+	sig       *signature
+	previous  string
+	expected  string
+	remaining int64
+	hasher    hash.Hash
+	done      bool
+}
+
+func newChunkedReader(body io.Reader, sig *signature) *chunkedReader {
+	c := &chunkedReader{r: bufio.NewReader(body), sig: sig, hasher: sha256.New()}
+	if sig != nil {
+		c.previous = sig.seed
+	}
+	return c
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.hasher.Write(p[:n])
+	c.remaining -= int64(n)
+	if err == io.EOF {
+		return n, errMalformedChunk
+	}
+	if err != nil {
+		return n, err
+	}
+
+	if c.remaining == 0 {
+		if err := c.finishChunk(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// nextChunk 读取分块头；遇到长度为 0 的结束块时校验其签名并丢弃剩余的 trailer。
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return errMalformedChunk
+	}
+	line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+
+	sizeField, ext, _ := bytes.Cut(line, []byte(";"))
+	size, err := strconv.ParseInt(string(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return errMalformedChunk
+	}
+
+	c.expected = ""
+	if c.sig != nil {
+		sig, ok := bytes.CutPrefix(ext, []byte("chunk-signature="))
+		if !ok {
+			return errMalformedChunk
+		}
+		c.expected = string(sig)
+	}
+	c.hasher.Reset()
+	c.remaining = size
+
+	if size == 0 {
+		if err := c.verify(); err != nil {
+			return err
+		}
+		c.done = true
+		_, err := io.Copy(io.Discard, c.r)
+		return err
+	}
+	return nil
+}
+
+func (c *chunkedReader) finishChunk() error {
+	crlf := make([]byte, 2)
+	if _, err := io.ReadFull(c.r, crlf); err != nil || string(crlf) != "\r\n" {
+		return errMalformedChunk
+	}
+	return c.verify()
+}
+
+func (c *chunkedReader) verify() error {
+	if c.sig == nil {
+		return nil
+	}
+	computed := c.sig.chunkSignature(c.previous, hex.EncodeToString(c.hasher.Sum(nil)))
+	if !hmac.Equal([]byte(computed), []byte(c.expected)) {
+		return errSignatureDoesNotMatch
+	}
+	c.previous = computed
+	return nil
+}
+
+// hashingReader 在读到 EOF 时比对请求体的 SHA-256 与 x-amz-content-sha256。
+type hashingReader struct {
+	r        io.Reader
+	hasher   hash.Hash
+	expected string
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hasher.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(h.hasher.Sum(nil)) != h.expected {
+		return n, errContentSHA256Mismatch
+	}
+	return n, err
+}
@@ -0,0 +1,111 @@
+package s3api
+
+import (
+	"context"
+	"strings"
+
+	"droplite/internal/auth"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+)
+
+// Credentials 是 S3 网关使用的 SigV4 凭证，OwnerID 与 Scopes 为签名通过后写入 context 的调用方。
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	OwnerID         string
+	// Actor 与 KeyID 是审计记录中的调用方与 Key ID，含义同 auth.Principal。
+	Actor  string
+	KeyID  string
+	Scopes []string
+	// Networks 限制凭证可以从哪些客户端地址使用，零值不限制。
+	Networks auth.NetworkPolicy
+}
+
+// DeriveCredentials 由 API Key 确定性地派生 S3 凭证，规则见 service.DeriveS3Credentials。
+func DeriveCredentials(apiKey string) Credentials {
+	accessKeyID, secret := service.DeriveS3Credentials(apiKey)
+	principal := auth.StaticKeyPrincipal(apiKey)
+	return Credentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secret,
+		OwnerID:         principal.OwnerID,
+		Actor:           principal.Actor,
+		KeyID:           principal.KeyID,
+		Scopes:          principal.Scopes,
+	}
+}
+
+// CredentialStore 按 AccessKeyID 查找凭证，不存在时返回 errInvalidAccessKeyID。
+type CredentialStore interface {
+	Lookup(ctx context.Context, accessKeyID string) (Credentials, error)
+}
+
+// NewStaticCredentialStore 为静态 API Key 列表中的每个 Key 派生一组凭证。
+func NewStaticCredentialStore(apiKeys []string) CredentialStore {
+	store := make(staticCredentialStore, len(apiKeys))
+	for _, key := range apiKeys {
+		trimmed := strings.TrimSpace(key)
+		if trimmed == "" {
+			continue
+		}
+		creds := DeriveCredentials(trimmed)
+		store[creds.AccessKeyID] = creds
+	}
+	return store
+}
+
+type staticCredentialStore map[string]Credentials
+
+func (s staticCredentialStore) Lookup(ctx context.Context, accessKeyID string) (Credentials, error) {
+	creds, ok := s[accessKeyID]
+	if !ok {
+		return Credentials{}, errInvalidAccessKeyID
+	}
+	return creds, nil
+}
+
+// KeyLookup 按 AccessKeyID 查找数据库签发的 API Key，由 service.APIKeyService 实现。
+type KeyLookup interface {
+	LookupS3Credentials(ctx context.Context, accessKeyID string) (*repository.APIKey, error)
+}
+
+// NewCredentialStore 先查静态 API_KEYS 派生的凭证，再查数据库签发的 Key；keys 为 nil 时只使用静态列表。
+func NewCredentialStore(apiKeys []string, keys KeyLookup) CredentialStore {
+	static := NewStaticCredentialStore(apiKeys)
+	if keys == nil {
+		return static
+	}
+	return &chainedCredentialStore{static: static, keys: keys}
+}
+
+type chainedCredentialStore struct {
+	static CredentialStore
+	keys   KeyLookup
+}
+
+func (s *chainedCredentialStore) Lookup(ctx context.Context, accessKeyID string) (Credentials, error) {
+	if creds, err := s.static.Lookup(ctx, accessKeyID); err == nil {
+		return creds, nil
+	}
+	key, err := s.keys.LookupS3Credentials(ctx, accessKeyID)
+	if err != nil {
+		// 吊销、过期与不存在对客户端一视同仁；数据库故障按内部错误处理
+		if kind := service.ErrorKindOf(err); kind == service.KindNotFound || kind == service.KindUnauthorized {
+			return Credentials{}, errInvalidAccessKeyID
+		}
+		return Credentials{}, err
+	}
+	networks, err := service.APIKeyNetworkPolicy(key)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{
+		AccessKeyID:     key.S3AccessKeyID,
+		SecretAccessKey: key.S3Secret,
+		OwnerID:         key.OwnerID,
+		KeyID:           key.ID,
+		Scopes:          key.Scopes,
+		Networks:        networks,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+
+	"droplite/internal/service"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// s3Error 是以 S3 XML 错误格式返回给客户端的错误。
+type s3Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newS3Error(code, message string, status int) *s3Error {
+	return &s3Error{Code: code, Message: message, Status: status}
+}
+
+var (
+	errAccessDenied             = newS3Error("AccessDenied", "Access Denied", http.StatusForbidden)
+	errInvalidAccessKeyID       = newS3Error("InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden)
+	errSignatureDoesNotMatch    = newS3Error("SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden)
+	errRequestTimeTooSkewed     = newS3Error("RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden)
+	errExpiredPresignRequest    = newS3Error("AccessDenied", "Request has expired", http.StatusForbidden)
+	errAuthorizationMalformed   = newS3Error("AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest)
+	errMissingContentSHA256     = newS3Error("InvalidRequest", "Missing required header for this request: x-amz-content-sha256", http.StatusBadRequest)
+	errContentSHA256Mismatch    = newS3Error("XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest)
+	errInvalidDigest            = newS3Error("InvalidDigest", "The Content-MD5 you specified is not valid.", http.StatusBadRequest)
+	errBadDigest                = newS3Error("BadDigest", "The Content-MD5 you specified did not match what we received.", http.StatusBadRequest)
+	errIncompleteBody           = newS3Error("IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest)
+	errMalformedChunk           = newS3Error("IncompleteBody", "The aws-chunked request body is malformed.", http.StatusBadRequest)
+	errEntityTooLarge           = newS3Error("EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest)
+	errEmptyObject              = newS3Error("InvalidArgument", "Empty objects are not supported.", http.StatusBadRequest)
+	errInvalidBucketName        = newS3Error("InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest)
+	errNoSuchKey                = newS3Error("NoSuchKey", "The specified key does not exist.", http.StatusNotFound)
+	errNotImplemented           = newS3Error("NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented)
+	errInternalError            = newS3Error("InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError)
+	errServiceUnavailable       = newS3Error("ServiceUnavailable", "Please reduce your request rate.", http.StatusServiceUnavailable)
+	errSlowDown                 = newS3Error("SlowDown", "Too many uploads in progress, please retry later.", http.StatusServiceUnavailable)
+	errUnsupportedStreamingMode = newS3Error("NotImplemented", "The requested x-amz-content-sha256 streaming mode is not supported.", http.StatusNotImplemented)
+)
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// toS3Error 将服务层错误码映射为最接近的 S3 错误。
+func toS3Error(err error) *s3Error {
+	var s3Err *s3Error
+	if errors.As(err, &s3Err) {
+		return s3Err
+	}
+
+	svcErr := service.AsError(err)
+	switch svcErr.Kind {
+	case service.KindValidation:
+		return newS3Error("InvalidArgument", svcErr.Message, http.StatusBadRequest)
+	case service.KindNotFound:
+		return errNoSuchKey
+	case service.KindPayloadTooLarge:
+		return errEntityTooLarge
+	case service.KindUnauthorized, service.KindForbidden, service.KindQuotaExceeded:
+		return newS3Error("AccessDenied", svcErr.Message, http.StatusForbidden)
+	case service.KindRateLimited:
+		return newS3Error("SlowDown", svcErr.Message, http.StatusServiceUnavailable)
+	case service.KindStorageUnavailable, service.KindDatabaseUnavailable:
+		return errServiceUnavailable
+	default:
+		return errInternalError
+	}
+}
+
+// writeError 以 S3 XML 格式输出错误；HEAD 请求只返回状态码。
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	s3Err := toS3Error(err)
+	requestID := chimiddleware.GetReqID(r.Context())
+	if s3Err.Status >= http.StatusInternalServerError {
+		log.Printf("[s3] [%s] %s %s: %v", requestID, r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("x-amz-request-id", requestID)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.Status)
+		return
+	}
+	writeXML(w, s3Err.Status, errorResponse{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  r.URL.Path,
+		RequestID: requestID,
+	})
+}
+
+func writeXML(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(body)
+}
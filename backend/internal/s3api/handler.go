@@ -0,0 +1,585 @@
+package s3api
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	// listPageSize 是列举对象时每次向仓储请求的记录数。
+	listPageSize = 500
+	// defaultMaxKeys 是 ListObjectsV2 单页返回的最大条目数。
+	defaultMaxKeys   = 1000
+	metaHeaderPrefix = "X-Amz-Meta-"
+)
+
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// unsupportedSubresources 是本网关未实现的 S3 子资源查询参数。
+var unsupportedSubresources = []string{
+	"acl", "tagging", "uploads", "uploadId", "partNumber", "versioning", "versionId",
+	"policy", "lifecycle", "cors", "retention", "legal-hold", "attributes", "delete",
+}
+
+// Handler 实现 S3 API 的最小子集（ListObjectsV2、GetObject、PutObject、HeadObject、DeleteObject），
+// 仅支持路径风格访问。bucket 映射为 owner 名下的顶层目录，对象 key 为其下的相对路径，
+// 即文件的 original_name 为 "<bucket>/<key>"，与 WebDAV 看到的路径一致。
+type Handler struct {
+	files         *service.FileService
+	creds         CredentialStore
+	region        string
+	maxUploadSize int64
+	transfers     *dlmiddleware.TransferLimiter
+}
+
+// NewHandler 创建 S3 网关的 http.Handler；creds 为 nil 时不校验签名（开发模式）。
+// 只信任来自 trustedProxies 的 X-Forwarded-For，凭证的网络策略按由此得到的客户端地址判断。
+// PutObject 与 GetObject 经 transfers 做上传准入与按 owner 限速，transfers 为 nil 时不做限制。
+// 对象的上传、下载、删除与鉴权失败按 REST 入口的格式写入 audit，audit 为 nil 时不记录。
+func NewHandler(files *service.FileService, creds CredentialStore, region string, maxUploadSize int64, trustedProxies []netip.Prefix, transfers *dlmiddleware.TransferLimiter, audit auth.AuditRecorder) http.Handler {
+	h := &Handler{files: files, creds: creds, region: region, maxUploadSize: maxUploadSize, transfers: transfers}
+
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(dlmiddleware.RealIP(trustedProxies))
+	r.Use(dlmiddleware.AuditTrail(audit))
+	r.Use(chimiddleware.Logger)
+	r.Use(chimiddleware.Recoverer)
+	r.Handle("/*", http.HandlerFunc(h.serve))
+	return r
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	var sig *signature
+	if h.creds != nil {
+		var err error
+		sig, err = h.authenticate(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		principal := auth.Principal{
+			OwnerID:  sig.creds.OwnerID,
+			Actor:    sig.creds.Actor,
+			KeyID:    sig.creds.KeyID,
+			Scopes:   sig.creds.Scopes,
+			Networks: sig.creds.Networks,
+		}
+		if addr := dlmiddleware.ClientAddr(r); !principal.Networks.Permits(addr) {
+			dlmiddleware.DenyNetwork(r.Context(), principal, "s3")
+			writeError(w, r, newS3Error("AccessDenied", auth.NetworkDeniedMessage(addr), http.StatusForbidden))
+			return
+		}
+		r = r.WithContext(dlmiddleware.WithPrincipal(r.Context(), principal))
+	}
+	// 与 REST 路由上的 AuditAction 一样在 scope 检查之前标注，缺少 scope 的请求也以该动作记录
+	if action := objectAuditAction(r); action != "" {
+		dlmiddleware.SetAuditAction(r.Context(), action)
+	}
+	if !dlmiddleware.HasScope(r.Context(), methodScope(r.Method)) {
+		writeError(w, r, errAccessDenied)
+		return
+	}
+
+	query := r.URL.Query()
+	for _, name := range unsupportedSubresources {
+		if query.Has(name) {
+			writeError(w, r, errNotImplemented)
+			return
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case bucket == "":
+		// ListBuckets 未实现
+		writeError(w, r, errNotImplemented)
+	case !bucketNamePattern.MatchString(bucket):
+		writeError(w, r, errInvalidBucketName)
+	case key == "":
+		switch {
+		case r.Method == http.MethodGet && query.Has("location"):
+			h.getBucketLocation(w, r)
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			h.listObjectsV2(w, r, bucket)
+		case r.Method == http.MethodHead:
+			// bucket 随首次写入隐式存在，对合法名称一律返回存在
+			w.WriteHeader(http.StatusOK)
+		default:
+			writeError(w, r, errNotImplemented)
+		}
+	default:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			h.getObject(w, r, bucket, key)
+		case http.MethodPut:
+			h.putObject(w, r, sig, bucket, key)
+		case http.MethodDelete:
+			h.deleteObject(w, r, bucket, key)
+		default:
+			writeError(w, r, errNotImplemented)
+		}
+	}
+}
+
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+func (h *Handler) getBucketLocation(w http.ResponseWriter, r *http.Request) {
+	location := h.region
+	if location == "us-east-1" {
+		// 与 AWS 一致，默认区域返回空值
+		location = ""
+	}
+	writeXML(w, http.StatusOK, locationConstraint{Location: location})
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectEntry  `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listObjectsV2 按 key 字典序列出对象；同一 key 只保留最新的记录。
+// continuation-token 为上一页最后一个条目（key 或公共前缀）的 base64 编码。
+func (h *Handler) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+
+	maxKeys := defaultMaxKeys
+	if raw := query.Get("max-keys"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeError(w, r, newS3Error("InvalidArgument", "max-keys must be a non-negative integer", http.StatusBadRequest))
+			return
+		}
+		maxKeys = min(n, defaultMaxKeys)
+	}
+
+	marker := query.Get("start-after")
+	if token := query.Get("continuation-token"); token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			writeError(w, r, newS3Error("InvalidArgument", "The continuation token provided is incorrect", http.StatusBadRequest))
+			return
+		}
+		marker = string(decoded)
+	}
+
+	records, err := h.list(r, repository.ListFilesParams{NamePrefix: bucket + "/" + prefix})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	latest := make(map[string]*repository.FileRecord, len(records))
+	keys := make([]string, 0, len(records))
+	for i := range records {
+		key := strings.TrimPrefix(records[i].OriginalName, bucket+"/")
+		if _, ok := latest[key]; !ok {
+			latest[key] = &records[i]
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := listBucketResult{
+		Name:              bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: query.Get("continuation-token"),
+		MaxKeys:           maxKeys,
+	}
+	last := ""
+	for _, key := range keys {
+		if key <= marker || (marker != "" && delimiter != "" && strings.HasSuffix(marker, delimiter) && strings.HasPrefix(key, marker)) {
+			continue
+		}
+
+		entry := key
+		isPrefix := false
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				entry = key[:len(prefix)+idx+len(delimiter)]
+				isPrefix = true
+			}
+		}
+		if isPrefix && entry == last {
+			continue
+		}
+
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			if last != "" {
+				result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			}
+			break
+		}
+
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: entry})
+		} else {
+			record := latest[key]
+			result.Contents = append(result.Contents, objectEntry{
+				Key:          key,
+				LastModified: record.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+				ETag:         etag(record),
+				Size:         record.SizeBytes,
+				StorageClass: "STANDARD",
+			})
+		}
+		result.KeyCount++
+		last = entry
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+// getObject 处理 GetObject 与 HeadObject，Range 与条件请求交给 http.ServeContent。
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	record, err := h.find(r, bucket+"/"+key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if record == nil {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+
+	dlmiddleware.SetAuditFileID(r.Context(), record.ID)
+
+	content := h.files.OpenContent(r.Context(), record)
+	defer content.Close()
+	if r.Method == http.MethodGet {
+		// 先打开内容流，使存储故障以 S3 错误返回，而不是在响应头发出后中断
+		if _, err := content.Read(nil); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", record.MimeType)
+	header.Set("ETag", etag(record))
+	header.Set("x-amz-request-id", chimiddleware.GetReqID(r.Context()))
+	for name, value := range record.Metadata {
+		headerName := metaHeaderPrefix + name
+		if !httpguts.ValidHeaderFieldName(headerName) {
+			continue
+		}
+		switch v := value.(type) {
+		case string, bool, float64, int, int64:
+			formatted := fmt.Sprint(v)
+			if httpguts.ValidHeaderFieldValue(formatted) {
+				header.Set(headerName, formatted)
+			}
+		}
+	}
+
+	shaped, done := h.transfers.ShapeResponseWriter(r.Context(), dlmiddleware.TransferKey(r.Context(), dlmiddleware.ClientAddr(r)), w)
+	defer done()
+	http.ServeContent(shaped, r, "", record.UpdatedAt, content)
+}
+
+// putObject 将请求体缓冲到临时文件并校验签名、长度与 Content-MD5，全部通过后才经 FileService 登记；
+// 覆盖已有 key 时先登记新记录，再软删除旧记录。
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, sig *signature, bucket, key string) {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeError(w, r, errNotImplemented)
+		return
+	}
+
+	declared := r.ContentLength
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	var body io.Reader = r.Body
+	switch payloadHash {
+	case streamingPayload, streamingPayloadTrailer:
+		body = newChunkedReader(r.Body, sig)
+		declared = -1
+	case streamingUnsignedTrailer:
+		body = newChunkedReader(r.Body, nil)
+		declared = -1
+	case "", unsignedPayload:
+	default:
+		if len(payloadHash) != sha256.Size*2 {
+			writeError(w, r, errUnsupportedStreamingMode)
+			return
+		}
+		body = &hashingReader{r: r.Body, hasher: sha256.New(), expected: payloadHash}
+	}
+	if raw := r.Header.Get("X-Amz-Decoded-Content-Length"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, r, newS3Error("InvalidArgument", "invalid x-amz-decoded-content-length", http.StatusBadRequest))
+			return
+		}
+		declared = n
+	}
+	if declared > h.maxUploadSize {
+		writeError(w, r, errEntityTooLarge)
+		return
+	}
+
+	var expectedMD5 []byte
+	if raw := r.Header.Get("Content-Md5"); raw != "" {
+		decoded, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil || len(decoded) != md5.Size {
+			writeError(w, r, errInvalidDigest)
+			return
+		}
+		expectedMD5 = decoded
+	}
+
+	// 与其他入口共用上传准入名额，按声明的长度预留
+	transferKey := dlmiddleware.TransferKey(r.Context(), dlmiddleware.ClientAddr(r))
+	release, err := h.transfers.Acquire(r.Context(), transferKey, dlmiddleware.UploadReservation(declared, h.maxUploadSize))
+	if err != nil {
+		if errors.Is(err, dlmiddleware.ErrUploadsBusy) {
+			w.Header().Set("Retry-After", dlmiddleware.UploadRetryAfter())
+			writeError(w, r, errSlowDown)
+		}
+		// 其余错误只可能是客户端取消，无需响应
+		return
+	}
+	defer release()
+	shapedBody, doneReading := h.transfers.ShapeReader(r.Context(), transferKey, io.NopCloser(body))
+	defer doneReading()
+	body = shapedBody
+
+	tmp, err := os.CreateTemp("", "droplite-s3-*")
+	if err != nil {
+		writeError(w, r, fmt.Errorf("create upload buffer: %w", err))
+		return
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	digest := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, digest), io.LimitReader(body, h.maxUploadSize+1))
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+		return
+	case size > h.maxUploadSize:
+		writeError(w, r, errEntityTooLarge)
+		return
+	case declared >= 0 && size != declared:
+		writeError(w, r, errIncompleteBody)
+		return
+	case size == 0:
+		writeError(w, r, errEmptyObject)
+		return
+	}
+	sum := digest.Sum(nil)
+	if expectedMD5 != nil && string(expectedMD5) != string(sum) {
+		writeError(w, r, errBadDigest)
+		return
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	mimeType := r.Header.Get("Content-Type")
+	if mimeType == "" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(tmp, head)
+		mimeType = http.DetectContentType(head[:n])
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	name := bucket + "/" + key
+	previous, err := h.list(r, repository.ListFilesParams{Name: name})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	md5Hex := hex.EncodeToString(sum)
+	checksum := "md5:" + md5Hex
+	created, err := h.files.RegisterFile(r.Context(), service.RegisterFileInput{
+		OwnerID:      dlmiddleware.GetOwnerID(r.Context()),
+		OriginalName: name,
+		MimeType:     mimeType,
+		SizeBytes:    size,
+		Checksum:     &checksum,
+		Metadata:     userMetadata(r.Header),
+		Reader:       tmp,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	dlmiddleware.SetAuditFileID(r.Context(), created.ID)
+	for _, record := range previous {
+		if err := h.files.DeleteFile(r.Context(), record.ID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		dlmiddleware.AddAuditEvent(r.Context(), auth.AuditFileDelete, record.ID, auth.AuditSuccess)
+	}
+
+	w.Header().Set("ETag", `"`+md5Hex+`"`)
+	w.Header().Set("x-amz-request-id", chimiddleware.GetReqID(r.Context()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteObject 软删除 key 对应的全部记录；与 S3 一致，key 不存在时同样返回 204。
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	records, err := h.list(r, repository.ListFilesParams{Name: bucket + "/" + key})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(records) > 0 {
+		dlmiddleware.SetAuditFileID(r.Context(), records[0].ID)
+	}
+	for i, record := range records {
+		if err := h.files.DeleteFile(r.Context(), record.ID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if i > 0 {
+			dlmiddleware.AddAuditEvent(r.Context(), auth.AuditFileDelete, record.ID, auth.AuditSuccess)
+		}
+	}
+	w.Header().Set("x-amz-request-id", chimiddleware.GetReqID(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// find 返回给定名称上最新的已存储文件，不存在时返回 nil。
+func (h *Handler) find(r *http.Request, name string) (*repository.FileRecord, error) {
+	records, err := h.files.ListFiles(r.Context(), repository.ListFilesParams{
+		OwnerID:  dlmiddleware.GetOwnerID(r.Context()),
+		Name:     name,
+		Statuses: []repository.FileStatus{repository.FileStatusStored},
+		Limit:    1,
+	})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+// list 翻页取回当前 owner 下满足条件的全部已存储文件。
+func (h *Handler) list(r *http.Request, params repository.ListFilesParams) ([]repository.FileRecord, error) {
+	params.OwnerID = dlmiddleware.GetOwnerID(r.Context())
+	params.Statuses = []repository.FileStatus{repository.FileStatusStored}
+	params.Limit = listPageSize
+
+	var all []repository.FileRecord
+	for {
+		page, err := h.files.ListFiles(r.Context(), params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < listPageSize {
+			return all, nil
+		}
+		params.Offset += len(page)
+	}
+}
+
+// etag 对经网关上传的文件返回内容 MD5，其余文件返回基于 ID 的不透明值。
+func etag(record *repository.FileRecord) string {
+	if record.Checksum != nil {
+		if sum, ok := strings.CutPrefix(*record.Checksum, "md5:"); ok && len(sum) == md5.Size*2 {
+			return `"` + sum + `"`
+		}
+	}
+	return `"` + record.ID + `"`
+}
+
+// userMetadata 收集 x-amz-meta-* 请求头作为文件 metadata，键名统一为小写。
+func userMetadata(header http.Header) map[string]any {
+	metadata := map[string]any{}
+	for name, values := range header {
+		if key, ok := strings.CutPrefix(name, metaHeaderPrefix); ok && key != "" && len(values) > 0 {
+			metadata[strings.ToLower(key)] = values[0]
+		}
+	}
+	return metadata
+}
+
+// methodScope 返回 HTTP 方法需要的 scope，未实现的方法按写操作处理。
+// objectAuditAction 返回对象级请求的审计动作，与 REST 端点一致；HEAD 与 bucket 级请求不标注。
+func objectAuditAction(r *http.Request) string {
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		return ""
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return auth.AuditFileDownload
+	case http.MethodPut:
+		return auth.AuditFileCreate
+	case http.MethodDelete:
+		return auth.AuditFileDelete
+	default:
+		return ""
+	}
+}
+
+func methodScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return auth.ScopeFilesRead
+	case http.MethodDelete:
+		return auth.ScopeFilesDelete
+	default:
+		return auth.ScopeFilesWrite
+	}
+}
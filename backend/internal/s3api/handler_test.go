@@ -0,0 +1,422 @@
+package s3api
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"droplite/internal/auth"
+	dlmiddleware "droplite/internal/middleware"
+	"droplite/internal/repository"
+	"droplite/internal/repository/memrepo"
+	"droplite/internal/service"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func newTestGateway(t *testing.T) (*httptest.Server, *memrepo.FileRepository) {
+	t.Helper()
+
+	repo := memrepo.NewFileRepository()
+	files := service.NewFileService(repo, memrepo.NewStorage())
+	srv := httptest.NewServer(NewHandler(files, NewStaticCredentialStore([]string{"key-a", "key-b"}), "us-east-1", 1<<20, nil, nil, nil))
+	t.Cleanup(srv.Close)
+	return srv, repo
+}
+
+func newClient(t *testing.T, srv *httptest.Server, accessKey, secretKey string, opts ...func(*minio.Options)) *minio.Client {
+	t.Helper()
+
+	options := &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKey, secretKey, ""),
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "http://"), options)
+	if err != nil {
+		t.Fatalf("create minio client: %v", err)
+	}
+	return client
+}
+
+func clientFor(t *testing.T, srv *httptest.Server, apiKey string, opts ...func(*minio.Options)) *minio.Client {
+	creds := DeriveCredentials(apiKey)
+	return newClient(t, srv, creds.AccessKeyID, creds.SecretAccessKey, opts...)
+}
+
+func readObject(t *testing.T, client *minio.Client, bucket, key string, opts minio.GetObjectOptions) string {
+	t.Helper()
+	obj, err := client.GetObject(context.Background(), bucket, key, opts)
+	if err != nil {
+		t.Fatalf("get object: %v", err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatalf("read object %s/%s: %v", bucket, key, err)
+	}
+	return string(data)
+}
+
+func getObjectError(client *minio.Client, bucket, key string) error {
+	obj, err := client.GetObject(context.Background(), bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	_, err = io.ReadAll(obj)
+	return err
+}
+
+func TestGateway_ObjectLifecycle(t *testing.T) {
+	srv, repo := newTestGateway(t)
+	client := clientFor(t, srv, "key-a")
+	ctx := context.Background()
+
+	content := "hello from s3"
+	info, err := client.PutObject(ctx, "photos", "2024/trip/a.txt", strings.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		ContentType:  "text/plain",
+		UserMetadata: map[string]string{"camera": "x100"},
+	})
+	if err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	sum := md5.Sum([]byte(content))
+	if info.ETag != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected md5 etag, got %q", info.ETag)
+	}
+	if rec := repo.Records()[0]; rec.OriginalName != "photos/2024/trip/a.txt" || rec.OwnerID != "key-a" || rec.Metadata["camera"] != "x100" {
+		t.Fatalf("unexpected stored record: %+v", rec)
+	}
+
+	stat, err := client.StatObject(ctx, "photos", "2024/trip/a.txt", minio.StatObjectOptions{})
+	if err != nil {
+		t.Fatalf("stat object: %v", err)
+	}
+	if stat.Size != int64(len(content)) || stat.ContentType != "text/plain" || stat.UserMetadata["Camera"] != "x100" || stat.ETag != info.ETag {
+		t.Fatalf("unexpected stat: %+v", stat)
+	}
+
+	if got := readObject(t, client, "photos", "2024/trip/a.txt", minio.GetObjectOptions{}); got != content {
+		t.Fatalf("unexpected content %q", got)
+	}
+	ranged := minio.GetObjectOptions{}
+	if err := ranged.SetRange(6, 9); err != nil {
+		t.Fatalf("set range: %v", err)
+	}
+	if got := readObject(t, client, "photos", "2024/trip/a.txt", ranged); got != "from" {
+		t.Fatalf("unexpected range content %q", got)
+	}
+
+	if err := client.RemoveObject(ctx, "photos", "2024/trip/a.txt", minio.RemoveObjectOptions{}); err != nil {
+		t.Fatalf("remove object: %v", err)
+	}
+	_, err = client.StatObject(ctx, "photos", "2024/trip/a.txt", minio.StatObjectOptions{})
+	if code := minio.ToErrorResponse(err).Code; code != "NoSuchKey" {
+		t.Fatalf("expected NoSuchKey after delete, got %v", err)
+	}
+}
+
+func TestGateway_PayloadModes(t *testing.T) {
+	srv, _ := newTestGateway(t)
+	ctx := context.Background()
+
+	cases := map[string]func(*minio.Options){
+		"streaming-signed": func(o *minio.Options) {},
+		"unsigned-trailer": func(o *minio.Options) { o.TrailingHeaders = true },
+	}
+	for name, opt := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := clientFor(t, srv, "key-a", opt)
+			body := strings.Repeat("0123456789", 20000)
+			_, err := client.PutObject(ctx, "modes", name, strings.NewReader(body), int64(len(body)), minio.PutObjectOptions{
+				DisableContentSha256: name == "unsigned-trailer",
+			})
+			if err != nil {
+				t.Fatalf("put object: %v", err)
+			}
+			if got := readObject(t, client, "modes", name, minio.GetObjectOptions{}); got != body {
+				t.Fatalf("content mismatch: got %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestGateway_ListObjectsV2(t *testing.T) {
+	srv, _ := newTestGateway(t)
+	client := clientFor(t, srv, "key-a")
+	ctx := context.Background()
+
+	for _, key := range []string{"a.txt", "docs/1.txt", "docs/2.txt", "docs/sub/3.txt", "z.txt"} {
+		if _, err := client.PutObject(ctx, "archive", key, strings.NewReader(key), int64(len(key)), minio.PutObjectOptions{}); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+
+	collect := func(opts minio.ListObjectsOptions) []string {
+		var keys []string
+		for obj := range client.ListObjects(ctx, "archive", opts) {
+			if obj.Err != nil {
+				t.Fatalf("list objects: %v", obj.Err)
+			}
+			keys = append(keys, obj.Key)
+		}
+		return keys
+	}
+
+	if got := strings.Join(collect(minio.ListObjectsOptions{}), ","); got != "a.txt,z.txt,docs/" {
+		t.Fatalf("unexpected delimited listing %q", got)
+	}
+	if got := strings.Join(collect(minio.ListObjectsOptions{Prefix: "docs/", Recursive: true, MaxKeys: 1}), ","); got != "docs/1.txt,docs/2.txt,docs/sub/3.txt" {
+		t.Fatalf("unexpected paginated listing %q", got)
+	}
+	if got := strings.Join(collect(minio.ListObjectsOptions{Recursive: true, StartAfter: "docs/2.txt"}), ","); got != "docs/sub/3.txt,z.txt" {
+		t.Fatalf("unexpected start-after listing %q", got)
+	}
+}
+
+func TestGateway_AuthAndOwnerScoping(t *testing.T) {
+	srv, _ := newTestGateway(t)
+	ctx := context.Background()
+
+	owner := clientFor(t, srv, "key-a")
+	if _, err := owner.PutObject(ctx, "private", "secret.txt", strings.NewReader("s3cr3t"), 6, minio.PutObjectOptions{}); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+
+	other := clientFor(t, srv, "key-b")
+	_, err := other.StatObject(ctx, "private", "secret.txt", minio.StatObjectOptions{})
+	if code := minio.ToErrorResponse(err).Code; code != "NoSuchKey" {
+		t.Fatalf("expected other owner to get NoSuchKey, got %v", err)
+	}
+	for obj := range other.ListObjects(ctx, "private", minio.ListObjectsOptions{Recursive: true}) {
+		t.Fatalf("other owner listed %q", obj.Key)
+	}
+
+	// HEAD 响应没有错误体，这里用 GET 请求读取 S3 错误码。
+	forged := newClient(t, srv, DeriveCredentials("key-a").AccessKeyID, "wrong-secret")
+	if err := getObjectError(forged, "private", "secret.txt"); minio.ToErrorResponse(err).Code != "SignatureDoesNotMatch" {
+		t.Fatalf("expected SignatureDoesNotMatch, got %v", err)
+	}
+	unknown := newClient(t, srv, "DLUNKNOWN", "secret")
+	if err := getObjectError(unknown, "private", "secret.txt"); minio.ToErrorResponse(err).Code != "InvalidAccessKeyId" {
+		t.Fatalf("expected InvalidAccessKeyId, got %v", err)
+	}
+
+	presigned, err := owner.PresignedGetObject(ctx, "private", "secret.txt", time.Minute, url.Values{})
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	resp, err := http.Get(presigned.String())
+	if err != nil {
+		t.Fatalf("get presigned: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "s3cr3t" {
+		t.Fatalf("unexpected presigned response %d: %s", resp.StatusCode, data)
+	}
+
+	resp, err = http.Get(srv.URL + "/private/secret.txt")
+	if err != nil {
+		t.Fatalf("anonymous get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected anonymous request to be denied, got %d", resp.StatusCode)
+	}
+}
+
+// stubKeyLookup 模拟 service.APIKeyService 中数据库签发的 Key。
+type stubKeyLookup map[string]*repository.APIKey
+
+func (s stubKeyLookup) LookupS3Credentials(ctx context.Context, accessKeyID string) (*repository.APIKey, error) {
+	key, ok := s[accessKeyID]
+	if !ok {
+		return nil, service.NewError(service.KindNotFound, "api key not found")
+	}
+	if key.RevokedAt != nil {
+		return nil, service.NewError(service.KindUnauthorized, "API key has been revoked")
+	}
+	return key, nil
+}
+
+func TestGateway_DatabaseIssuedKeys(t *testing.T) {
+	const issuedKey = "dl_0123456789abcdef_secret"
+	accessKeyID, secret := service.DeriveS3Credentials(issuedKey)
+	keys := stubKeyLookup{accessKeyID: {
+		OwnerID:       "key-a",
+		S3AccessKeyID: accessKeyID,
+		S3Secret:      secret,
+		Scopes:        []string{auth.ScopeFilesRead},
+	}}
+
+	repo := memrepo.NewFileRepository()
+	files := service.NewFileService(repo, memrepo.NewStorage())
+	srv := httptest.NewServer(NewHandler(files, NewCredentialStore([]string{"key-a"}, keys), "us-east-1", 1<<20, nil, nil, nil))
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	// 数据库 Key 的 owner 与静态 Key 相同，两者能看到同一批对象
+	if _, err := clientFor(t, srv, "key-a").PutObject(ctx, "docs", "a.txt", strings.NewReader("hello"), 5, minio.PutObjectOptions{}); err != nil {
+		t.Fatalf("put object with static key: %v", err)
+	}
+	issued := newClient(t, srv, accessKeyID, secret)
+	if got := readObject(t, issued, "docs", "a.txt", minio.GetObjectOptions{}); got != "hello" {
+		t.Fatalf("read with issued key = %q", got)
+	}
+	// 只有 files:read 的 Key 不能写入或删除
+	if _, err := issued.PutObject(ctx, "docs", "b.txt", strings.NewReader("x"), 1, minio.PutObjectOptions{}); minio.ToErrorResponse(err).Code != "AccessDenied" {
+		t.Fatalf("expected AccessDenied for put without files:write, got %v", err)
+	}
+	if err := issued.RemoveObject(ctx, "docs", "a.txt", minio.RemoveObjectOptions{}); minio.ToErrorResponse(err).Code != "AccessDenied" {
+		t.Fatalf("expected AccessDenied for delete without files:delete, got %v", err)
+	}
+
+	now := time.Now()
+	keys[accessKeyID].RevokedAt = &now
+	if err := getObjectError(issued, "docs", "a.txt"); minio.ToErrorResponse(err).Code != "InvalidAccessKeyId" {
+		t.Fatalf("expected revoked key to be rejected, got %v", err)
+	}
+}
+
+func TestGateway_PutObjectSharesAdmissionLimits(t *testing.T) {
+	repo := memrepo.NewFileRepository()
+	files := service.NewFileService(repo, memrepo.NewStorage())
+	transfers := dlmiddleware.NewTransferLimiter(dlmiddleware.TransferLimits{MaxConcurrent: 1})
+	srv := httptest.NewServer(NewHandler(files, nil, "us-east-1", 1<<20, nil, transfers, nil))
+	defer srv.Close()
+
+	put := func() *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/photos/a.txt", strings.NewReader("hello"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	release, err := transfers.Acquire(context.Background(), "owner:other", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := put(); resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 503 SlowDown with Retry-After, got %d", resp.StatusCode)
+	}
+	if len(repo.Records()) != 0 {
+		t.Fatal("expected rejected upload not to be stored")
+	}
+	release()
+	if resp := put(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected upload after release to succeed, got %d", resp.StatusCode)
+	}
+}
+
+// recordingAuditor 保存写入的审计记录。
+type recordingAuditor struct {
+	mu     sync.Mutex
+	events []auth.AuditEvent
+}
+
+func (a *recordingAuditor) RecordAudit(ctx context.Context, event auth.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, event)
+}
+
+func TestGateway_AuditsObjectOperations(t *testing.T) {
+	repo := memrepo.NewFileRepository()
+	audit := &recordingAuditor{}
+	files := service.NewFileService(repo, memrepo.NewStorage())
+	srv := httptest.NewServer(NewHandler(files, NewStaticCredentialStore([]string{"key-a"}), "us-east-1", 1<<20, nil, nil, audit))
+	t.Cleanup(srv.Close)
+	client := clientFor(t, srv, "key-a")
+	ctx := context.Background()
+
+	for _, content := range []string{"v1", "v2"} {
+		if _, err := client.PutObject(ctx, "docs", "a.txt", strings.NewReader(content), int64(len(content)), minio.PutObjectOptions{}); err != nil {
+			t.Fatalf("put object: %v", err)
+		}
+	}
+	readObject(t, client, "docs", "a.txt", minio.GetObjectOptions{})
+	if err := client.RemoveObject(ctx, "docs", "a.txt", minio.RemoveObjectOptions{}); err != nil {
+		t.Fatalf("remove object: %v", err)
+	}
+	forged := newClient(t, srv, DeriveCredentials("key-a").AccessKeyID, "wrong-secret")
+	_ = getObjectError(forged, "docs", "a.txt")
+
+	records := repo.Records()
+	first, second := records[0].ID, records[1].ID
+	want := []auth.AuditEvent{
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileCreate, FileID: first, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileCreate, FileID: second, Outcome: auth.AuditSuccess},
+		// 覆盖写入软删除的旧记录
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: first, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDownload, FileID: second, Outcome: auth.AuditSuccess},
+		{Actor: auth.StaticKeyActor, Action: auth.AuditFileDelete, FileID: second, Outcome: auth.AuditSuccess},
+		{Action: auth.AuditAuthFailure, Outcome: auth.AuditDenied},
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if len(audit.events) != len(want) {
+		t.Fatalf("expected %d audit events, got %+v", len(want), audit.events)
+	}
+	for i, got := range audit.events {
+		if got.Actor != want[i].Actor || got.Action != want[i].Action || got.FileID != want[i].FileID || got.Outcome != want[i].Outcome {
+			t.Fatalf("event %d: expected %+v, got %+v", i, want[i], got)
+		}
+		// 静态 Key 以派生的 Key ID 记录，不写入原值
+		if keyID, _ := auth.DeriveKeyCredentials("key-a"); want[i].Actor != "" && got.KeyID != keyID {
+			t.Fatalf("event %d: expected key id %s, got %+v", i, keyID, got)
+		}
+		if got.IP != "127.0.0.1" || got.RequestID == "" {
+			t.Fatalf("event %d: missing request details: %+v", i, got)
+		}
+	}
+}
+
+func TestGateway_AuditsNetworkDenials(t *testing.T) {
+	const issuedKey = "dl_0123456789abcdef_secret"
+	accessKeyID, secret := service.DeriveS3Credentials(issuedKey)
+	keys := stubKeyLookup{accessKeyID: {
+		ID:            "key-1",
+		OwnerID:       "alice",
+		S3AccessKeyID: accessKeyID,
+		S3Secret:      secret,
+		Scopes:        []string{auth.ScopeFilesRead},
+		DeniedCIDRs:   []string{"127.0.0.0/8"},
+	}}
+
+	audit := &recordingAuditor{}
+	files := service.NewFileService(memrepo.NewFileRepository(), memrepo.NewStorage())
+	srv := httptest.NewServer(NewHandler(files, NewCredentialStore(nil, keys), "us-east-1", 1<<20, nil, nil, audit))
+	t.Cleanup(srv.Close)
+
+	if err := getObjectError(newClient(t, srv, accessKeyID, secret), "docs", "a.txt"); minio.ToErrorResponse(err).Code != "AccessDenied" {
+		t.Fatalf("expected AccessDenied, got %v", err)
+	}
+
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if len(audit.events) != 1 {
+		t.Fatalf("expected one audit event, got %+v", audit.events)
+	}
+	got := audit.events[0]
+	if got.Action != auth.AuditNetworkDenied || got.Actor != "alice" || got.KeyID != "key-1" ||
+		got.Outcome != auth.AuditDenied || got.Status != http.StatusForbidden {
+		t.Fatalf("unexpected audit event %+v", got)
+	}
+}
@@ -0,0 +1,243 @@
+package s3api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat = "20060102T150405Z"
+	scopeDateFmt  = "20060102"
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	maxClockSkew     = 15 * time.Minute
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// signature 是一次校验通过的 SigV4 签名；流式上传需用它继续校验每个分块的签名。
+type signature struct {
+	creds      Credentials
+	amzDate    string
+	scope      string
+	signingKey []byte
+	seed       string
+}
+
+// chunkSignature 计算 aws-chunked 分块签名，previous 为上一块（首块为请求）的签名。
+func (s *signature) chunkSignature(previous string, chunkHash string) string {
+	stringToSign := strings.Join([]string{
+		signAlgorithm + "-PAYLOAD",
+		s.amzDate,
+		s.scope,
+		previous,
+		emptySHA256,
+		chunkHash,
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(s.signingKey, stringToSign))
+}
+
+// authenticate 校验 Authorization 头或预签名 URL 中的 SigV4 签名。
+func (h *Handler) authenticate(r *http.Request) (*signature, error) {
+	var (
+		credential    string
+		signedHeaders []string
+		provided      string
+		amzDate       string
+		payloadHash   string
+		presigned     bool
+	)
+
+	query := r.URL.Query()
+	switch {
+	case query.Get("X-Amz-Algorithm") != "":
+		if query.Get("X-Amz-Algorithm") != signAlgorithm {
+			return nil, errAuthorizationMalformed
+		}
+		presigned = true
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+		provided = query.Get("X-Amz-Signature")
+		amzDate = query.Get("X-Amz-Date")
+		payloadHash = unsignedPayload
+		if v := query.Get("X-Amz-Content-Sha256"); v != "" {
+			payloadHash = v
+		}
+	case r.Header.Get("Authorization") != "":
+		var err error
+		credential, signedHeaders, provided, err = parseAuthorization(r.Header.Get("Authorization"))
+		if err != nil {
+			return nil, err
+		}
+		amzDate = r.Header.Get("X-Amz-Date")
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			return nil, errMissingContentSHA256
+		}
+	default:
+		return nil, errAccessDenied
+	}
+
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" || provided == "" {
+		return nil, errAuthorizationMalformed
+	}
+	signedAt, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil || parts[1] != signedAt.Format(scopeDateFmt) {
+		return nil, errAuthorizationMalformed
+	}
+
+	now := time.Now().UTC()
+	if presigned {
+		expires, err := strconv.Atoi(r.URL.Query().Get("X-Amz-Expires"))
+		if err != nil || expires <= 0 || time.Duration(expires)*time.Second > maxPresignExpiry {
+			return nil, errAuthorizationMalformed
+		}
+		if now.After(signedAt.Add(time.Duration(expires)*time.Second)) || signedAt.After(now.Add(maxClockSkew)) {
+			return nil, errExpiredPresignRequest
+		}
+	} else if now.Sub(signedAt) > maxClockSkew || signedAt.Sub(now) > maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	creds, err := h.creds.Lookup(r.Context(), parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	scope := strings.Join(parts[1:], "/")
+	canonical := canonicalRequest(r, signedHeaders, payloadHash, presigned)
+	stringToSign := strings.Join([]string{signAlgorithm, amzDate, scope, hashHex([]byte(canonical))}, "\n")
+	signingKey := deriveSigningKey(creds.SecretAccessKey, parts[1], parts[2])
+	expected := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(provided)) {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	return &signature{
+		creds:      creds,
+		amzDate:    amzDate,
+		scope:      scope,
+		signingKey: signingKey,
+		seed:       expected,
+	}, nil
+}
+
+// parseAuthorization 解析 "AWS4-HMAC-SHA256 Credential=..., SignedHeaders=..., Signature=..."。
+func parseAuthorization(header string) (credential string, signedHeaders []string, sig string, err error) {
+	rest, ok := strings.CutPrefix(header, signAlgorithm+" ")
+	if !ok {
+		return "", nil, "", errAuthorizationMalformed
+	}
+	for _, field := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return "", nil, "", errAuthorizationMalformed
+		}
+		switch key {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig = value
+		}
+	}
+	if credential == "" || len(signedHeaders) == 0 || sig == "" {
+		return "", nil, "", errAuthorizationMalformed
+	}
+	return credential, signedHeaders, sig, nil
+}
+
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	query := r.URL.Query()
+	if presigned {
+		query.Del("X-Amz-Signature")
+	}
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	sorted := append([]string(nil), signedHeaders...)
+	sort.Strings(sorted)
+	var headers strings.Builder
+	for _, name := range sorted {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(canonicalHeaderValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		encodePath(r.URL.Path),
+		canonicalQuery,
+		headers.String(),
+		strings.Join(sorted, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalHeaderValue 返回参与签名的头部值；Go 会把 Host 与 Content-Length 从 Header 中移出，需单独取回。
+func canonicalHeaderValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	values := append([]string(nil), r.Header.Values(name)...)
+	for i, v := range values {
+		values[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// encodePath 按 SigV4 规则编码路径：除未保留字符与 '/' 外全部百分号编码。
+func encodePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0x0f])
+	}
+	return b.String()
+}
+
+func deriveSigningKey(secret, date, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,449 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"droplite/internal/auth"
+	"droplite/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyTag 是签发的 Key 的固定开头，便于密钥扫描工具识别。
+	apiKeyTag = "dl_"
+	// apiKeyTouchInterval 是 last_used_at 的最小更新间隔。
+	apiKeyTouchInterval = time.Minute
+	maxAPIKeyNameLength = 200
+	maxAPIKeyPageSize   = 100
+)
+
+// APIKeyService 签发、轮换、吊销并校验数据库中的 API Key。
+//
+// Key 的格式为 "dl_<prefix>_<secret>"：prefix 是公开的查找前缀，数据库只保存整个 Key 的
+// SHA-256。owner ID 与 Key 本身无关，轮换后文件仍归属同一 owner。
+//
+// S3 SigV4 与请求签名校验需要还原派生的 secret，无法只存哈希；secrets 不为 nil 时以它加密后保存。
+type APIKeyService struct {
+	repo    repository.APIKeyRepository
+	secrets *SecretBox
+	now     func() time.Time
+}
+
+// NewAPIKeyService 创建 APIKeyService。secrets 为 nil 时 S3 secret 以明文保存，只应用于关闭鉴权的开发环境。
+func NewAPIKeyService(repo repository.APIKeyRepository, secrets *SecretBox) *APIKeyService {
+	return &APIKeyService{repo: repo, secrets: secrets, now: time.Now}
+}
+
+// IssuedAPIKey 是签发或轮换的结果，Key 为明文，只在此时返回一次。
+type IssuedAPIKey struct {
+	repository.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKeyInput 描述签发 API Key 所需的信息。
+type CreateAPIKeyInput struct {
+	// OwnerID 为空时生成新的 owner；迁移旧 Key 时可传入旧 Key 原值以保留文件归属。
+	OwnerID   string
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+	// AllowedCIDRs 与 DeniedCIDRs 限制 Key 可以从哪些网络使用，为空时不限制。
+	AllowedCIDRs []string
+	DeniedCIDRs  []string
+}
+
+// Create 校验输入后签发新的 API Key。
+func (s *APIKeyService) Create(ctx context.Context, input CreateAPIKeyInput) (*IssuedAPIKey, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("api key service not initialized")
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, NewError(KindValidation, "name must not be empty")
+	}
+	if len(name) > maxAPIKeyNameLength {
+		return nil, NewError(KindValidation, fmt.Sprintf("name must be at most %d characters", maxAPIKeyNameLength))
+	}
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	allowed, denied, err := normalizeNetworks(input.AllowedCIDRs, input.DeniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	var expiresAt *time.Time
+	if input.ExpiresAt != nil {
+		at := input.ExpiresAt.UTC()
+		if !at.After(s.now()) {
+			return nil, NewError(KindValidation, "expires_at must be in the future")
+		}
+		expiresAt = &at
+	}
+	ownerID := strings.TrimSpace(input.OwnerID)
+	if ownerID == "" {
+		ownerID = uuid.NewString()
+	}
+
+	key, secret, err := s.newSecret()
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.repo.Create(ctx, &repository.APIKey{
+		ID:            uuid.NewString(),
+		OwnerID:       ownerID,
+		Name:          name,
+		Prefix:        secret.Prefix,
+		KeyHash:       secret.KeyHash,
+		Scopes:        scopes,
+		S3AccessKeyID: secret.S3AccessKeyID,
+		S3Secret:      secret.S3Secret,
+		AllowedCIDRs:  allowed,
+		DeniedCIDRs:   denied,
+		ExpiresAt:     expiresAt,
+	})
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	return &IssuedAPIKey{APIKey: *record, Key: key}, nil
+}
+
+// List 分页返回 API Key，不包含哈希与 S3 secret。
+func (s *APIKeyService) List(ctx context.Context, params repository.ListAPIKeysParams) ([]repository.APIKey, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("api key service not initialized")
+	}
+	if params.Limit <= 0 || params.Limit > maxAPIKeyPageSize {
+		params.Limit = maxAPIKeyPageSize
+	}
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+	keys, err := s.repo.List(ctx, params)
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	return keys, nil
+}
+
+// Get 返回单个 API Key。
+func (s *APIKeyService) Get(ctx context.Context, id string) (*repository.APIKey, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("api key service not initialized")
+	}
+	key, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	return key, nil
+}
+
+// Rotate 为未吊销的 Key 生成新的明文，旧明文立即失效；owner、名称、scopes 与有效期不变。
+func (s *APIKeyService) Rotate(ctx context.Context, id string) (*IssuedAPIKey, error) {
+	existing, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RevokedAt != nil {
+		return nil, NewError(KindConflict, "api key has been revoked")
+	}
+
+	key, secret, err := s.newSecret()
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.repo.UpdateSecret(ctx, id, secret)
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	return &IssuedAPIKey{APIKey: *record, Key: key}, nil
+}
+
+// UpdateAPIKeyNetworksInput 描述要替换的网络列表，为 nil 的字段保持不变，空列表表示清除。
+type UpdateAPIKeyNetworksInput struct {
+	AllowedCIDRs *[]string
+	DeniedCIDRs  *[]string
+}
+
+// UpdateNetworks 替换未吊销的 Key 的 CIDR 允许与拒绝列表，下一个请求起生效。
+func (s *APIKeyService) UpdateNetworks(ctx context.Context, id string, input UpdateAPIKeyNetworksInput) (*repository.APIKey, error) {
+	existing, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RevokedAt != nil {
+		return nil, NewError(KindConflict, "api key has been revoked")
+	}
+
+	allowed, denied := existing.AllowedCIDRs, existing.DeniedCIDRs
+	if input.AllowedCIDRs != nil {
+		allowed = *input.AllowedCIDRs
+	}
+	if input.DeniedCIDRs != nil {
+		denied = *input.DeniedCIDRs
+	}
+	allowed, denied, err = normalizeNetworks(allowed, denied)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.repo.UpdateNetworks(ctx, id, allowed, denied)
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	return key, nil
+}
+
+// Revoke 吊销 Key，重复吊销是幂等的。
+func (s *APIKeyService) Revoke(ctx context.Context, id string) (*repository.APIKey, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("api key service not initialized")
+	}
+	key, err := s.repo.Revoke(ctx, id, s.now().UTC())
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	return key, nil
+}
+
+// VerifyAPIKey 校验明文 Key 并返回其 owner ID、Key ID 与 scopes，供鉴权中间件使用。
+// 格式错误、不存在、哈希不符、已吊销或已过期的 Key 一律返回 unauthorized。
+func (s *APIKeyService) VerifyAPIKey(ctx context.Context, key string) (auth.Principal, error) {
+	if s == nil || s.repo == nil {
+		return auth.Principal{}, errors.New("api key service not initialized")
+	}
+	prefix, ok := parseAPIKey(key)
+	if !ok {
+		return auth.Principal{}, NewError(KindUnauthorized, "invalid API key")
+	}
+	record, err := s.repo.GetByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrNotFound) {
+		return auth.Principal{}, NewError(KindUnauthorized, "invalid API key")
+	}
+	if err != nil {
+		return auth.Principal{}, repositoryError(err, "api key not found")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(record.KeyHash)) != 1 {
+		return auth.Principal{}, NewError(KindUnauthorized, "invalid API key")
+	}
+	now := s.now().UTC()
+	if err := checkAPIKeyUsable(record, now); err != nil {
+		return auth.Principal{}, err
+	}
+
+	principal, err := apiKeyPrincipal(record)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	// 最近使用时间只用于展示，写入失败不影响本次鉴权
+	_ = s.repo.TouchLastUsed(ctx, record.ID, now, apiKeyTouchInterval)
+	return principal, nil
+}
+
+// LookupS3Credentials 按 S3 AccessKeyID 查找可用的 Key，供 S3 网关校验 SigV4 签名。返回的 S3Secret 已解密。
+func (s *APIKeyService) LookupS3Credentials(ctx context.Context, accessKeyID string) (*repository.APIKey, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("api key service not initialized")
+	}
+	record, err := s.repo.GetByS3AccessKeyID(ctx, accessKeyID)
+	if err != nil {
+		return nil, repositoryError(err, "api key not found")
+	}
+	if err := checkAPIKeyUsable(record, s.now().UTC()); err != nil {
+		return nil, err
+	}
+	if record.S3Secret, err = s.secrets.Open(record.S3Secret, record.S3AccessKeyID); err != nil {
+		return nil, WrapError(KindInternal, "decrypt s3 secret", err)
+	}
+	return record, nil
+}
+
+// EncryptStoredSecrets 加密启用 SecretBox 之前以明文保存的 S3 secret（包括已吊销的 Key），返回处理的数量。
+// 服务启动时调用，已加密的值跳过，可重复执行。
+func (s *APIKeyService) EncryptStoredSecrets(ctx context.Context) (int, error) {
+	if s == nil || s.repo == nil {
+		return 0, errors.New("api key service not initialized")
+	}
+	if s.secrets == nil {
+		return 0, nil
+	}
+	encrypted := 0
+	params := repository.ListAPIKeysParams{IncludeRevoked: true, Limit: maxAPIKeyPageSize}
+	for {
+		keys, err := s.repo.List(ctx, params)
+		if err != nil {
+			return encrypted, err
+		}
+		for _, key := range keys {
+			if IsSealed(key.S3Secret) {
+				continue
+			}
+			sealed, err := s.secrets.Seal(key.S3Secret, key.S3AccessKeyID)
+			if err != nil {
+				return encrypted, err
+			}
+			if _, err := s.repo.UpdateSecret(ctx, key.ID, repository.APIKeySecret{
+				Prefix:        key.Prefix,
+				KeyHash:       key.KeyHash,
+				S3AccessKeyID: key.S3AccessKeyID,
+				S3Secret:      sealed,
+			}); err != nil {
+				return encrypted, err
+			}
+			encrypted++
+		}
+		if len(keys) < params.Limit {
+			return encrypted, nil
+		}
+		params.Offset += len(keys)
+	}
+}
+
+// LookupSigningKey 按 Key ID（即 S3 AccessKeyID）查找请求签名使用的密钥，供签名鉴权使用。
+func (s *APIKeyService) LookupSigningKey(ctx context.Context, keyID string) (auth.SigningKey, error) {
+	record, err := s.LookupS3Credentials(ctx, keyID)
+	if err != nil {
+		return auth.SigningKey{}, err
+	}
+	principal, err := apiKeyPrincipal(record)
+	if err != nil {
+		return auth.SigningKey{}, err
+	}
+
+	// 最近使用时间只用于展示，写入失败不影响本次鉴权
+	_ = s.repo.TouchLastUsed(ctx, record.ID, s.now().UTC(), apiKeyTouchInterval)
+	return auth.SigningKey{Secret: record.S3Secret, Principal: principal}, nil
+}
+
+// APIKeyNetworkPolicy 返回 Key 的网络策略，供不经过 Principal 的入口（如 S3 网关）使用。
+func APIKeyNetworkPolicy(record *repository.APIKey) (auth.NetworkPolicy, error) {
+	policy, err := auth.ParseNetworkPolicy(record.AllowedCIDRs, record.DeniedCIDRs)
+	if err != nil {
+		return auth.NetworkPolicy{}, WrapError(KindInternal, "parse api key networks", err)
+	}
+	return policy, nil
+}
+
+func apiKeyPrincipal(record *repository.APIKey) (auth.Principal, error) {
+	networks, err := APIKeyNetworkPolicy(record)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.Principal{OwnerID: record.OwnerID, KeyID: record.ID, Scopes: record.Scopes, Networks: networks}, nil
+}
+
+func checkAPIKeyUsable(record *repository.APIKey, now time.Time) error {
+	if record.RevokedAt != nil {
+		return NewError(KindUnauthorized, "API key has been revoked")
+	}
+	if record.ExpiresAt != nil && !now.Before(*record.ExpiresAt) {
+		return NewError(KindUnauthorized, "API key has expired")
+	}
+	return nil
+}
+
+// DeriveS3Credentials 由 API Key 确定性地派生 S3 凭证，与请求签名的凭证相同，规则见 auth.DeriveKeyCredentials。
+func DeriveS3Credentials(apiKey string) (accessKeyID, secretAccessKey string) {
+	return auth.DeriveKeyCredentials(apiKey)
+}
+
+// newSecret 生成明文 Key 及加密后待保存的凭证字段。
+func (s *APIKeyService) newSecret() (string, repository.APIKeySecret, error) {
+	key, secret, err := newAPIKeySecret()
+	if err != nil {
+		return "", repository.APIKeySecret{}, WrapError(KindInternal, "generate api key", err)
+	}
+	if secret.S3Secret, err = s.secrets.Seal(secret.S3Secret, secret.S3AccessKeyID); err != nil {
+		return "", repository.APIKeySecret{}, WrapError(KindInternal, "encrypt s3 secret", err)
+	}
+	return key, secret, nil
+}
+
+// newAPIKeySecret 生成明文 Key 及其需要持久化的凭证字段。
+func newAPIKeySecret() (string, repository.APIKeySecret, error) {
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", repository.APIKeySecret{}, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", repository.APIKeySecret{}, err
+	}
+
+	p := hex.EncodeToString(prefix)
+	key := apiKeyTag + p + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	accessKeyID, s3Secret := DeriveS3Credentials(key)
+	return key, repository.APIKeySecret{
+		Prefix:        p,
+		KeyHash:       hashAPIKey(key),
+		S3AccessKeyID: accessKeyID,
+		S3Secret:      s3Secret,
+	}, nil
+}
+
+// parseAPIKey 取出 Key 中的查找前缀。前缀是十六进制，secret 部分可能包含 "_"。
+func parseAPIKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyTag)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeNetworks 校验 CIDR 列表并规范化为网络地址形式（如 "10.1.2.3/8" 变为 "10.0.0.0/8"），去掉重复项。
+func normalizeNetworks(allowed, denied []string) ([]string, []string, error) {
+	policy, err := auth.ParseNetworkPolicy(allowed, denied)
+	if err != nil {
+		return nil, nil, NewError(KindValidation, err.Error())
+	}
+	return prefixStrings(policy.Allow), prefixStrings(policy.Deny), nil
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	out := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if s := prefix.String(); !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func normalizeScopes(raw []string) ([]string, error) {
+	if len(raw) == 0 {
+		return slices.Clone(auth.DefaultScopes), nil
+	}
+	scopes := make([]string, 0, len(raw))
+	for _, scope := range raw {
+		scope = strings.TrimSpace(scope)
+		if !slices.Contains(auth.Scopes, scope) {
+			return nil, NewError(KindValidation, fmt.Sprintf("unknown scope %q", scope))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"droplite/internal/auth"
+	"droplite/internal/repository"
+)
+
+type mockAPIKeyRepo struct {
+	keys    map[string]*repository.APIKey
+	touched int
+}
+
+func newMockAPIKeyRepo() *mockAPIKeyRepo {
+	return &mockAPIKeyRepo{keys: map[string]*repository.APIKey{}}
+}
+
+func (m *mockAPIKeyRepo) Create(ctx context.Context, key *repository.APIKey) (*repository.APIKey, error) {
+	stored := *key
+	m.keys[key.ID] = &stored
+	return key, nil
+}
+
+func (m *mockAPIKeyRepo) GetByID(ctx context.Context, id string) (*repository.APIKey, error) {
+	if key, ok := m.keys[id]; ok {
+		copied := *key
+		return &copied, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockAPIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*repository.APIKey, error) {
+	for _, key := range m.keys {
+		if key.Prefix == prefix {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockAPIKeyRepo) GetByS3AccessKeyID(ctx context.Context, accessKeyID string) (*repository.APIKey, error) {
+	for _, key := range m.keys {
+		if key.S3AccessKeyID == accessKeyID {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockAPIKeyRepo) List(ctx context.Context, params repository.ListAPIKeysParams) ([]repository.APIKey, error) {
+	var out []repository.APIKey
+	for _, key := range m.keys {
+		out = append(out, *key)
+	}
+	return out, nil
+}
+
+func (m *mockAPIKeyRepo) UpdateSecret(ctx context.Context, id string, secret repository.APIKeySecret) (*repository.APIKey, error) {
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	key.Prefix, key.KeyHash = secret.Prefix, secret.KeyHash
+	key.S3AccessKeyID, key.S3Secret = secret.S3AccessKeyID, secret.S3Secret
+	copied := *key
+	return &copied, nil
+}
+
+func (m *mockAPIKeyRepo) UpdateNetworks(ctx context.Context, id string, allowed, denied []string) (*repository.APIKey, error) {
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	key.AllowedCIDRs, key.DeniedCIDRs = allowed, denied
+	copied := *key
+	return &copied, nil
+}
+
+func (m *mockAPIKeyRepo) Revoke(ctx context.Context, id string, at time.Time) (*repository.APIKey, error) {
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (m *mockAPIKeyRepo) TouchLastUsed(ctx context.Context, id string, at time.Time, minInterval time.Duration) error {
+	m.touched++
+	return nil
+}
+
+func TestAPIKeyService_CreateStoresOnlyHash(t *testing.T) {
+	repo := newMockAPIKeyRepo()
+	svc := NewAPIKeyService(repo, nil)
+
+	issued, err := svc.Create(context.Background(), CreateAPIKeyInput{Name: " ci "})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(issued.Key, "dl_"+issued.Prefix+"_") {
+		t.Fatalf("key %q does not carry prefix %q", issued.Key, issued.Prefix)
+	}
+	stored := repo.keys[issued.ID]
+	if stored.KeyHash == "" || strings.Contains(stored.KeyHash, issued.Key) || stored.Name != "ci" {
+		t.Fatalf("unexpected stored key: %+v", stored)
+	}
+	if stored.OwnerID == "" || stored.OwnerID == issued.Key {
+		t.Fatalf("owner ID must be independent of the key, got %q", stored.OwnerID)
+	}
+	if len(stored.Scopes) != len(auth.DefaultScopes) {
+		t.Fatalf("expected default scopes, got %v", stored.Scopes)
+	}
+	accessKeyID, secret := DeriveS3Credentials(issued.Key)
+	if stored.S3AccessKeyID != accessKeyID || stored.S3Secret != secret {
+		t.Fatal("expected S3 credentials derived from the issued key")
+	}
+
+	principal, err := svc.VerifyAPIKey(context.Background(), issued.Key)
+	if err != nil || principal.OwnerID != stored.OwnerID || principal.KeyID != stored.ID || len(principal.Scopes) != len(stored.Scopes) {
+		t.Fatalf("verify = %+v, %v", principal, err)
+	}
+	if repo.touched != 1 {
+		t.Fatalf("expected last_used_at to be touched once, got %d", repo.touched)
+	}
+}
+
+func TestAPIKeyService_CreateValidation(t *testing.T) {
+	svc := NewAPIKeyService(newMockAPIKeyRepo(), nil)
+	past := time.Now().Add(-time.Hour)
+
+	cases := map[string]CreateAPIKeyInput{
+		"empty name":    {Name: "  "},
+		"unknown scope": {Name: "ci", Scopes: []string{"files:read", "files:everything"}},
+		"past expiry":   {Name: "ci", ExpiresAt: &past},
+	}
+	for name, input := range cases {
+		if _, err := svc.Create(context.Background(), input); ErrorKindOf(err) != KindValidation {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestAPIKeyService_VerifyRejectsUnusableKeys(t *testing.T) {
+	repo := newMockAPIKeyRepo()
+	svc := NewAPIKeyService(repo, nil)
+	ctx := context.Background()
+
+	issued, err := svc.Create(ctx, CreateAPIKeyInput{Name: "ci", OwnerID: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "legacy-key", "dl_", "dl_" + issued.Prefix + "_wrong", issued.Key + "x"} {
+		if _, err := svc.VerifyAPIKey(ctx, key); ErrorKindOf(err) != KindUnauthorized {
+			t.Errorf("key %q: expected unauthorized, got %v", key, err)
+		}
+	}
+
+	// 签名密钥可由客户端从明文 Key 派生，与服务端保存的 S3 secret 一致
+	keyID, secret := auth.DeriveKeyCredentials(issued.Key)
+	signing, err := svc.LookupSigningKey(ctx, keyID)
+	if err != nil || signing.Secret != secret || signing.Principal.OwnerID != "alice" || signing.Principal.KeyID != issued.ID {
+		t.Fatalf("lookup signing key = %+v, %v", signing, err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	repo.keys[issued.ID].ExpiresAt = &expired
+	if _, err := svc.VerifyAPIKey(ctx, issued.Key); ErrorKindOf(err) != KindUnauthorized {
+		t.Fatalf("expected expired key to be rejected, got %v", err)
+	}
+	if _, err := svc.LookupS3Credentials(ctx, issued.S3AccessKeyID); ErrorKindOf(err) != KindUnauthorized {
+		t.Fatalf("expected expired key to be rejected by S3 lookup, got %v", err)
+	}
+	if _, err := svc.LookupSigningKey(ctx, keyID); ErrorKindOf(err) != KindUnauthorized {
+		t.Fatalf("expected expired key to be rejected for signing, got %v", err)
+	}
+}
+
+func TestAPIKeyService_RotateAndRevoke(t *testing.T) {
+	repo := newMockAPIKeyRepo()
+	svc := NewAPIKeyService(repo, nil)
+	ctx := context.Background()
+
+	issued, err := svc.Create(ctx, CreateAPIKeyInput{Name: "ci", Scopes: []string{auth.ScopeFilesRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := svc.Rotate(ctx, issued.ID)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated.Key == issued.Key || rotated.Prefix == issued.Prefix || rotated.OwnerID != issued.OwnerID {
+		t.Fatalf("unexpected rotation result: %+v", rotated)
+	}
+	if _, err := svc.VerifyAPIKey(ctx, issued.Key); ErrorKindOf(err) != KindUnauthorized {
+		t.Fatalf("expected old key to be rejected, got %v", err)
+	}
+	principal, err := svc.VerifyAPIKey(ctx, rotated.Key)
+	if err != nil || principal.OwnerID != issued.OwnerID || len(principal.Scopes) != 1 || principal.Scopes[0] != auth.ScopeFilesRead {
+		t.Fatalf("verify rotated = %+v, %v", principal, err)
+	}
+
+	if _, err := svc.Revoke(ctx, issued.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := svc.VerifyAPIKey(ctx, rotated.Key); ErrorKindOf(err) != KindUnauthorized {
+		t.Fatalf("expected revoked key to be rejected, got %v", err)
+	}
+	if _, err := svc.Rotate(ctx, issued.ID); ErrorKindOf(err) != KindConflict {
+		t.Fatalf("expected conflict rotating a revoked key, got %v", err)
+	}
+	if _, err := svc.Revoke(ctx, "missing"); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestAPIKeyService_EncryptsS3SecretAtRest(t *testing.T) {
+	box, err := NewSecretBox([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newMockAPIKeyRepo()
+	ctx := context.Background()
+
+	// 启用加密前以明文保存的 Key
+	legacy, err := NewAPIKeyService(repo, nil).Create(ctx, CreateAPIKeyInput{Name: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewAPIKeyService(repo, box)
+	issued, err := svc.Create(ctx, CreateAPIKeyInput{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret := DeriveS3Credentials(issued.Key)
+	if stored := repo.keys[issued.ID].S3Secret; !IsSealed(stored) || strings.Contains(stored, secret) {
+		t.Fatalf("expected S3 secret to be stored encrypted, got %q", stored)
+	}
+	record, err := svc.LookupS3Credentials(ctx, issued.S3AccessKeyID)
+	if err != nil || record.S3Secret != secret {
+		t.Fatalf("lookup = %+v, %v", record, err)
+	}
+
+	// 密文绑定到 AccessKeyID，挪到其他行无法解密
+	repo.keys[legacy.ID].S3Secret = repo.keys[issued.ID].S3Secret
+	if _, err := svc.LookupS3Credentials(ctx, legacy.S3AccessKeyID); ErrorKindOf(err) != KindInternal {
+		t.Fatalf("expected swapped ciphertext to fail, got %v", err)
+	}
+	_, legacySecret := DeriveS3Credentials(legacy.Key)
+	repo.keys[legacy.ID].S3Secret = legacySecret
+
+	if n, err := svc.EncryptStoredSecrets(ctx); err != nil || n != 1 {
+		t.Fatalf("encrypt stored = %d, %v", n, err)
+	}
+	if !IsSealed(repo.keys[legacy.ID].S3Secret) {
+		t.Fatal("expected legacy secret to be encrypted")
+	}
+	signing, err := svc.LookupSigningKey(ctx, legacy.S3AccessKeyID)
+	if err != nil || signing.Secret != legacySecret {
+		t.Fatalf("lookup signing key = %+v, %v", signing, err)
+	}
+	if n, _ := svc.EncryptStoredSecrets(ctx); n != 0 {
+		t.Fatalf("expected second pass to skip encrypted secrets, got %d", n)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"droplite/internal/auth"
+	"droplite/internal/repository"
+)
+
+const (
+	maxAuditPageSize = 1000
+	// auditExportPageSize 是导出时每次从数据库读取的条数。
+	auditExportPageSize = 500
+	// auditWriteTimeout 限制单条审计记录的写入时间，客户端断开后仍会完成写入。
+	auditWriteTimeout = 5 * time.Second
+)
+
+// AuditService 写入并查询审计日志，实现 auth.AuditRecorder。
+type AuditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// RecordAudit 同步写入一条审计记录；失败只记录日志，不影响已经完成的请求。
+func (s *AuditService) RecordAudit(ctx context.Context, event auth.AuditEvent) {
+	if s == nil || s.repo == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditWriteTimeout)
+	defer cancel()
+
+	_, err := s.repo.Append(ctx, &repository.AuditEvent{
+		OccurredAt: event.OccurredAt,
+		Actor:      event.Actor,
+		KeyID:      event.KeyID,
+		Action:     event.Action,
+		FileID:     event.FileID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Outcome:    event.Outcome,
+		Status:     event.Status,
+	})
+	if err != nil {
+		log.Printf("[audit] record %s (request %s): %v", event.Action, event.RequestID, err)
+	}
+}
+
+// List 按时间倒序返回一页审计记录，每页最多 1000 条。
+func (s *AuditService) List(ctx context.Context, params repository.ListAuditEventsParams) ([]repository.AuditEvent, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("audit service not initialized")
+	}
+	if err := validateAuditParams(params); err != nil {
+		return nil, err
+	}
+	if params.Limit <= 0 || params.Limit > maxAuditPageSize {
+		params.Limit = maxAuditPageSize
+	}
+	events, err := s.repo.List(ctx, params)
+	if err != nil {
+		return nil, repositoryError(err, "audit event not found")
+	}
+	return events, nil
+}
+
+// Export 按时间倒序逐条交给 emit，直到没有更多记录、达到 params.Limit（大于 0 时）或 emit 返回错误。
+func (s *AuditService) Export(ctx context.Context, params repository.ListAuditEventsParams, emit func(repository.AuditEvent) error) error {
+	if s == nil || s.repo == nil {
+		return errors.New("audit service not initialized")
+	}
+	if err := validateAuditParams(params); err != nil {
+		return err
+	}
+
+	remaining := params.Limit
+	for {
+		page := params
+		page.Limit = auditExportPageSize
+		if remaining > 0 && remaining < page.Limit {
+			page.Limit = remaining
+		}
+		events, err := s.repo.List(ctx, page)
+		if err != nil {
+			return repositoryError(err, "audit event not found")
+		}
+		for _, event := range events {
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+		if remaining > 0 {
+			remaining -= len(events)
+			if remaining <= 0 {
+				return nil
+			}
+		}
+		if len(events) < page.Limit {
+			return nil
+		}
+		params.BeforeID = events[len(events)-1].ID
+	}
+}
+
+func validateAuditParams(params repository.ListAuditEventsParams) error {
+	if params.Since != nil && params.Until != nil && !params.Since.Before(*params.Until) {
+		return NewError(KindValidation, "since must be before until")
+	}
+	switch params.Outcome {
+	case "", auth.AuditSuccess, auth.AuditDenied, auth.AuditFailure:
+	default:
+		return NewError(KindValidation, "outcome must be one of success, denied, failure")
+	}
+	if params.Limit < 0 || params.BeforeID < 0 {
+		return NewError(KindValidation, "limit and before must not be negative")
+	}
+	return nil
+}
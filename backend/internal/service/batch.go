@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// BatchOp 是批量操作的类型。
+type BatchOp string
+
+const (
+	BatchOpDelete        BatchOp = "delete"
+	BatchOpRestore       BatchOp = "restore"
+	BatchOpTag           BatchOp = "tag"
+	BatchOpPatchMetadata BatchOp = "patch_metadata"
+	BatchOpSetExpiry     BatchOp = "set_expiry"
+)
+
+// MaxBatchItems 是单次批量请求允许处理的文件数（各操作 ids 数量之和）。
+const MaxBatchItems = 1000
+
+// metadataTagsKey 是标签在 metadata 中的键。
+const metadataTagsKey = "tags"
+
+// BatchOperation 描述对一组文件执行的同一操作。
+// Tags 仅用于 tag，Metadata 仅用于 patch_metadata，ExpiresAt 仅用于 set_expiry（为空表示取消过期）。
+type BatchOperation struct {
+	Op        BatchOp        `json:"op"`
+	IDs       []string       `json:"ids"`
+	Tags      []string       `json:"tags,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+}
+
+// BatchItemStatus 是单个文件在批量请求中的处理结果。
+type BatchItemStatus string
+
+const (
+	BatchItemOK         BatchItemStatus = "ok"
+	BatchItemFailed     BatchItemStatus = "failed"
+	BatchItemSkipped    BatchItemStatus = "skipped"     // 原子模式下失败项之后未执行的项
+	BatchItemRolledBack BatchItemStatus = "rolled_back" // 原子模式下已执行但随事务回滚的项
+)
+
+// BatchItemError 是单项失败的原因，字段与 API 错误体一致。
+type BatchItemError struct {
+	Code    ErrorKind `json:"code"`
+	Message string    `json:"message"`
+	Details any       `json:"details,omitempty"`
+}
+
+// BatchItemResult 是单个文件的处理结果，按请求中的顺序排列。
+type BatchItemResult struct {
+	Op     BatchOp                `json:"op"`
+	ID     string                 `json:"id"`
+	Status BatchItemStatus        `json:"status"`
+	File   *repository.FileRecord `json:"file,omitempty"`
+	Error  *BatchItemError        `json:"error,omitempty"`
+}
+
+// BatchResult 汇总一次批量请求的结果。
+type BatchResult struct {
+	Atomic    bool              `json:"atomic"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// batchEvent 记录成功项需要发布的事件，原子模式下待事务提交后再发布。
+type batchEvent struct {
+	eventType EventType
+	record    *repository.FileRecord
+}
+
+// errBatchAborted 用于在原子模式下中止事务。
+var errBatchAborted = errors.New("batch aborted")
+
+// ApplyBatch 按顺序对 owner 名下的文件执行批量操作。
+// 非原子模式逐项提交，单项失败不影响其他项；原子模式在同一事务中执行，遇到第一个失败即整体回滚。
+func (s *FileService) ApplyBatch(ctx context.Context, ownerID string, ops []BatchOperation, atomic bool) (*BatchResult, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file service not initialized")
+	}
+	if err := validateBatch(ops); err != nil {
+		return nil, err
+	}
+
+	result := &BatchResult{Atomic: atomic}
+	for _, op := range ops {
+		for _, id := range op.IDs {
+			result.Results = append(result.Results, BatchItemResult{Op: op.Op, ID: id, Status: BatchItemSkipped})
+		}
+	}
+
+	if !atomic {
+		i := 0
+		for _, op := range ops {
+			for _, id := range op.IDs {
+				record, event, err := s.applyBatchItem(ctx, s.repo, ownerID, op, id)
+				result.settle(i, record, err)
+				if err == nil && event != "" {
+					s.publish(ctx, event, record)
+				}
+				i++
+			}
+		}
+		return result, nil
+	}
+
+	var events []batchEvent
+	err := s.repo.WithTx(ctx, func(repo repository.FileRepository) error {
+		i := 0
+		for _, op := range ops {
+			for _, id := range op.IDs {
+				record, event, err := s.applyBatchItem(ctx, repo, ownerID, op, id)
+				result.settle(i, record, err)
+				if err != nil {
+					return errBatchAborted
+				}
+				if event != "" {
+					events = append(events, batchEvent{eventType: event, record: record})
+				}
+				i++
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errBatchAborted):
+		result.Succeeded = 0
+		for i := range result.Results {
+			if result.Results[i].Status == BatchItemOK {
+				result.Results[i].Status = BatchItemRolledBack
+				result.Results[i].File = nil
+			}
+		}
+		return result, nil
+	case err != nil:
+		return nil, repositoryError(err, "file not found")
+	}
+
+	for _, e := range events {
+		s.publish(ctx, e.eventType, e.record)
+	}
+	return result, nil
+}
+
+// settle 记录第 i 项的执行结果。
+func (r *BatchResult) settle(i int, record *repository.FileRecord, err error) {
+	item := &r.Results[i]
+	if err != nil {
+		svcErr := AsError(err)
+		item.Status = BatchItemFailed
+		item.Error = &BatchItemError{Code: svcErr.Kind, Message: svcErr.Message, Details: svcErr.Details}
+		r.Failed++
+		return
+	}
+	item.Status = BatchItemOK
+	if item.Op != BatchOpDelete {
+		item.File = record
+	}
+	r.Succeeded++
+}
+
+// applyBatchItem 对单个文件执行操作，返回更新后的记录与需要发布的事件（无变化时为空）。
+func (s *FileService) applyBatchItem(ctx context.Context, repo repository.FileRepository, ownerID string, op BatchOperation, id string) (*repository.FileRecord, EventType, error) {
+	record, err := repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, "", repositoryError(err, "file not found")
+	}
+	// 不暴露其他 owner 的文件是否存在
+	if record.OwnerID != ownerID {
+		return nil, "", NewError(KindNotFound, "file not found")
+	}
+
+	switch op.Op {
+	case BatchOpDelete:
+		if record.Status == repository.FileStatusDeleted {
+			return record, "", nil
+		}
+		if err := repo.UpdateStatus(ctx, id, repository.FileStatusDeleted); err != nil {
+			return nil, "", repositoryError(err, "file not found")
+		}
+		record.Status = repository.FileStatusDeleted
+		return record, EventFileDeleted, nil
+
+	case BatchOpRestore:
+		if record.Status != repository.FileStatusDeleted {
+			return nil, "", NewError(KindConflict, "file is not deleted")
+		}
+		if record.ExpiresAt != nil && !record.ExpiresAt.After(time.Now()) {
+			return nil, "", NewError(KindConflict, "file has expired; set a new expiry before restoring")
+		}
+		if err := repo.UpdateStatus(ctx, id, repository.FileStatusStored); err != nil {
+			return nil, "", repositoryError(err, "file not found")
+		}
+		record.Status = repository.FileStatusStored
+		return record, EventFileUpdated, nil
+
+	case BatchOpTag, BatchOpPatchMetadata:
+		var merged map[string]any
+		if op.Op == BatchOpTag {
+			merged, err = addTags(record.Metadata, op.Tags)
+			if err != nil {
+				return nil, "", err
+			}
+		} else {
+			merged = mergeMetadata(record.Metadata, op.Metadata)
+		}
+		if err := s.validateMetadata(ctx, ownerID, merged); err != nil {
+			return nil, "", err
+		}
+		updated, err := repo.UpdateMetadata(ctx, id, merged)
+		if err != nil {
+			return nil, "", repositoryError(err, "file not found")
+		}
+		return updated, EventFileUpdated, nil
+
+	case BatchOpSetExpiry:
+		updated, err := repo.UpdateExpiry(ctx, id, op.ExpiresAt)
+		if err != nil {
+			return nil, "", repositoryError(err, "file not found")
+		}
+		return updated, EventFileUpdated, nil
+	}
+	return nil, "", NewError(KindValidation, fmt.Sprintf("unsupported op %q", op.Op))
+}
+
+// addTags 将 tags 并入 metadata.tags（保持原有顺序并去重）。
+func addTags(metadata map[string]any, tags []string) (map[string]any, error) {
+	var current []any
+	if raw, ok := metadata[metadataTagsKey]; ok && raw != nil {
+		list, ok := raw.([]any)
+		if !ok {
+			return nil, NewError(KindValidation, "metadata.tags must be an array of strings")
+		}
+		current = list
+	}
+
+	seen := make(map[string]bool, len(current)+len(tags))
+	merged := make([]any, 0, len(current)+len(tags))
+	for _, v := range current {
+		tag, ok := v.(string)
+		if !ok {
+			return nil, NewError(KindValidation, "metadata.tags must be an array of strings")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return mergeMetadata(metadata, map[string]any{metadataTagsKey: merged}), nil
+}
+
+func validateBatch(ops []BatchOperation) error {
+	if len(ops) == 0 {
+		return NewError(KindValidation, "operations is required")
+	}
+
+	total := 0
+	for i := range ops {
+		op := &ops[i]
+		if len(op.IDs) == 0 {
+			return NewError(KindValidation, fmt.Sprintf("operations[%d].ids is required", i))
+		}
+		for _, id := range op.IDs {
+			if strings.TrimSpace(id) == "" {
+				return NewError(KindValidation, fmt.Sprintf("operations[%d].ids must not contain empty ids", i))
+			}
+		}
+		total += len(op.IDs)
+
+		switch op.Op {
+		case BatchOpDelete, BatchOpRestore, BatchOpSetExpiry:
+		case BatchOpTag:
+			tags := make([]string, 0, len(op.Tags))
+			for _, tag := range op.Tags {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			if len(tags) == 0 {
+				return NewError(KindValidation, fmt.Sprintf("operations[%d].tags is required", i))
+			}
+			op.Tags = tags
+		case BatchOpPatchMetadata:
+			if op.Metadata == nil {
+				return NewError(KindValidation, fmt.Sprintf("operations[%d].metadata is required", i))
+			}
+		default:
+			return NewError(KindValidation, fmt.Sprintf("operations[%d].op %q is not supported", i, op.Op))
+		}
+	}
+	if total > MaxBatchItems {
+		return NewError(KindValidation, fmt.Sprintf("batch exceeds %d items", MaxBatchItems))
+	}
+	return nil
+}
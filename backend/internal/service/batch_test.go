@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// batchRepo 是按 ID 保存记录的内存仓储，WithTx 在 fn 失败时恢复快照。
+type batchRepo struct {
+	mockFileRepo
+	records map[string]repository.FileRecord
+}
+
+func newBatchRepo(records ...repository.FileRecord) *batchRepo {
+	repo := &batchRepo{records: map[string]repository.FileRecord{}}
+	for _, rec := range records {
+		repo.records[rec.ID] = rec
+	}
+	return repo
+}
+
+func (m *batchRepo) GetByID(ctx context.Context, id string) (*repository.FileRecord, error) {
+	rec, ok := m.records[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (m *batchRepo) UpdateStatus(ctx context.Context, id string, status repository.FileStatus) error {
+	rec, ok := m.records[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	rec.Status = status
+	m.records[id] = rec
+	return nil
+}
+
+func (m *batchRepo) UpdateMetadata(ctx context.Context, id string, metadata map[string]any) (*repository.FileRecord, error) {
+	rec, ok := m.records[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	rec.Metadata = metadata
+	m.records[id] = rec
+	return &rec, nil
+}
+
+func (m *batchRepo) UpdateExpiry(ctx context.Context, id string, expiresAt *time.Time) (*repository.FileRecord, error) {
+	rec, ok := m.records[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	rec.ExpiresAt = expiresAt
+	m.records[id] = rec
+	return &rec, nil
+}
+
+func (m *batchRepo) WithTx(ctx context.Context, fn func(repo repository.FileRepository) error) error {
+	snapshot := make(map[string]repository.FileRecord, len(m.records))
+	for id, rec := range m.records {
+		snapshot[id] = rec
+	}
+	if err := fn(m); err != nil {
+		m.records = snapshot
+		return err
+	}
+	return nil
+}
+
+func TestFileService_ApplyBatch_ReportsPerItemResults(t *testing.T) {
+	repo := newBatchRepo(
+		repository.FileRecord{ID: "a", OwnerID: "owner-a", Status: repository.FileStatusStored, Metadata: map[string]any{"tags": []any{"x"}}},
+		repository.FileRecord{ID: "b", OwnerID: "owner-a", Status: repository.FileStatusDeleted},
+		repository.FileRecord{ID: "c", OwnerID: "owner-b", Status: repository.FileStatusStored},
+	)
+	publisher := &recordingPublisher{}
+	svc := NewFileService(repo, nil)
+	svc.SetEventPublisher(publisher)
+
+	result, err := svc.ApplyBatch(context.Background(), "owner-a", []BatchOperation{
+		{Op: BatchOpTag, IDs: []string{"a", "c"}, Tags: []string{"x", " y "}},
+		{Op: BatchOpRestore, IDs: []string{"a", "b"}},
+	}, false)
+	if err != nil {
+		t.Fatalf("apply batch: %v", err)
+	}
+
+	want := []BatchItemStatus{BatchItemOK, BatchItemFailed, BatchItemFailed, BatchItemOK}
+	for i, status := range want {
+		if result.Results[i].Status != status {
+			t.Fatalf("item %d: expected %s, got %+v", i, status, result.Results[i])
+		}
+	}
+	if result.Results[1].Error.Code != KindNotFound || result.Results[2].Error.Code != KindConflict {
+		t.Fatalf("unexpected item errors %+v", result.Results)
+	}
+	if tags := repo.records["a"].Metadata["tags"].([]any); len(tags) != 2 || tags[1] != "y" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if repo.records["b"].Status != repository.FileStatusStored {
+		t.Fatalf("expected b restored, got %s", repo.records["b"].Status)
+	}
+	if len(publisher.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(publisher.events))
+	}
+}
+
+func TestFileService_ApplyBatch_AtomicRollsBack(t *testing.T) {
+	repo := newBatchRepo(
+		repository.FileRecord{ID: "a", OwnerID: "owner-a", Status: repository.FileStatusStored},
+		repository.FileRecord{ID: "b", OwnerID: "owner-a", Status: repository.FileStatusStored},
+	)
+	publisher := &recordingPublisher{}
+	svc := NewFileService(repo, nil)
+	svc.SetEventPublisher(publisher)
+
+	expiresAt := time.Now().Add(time.Hour)
+	result, err := svc.ApplyBatch(context.Background(), "owner-a", []BatchOperation{
+		{Op: BatchOpSetExpiry, IDs: []string{"a"}, ExpiresAt: &expiresAt},
+		{Op: BatchOpDelete, IDs: []string{"a", "missing", "b"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("apply batch: %v", err)
+	}
+
+	want := []BatchItemStatus{BatchItemRolledBack, BatchItemRolledBack, BatchItemFailed, BatchItemSkipped}
+	for i, status := range want {
+		if result.Results[i].Status != status {
+			t.Fatalf("item %d: expected %s, got %+v", i, status, result.Results[i])
+		}
+	}
+	if result.Succeeded != 0 || result.Failed != 1 {
+		t.Fatalf("unexpected counts %+v", result)
+	}
+	if rec := repo.records["a"]; rec.Status != repository.FileStatusStored || rec.ExpiresAt != nil {
+		t.Fatalf("expected a unchanged, got %+v", rec)
+	}
+	if len(publisher.events) != 0 {
+		t.Fatalf("expected no events after rollback, got %d", len(publisher.events))
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"droplite/internal/repository"
+)
+
+// OpenContent 返回文件内容的 io.ReadSeekCloser。存储流在首次 Read 时才打开，
+// Seek 通过重新打开或向前跳读实现，便于 http.ServeContent 处理 Range 请求。
+func (s *FileService) OpenContent(ctx context.Context, record *repository.FileRecord) io.ReadSeekCloser {
+	return &contentReader{service: s, ctx: ctx, record: record}
+}
+
+type contentReader struct {
+	service *FileService
+	ctx     context.Context
+	record  *repository.FileRecord
+
+	offset  int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+func (c *contentReader) Read(p []byte) (int, error) {
+	if c.offset >= c.record.SizeBytes {
+		return 0, io.EOF
+	}
+	if c.body == nil || c.bodyPos > c.offset {
+		if c.body != nil {
+			_ = c.body.Close()
+			c.body = nil
+		}
+		body, err := c.service.GetFileContent(c.ctx, c.record.StoragePath)
+		if err != nil {
+			return 0, err
+		}
+		c.body, c.bodyPos = body, 0
+	}
+	if c.bodyPos < c.offset {
+		skipped, err := io.CopyN(io.Discard, c.body, c.offset-c.bodyPos)
+		c.bodyPos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := c.body.Read(p)
+	c.offset += int64(n)
+	c.bodyPos += int64(n)
+	return n, err
+}
+
+func (c *contentReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = c.offset + offset
+	case io.SeekEnd:
+		next = c.record.SizeBytes + offset
+	default:
+		return 0, NewError(KindValidation, "invalid seek whence")
+	}
+	if next < 0 {
+		return 0, NewError(KindValidation, "negative seek position")
+	}
+	c.offset = next
+	return next, nil
+}
+
+func (c *contentReader) Close() error {
+	if c.body == nil {
+		return nil
+	}
+	return c.body.Close()
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+
+	"droplite/internal/repository"
+	"droplite/internal/storage"
+)
+
+// ErrorKind 是面向客户端的机器可读错误码。
+type ErrorKind string
+
+const (
+	KindValidation          ErrorKind = "validation"
+	KindNotFound            ErrorKind = "not_found"
+	KindConflict            ErrorKind = "conflict"
+	KindPayloadTooLarge     ErrorKind = "payload_too_large"
+	KindQuotaExceeded       ErrorKind = "quota_exceeded"
+	KindUnauthorized        ErrorKind = "unauthorized"
+	KindForbidden           ErrorKind = "forbidden"
+	KindRateLimited         ErrorKind = "rate_limited"
+	KindStorageUnavailable  ErrorKind = "storage_unavailable"
+	KindDatabaseUnavailable ErrorKind = "database_unavailable"
+	KindInternal            ErrorKind = "internal"
+)
+
+// Error 是服务层统一的类型化错误，Message 可直接返回给客户端，Err 保留底层原因。
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Details any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails 附加结构化的错误详情（如字段级错误）。
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// NewError 创建不带底层原因的类型化错误。
+func NewError(kind ErrorKind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// WrapError 创建包装底层原因的类型化错误。
+func WrapError(kind ErrorKind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// AsError 将任意错误归一为 *Error，无法识别的错误视为内部错误。
+func AsError(err error) *Error {
+	var svcErr *Error
+	if errors.As(err, &svcErr) {
+		return svcErr
+	}
+	var verr *MetadataValidationError
+	if errors.As(err, &verr) {
+		return metadataValidationError(verr)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return WrapError(KindNotFound, "resource not found", err)
+	}
+	return WrapError(KindInternal, "internal server error", err)
+}
+
+// ErrorKindOf 返回 err 对应的错误码。
+func ErrorKindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	return AsError(err).Kind
+}
+
+// repositoryError 将仓储层错误映射为 not_found 或 database_unavailable。
+func repositoryError(err error, notFoundMessage string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return WrapError(KindNotFound, notFoundMessage, err)
+	}
+	return WrapError(KindDatabaseUnavailable, "database unavailable", err)
+}
+
+// storageError 将存储层错误映射为 not_found 或 storage_unavailable。
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	// 读取上传内容时产生的类型化错误（如大小不符）保持原样
+	var svcErr *Error
+	if errors.As(err, &svcErr) {
+		return svcErr
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return WrapError(KindNotFound, "file content not found", err)
+	}
+	return WrapError(KindStorageUnavailable, "storage unavailable", err)
+}
+
+func metadataValidationError(verr *MetadataValidationError) *Error {
+	return WrapError(KindValidation, "metadata validation failed", verr).
+		WithDetails(map[string]any{"fields": verr.Fields})
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+// EventStream 将文件事件写入事件日志，并按 owner 唤醒本进程内的 SSE 订阅者。
+// 其他副本写入的事件通过 Run 中的 EventListener（Postgres LISTEN/NOTIFY）得知。
+type EventStream struct {
+	repo repository.EventRepository
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+func NewEventStream(repo repository.EventRepository) *EventStream {
+	return &EventStream{repo: repo, subscribers: make(map[string]map[chan struct{}]struct{})}
+}
+
+// Publish 将事件追加到事件日志并唤醒该 owner 的订阅者。
+func (s *EventStream) Publish(ctx context.Context, event Event) error {
+	if s == nil || s.repo == nil {
+		return errors.New("event stream not initialized")
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	if _, err := s.repo.Append(ctx, &repository.EventRecord{
+		ID:      event.ID,
+		OwnerID: event.OwnerID,
+		Type:    string(event.Type),
+		Payload: payload,
+	}); err != nil {
+		return fmt.Errorf("append event: %w", err)
+	}
+	s.wake(event.OwnerID)
+	return nil
+}
+
+// Subscribe 返回一个在 owner 有新事件时收到信号的通道；信号会合并，收到后应重新读取事件日志。
+// 调用方结束时必须调用返回的取消函数。
+func (s *EventStream) Subscribe(ownerID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	if s.subscribers[ownerID] == nil {
+		s.subscribers[ownerID] = make(map[chan struct{}]struct{})
+	}
+	s.subscribers[ownerID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[ownerID], ch)
+		if len(s.subscribers[ownerID]) == 0 {
+			delete(s.subscribers, ownerID)
+		}
+	}
+}
+
+// Since 按顺序返回 owner 在 afterSeq 之后的最多 limit 条事件。
+func (s *EventStream) Since(ctx context.Context, ownerID string, afterSeq int64, limit int) ([]repository.EventRecord, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("event stream not initialized")
+	}
+	events, err := s.repo.ListSince(ctx, ownerID, afterSeq, limit)
+	if err != nil {
+		return nil, repositoryError(err, "event not found")
+	}
+	return events, nil
+}
+
+// LatestSeq 返回事件日志当前的最大序号，新连接从这里开始推送。
+func (s *EventStream) LatestSeq(ctx context.Context) (int64, error) {
+	if s == nil || s.repo == nil {
+		return 0, errors.New("event stream not initialized")
+	}
+	seq, err := s.repo.LatestSeq(ctx)
+	if err != nil {
+		return 0, repositoryError(err, "event not found")
+	}
+	return seq, nil
+}
+
+// Prune 删除早于 before 的事件，早于保留期的 Last-Event-ID 将无法完整续传。
+func (s *EventStream) Prune(ctx context.Context, before time.Time) (int64, error) {
+	if s == nil || s.repo == nil {
+		return 0, errors.New("event stream not initialized")
+	}
+	return s.repo.DeleteBefore(ctx, before)
+}
+
+// Run 持续监听跨副本的事件通知，连接断开后退避重连，直到 ctx 结束。
+// 重连时唤醒全部订阅者，由它们按各自的序号补读断线期间的事件。
+func (s *EventStream) Run(ctx context.Context, listener repository.EventListener) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := listener.Listen(ctx, func(seq int64, ownerID string) { s.wake(ownerID) })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[events] listen: %v", err)
+		s.wakeAll()
+
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (s *EventStream) wake(ownerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[ownerID] {
+		signal(ch)
+	}
+}
+
+func (s *EventStream) wakeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subs := range s.subscribers {
+		for ch := range subs {
+			signal(ch)
+		}
+	}
+}
+
+// signal 非阻塞地发送信号，通道中已有未处理信号时直接合并。
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"droplite/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// EventType 是文件生命周期事件的类型。
+type EventType string
+
+const (
+	EventFileCreated EventType = "file.created"
+	EventFileUpdated EventType = "file.updated"
+	EventFileDeleted EventType = "file.deleted"
+	EventFileExpired EventType = "file.expired"
+)
+
+// EventTypes 列出全部可订阅的事件类型。
+var EventTypes = []EventType{EventFileCreated, EventFileUpdated, EventFileDeleted, EventFileExpired}
+
+// Event 是一次文件生命周期事件，序列化后即 webhook 的请求体。
+type Event struct {
+	ID         string                 `json:"id"`
+	Type       EventType              `json:"type"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	OwnerID    string                 `json:"-"`
+	Data       *repository.FileRecord `json:"data"`
+}
+
+// EventPublisher 接收文件生命周期事件。
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// SetEventPublisher 注入事件发布器，传入 nil 表示不发布事件。
+func (s *FileService) SetEventPublisher(p EventPublisher) {
+	s.events = p
+}
+
+// publish 发布文件事件；发布失败只记录日志，不影响已经完成的文件操作。
+func (s *FileService) publish(ctx context.Context, eventType EventType, record *repository.FileRecord) {
+	if s.events == nil || record == nil {
+		return
+	}
+	event := Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		OwnerID:    record.OwnerID,
+		Data:       record,
+	}
+	if err := s.events.Publish(ctx, event); err != nil {
+		log.Printf("[events] publish %s for file %s: %v", eventType, record.ID, err)
+	}
+}
+
+// EventPublishers 将同一事件依次交给多个发布器，返回遇到的全部错误。
+type EventPublishers []EventPublisher
+
+func (p EventPublishers) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
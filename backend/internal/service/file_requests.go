@@ -0,0 +1,419 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// fileRequestTag 是文件请求令牌的固定开头。
+	fileRequestTag           = "fr_"
+	maxFileRequestNameLength = 200
+	// DefaultFileRequestTTL 是未指定有效期时文件请求的有效期。
+	DefaultFileRequestTTL = 7 * 24 * time.Hour
+	// MaxFileRequestTTL 是文件请求允许的最长有效期。
+	MaxFileRequestTTL = 90 * 24 * time.Hour
+	// metadataFileRequestKey 是上传文件 metadata 中记录来源文件请求的键。
+	metadataFileRequestKey = "file_request"
+)
+
+// FileRequests 签发只允许上传的文件请求链接，并接收匿名上传。
+//
+// 令牌格式为 "fr_<prefix>_<secret>"，与 API Key 相同只保存整个令牌的 SHA-256；
+// 上传的文件归属创建请求的 owner，metadata 中记录请求 ID 与令牌前缀。
+type FileRequests struct {
+	repo          repository.FileRequestRepository
+	files         *FileService
+	maxUploadSize int64
+	now           func() time.Time
+}
+
+// NewFileRequests 创建文件请求服务，maxUploadSize 是单个文件大小的上限。
+func NewFileRequests(repo repository.FileRequestRepository, files *FileService, maxUploadSize int64) *FileRequests {
+	return &FileRequests{repo: repo, files: files, maxUploadSize: maxUploadSize, now: time.Now}
+}
+
+// IssuedFileRequest 是创建结果，Token 为明文，只在此时返回一次。URL 由 API 层根据对外地址填充。
+type IssuedFileRequest struct {
+	repository.FileRequest
+	Token string `json:"token"`
+	URL   string `json:"url,omitempty"`
+}
+
+// CreateFileRequestInput 描述创建文件请求所需的信息。
+type CreateFileRequestInput struct {
+	OwnerID          string
+	Name             string
+	Folder           string
+	Tags             []string
+	MaxFileSize      int64
+	MaxFiles         int
+	AllowedMimeTypes []string
+	// TTL 为 0 时使用 DefaultFileRequestTTL。
+	TTL time.Duration
+}
+
+// Create 校验输入后签发文件请求。
+func (s *FileRequests) Create(ctx context.Context, input CreateFileRequestInput) (*IssuedFileRequest, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file requests not initialized")
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, NewError(KindValidation, "name must not be empty")
+	}
+	if len(name) > maxFileRequestNameLength {
+		return nil, NewError(KindValidation, fmt.Sprintf("name must be at most %d characters", maxFileRequestNameLength))
+	}
+	folder, err := normalizeFolder(input.Folder)
+	if err != nil {
+		return nil, err
+	}
+	if input.MaxFileSize < 0 || input.MaxFileSize > s.maxUploadSize {
+		return nil, NewError(KindValidation, fmt.Sprintf("max_file_size must be between 0 and %d", s.maxUploadSize))
+	}
+	if input.MaxFiles < 0 {
+		return nil, NewError(KindValidation, "max_files must not be negative")
+	}
+	mimeTypes, err := normalizeMimeTypes(input.AllowedMimeTypes)
+	if err != nil {
+		return nil, err
+	}
+	ttl := input.TTL
+	if ttl == 0 {
+		ttl = DefaultFileRequestTTL
+	}
+	if ttl < time.Second || ttl > MaxFileRequestTTL {
+		return nil, NewError(KindValidation, fmt.Sprintf("expires_in must be between 1s and %s", MaxFileRequestTTL))
+	}
+
+	token, prefix, err := newFileRequestToken()
+	if err != nil {
+		return nil, WrapError(KindInternal, "generate file request token", err)
+	}
+	record, err := s.repo.Create(ctx, &repository.FileRequest{
+		ID:               uuid.NewString(),
+		OwnerID:          input.OwnerID,
+		Name:             name,
+		Prefix:           prefix,
+		TokenHash:        hashAPIKey(token),
+		Folder:           folder,
+		Tags:             normalizeTags(input.Tags),
+		MaxFileSize:      input.MaxFileSize,
+		MaxFiles:         input.MaxFiles,
+		AllowedMimeTypes: mimeTypes,
+		ExpiresAt:        s.now().Add(ttl).UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return nil, repositoryError(err, "file request not found")
+	}
+	return &IssuedFileRequest{FileRequest: *record, Token: token}, nil
+}
+
+// List 返回 owner 的全部文件请求。
+func (s *FileRequests) List(ctx context.Context, ownerID string) ([]repository.FileRequest, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file requests not initialized")
+	}
+	requests, err := s.repo.ListByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, repositoryError(err, "file request not found")
+	}
+	return requests, nil
+}
+
+// Revoke 吊销 owner 的文件请求，重复吊销是幂等的。
+func (s *FileRequests) Revoke(ctx context.Context, ownerID, id string) (*repository.FileRequest, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file requests not initialized")
+	}
+	existing, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, repositoryError(err, "file request not found")
+	}
+	if existing.OwnerID != ownerID {
+		return nil, NewError(KindNotFound, "file request not found")
+	}
+	request, err := s.repo.Revoke(ctx, id, s.now().UTC())
+	if err != nil {
+		return nil, repositoryError(err, "file request not found")
+	}
+	return request, nil
+}
+
+// Resolve 校验令牌并返回可用的文件请求；令牌无效、已吊销、已过期或名额用完时一律返回 not_found。
+func (s *FileRequests) Resolve(ctx context.Context, token string) (*repository.FileRequest, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file requests not initialized")
+	}
+	invalid := NewError(KindNotFound, "file request is invalid or expired")
+
+	rest, ok := strings.CutPrefix(token, fileRequestTag)
+	if !ok {
+		return nil, invalid
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return nil, invalid
+	}
+	request, err := s.repo.GetByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, repositoryError(err, "file request not found")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(token)), []byte(request.TokenHash)) != 1 || !fileRequestOpen(request, s.now()) {
+		return nil, invalid
+	}
+	return request, nil
+}
+
+// FileRequestUpload 描述一次匿名上传。
+type FileRequestUpload struct {
+	OriginalName string
+	MimeType     string
+	SizeBytes    int64
+	Reader       io.Reader
+}
+
+// Upload 按文件请求的限制校验后登记文件。名额在写入前占用，失败时归还。
+func (s *FileRequests) Upload(ctx context.Context, token string, upload FileRequestUpload) (*repository.FileRecord, error) {
+	request, err := s.Resolve(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := request.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = s.maxUploadSize
+	}
+	if upload.SizeBytes > maxSize {
+		return nil, NewError(KindPayloadTooLarge, fmt.Sprintf("file exceeds size limit (%d bytes)", maxSize))
+	}
+	if !mimeTypeAllowed(request.AllowedMimeTypes, upload.MimeType) {
+		return nil, NewError(KindValidation, fmt.Sprintf("mime type %q is not accepted by this file request", upload.MimeType))
+	}
+	reader := upload.Reader
+	if len(request.AllowedMimeTypes) > 0 {
+		// 客户端声明的类型不可信，再按内容嗅探一次
+		head, err := readSniffHead(upload.Reader)
+		if err != nil {
+			return nil, WrapError(KindInternal, "unable to read uploaded file", err)
+		}
+		sniffed := http.DetectContentType(head)
+		if !mimeTypeAllowed(request.AllowedMimeTypes, sniffed) && !refinesSniffedType(upload.MimeType, sniffed) {
+			return nil, NewError(KindValidation, fmt.Sprintf("file content looks like %q, which is not accepted by this file request", sniffed))
+		}
+		reader = io.MultiReader(bytes.NewReader(head), upload.Reader)
+	}
+	name := uploadFilename(upload.OriginalName)
+	if name == "" {
+		return nil, NewError(KindValidation, "file name is required")
+	}
+
+	reserved, err := s.repo.ReserveUpload(ctx, request.ID, s.now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(KindNotFound, "file request is invalid or expired")
+	}
+	if err != nil {
+		return nil, repositoryError(err, "file request not found")
+	}
+
+	metadata := map[string]any{
+		metadataFileRequestKey: map[string]any{"id": reserved.ID, "token_prefix": reserved.Prefix},
+	}
+	if len(reserved.Tags) > 0 {
+		tags := make([]any, len(reserved.Tags))
+		for i, tag := range reserved.Tags {
+			tags[i] = tag
+		}
+		metadata[metadataTagsKey] = tags
+	}
+	record, err := s.files.RegisterFile(ctx, RegisterFileInput{
+		OwnerID:      reserved.OwnerID,
+		OriginalName: path.Join(reserved.Folder, name),
+		MimeType:     upload.MimeType,
+		SizeBytes:    upload.SizeBytes,
+		Metadata:     metadata,
+		Reader:       reader,
+	})
+	if err != nil {
+		if releaseErr := s.repo.ReleaseUpload(context.WithoutCancel(ctx), reserved.ID); releaseErr != nil {
+			log.Printf("[file-requests] release slot of %s: %v", reserved.ID, releaseErr)
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// fileRequestOpen 判断文件请求是否仍可接收上传。
+func fileRequestOpen(request *repository.FileRequest, now time.Time) bool {
+	return request.RevokedAt == nil && now.Before(request.ExpiresAt) &&
+		(request.MaxFiles == 0 || request.UploadCount < request.MaxFiles)
+}
+
+func newFileRequestToken() (token, prefix string, err error) {
+	rawPrefix := make([]byte, 8)
+	if _, err := rand.Read(rawPrefix); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(rawPrefix)
+	return fileRequestTag + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// normalizeFolder 清理目标目录，与 WebDAV 一致以 "/" 分隔且不含首尾斜杠。
+func normalizeFolder(raw string) (string, error) {
+	folder := strings.Trim(strings.TrimSpace(raw), "/")
+	if folder == "" {
+		return "", nil
+	}
+	for _, segment := range strings.Split(folder, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", NewError(KindValidation, "folder must be a relative path without empty, . or .. segments")
+		}
+	}
+	return folder, nil
+}
+
+func normalizeTags(raw []string) []string {
+	tags := make([]string, 0, len(raw))
+	for _, tag := range raw {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// normalizeMimeTypes 校验允许的 MIME 类型，支持 "image/*" 形式的通配。
+func normalizeMimeTypes(raw []string) ([]string, error) {
+	types := make([]string, 0, len(raw))
+	for _, value := range raw {
+		value = strings.ToLower(strings.TrimSpace(value))
+		major, minor, ok := strings.Cut(value, "/")
+		if !ok || major == "" || minor == "" || major == "*" {
+			return nil, NewError(KindValidation, fmt.Sprintf("invalid mime type %q", value))
+		}
+		if !slices.Contains(types, value) {
+			types = append(types, value)
+		}
+	}
+	return types, nil
+}
+
+// mimeTypeAllowed 比较去掉参数后的媒体类型，allowed 为空时不限制。
+func mimeTypeAllowed(allowed []string, mimeType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, candidate := range allowed {
+		if candidate == mediaType || candidate == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffLength 是 http.DetectContentType 最多参考的字节数。
+const sniffLength = 512
+
+// readSniffHead 读取内容嗅探所需的开头部分，文件不足 sniffLength 时返回全部内容。
+func readSniffHead(r io.Reader) ([]byte, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// refinesSniffedType 判断声明的类型是否只是嗅探结果的细化。
+// http.DetectContentType 对纯文本、zip 容器与无法识别的二进制只给出笼统的类型，此时以声明的类型为准，
+// 例如 JSON 嗅探为 text/plain、docx 嗅探为 application/zip。声明为 DetectContentType 能识别的类型
+// 却嗅探为 application/octet-stream 时说明内容与声明不符，例如声明为 PDF 的可执行文件。
+func refinesSniffedType(declared, sniffed string) bool {
+	declaredType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		return false
+	}
+	sniffedType, _, _ := mime.ParseMediaType(sniffed)
+	switch sniffedType {
+	case "application/octet-stream":
+		return !sniffableType(declaredType)
+	case "text/plain":
+		return textualType(declaredType)
+	case "application/zip":
+		return zipBasedType(declaredType)
+	}
+	return false
+}
+
+// sniffedSignatures 是 http.DetectContentType 按文件头识别的 application 类型。
+var sniffedSignatures = []string{
+	"application/pdf", "application/postscript", "application/ogg", "application/wasm",
+	"application/vnd.ms-fontobject", "application/gzip", "application/x-gzip",
+	"application/x-rar-compressed", "application/vnd.rar",
+}
+
+// sniffableType 判断 http.DetectContentType 能否识别该类型的内容。
+func sniffableType(mediaType string) bool {
+	major, _, _ := strings.Cut(mediaType, "/")
+	switch major {
+	case "image", "audio", "video", "font":
+		return true
+	}
+	return textualType(mediaType) || zipBasedType(mediaType) || slices.Contains(sniffedSignatures, mediaType)
+}
+
+// textualType 判断内容是否为文本，这类内容嗅探为 text/plain 或更具体的文本类型。
+func textualType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" || mediaType == "application/xml" ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// zipBasedType 判断内容是否为 zip 容器，这类内容嗅探为 application/zip。
+func zipBasedType(mediaType string) bool {
+	return mediaType == "application/zip" || strings.HasSuffix(mediaType, "+zip") ||
+		strings.HasPrefix(mediaType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mediaType, "application/vnd.oasis.opendocument.")
+}
+
+// uploadFilename 只保留客户端文件名的最后一段，匿名上传不能指定目录。
+func uploadFilename(raw string) string {
+	name := path.Base(strings.ReplaceAll(strings.TrimSpace(raw), "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
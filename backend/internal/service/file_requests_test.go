@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+type mockFileRequestRepo struct {
+	mu       sync.Mutex
+	requests map[string]*repository.FileRequest
+}
+
+func newMockFileRequestRepo() *mockFileRequestRepo {
+	return &mockFileRequestRepo{requests: map[string]*repository.FileRequest{}}
+}
+
+func (m *mockFileRequestRepo) Create(ctx context.Context, request *repository.FileRequest) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *request
+	m.requests[stored.ID] = &stored
+	copied := stored
+	return &copied, nil
+}
+
+func (m *mockFileRequestRepo) GetByID(ctx context.Context, id string) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if request, ok := m.requests[id]; ok {
+		copied := *request
+		return &copied, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockFileRequestRepo) GetByPrefix(ctx context.Context, prefix string) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, request := range m.requests {
+		if request.Prefix == prefix {
+			copied := *request
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockFileRequestRepo) ListByOwner(ctx context.Context, ownerID string) ([]repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []repository.FileRequest
+	for _, request := range m.requests {
+		if request.OwnerID == ownerID {
+			result = append(result, *request)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockFileRequestRepo) Revoke(ctx context.Context, id string, at time.Time) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request, ok := m.requests[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if request.RevokedAt == nil {
+		request.RevokedAt = &at
+	}
+	copied := *request
+	return &copied, nil
+}
+
+func (m *mockFileRequestRepo) ReserveUpload(ctx context.Context, id string, now time.Time) (*repository.FileRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request, ok := m.requests[id]
+	if !ok || !fileRequestOpen(request, now) {
+		return nil, repository.ErrNotFound
+	}
+	request.UploadCount++
+	copied := *request
+	return &copied, nil
+}
+
+func (m *mockFileRequestRepo) ReleaseUpload(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if request, ok := m.requests[id]; ok && request.UploadCount > 0 {
+		request.UploadCount--
+	}
+	return nil
+}
+
+func TestFileRequests_CreateValidation(t *testing.T) {
+	svc := NewFileRequests(newMockFileRequestRepo(), nil, 1024)
+
+	cases := map[string]CreateFileRequestInput{
+		"empty name":       {Name: " "},
+		"folder traversal": {Name: "n", Folder: "a/../b"},
+		"oversized limit":  {Name: "n", MaxFileSize: 2048},
+		"negative files":   {Name: "n", MaxFiles: -1},
+		"bad mime":         {Name: "n", AllowedMimeTypes: []string{"image"}},
+		"ttl too long":     {Name: "n", TTL: MaxFileRequestTTL + time.Hour},
+	}
+	for name, input := range cases {
+		input.OwnerID = "alice"
+		if _, err := svc.Create(context.Background(), input); ErrorKindOf(err) != KindValidation {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestFileRequests_UploadLandsInOwnerFolder(t *testing.T) {
+	repo := newMockFileRequestRepo()
+	files := &mockFileRepo{}
+	writer := &mockWriter{}
+	svc := NewFileRequests(repo, NewFileService(files, writer), 1024)
+
+	issued, err := svc.Create(context.Background(), CreateFileRequestInput{
+		OwnerID:          "alice",
+		Name:             "Receipts",
+		Folder:           "/inbox/receipts/",
+		Tags:             []string{"expenses", " expenses "},
+		MaxFiles:         1,
+		AllowedMimeTypes: []string{"image/*", "application/pdf"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(issued.Token, "fr_"+issued.Prefix+"_") || issued.TokenHash == issued.Token {
+		t.Fatalf("unexpected token %q for prefix %q", issued.Token, issued.Prefix)
+	}
+	if issued.Folder != "inbox/receipts" || len(issued.Tags) != 1 {
+		t.Fatalf("expected normalized folder and tags, got %+v", issued.FileRequest)
+	}
+
+	png := "\x89PNG\r\n\x1a\ndata"
+	upload := func(name, mimeType, content string) error {
+		_, err := svc.Upload(context.Background(), issued.Token, FileRequestUpload{
+			OriginalName: name,
+			MimeType:     mimeType,
+			SizeBytes:    int64(len(content)),
+			Reader:       strings.NewReader(content),
+		})
+		return err
+	}
+
+	if err := upload("notes.txt", "text/plain", "data"); ErrorKindOf(err) != KindValidation {
+		t.Fatalf("expected mime type rejection, got %v", err)
+	}
+	// 声明为图片但内容是 HTML
+	if err := upload("scan.png", "image/png", "<!DOCTYPE html><script>alert(1)</script>"); ErrorKindOf(err) != KindValidation {
+		t.Fatalf("expected sniffed content type rejection, got %v", err)
+	}
+	// 声明为 PDF 但内容是无法识别的二进制（ELF 可执行文件）
+	if err := upload("report.pdf", "application/pdf", "\x7fELF\x02\x01\x01\x00\x00\x00"); ErrorKindOf(err) != KindValidation {
+		t.Fatalf("expected unrecognized binary declared as pdf to be rejected, got %v", err)
+	}
+	if err := upload(`C:\scans\..\scan.png`, "image/png", png); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	record := files.createRecord
+	if record.OwnerID != "alice" || record.OriginalName != "inbox/receipts/scan.png" {
+		t.Fatalf("unexpected file record %+v", record)
+	}
+	if string(writer.data) != png {
+		t.Fatalf("expected the sniffed head to be stored, got %q", writer.data)
+	}
+	source, _ := record.Metadata[metadataFileRequestKey].(map[string]any)
+	if source["id"] != issued.ID || source["token_prefix"] != issued.Prefix {
+		t.Fatalf("expected file request metadata, got %v", record.Metadata)
+	}
+	if tags, _ := record.Metadata[metadataTagsKey].([]any); len(tags) != 1 || tags[0] != "expenses" {
+		t.Fatalf("expected tags metadata, got %v", record.Metadata)
+	}
+
+	// max_files 用完后链接与无效令牌不可区分
+	if err := upload("second.png", "image/png", png); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected not_found once the request is full, got %v", err)
+	}
+}
+
+func TestFileRequests_ReleasesSlotOnFailureAndHonoursRevoke(t *testing.T) {
+	repo := newMockFileRequestRepo()
+	svc := NewFileRequests(repo, NewFileService(&mockFileRepo{}, &mockWriter{err: errors.New("disk full")}), 1024)
+
+	issued, err := svc.Create(context.Background(), CreateFileRequestInput{OwnerID: "alice", Name: "Drop", MaxFiles: 1, MaxFileSize: 8})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	upload := FileRequestUpload{OriginalName: "a.bin", MimeType: "application/octet-stream", SizeBytes: 4, Reader: strings.NewReader("data")}
+
+	if _, err := svc.Upload(context.Background(), issued.Token, upload); err == nil {
+		t.Fatal("expected storage failure")
+	}
+	if stored, _ := repo.GetByID(context.Background(), issued.ID); stored.UploadCount != 0 {
+		t.Fatalf("expected the slot to be released, got upload_count=%d", stored.UploadCount)
+	}
+
+	upload.SizeBytes = 16
+	if _, err := svc.Upload(context.Background(), issued.Token, upload); ErrorKindOf(err) != KindPayloadTooLarge {
+		t.Fatalf("expected payload too large, got %v", err)
+	}
+
+	if _, err := svc.Revoke(context.Background(), "mallory", issued.ID); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected not_found when revoking another owner's request, got %v", err)
+	}
+	if _, err := svc.Revoke(context.Background(), "alice", issued.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := svc.Resolve(context.Background(), issued.Token); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected revoked request to be unusable, got %v", err)
+	}
+	if _, err := svc.Resolve(context.Background(), issued.Token+"x"); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected tampered token to be rejected, got %v", err)
+	}
+}
+
+func TestRefinesSniffedType(t *testing.T) {
+	cases := []struct {
+		declared, sniffed string
+		want              bool
+	}{
+		{"application/json", "text/plain; charset=utf-8", true},
+		{"text/csv", "text/plain; charset=utf-8", true},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip", true},
+		{"application/x-custom", "application/octet-stream", true},
+		{"application/pdf", "application/octet-stream", false},
+		{"image/png", "application/octet-stream", false},
+		{"application/zip", "application/octet-stream", false},
+		{"text/csv", "application/octet-stream", false},
+		{"application/json", "application/octet-stream", false},
+		{"image/png", "text/plain; charset=utf-8", false},
+		{"image/png", "text/html; charset=utf-8", false},
+		{"application/pdf", "application/zip", false},
+	}
+	for _, tc := range cases {
+		if got := refinesSniffedType(tc.declared, tc.sniffed); got != tc.want {
+			t.Errorf("refinesSniffedType(%q, %q) = %v, want %v", tc.declared, tc.sniffed, got, tc.want)
+		}
+	}
+}
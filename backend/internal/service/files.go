@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -18,16 +19,29 @@ import (
 
 // FileService 封装文件元数据的业务流程。
 type FileService struct {
-	repo  repository.FileRepository
-	store storage.Storage
+	repo      repository.FileRepository
+	store     storage.Storage
+	validator MetadataValidator
+	events    EventPublisher
+}
+
+// MetadataValidator 在 metadata 落库前进行校验，字段级失败返回 *MetadataValidationError。
+type MetadataValidator interface {
+	ValidateMetadata(ctx context.Context, ownerID string, metadata map[string]any) error
 }
 
 func NewFileService(repo repository.FileRepository, store storage.Storage) *FileService {
 	return &FileService{repo: repo, store: store}
 }
 
+// SetMetadataValidator 注入 metadata 校验器，传入 nil 表示不校验。
+func (s *FileService) SetMetadataValidator(v MetadataValidator) {
+	s.validator = v
+}
+
 // RegisterFileInput 描述创建文件记录所需的信息。
 type RegisterFileInput struct {
+	OwnerID      string
 	OriginalName string
 	MimeType     string
 	SizeBytes    int64
@@ -44,6 +58,9 @@ func (s *FileService) RegisterFile(ctx context.Context, input RegisterFileInput)
 		return nil, errors.New("file service not initialized")
 	}
 	if err := validateRegisterInput(input); err != nil {
+		return nil, WrapError(KindValidation, err.Error(), err)
+	}
+	if err := s.validateMetadata(ctx, input.OwnerID, normalizeMetadata(input.Metadata)); err != nil {
 		return nil, err
 	}
 
@@ -55,6 +72,7 @@ func (s *FileService) RegisterFile(ctx context.Context, input RegisterFileInput)
 	}
 	record := &repository.FileRecord{
 		ID:           fileID,
+		OwnerID:      input.OwnerID,
 		OriginalName: input.OriginalName,
 		MimeType:     input.MimeType,
 		SizeBytes:    input.SizeBytes,
@@ -69,12 +87,17 @@ func (s *FileService) RegisterFile(ctx context.Context, input RegisterFileInput)
 
 	if s.store != nil && input.Reader != nil {
 		if _, err := s.store.Write(ctx, record.StoragePath, input.Reader); err != nil {
-			return nil, fmt.Errorf("write storage: %w", err)
+			return nil, storageError(fmt.Errorf("write storage: %w", err))
 		}
 		record.Status = repository.FileStatusStored
 	}
 
-	return s.repo.Create(ctx, record)
+	created, err := s.repo.Create(ctx, record)
+	if err != nil {
+		return nil, repositoryError(err, "file not found")
+	}
+	s.publish(ctx, EventFileCreated, created)
+	return created, nil
 }
 
 // ListFiles 以分页形式列出文件。
@@ -82,7 +105,11 @@ func (s *FileService) ListFiles(ctx context.Context, params repository.ListFiles
 	if s == nil || s.repo == nil {
 		return nil, errors.New("file service not initialized")
 	}
-	return s.repo.List(ctx, params)
+	files, err := s.repo.List(ctx, params)
+	if err != nil {
+		return nil, repositoryError(err, "file not found")
+	}
+	return files, nil
 }
 
 // GetFile 根据 ID 获取文件元数据。
@@ -90,7 +117,24 @@ func (s *FileService) GetFile(ctx context.Context, id string) (*repository.FileR
 	if s == nil || s.repo == nil {
 		return nil, errors.New("file service not initialized")
 	}
-	return s.repo.GetByID(ctx, id)
+	file, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, repositoryError(err, "file not found")
+	}
+	return file, nil
+}
+
+// GetOwnedFile 获取属于 ownerID 的文件元数据。文件属于其他 owner 时与不存在一样返回 not_found，
+// 不暴露其存在。
+func (s *FileService) GetOwnedFile(ctx context.Context, id, ownerID string) (*repository.FileRecord, error) {
+	file, err := s.GetFile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if file.OwnerID != ownerID {
+		return nil, NewError(KindNotFound, "file not found")
+	}
+	return file, nil
 }
 
 // GetFileContent 返回文件的内容流，调用方需负责关闭。
@@ -98,7 +142,11 @@ func (s *FileService) GetFileContent(ctx context.Context, storagePath string) (i
 	if s == nil || s.store == nil {
 		return nil, errors.New("file service not initialized")
 	}
-	return s.store.Read(ctx, storagePath)
+	content, err := s.store.Read(ctx, storagePath)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return content, nil
 }
 
 // DeleteFile 软删除文件（将状态更新为 deleted）。
@@ -106,7 +154,146 @@ func (s *FileService) DeleteFile(ctx context.Context, id string) error {
 	if s == nil || s.repo == nil {
 		return errors.New("file service not initialized")
 	}
-	return s.repo.UpdateStatus(ctx, id, repository.FileStatusDeleted)
+	if err := s.repo.UpdateStatus(ctx, id, repository.FileStatusDeleted); err != nil {
+		return repositoryError(err, "file not found")
+	}
+	s.publishByID(ctx, EventFileDeleted, id)
+	return nil
+}
+
+// DeleteOwnedFile 软删除属于 ownerID 的文件，归属不符时返回 not_found。
+func (s *FileService) DeleteOwnedFile(ctx context.Context, id, ownerID string) error {
+	if _, err := s.GetOwnedFile(ctx, id, ownerID); err != nil {
+		return err
+	}
+	return s.DeleteFile(ctx, id)
+}
+
+// expireBatchSize 是每轮过期清理从仓储读取的文件数量。
+const expireBatchSize = 100
+
+// ExpireFiles 将 expires_at 不晚于 now 的文件软删除并发布 file.expired 事件，返回处理数量。
+func (s *FileService) ExpireFiles(ctx context.Context, now time.Time) (int, error) {
+	if s == nil || s.repo == nil {
+		return 0, errors.New("file service not initialized")
+	}
+
+	expired := 0
+	for {
+		batch, err := s.repo.List(ctx, repository.ListFilesParams{
+			Statuses:      []repository.FileStatus{repository.FileStatusPending, repository.FileStatusStored, repository.FileStatusFailed},
+			ExpiresBefore: now,
+			Limit:         expireBatchSize,
+		})
+		if err != nil {
+			return expired, repositoryError(err, "file not found")
+		}
+		for i := range batch {
+			record := &batch[i]
+			if err := s.repo.UpdateStatus(ctx, record.ID, repository.FileStatusDeleted); err != nil {
+				return expired, repositoryError(err, "file not found")
+			}
+			record.Status = repository.FileStatusDeleted
+			s.publish(ctx, EventFileExpired, record)
+			expired++
+		}
+		if len(batch) < expireBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// publishByID 重新读取记录后发布事件，供只持有文件 ID 的操作使用。
+func (s *FileService) publishByID(ctx context.Context, eventType EventType, id string) {
+	if s.events == nil {
+		return
+	}
+	record, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		log.Printf("[events] load file %s for %s: %v", id, eventType, err)
+		return
+	}
+	s.publish(ctx, eventType, record)
+}
+
+// RenameFile 修改文件名（WebDAV 中即文件路径）。
+func (s *FileService) RenameFile(ctx context.Context, id, name string) (*repository.FileRecord, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file service not initialized")
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, NewError(KindValidation, "original_name is required")
+	}
+	updated, err := s.repo.Rename(ctx, id, name)
+	if err != nil {
+		return nil, repositoryError(err, "file not found")
+	}
+	s.publish(ctx, EventFileUpdated, updated)
+	return updated, nil
+}
+
+// UpdateMetadata 以 JSON merge patch 语义更新文件 metadata：值为 null 的键会被移除。
+func (s *FileService) UpdateMetadata(ctx context.Context, id, ownerID string, patch map[string]any) (*repository.FileRecord, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("file service not initialized")
+	}
+
+	record, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, repositoryError(err, "file not found")
+	}
+	// 先确认归属再校验，避免通过校验错误探测其他 owner 的文件与 Schema
+	if record.OwnerID != ownerID {
+		return nil, NewError(KindNotFound, "file not found")
+	}
+
+	merged := mergeMetadata(record.Metadata, patch)
+	if err := s.validateMetadata(ctx, ownerID, merged); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repo.UpdateMetadata(ctx, id, merged)
+	if err != nil {
+		return nil, repositoryError(err, "file not found")
+	}
+	s.publish(ctx, EventFileUpdated, updated)
+	return updated, nil
+}
+
+func (s *FileService) validateMetadata(ctx context.Context, ownerID string, metadata map[string]any) error {
+	if s.validator == nil {
+		return nil
+	}
+	err := s.validator.ValidateMetadata(ctx, ownerID, metadata)
+	if err == nil {
+		return nil
+	}
+	var verr *MetadataValidationError
+	if errors.As(err, &verr) {
+		return metadataValidationError(verr)
+	}
+	return WrapError(KindDatabaseUnavailable, "metadata schemas unavailable", err)
+}
+
+func mergeMetadata(base, patch map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(patch))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		if nested, ok := v.(map[string]any); ok {
+			if current, ok := merged[k].(map[string]any); ok {
+				merged[k] = mergeMetadata(current, nested)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
 }
 
 func validateRegisterInput(input RegisterFileInput) error {
@@ -7,12 +7,15 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"droplite/internal/repository"
 	"droplite/internal/storage"
 )
 
 type mockFileRepo struct {
+	getResult    *repository.FileRecord
+	updatedMeta  map[string]any
 	createRecord *repository.FileRecord
 	createErr    error
 	listParams   repository.ListFilesParams
@@ -29,6 +32,9 @@ func (m *mockFileRepo) Create(ctx context.Context, record *repository.FileRecord
 }
 
 func (m *mockFileRepo) GetByID(ctx context.Context, id string) (*repository.FileRecord, error) {
+	if m.getResult != nil {
+		return m.getResult, nil
+	}
 	return nil, repository.ErrNotFound
 }
 
@@ -44,6 +50,23 @@ func (m *mockFileRepo) UpdateStatus(ctx context.Context, id string, status repos
 	return nil
 }
 
+func (m *mockFileRepo) UpdateMetadata(ctx context.Context, id string, metadata map[string]any) (*repository.FileRecord, error) {
+	m.updatedMeta = metadata
+	return &repository.FileRecord{ID: id, Metadata: metadata}, nil
+}
+
+func (m *mockFileRepo) Rename(ctx context.Context, id, name string) (*repository.FileRecord, error) {
+	return &repository.FileRecord{ID: id, OriginalName: name}, nil
+}
+
+func (m *mockFileRepo) UpdateExpiry(ctx context.Context, id string, expiresAt *time.Time) (*repository.FileRecord, error) {
+	return &repository.FileRecord{ID: id, ExpiresAt: expiresAt}, nil
+}
+
+func (m *mockFileRepo) WithTx(ctx context.Context, fn func(repo repository.FileRepository) error) error {
+	return fn(m)
+}
+
 type mockWriter struct {
 	key  string
 	data []byte
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"droplite/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// FieldError 描述单个字段的校验失败原因。
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// MetadataValidationError 表示 metadata 未通过 Schema 校验，携带字段级错误。
+type MetadataValidationError struct {
+	Fields []FieldError
+}
+
+func (e *MetadataValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return "metadata validation failed: " + strings.Join(parts, "; ")
+}
+
+// SchemaService 管理元数据 JSON Schema，并实现 MetadataValidator。
+type SchemaService struct {
+	repo repository.MetadataSchemaRepository
+
+	mu       sync.Mutex
+	compiled map[string]compiledSchema
+}
+
+type compiledSchema struct {
+	updatedAt time.Time
+	schema    *jsonschema.Schema
+}
+
+func NewSchemaService(repo repository.MetadataSchemaRepository) *SchemaService {
+	return &SchemaService{repo: repo, compiled: make(map[string]compiledSchema)}
+}
+
+// RegisterSchemaInput 描述登记 Schema 所需的信息。
+type RegisterSchemaInput struct {
+	Scope       repository.SchemaScope
+	ScopeValue  string
+	Schema      json.RawMessage
+	Description string
+}
+
+// RegisterSchema 编译校验 Schema 后按维度创建或覆盖。
+func (s *SchemaService) RegisterSchema(ctx context.Context, input RegisterSchemaInput) (*repository.MetadataSchema, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("schema service not initialized")
+	}
+
+	switch input.Scope {
+	case repository.SchemaScopeType, repository.SchemaScopeOwner:
+	default:
+		return nil, NewError(KindValidation, fmt.Sprintf("scope must be %q or %q", repository.SchemaScopeType, repository.SchemaScopeOwner))
+	}
+	input.ScopeValue = strings.TrimSpace(input.ScopeValue)
+	if input.ScopeValue == "" {
+		return nil, NewError(KindValidation, "scope_value is required")
+	}
+	if len(bytes.TrimSpace(input.Schema)) == 0 {
+		return nil, NewError(KindValidation, "schema is required")
+	}
+	if _, err := compileSchema("candidate", input.Schema); err != nil {
+		return nil, WrapError(KindValidation, "invalid schema: "+err.Error(), err)
+	}
+
+	schema, err := s.repo.Upsert(ctx, &repository.MetadataSchema{
+		ID:          uuid.NewString(),
+		Scope:       input.Scope,
+		ScopeValue:  input.ScopeValue,
+		Schema:      input.Schema,
+		Description: strings.TrimSpace(input.Description),
+	})
+	if err != nil {
+		return nil, repositoryError(err, "schema not found")
+	}
+	return schema, nil
+}
+
+// ListSchemas 返回全部已登记的 Schema。
+func (s *SchemaService) ListSchemas(ctx context.Context) ([]repository.MetadataSchema, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("schema service not initialized")
+	}
+	schemas, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, repositoryError(err, "schema not found")
+	}
+	return schemas, nil
+}
+
+// DeleteSchema 删除指定 Schema。
+func (s *SchemaService) DeleteSchema(ctx context.Context, id string) error {
+	if s == nil || s.repo == nil {
+		return errors.New("schema service not initialized")
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return repositoryError(err, "schema not found")
+	}
+
+	s.mu.Lock()
+	delete(s.compiled, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// ValidateMetadata 使用与 metadata.type 及 owner 匹配的全部 Schema 校验 metadata。
+func (s *SchemaService) ValidateMetadata(ctx context.Context, ownerID string, metadata map[string]any) error {
+	if s == nil || s.repo == nil {
+		return nil
+	}
+
+	match := repository.SchemaMatch{OwnerID: ownerID}
+	if typ, ok := metadata["type"].(string); ok {
+		match.Type = typ
+	}
+	if match.Type == "" && match.OwnerID == "" {
+		return nil
+	}
+
+	schemas, err := s.repo.FindMatching(ctx, match)
+	if err != nil {
+		return fmt.Errorf("load metadata schemas: %w", err)
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	// 经 JSON 往返得到与 Schema 校验器期望一致的值类型
+	instance, err := toJSONValue(metadata)
+	if err != nil {
+		return err
+	}
+
+	var fields []FieldError
+	for _, def := range schemas {
+		compiled, err := s.compiledFor(def)
+		if err != nil {
+			return fmt.Errorf("compile schema %s: %w", def.ID, err)
+		}
+		if err := compiled.Validate(instance); err != nil {
+			var verr *jsonschema.ValidationError
+			if !errors.As(err, &verr) {
+				return err
+			}
+			fields = append(fields, collectFieldErrors(verr)...)
+		}
+	}
+	if len(fields) > 0 {
+		return &MetadataValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func (s *SchemaService) compiledFor(def repository.MetadataSchema) (*jsonschema.Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.compiled[def.ID]; ok && cached.updatedAt.Equal(def.UpdatedAt) {
+		return cached.schema, nil
+	}
+	compiled, err := compileSchema(def.ID, def.Schema)
+	if err != nil {
+		return nil, err
+	}
+	s.compiled[def.ID] = compiledSchema{updatedAt: def.UpdatedAt, schema: compiled}
+	return compiled, nil
+}
+
+func compileSchema(name string, raw json.RawMessage) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	url := "droplite://metadata-schemas/" + name + ".json"
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(embeddedOnlyLoader{})
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// embeddedOnlyLoader 拒绝加载任何外部资源。Schema 由管理员提交，但编译发生在服务端，
+// 默认 loader 会读取 file:// 引用的本地文件；$ref 只能指向 Schema 内部或内置的元 Schema。
+type embeddedOnlyLoader struct{}
+
+func (embeddedOnlyLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("external $ref %q is not allowed", url)
+}
+
+func toJSONValue(metadata map[string]any) (any, error) {
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("encode metadata: %w", err)
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+}
+
+var schemaMessagePrinter = message.NewPrinter(language.English)
+
+// collectFieldErrors 将校验错误树展开为叶子节点对应的字段错误。
+func collectFieldErrors(verr *jsonschema.ValidationError) []FieldError {
+	if len(verr.Causes) > 0 {
+		var out []FieldError
+		for _, cause := range verr.Causes {
+			out = append(out, collectFieldErrors(cause)...)
+		}
+		return out
+	}
+
+	if required, ok := verr.ErrorKind.(*kind.Required); ok {
+		out := make([]FieldError, 0, len(required.Missing))
+		for _, name := range required.Missing {
+			out = append(out, FieldError{
+				Field:   fieldPath(append(append([]string{}, verr.InstanceLocation...), name)),
+				Message: "is required",
+			})
+		}
+		return out
+	}
+
+	return []FieldError{{
+		Field:   fieldPath(verr.InstanceLocation),
+		Message: verr.ErrorKind.LocalizedString(schemaMessagePrinter),
+	}}
+}
+
+func fieldPath(location []string) string {
+	if len(location) == 0 {
+		return "metadata"
+	}
+	return "metadata." + strings.Join(location, ".")
+}
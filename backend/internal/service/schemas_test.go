@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+type mockSchemaRepo struct {
+	schemas []repository.MetadataSchema
+	match   repository.SchemaMatch
+}
+
+func (m *mockSchemaRepo) Upsert(ctx context.Context, schema *repository.MetadataSchema) (*repository.MetadataSchema, error) {
+	m.schemas = append(m.schemas, *schema)
+	return schema, nil
+}
+
+func (m *mockSchemaRepo) List(ctx context.Context) ([]repository.MetadataSchema, error) {
+	return m.schemas, nil
+}
+
+func (m *mockSchemaRepo) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *mockSchemaRepo) FindMatching(ctx context.Context, match repository.SchemaMatch) ([]repository.MetadataSchema, error) {
+	m.match = match
+	var out []repository.MetadataSchema
+	for _, s := range m.schemas {
+		if (s.Scope == repository.SchemaScopeType && s.ScopeValue == match.Type) ||
+			(s.Scope == repository.SchemaScopeOwner && s.ScopeValue == match.OwnerID) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+const invoiceSchema = `{
+	"type": "object",
+	"required": ["type", "amount"],
+	"properties": {
+		"amount": {"type": "number", "minimum": 0}
+	}
+}`
+
+func TestSchemaService_RegisterSchema_RejectsInvalidSchema(t *testing.T) {
+	svc := NewSchemaService(&mockSchemaRepo{})
+
+	_, err := svc.RegisterSchema(context.Background(), RegisterSchemaInput{
+		Scope:      repository.SchemaScopeType,
+		ScopeValue: "invoice",
+		Schema:     json.RawMessage(`{"type": 42}`),
+	})
+	if err == nil {
+		t.Fatal("expected invalid schema error, got nil")
+	}
+}
+
+func TestSchemaService_RegisterSchema_RejectsExternalRefs(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "amount.json")
+	if err := os.WriteFile(local, []byte(`{"type": "number"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewSchemaService(&mockSchemaRepo{})
+
+	for _, ref := range []string{"file://" + filepath.ToSlash(local), "http://169.254.169.254/latest/meta-data"} {
+		_, err := svc.RegisterSchema(context.Background(), RegisterSchemaInput{
+			Scope:      repository.SchemaScopeType,
+			ScopeValue: "invoice",
+			Schema:     json.RawMessage(`{"properties": {"amount": {"$ref": "` + ref + `"}}}`),
+		})
+		if ErrorKindOf(err) != KindValidation {
+			t.Fatalf("expected validation error for $ref %s, got %v", ref, err)
+		}
+	}
+
+	// Schema 内部引用与内置的元 Schema 不受影响
+	if _, err := svc.RegisterSchema(context.Background(), RegisterSchemaInput{
+		Scope:      repository.SchemaScopeType,
+		ScopeValue: "invoice",
+		Schema: json.RawMessage(`{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"$defs": {"amount": {"type": "number"}},
+			"properties": {"amount": {"$ref": "#/$defs/amount"}}
+		}`),
+	}); err != nil {
+		t.Fatalf("expected internal $ref to be accepted, got %v", err)
+	}
+}
+
+func TestFileService_RegisterFile_ValidatesMetadataAgainstTypeSchema(t *testing.T) {
+	schemaRepo := &mockSchemaRepo{schemas: []repository.MetadataSchema{{
+		ID:         "s1",
+		Scope:      repository.SchemaScopeType,
+		ScopeValue: "invoice",
+		Schema:     json.RawMessage(invoiceSchema),
+		UpdatedAt:  time.Now(),
+	}}}
+	repo := &mockFileRepo{}
+	svc := NewFileService(repo, &mockWriter{})
+	svc.SetMetadataValidator(NewSchemaService(schemaRepo))
+
+	_, err := svc.RegisterFile(context.Background(), RegisterFileInput{
+		OwnerID:      "owner-1",
+		OriginalName: "invoice.pdf",
+		MimeType:     "application/pdf",
+		SizeBytes:    4,
+		Metadata:     map[string]any{"type": "invoice", "amount": -3},
+		Reader:       bytes.NewReader([]byte("data")),
+	})
+
+	var verr *MetadataValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected MetadataValidationError, got %v", err)
+	}
+	if len(verr.Fields) != 1 || verr.Fields[0].Field != "metadata.amount" {
+		t.Fatalf("unexpected field errors: %+v", verr.Fields)
+	}
+	if repo.createRecord != nil {
+		t.Fatal("repository should not be called when metadata is invalid")
+	}
+	if schemaRepo.match.OwnerID != "owner-1" || schemaRepo.match.Type != "invoice" {
+		t.Fatalf("unexpected schema match: %+v", schemaRepo.match)
+	}
+}
+
+func TestFileService_UpdateMetadata_MergesAndValidates(t *testing.T) {
+	schemaRepo := &mockSchemaRepo{schemas: []repository.MetadataSchema{{
+		ID:         "s1",
+		Scope:      repository.SchemaScopeType,
+		ScopeValue: "invoice",
+		Schema:     json.RawMessage(invoiceSchema),
+		UpdatedAt:  time.Now(),
+	}}}
+	repo := &mockFileRepo{getResult: &repository.FileRecord{
+		ID:       "f1",
+		Metadata: map[string]any{"type": "invoice", "amount": 10, "draft": true},
+	}}
+	svc := NewFileService(repo, nil)
+	svc.SetMetadataValidator(NewSchemaService(schemaRepo))
+
+	_, err := svc.UpdateMetadata(context.Background(), "f1", "", map[string]any{"amount": nil})
+	var verr *MetadataValidationError
+	if !errors.As(err, &verr) || verr.Fields[0].Field != "metadata.amount" {
+		t.Fatalf("expected missing amount error, got %v", err)
+	}
+
+	record, err := svc.UpdateMetadata(context.Background(), "f1", "", map[string]any{"amount": 20, "draft": nil})
+	if err != nil {
+		t.Fatalf("UpdateMetadata returned error: %v", err)
+	}
+	if _, ok := record.Metadata["draft"]; ok {
+		t.Fatalf("expected draft to be removed, got %+v", record.Metadata)
+	}
+	if repo.updatedMeta["amount"] != 20 {
+		t.Fatalf("expected amount to be patched, got %+v", repo.updatedMeta)
+	}
+}
+
+func TestFileService_UpdateMetadata_ChecksOwnerBeforeValidating(t *testing.T) {
+	schemaRepo := &mockSchemaRepo{}
+	repo := &mockFileRepo{getResult: &repository.FileRecord{
+		ID:       "f1",
+		OwnerID:  "alice",
+		Metadata: map[string]any{"type": "invoice"},
+	}}
+	svc := NewFileService(repo, nil)
+	svc.SetMetadataValidator(NewSchemaService(schemaRepo))
+
+	_, err := svc.UpdateMetadata(context.Background(), "f1", "mallory", map[string]any{"amount": 1})
+	if ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected not_found for another owner's file, got %v", err)
+	}
+	if schemaRepo.match != (repository.SchemaMatch{}) || repo.updatedMeta != nil {
+		t.Fatalf("expected no validation or update, got match %+v meta %+v", schemaRepo.match, repo.updatedMeta)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix 标记 SecretBox 加密后的值，没有该前缀的值视为加密上线前写入的明文。
+const sealedPrefix = "enc:v1:"
+
+// SecretBox 用服务端密钥以 AES-256-GCM 加密需要还原的凭证（如 S3 secret），
+// 密文格式为 "enc:v1:<base64(nonce|密文)>"。
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox 使用 32 字节密钥创建 SecretBox。
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secret box key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal 加密 plaintext。aad 与密文绑定，解密时必须相同，用于防止把一行的密文挪到另一行。
+// b 为 nil 时原样返回，供未配置密钥的开发环境使用。
+func (b *SecretBox) Seal(plaintext, aad string) (string, error) {
+	if b == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open 解密 Seal 的结果，没有加密前缀的旧值原样返回。
+func (b *SecretBox) Open(stored, aad string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if b == nil {
+		return "", errors.New("secret is encrypted but no key is configured")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", errors.New("malformed encrypted secret")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return "", errors.New("decrypt secret: wrong key or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed 判断值是否已加密。
+func IsSealed(stored string) bool {
+	return strings.HasPrefix(stored, sealedPrefix)
+}
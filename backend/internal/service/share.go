@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"droplite/internal/auth"
+	"droplite/internal/repository"
+)
+
+const (
+	// DefaultShareTTL 是未指定有效期时分享链接的有效期。
+	DefaultShareTTL = 24 * time.Hour
+	// MaxShareTTL 是分享链接允许的最长有效期。
+	MaxShareTTL = 30 * 24 * time.Hour
+)
+
+// ShareLink 是一条免登录下载链接。URL 由 API 层根据对外地址填充。
+type ShareLink struct {
+	FileID       string    `json:"file_id"`
+	Token        string    `json:"token"`
+	URL          string    `json:"url,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	AllowedCIDRs []string  `json:"allowed_cidrs,omitempty"`
+	DeniedCIDRs  []string  `json:"denied_cidrs,omitempty"`
+}
+
+// ShareNetworks 限制分享链接可以从哪些网络打开，规则与 API Key 的网络限制相同，为空时不限制。
+type ShareNetworks struct {
+	AllowedCIDRs []string
+	DeniedCIDRs  []string
+}
+
+// ShareLinks 签发与校验分享链接。令牌为 "<file id>.<过期 unix 秒>.<签名>"，
+// 签名为 HMAC-SHA256(secret, "<file id>.<过期 unix 秒>")，服务端不保存任何状态。
+// 限制了网络的链接为 "<file id>.<过期 unix 秒>.<网络>.<签名>"，网络列表随令牌一起签名，无法被改写。
+type ShareLinks struct {
+	files  *FileService
+	secret []byte
+}
+
+// NewShareLinks 创建分享链接服务，secret 为空时无法签发链接。
+func NewShareLinks(files *FileService, secret string) *ShareLinks {
+	return &ShareLinks{files: files, secret: []byte(secret)}
+}
+
+// Create 为 owner 名下已存储的文件签发有效期为 ttl 的链接，ttl 为 0 时使用 DefaultShareTTL。
+func (s *ShareLinks) Create(ctx context.Context, ownerID, fileID string, ttl time.Duration, networks ShareNetworks) (*ShareLink, error) {
+	if s == nil || s.files == nil || len(s.secret) == 0 {
+		return nil, errors.New("share links not initialized")
+	}
+	if ttl == 0 {
+		ttl = DefaultShareTTL
+	}
+	if ttl < time.Second || ttl > MaxShareTTL {
+		return nil, NewError(KindValidation, fmt.Sprintf("expires_in must be between 1s and %s", MaxShareTTL))
+	}
+	allowed, denied, err := normalizeNetworks(networks.AllowedCIDRs, networks.DeniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.files.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.OwnerID != ownerID {
+		return nil, NewError(KindNotFound, "file not found")
+	}
+	if file.Status != repository.FileStatusStored {
+		return nil, NewError(KindConflict, "only stored files can be shared")
+	}
+
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	if file.ExpiresAt != nil && file.ExpiresAt.Before(expiresAt) {
+		// 链接不应比文件本身活得更久
+		expiresAt = file.ExpiresAt.UTC().Truncate(time.Second)
+	}
+	link := &ShareLink{
+		FileID:    file.ID,
+		Token:     s.sign(file.ID, expiresAt, encodeShareNetworks(allowed, denied)),
+		ExpiresAt: expiresAt,
+	}
+	if len(allowed) > 0 {
+		link.AllowedCIDRs = allowed
+	}
+	if len(denied) > 0 {
+		link.DeniedCIDRs = denied
+	}
+	return link, nil
+}
+
+// Resolve 校验令牌并返回可供下载的文件；令牌无效、过期或文件不可下载时一律返回 not_found。
+// 令牌有效但 client 不在链接的网络限制内时返回 forbidden。
+func (s *ShareLinks) Resolve(ctx context.Context, token string, client netip.Addr, now time.Time) (*repository.FileRecord, error) {
+	if s == nil || s.files == nil || len(s.secret) == 0 {
+		return nil, errors.New("share links not initialized")
+	}
+	invalid := NewError(KindNotFound, "share link is invalid or expired")
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, invalid
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	expiresAt := time.Unix(unix, 0)
+	encodedNetworks := ""
+	if len(parts) == 4 {
+		encodedNetworks = parts[2]
+	}
+	if !hmac.Equal([]byte(s.sign(parts[0], expiresAt, encodedNetworks)), []byte(token)) || !now.Before(expiresAt) {
+		return nil, invalid
+	}
+	networks, err := decodeShareNetworks(encodedNetworks)
+	if err != nil {
+		return nil, invalid
+	}
+	if !networks.Permits(client) {
+		return nil, NewError(KindForbidden, auth.NetworkDeniedMessage(client))
+	}
+
+	file, err := s.files.GetFile(ctx, parts[0])
+	if err != nil {
+		if ErrorKindOf(err) == KindNotFound {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if file.Status != repository.FileStatusStored || (file.ExpiresAt != nil && !now.Before(*file.ExpiresAt)) {
+		return nil, invalid
+	}
+	return file, nil
+}
+
+func (s *ShareLinks) sign(fileID string, expiresAt time.Time, encodedNetworks string) string {
+	payload := fileID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	if encodedNetworks != "" {
+		payload += "." + encodedNetworks
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeShareNetworks 把网络列表编码为令牌中的一段："<允许,...>;<拒绝,...>" 的 base64url，没有限制时为空。
+func encodeShareNetworks(allowed, denied []string) string {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(allowed, ",") + ";" + strings.Join(denied, ",")))
+}
+
+func decodeShareNetworks(encoded string) (auth.NetworkPolicy, error) {
+	if encoded == "" {
+		return auth.NetworkPolicy{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return auth.NetworkPolicy{}, err
+	}
+	allowed, denied, ok := strings.Cut(string(raw), ";")
+	if !ok {
+		return auth.NetworkPolicy{}, errors.New("malformed share networks")
+	}
+	return auth.ParseNetworkPolicy(strings.Split(allowed, ","), strings.Split(denied, ","))
+}
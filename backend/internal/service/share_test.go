@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+func TestShareLinks_ResolveRejectsTamperedAndExpiredTokens(t *testing.T) {
+	fileExpiry := time.Now().Add(2 * time.Hour)
+	repo := &mockFileRepo{getResult: &repository.FileRecord{
+		ID:        "file-1",
+		OwnerID:   "owner-a",
+		Status:    repository.FileStatusStored,
+		ExpiresAt: &fileExpiry,
+	}}
+	links := NewShareLinks(NewFileService(repo, nil), "secret")
+	ctx := context.Background()
+
+	link, err := links.Create(ctx, "owner-a", "file-1", 24*time.Hour, ShareNetworks{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if link.ExpiresAt.After(fileExpiry) {
+		t.Fatalf("link outlives file: %s > %s", link.ExpiresAt, fileExpiry)
+	}
+	if _, err := links.Resolve(ctx, link.Token, netip.Addr{}, time.Now()); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	parts := strings.Split(link.Token, ".")
+	tampered := parts[0] + "." + "9999999999" + "." + parts[2]
+	for name, token := range map[string]string{"tampered": tampered, "garbage": "abc"} {
+		if _, err := links.Resolve(ctx, token, netip.Addr{}, time.Now()); ErrorKindOf(err) != KindNotFound {
+			t.Fatalf("%s token: expected not_found, got %v", name, err)
+		}
+	}
+	if _, err := links.Resolve(ctx, link.Token, netip.Addr{}, link.ExpiresAt); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expired token: expected not_found, got %v", err)
+	}
+	if _, err := NewShareLinks(NewFileService(repo, nil), "rotated").Resolve(ctx, link.Token, netip.Addr{}, time.Now()); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("rotated secret: expected not_found, got %v", err)
+	}
+
+	if _, err := links.Create(ctx, "owner-a", "file-1", MaxShareTTL+time.Hour, ShareNetworks{}); ErrorKindOf(err) != KindValidation {
+		t.Fatalf("expected validation error for long ttl, got %v", err)
+	}
+}
+
+func TestShareLinks_RestrictsNetworks(t *testing.T) {
+	repo := &mockFileRepo{getResult: &repository.FileRecord{ID: "file-1", OwnerID: "owner-a", Status: repository.FileStatusStored}}
+	links := NewShareLinks(NewFileService(repo, nil), "secret")
+	ctx := context.Background()
+
+	link, err := links.Create(ctx, "owner-a", "file-1", time.Hour, ShareNetworks{
+		AllowedCIDRs: []string{"10.1.2.3/8"},
+		DeniedCIDRs:  []string{"10.9.0.0/16"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(link.AllowedCIDRs) != 1 || link.AllowedCIDRs[0] != "10.0.0.0/8" {
+		t.Fatalf("expected normalized allow list, got %v", link.AllowedCIDRs)
+	}
+
+	if _, err := links.Resolve(ctx, link.Token, netip.MustParseAddr("10.2.0.1"), time.Now()); err != nil {
+		t.Fatalf("expected allowed network to resolve, got %v", err)
+	}
+	for _, ip := range []string{"10.9.0.1", "192.0.2.1"} {
+		if _, err := links.Resolve(ctx, link.Token, netip.MustParseAddr(ip), time.Now()); ErrorKindOf(err) != KindForbidden {
+			t.Fatalf("%s: expected forbidden, got %v", ip, err)
+		}
+	}
+
+	// 去掉网络段后签名不再匹配
+	parts := strings.Split(link.Token, ".")
+	stripped := parts[0] + "." + parts[1] + "." + parts[3]
+	if _, err := links.Resolve(ctx, stripped, netip.MustParseAddr("192.0.2.1"), time.Now()); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected stripped token to be invalid, got %v", err)
+	}
+
+	if _, err := links.Create(ctx, "owner-a", "file-1", time.Hour, ShareNetworks{AllowedCIDRs: []string{"not-a-cidr"}}); ErrorKindOf(err) != KindValidation {
+		t.Fatalf("expected validation error for bad CIDR, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/storage"
+)
+
+// ExportedFile 是 NDJSON 导出中的一行：FileRecord 的全部字段加上 owner_id。
+type ExportedFile struct {
+	repository.FileRecord
+	OwnerID string `json:"owner_id"`
+}
+
+// ExportFiles 将全部文件记录逐行写为 NDJSON，ownerID 非空时只导出该 owner 的记录。
+// 返回写出的记录数。
+func ExportFiles(ctx context.Context, store repository.FileRecordStore, w io.Writer, ownerID string) (int, error) {
+	if store == nil {
+		return 0, errors.New("file record store not initialized")
+	}
+
+	enc := json.NewEncoder(w)
+	count := 0
+	err := store.Each(ctx, func(record repository.FileRecord) error {
+		if ownerID != "" && record.OwnerID != ownerID {
+			return nil
+		}
+		if err := enc.Encode(ExportedFile{FileRecord: record, OwnerID: record.OwnerID}); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ConflictPolicy 决定导入时如何处理冲突记录。
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"      // 跳过并报告
+	ConflictOverwrite ConflictPolicy = "overwrite" // 以导入的记录覆盖
+	ConflictFail      ConflictPolicy = "fail"      // 立即停止导入
+)
+
+// ImportOutcome 是单条记录的导入结果。
+type ImportOutcome string
+
+const (
+	ImportInserted      ImportOutcome = "inserted"
+	ImportUpdated       ImportOutcome = "updated"
+	ImportUnchanged     ImportOutcome = "unchanged"
+	ImportConflict      ImportOutcome = "conflict"
+	ImportInvalid       ImportOutcome = "invalid"
+	ImportMissingObject ImportOutcome = "missing_object"
+)
+
+// ImportOptions 控制导入行为。
+type ImportOptions struct {
+	OnConflict ConflictPolicy
+	// Storage 非 nil 时校验 stored 状态记录的 storage_path 在目标存储中存在且大小一致，
+	// 不满足的记录不会导入。
+	Storage storage.Reader
+	// DryRun 只计算结果，不写入数据库。
+	DryRun bool
+}
+
+// ImportResult 描述一行的导入结果。
+type ImportResult struct {
+	Line    int           `json:"line"`
+	ID      string        `json:"id,omitempty"`
+	Outcome ImportOutcome `json:"outcome"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+// ImportSummary 汇总导入结果。
+type ImportSummary struct {
+	Inserted       int `json:"inserted"`
+	Updated        int `json:"updated"`
+	Unchanged      int `json:"unchanged"`
+	Conflicts      int `json:"conflicts"`
+	Invalid        int `json:"invalid"`
+	MissingObjects int `json:"missing_objects"`
+}
+
+// Clean 表示所有记录都已导入或无需变更。
+func (s ImportSummary) Clean() bool {
+	return s.Conflicts == 0 && s.Invalid == 0 && s.MissingObjects == 0
+}
+
+func (s *ImportSummary) add(outcome ImportOutcome) {
+	switch outcome {
+	case ImportInserted:
+		s.Inserted++
+	case ImportUpdated:
+		s.Updated++
+	case ImportUnchanged:
+		s.Unchanged++
+	case ImportConflict:
+		s.Conflicts++
+	case ImportInvalid:
+		s.Invalid++
+	case ImportMissingObject:
+		s.MissingObjects++
+	}
+}
+
+// ErrImportConflict 在 ConflictFail 策略下遇到冲突时返回。
+var ErrImportConflict = errors.New("import conflict")
+
+// ImportFiles 逐行读取 NDJSON 并按 ID upsert。
+//
+// 目标库中不存在的记录直接插入；已存在且内容相同的记录不变；已存在时，
+// owner 或 storage_path 不同、或目标记录的 updated_at 更新，视为冲突并按 OnConflict 处理，
+// 其余情况以导入的记录覆盖。每一行的结果都会传给 report（可为 nil）。
+func ImportFiles(ctx context.Context, store repository.FileRecordStore, r io.Reader, opts ImportOptions, report func(ImportResult)) (ImportSummary, error) {
+	var summary ImportSummary
+	if store == nil {
+		return summary, errors.New("file record store not initialized")
+	}
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictSkip
+	}
+	if report == nil {
+		report = func(ImportResult) {}
+	}
+
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return summary, readErr
+		}
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			result, err := importLine(ctx, store, raw, opts)
+			result.Line = line
+			summary.add(result.Outcome)
+			report(result)
+			if err != nil {
+				return summary, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if readErr != nil {
+			return summary, nil
+		}
+	}
+}
+
+func importLine(ctx context.Context, store repository.FileRecordStore, raw []byte, opts ImportOptions) (ImportResult, error) {
+	var exported ExportedFile
+	if err := json.Unmarshal(raw, &exported); err != nil {
+		return ImportResult{Outcome: ImportInvalid, Reason: err.Error()}, nil
+	}
+	record := exported.FileRecord
+	record.OwnerID = exported.OwnerID
+	result := ImportResult{ID: record.ID}
+	if reason := validateImported(&record); reason != "" {
+		result.Outcome, result.Reason = ImportInvalid, reason
+		return result, nil
+	}
+	if record.Metadata == nil {
+		record.Metadata = map[string]any{}
+	}
+
+	if opts.Storage != nil && record.Status == repository.FileStatusStored {
+		if reason, err := checkObject(ctx, opts.Storage, &record); err != nil {
+			return result, err
+		} else if reason != "" {
+			result.Outcome, result.Reason = ImportMissingObject, reason
+			return result, nil
+		}
+	}
+
+	existing, err := store.GetByID(ctx, record.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		result.Outcome = ImportInserted
+	case err != nil:
+		return result, err
+	case sameRecord(existing, &record):
+		result.Outcome = ImportUnchanged
+		return result, nil
+	default:
+		result.Outcome = ImportUpdated
+		if reason := conflictReason(existing, &record); reason != "" {
+			if opts.OnConflict != ConflictOverwrite {
+				result.Outcome, result.Reason = ImportConflict, reason
+				if opts.OnConflict == ConflictFail {
+					return result, fmt.Errorf("%w: %s: %s", ErrImportConflict, record.ID, reason)
+				}
+				return result, nil
+			}
+			result.Reason = "overwrote: " + reason
+		}
+	}
+
+	if !opts.DryRun {
+		if err := store.Upsert(ctx, &record); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// validateImported 检查导入记录的必填字段。owner_id 可以为空：迁移 0003 之前的记录
+// 与 AUTH_ENABLED=false 时上传的文件都没有 owner。
+func validateImported(record *repository.FileRecord) string {
+	switch {
+	case record.ID == "":
+		return "id is required"
+	case record.StoragePath == "":
+		return "storage_path is required"
+	case record.CreatedAt.IsZero() || record.UpdatedAt.IsZero():
+		return "created_at and updated_at are required"
+	}
+	switch record.Status {
+	case repository.FileStatusPending, repository.FileStatusStored, repository.FileStatusFailed, repository.FileStatusDeleted:
+		return ""
+	default:
+		return fmt.Sprintf("unknown status %q", record.Status)
+	}
+}
+
+// checkObject 返回对象缺失或大小不符的原因；存储本身出错时返回 error。
+func checkObject(ctx context.Context, store storage.Reader, record *repository.FileRecord) (string, error) {
+	var (
+		size int64
+		err  error
+	)
+	if statter, ok := store.(storage.Statter); ok {
+		size, err = statter.Stat(ctx, record.StoragePath)
+	} else {
+		var body io.ReadCloser
+		if body, err = store.Read(ctx, record.StoragePath); err == nil {
+			size, err = io.Copy(io.Discard, body)
+			_ = body.Close()
+		}
+	}
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return fmt.Sprintf("object %s not found in storage", record.StoragePath), nil
+	case err != nil:
+		return "", fmt.Errorf("check object %s: %w", record.StoragePath, err)
+	case size != record.SizeBytes:
+		return fmt.Sprintf("object %s is %d bytes, record says %d", record.StoragePath, size, record.SizeBytes), nil
+	}
+	return "", nil
+}
+
+func conflictReason(existing, imported *repository.FileRecord) string {
+	switch {
+	case existing.OwnerID != imported.OwnerID:
+		return "owner_id differs"
+	case existing.StoragePath != imported.StoragePath:
+		return "storage_path differs"
+	case existing.UpdatedAt.After(imported.UpdatedAt):
+		return "target record was updated more recently"
+	}
+	return ""
+}
+
+func sameRecord(a, b *repository.FileRecord) bool {
+	return a.OwnerID == b.OwnerID &&
+		a.OriginalName == b.OriginalName &&
+		a.MimeType == b.MimeType &&
+		a.SizeBytes == b.SizeBytes &&
+		a.StoragePath == b.StoragePath &&
+		equalStringPtr(a.Checksum, b.Checksum) &&
+		a.Status == b.Status &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.UpdatedAt.Equal(b.UpdatedAt) &&
+		equalTimePtr(a.ExpiresAt, b.ExpiresAt) &&
+		sameMetadata(a.Metadata, b.Metadata)
+}
+
+// sameMetadata 经 JSON 往返后比较，避免数字类型差异（数据库读出与 NDJSON 解析均为 float64）。
+func sameMetadata(a, b map[string]any) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(v map[string]any) any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
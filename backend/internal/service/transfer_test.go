@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+	"droplite/internal/storage"
+)
+
+// recordStore 是按 ID 保存记录的内存 FileRecordStore。
+type recordStore struct {
+	records map[string]repository.FileRecord
+	upserts int
+}
+
+func newRecordStore(records ...repository.FileRecord) *recordStore {
+	store := &recordStore{records: map[string]repository.FileRecord{}}
+	for _, rec := range records {
+		store.records[rec.ID] = rec
+	}
+	return store
+}
+
+func (s *recordStore) GetByID(ctx context.Context, id string) (*repository.FileRecord, error) {
+	rec, ok := s.records[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (s *recordStore) Each(ctx context.Context, fn func(record repository.FileRecord) error) error {
+	ids := make([]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := fn(s.records[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *recordStore) Upsert(ctx context.Context, record *repository.FileRecord) error {
+	s.records[record.ID] = *record
+	s.upserts++
+	return nil
+}
+
+// statStorage 只记录对象大小。
+type statStorage map[string]int64
+
+func (s statStorage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("unexpected read")
+}
+
+func (s statStorage) Stat(ctx context.Context, key string) (int64, error) {
+	size, ok := s[key]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+	}
+	return size, nil
+}
+
+func transferRecord(id, owner string, updated time.Time) repository.FileRecord {
+	checksum := "sha256:abc"
+	return repository.FileRecord{
+		ID:           id,
+		OwnerID:      owner,
+		OriginalName: id + ".txt",
+		MimeType:     "text/plain",
+		SizeBytes:    5,
+		StoragePath:  "2026/01/" + id,
+		Checksum:     &checksum,
+		Status:       repository.FileStatusStored,
+		Metadata:     map[string]any{"count": float64(2), "tags": []any{"a"}},
+		CreatedAt:    updated.Add(-time.Hour),
+		UpdatedAt:    updated,
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := newRecordStore(transferRecord("a", "alice", now), transferRecord("b", "bob", now))
+
+	var buf bytes.Buffer
+	n, err := ExportFiles(context.Background(), source, &buf, "")
+	if err != nil || n != 2 {
+		t.Fatalf("export = %d, %v", n, err)
+	}
+	if !strings.Contains(buf.String(), `"owner_id":"alice"`) {
+		t.Fatalf("export does not include owner_id: %s", buf.String())
+	}
+
+	target := newRecordStore()
+	exported := buf.String()
+	summary, err := ImportFiles(context.Background(), target, strings.NewReader(exported), ImportOptions{}, nil)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Inserted != 2 || !summary.Clean() {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if got, want := target.records["a"], source.records["a"]; got.OwnerID != "alice" || !sameRecord(&got, &want) {
+		t.Fatalf("imported record = %+v", got)
+	}
+
+	// 再次导入同一份数据不产生写入
+	summary, err = ImportFiles(context.Background(), target, strings.NewReader(exported), ImportOptions{}, nil)
+	if err != nil || summary.Unchanged != 2 || target.upserts != 2 {
+		t.Fatalf("second import = %+v, %v (upserts %d)", summary, err, target.upserts)
+	}
+
+	buf.Reset()
+	if n, err := ExportFiles(context.Background(), source, &buf, "bob"); err != nil || n != 1 {
+		t.Fatalf("export by owner = %d, %v", n, err)
+	}
+}
+
+func TestExportImport_OwnerlessRecords(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := newRecordStore(transferRecord("legacy", "", now), transferRecord("a", "alice", now))
+
+	var buf bytes.Buffer
+	if n, err := ExportFiles(context.Background(), source, &buf, ""); err != nil || n != 2 {
+		t.Fatalf("export = %d, %v", n, err)
+	}
+	target := newRecordStore()
+	summary, err := ImportFiles(context.Background(), target, strings.NewReader(buf.String()), ImportOptions{}, nil)
+	if err != nil || summary.Inserted != 2 || !summary.Clean() {
+		t.Fatalf("import = %+v, %v", summary, err)
+	}
+	if got, want := target.records["legacy"], source.records["legacy"]; got.OwnerID != "" || !sameRecord(&got, &want) {
+		t.Fatalf("imported ownerless record = %+v", got)
+	}
+}
+
+func TestImportFiles_Conflicts(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	newer := transferRecord("newer", "alice", now.Add(time.Hour))
+	otherOwner := transferRecord("owner", "mallory", now)
+	stale := transferRecord("stale", "alice", now.Add(-time.Hour))
+	stale.OriginalName = "old-name.txt"
+
+	var input bytes.Buffer
+	source := newRecordStore(transferRecord("newer", "alice", now), transferRecord("owner", "alice", now), transferRecord("stale", "alice", now))
+	if _, err := ExportFiles(context.Background(), source, &input, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		target := newRecordStore(newer, otherOwner, stale)
+		var results []ImportResult
+		summary, err := ImportFiles(context.Background(), target, bytes.NewReader(input.Bytes()), ImportOptions{}, func(r ImportResult) {
+			results = append(results, r)
+		})
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if summary.Conflicts != 2 || summary.Updated != 1 {
+			t.Fatalf("summary = %+v", summary)
+		}
+		if target.records["newer"].UpdatedAt != newer.UpdatedAt || target.records["owner"].OwnerID != "mallory" {
+			t.Fatal("conflicting records should be left untouched")
+		}
+		if target.records["stale"].OriginalName != "stale.txt" {
+			t.Fatalf("older target record should be updated, got %q", target.records["stale"].OriginalName)
+		}
+		if results[0].Line != 1 || results[0].Reason != "target record was updated more recently" {
+			t.Fatalf("first result = %+v", results[0])
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		target := newRecordStore(newer, otherOwner, stale)
+		summary, err := ImportFiles(context.Background(), target, bytes.NewReader(input.Bytes()), ImportOptions{OnConflict: ConflictOverwrite}, nil)
+		if err != nil || summary.Updated != 3 {
+			t.Fatalf("summary = %+v, %v", summary, err)
+		}
+		if target.records["owner"].OwnerID != "alice" {
+			t.Fatal("overwrite should replace the owner")
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		target := newRecordStore(newer, otherOwner, stale)
+		_, err := ImportFiles(context.Background(), target, bytes.NewReader(input.Bytes()), ImportOptions{OnConflict: ConflictFail}, nil)
+		if !errors.Is(err, ErrImportConflict) || !strings.HasPrefix(err.Error(), "line 1:") {
+			t.Fatalf("err = %v", err)
+		}
+		if target.upserts != 0 {
+			t.Fatalf("upserts = %d", target.upserts)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		target := newRecordStore(stale)
+		summary, err := ImportFiles(context.Background(), target, bytes.NewReader(input.Bytes()), ImportOptions{DryRun: true}, nil)
+		if err != nil || summary.Inserted != 2 || summary.Updated != 1 || target.upserts != 0 {
+			t.Fatalf("summary = %+v, %v (upserts %d)", summary, err, target.upserts)
+		}
+	})
+}
+
+func TestImportFiles_InvalidLinesAndStorageCheck(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var input bytes.Buffer
+	source := newRecordStore(transferRecord("ok", "alice", now), transferRecord("missing", "alice", now), transferRecord("short", "alice", now))
+	deleted := transferRecord("deleted", "alice", now)
+	deleted.Status = repository.FileStatusDeleted
+	source.records["deleted"] = deleted
+	if _, err := ExportFiles(context.Background(), source, &input, ""); err != nil {
+		t.Fatal(err)
+	}
+	input.WriteString("\nnot json\n")
+	input.WriteString(`{"id":"x","owner_id":"alice","storage_path":"p","status":"bogus","created_at":"2026-01-01T00:00:00Z","updated_at":"2026-01-01T00:00:00Z"}`)
+
+	objects := statStorage{"2026/01/ok": 5, "2026/01/short": 3}
+	target := newRecordStore()
+	var results []ImportResult
+	summary, err := ImportFiles(context.Background(), target, &input, ImportOptions{Storage: objects}, func(r ImportResult) {
+		results = append(results, r)
+	})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	// deleted 状态的记录不校验对象
+	if summary.Inserted != 2 || summary.MissingObjects != 2 || summary.Invalid != 2 || summary.Clean() {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if _, ok := target.records["missing"]; ok {
+		t.Fatal("record with missing object should not be imported")
+	}
+	last := results[len(results)-1]
+	if last.Line != 7 || last.Outcome != ImportInvalid || !strings.Contains(last.Reason, "bogus") {
+		t.Fatalf("last result = %+v", last)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errWebhookRedirect 是回调地址返回重定向时的错误，重定向目标未经校验，一律不跟随。
+var errWebhookRedirect = errors.New("webhook endpoint redirected; redirects are not followed")
+
+// nonPublicNetworks 是 netip.Addr 的分类方法之外仍不能作为回调目标的保留网段。
+var nonPublicNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// NewWebhookClient 创建投递 webhook 使用的 HTTP 客户端。
+//
+// 回调地址由用户提供，为防止借此访问内网（SSRF），连接建立时检查解析后的实际地址，拒绝回环、私有、
+// 链路本地与未指定地址。检查在拨号时进行，DNS 重绑定也无法绕过；客户端不使用环境变量中的代理。
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkWebhookAddress(address)
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: refuseWebhookRedirect,
+	}
+}
+
+func refuseWebhookRedirect(*http.Request, []*http.Request) error {
+	return errWebhookRedirect
+}
+
+// checkWebhookAddress 检查拨号的 "ip:port" 是否为公网地址。
+func checkWebhookAddress(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook address %q: %w", address, err)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is not a public address", addrPort.Addr())
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
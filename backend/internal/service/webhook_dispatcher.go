@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+const (
+	// WebhookSignatureHeader 携带 "t=<unix 秒>,v1=<hex HMAC-SHA256>"，签名内容为 "<t>.<请求体>"。
+	WebhookSignatureHeader = "X-Droplite-Signature"
+	WebhookEventHeader     = "X-Droplite-Event"
+	WebhookDeliveryHeader  = "X-Droplite-Delivery"
+)
+
+// SignWebhookPayload 计算 webhook 请求的签名头取值，接收方可按同样方式校验。
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDispatcher 从 Postgres 投递队列中领取到期记录并发送，失败时按指数退避重试。
+type WebhookDispatcher struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+
+	// BatchSize 是每轮领取的最大记录数。
+	BatchSize int
+	// MaxAttempts 是单条投递的最大尝试次数，用尽后标记为 failed。
+	MaxAttempts int
+	// BaseBackoff 为首次重试的等待时间，之后每次翻倍，最长不超过 MaxBackoff。
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease 是领取后其他实例不会重复领取的时长。一批记录依次投递，Lease 应不小于 BatchSize 乘以单次请求超时；
+	// 租约内来不及投递的记录留待租约过期后重新领取。
+	Lease time.Duration
+}
+
+// NewWebhookDispatcher 创建 WebhookDispatcher。client 为 nil 时使用 NewWebhookClient；
+// 无论传入什么客户端都不跟随重定向。
+func NewWebhookDispatcher(repo repository.WebhookRepository, client *http.Client) *WebhookDispatcher {
+	if client == nil {
+		client = NewWebhookClient(10 * time.Second)
+	} else {
+		copied := *client
+		copied.CheckRedirect = refuseWebhookRedirect
+		client = &copied
+	}
+	const batchSize = 20
+	return &WebhookDispatcher{
+		repo:        repo,
+		client:      client,
+		BatchSize:   batchSize,
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  time.Hour,
+		Lease:       batchSize*client.Timeout + time.Minute,
+	}
+}
+
+// Run 每隔 interval 投递一轮到期记录，直到 ctx 结束。
+func (d *WebhookDispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := d.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("[webhooks] deliver due: %v", err)
+			}
+			// 批次未满说明已无积压，等待下一个周期
+			if err != nil || n < d.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue 领取并投递一批到期记录，返回处理的记录数。
+//
+// 请求只在租约内发出：剩余租约不足一次请求超时的记录不再投递，租约过期后由任一实例重新领取；
+// 结果按领取时的状态有条件地写回，已被重新领取的记录不会被覆盖。
+func (d *WebhookDispatcher) DeliverDue(ctx context.Context) (int, error) {
+	leaseEnd := time.Now().Add(d.Lease)
+	deliveries, err := d.repo.ClaimDueDeliveries(ctx, d.BatchSize, d.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("claim deliveries: %w", err)
+	}
+	// 请求不能拖到租约之后，落库结果则不受此限
+	sendCtx, cancel := context.WithDeadline(ctx, leaseEnd)
+	defer cancel()
+
+	webhooks := make(map[string]*repository.Webhook)
+	for i := range deliveries {
+		if time.Until(leaseEnd) < d.client.Timeout {
+			return i, nil
+		}
+		delivery := &deliveries[i]
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = d.repo.GetByID(ctx, delivery.WebhookID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return i, fmt.Errorf("load webhook %s: %w", delivery.WebhookID, err)
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+
+		claim := repository.DeliveryClaim{Attempts: delivery.Attempts, NextAttemptAt: delivery.NextAttemptAt}
+		d.attempt(sendCtx, webhook, delivery)
+		if err := d.repo.UpdateDelivery(ctx, delivery, claim); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return i + 1, fmt.Errorf("record delivery %s: %w", delivery.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt 发送一次请求并把结果写回 delivery。
+func (d *WebhookDispatcher) attempt(ctx context.Context, webhook *repository.Webhook, delivery *repository.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = nil
+
+	if webhook == nil || !webhook.Active {
+		delivery.Status = repository.DeliveryStatusFailed
+		delivery.LastError = "webhook is disabled"
+		return
+	}
+
+	status, err := d.send(ctx, webhook, delivery, now)
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+	if err == nil {
+		delivery.Status = repository.DeliveryStatusSucceeded
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = repository.DeliveryStatusFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *repository.Webhook, delivery *repository.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "droplite-webhooks/1")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff 返回第 attempts 次失败后的等待时间：BaseBackoff * 2^(attempts-1)，上限 MaxBackoff。
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.MaxBackoff)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"droplite/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// WebhookService 管理 owner 登记的 webhook，并实现 EventPublisher 将事件写入投递队列。
+type WebhookService struct {
+	repo repository.WebhookRepository
+}
+
+func NewWebhookService(repo repository.WebhookRepository) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// RegisterWebhookInput 描述登记 webhook 所需的信息。
+type RegisterWebhookInput struct {
+	OwnerID     string
+	URL         string
+	Events      []string
+	Description string
+}
+
+// RegisterWebhook 校验回调地址与事件类型后登记 webhook。
+// 返回的记录包含签名密钥，这是调用方唯一一次拿到明文密钥的机会。
+// 域名解析结果会变化，地址是否指向内网在每次投递建立连接时检查（见 NewWebhookClient），登记时不做判断。
+func (s *WebhookService) RegisterWebhook(ctx context.Context, input RegisterWebhookInput) (*repository.Webhook, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("webhook service not initialized")
+	}
+
+	endpoint := strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, NewError(KindValidation, "url must be an absolute http(s) URL")
+	}
+	events, err := normalizeEventTypes(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, WrapError(KindInternal, "generate webhook secret", err)
+	}
+
+	webhook, err := s.repo.Create(ctx, &repository.Webhook{
+		ID:          uuid.NewString(),
+		OwnerID:     input.OwnerID,
+		URL:         endpoint,
+		Secret:      secret,
+		Events:      events,
+		Description: strings.TrimSpace(input.Description),
+		Active:      true,
+	})
+	if err != nil {
+		return nil, repositoryError(err, "webhook not found")
+	}
+	return webhook, nil
+}
+
+// ListWebhooks 返回 owner 登记的 webhook，不包含签名密钥。
+func (s *WebhookService) ListWebhooks(ctx context.Context, ownerID string) ([]repository.Webhook, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("webhook service not initialized")
+	}
+	webhooks, err := s.repo.ListByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, repositoryError(err, "webhook not found")
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook 删除 owner 名下的 webhook 及其投递日志。
+func (s *WebhookService) DeleteWebhook(ctx context.Context, ownerID, id string) error {
+	if s == nil || s.repo == nil {
+		return errors.New("webhook service not initialized")
+	}
+	if _, err := s.ownedWebhook(ctx, ownerID, id); err != nil {
+		return err
+	}
+	return repositoryError(s.repo.Delete(ctx, id), "webhook not found")
+}
+
+// ListDeliveries 分页返回 owner 名下某个 webhook 的投递日志。
+func (s *WebhookService) ListDeliveries(ctx context.Context, ownerID string, params repository.ListDeliveriesParams) ([]repository.WebhookDelivery, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("webhook service not initialized")
+	}
+	if _, err := s.ownedWebhook(ctx, ownerID, params.WebhookID); err != nil {
+		return nil, err
+	}
+	switch params.Status {
+	case "", repository.DeliveryStatusPending, repository.DeliveryStatusSucceeded, repository.DeliveryStatusFailed:
+	default:
+		return nil, NewError(KindValidation, fmt.Sprintf("unknown delivery status %q", params.Status))
+	}
+	deliveries, err := s.repo.ListDeliveries(ctx, params)
+	if err != nil {
+		return nil, repositoryError(err, "delivery not found")
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery 以原事件内容重新排队一次投递，原投递日志保持不变。
+// 新投递沿用原事件 ID，接收方可据此去重。
+func (s *WebhookService) ReplayDelivery(ctx context.Context, ownerID, webhookID, deliveryID string) (*repository.WebhookDelivery, error) {
+	if s == nil || s.repo == nil {
+		return nil, errors.New("webhook service not initialized")
+	}
+	if _, err := s.ownedWebhook(ctx, ownerID, webhookID); err != nil {
+		return nil, err
+	}
+	original, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, repositoryError(err, "delivery not found")
+	}
+	if original.WebhookID != webhookID {
+		return nil, NewError(KindNotFound, "delivery not found")
+	}
+
+	now := time.Now().UTC()
+	replay := repository.WebhookDelivery{
+		ID:            uuid.NewString(),
+		WebhookID:     webhookID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        repository.DeliveryStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.repo.EnqueueDeliveries(ctx, []repository.WebhookDelivery{replay}); err != nil {
+		return nil, repositoryError(err, "delivery not found")
+	}
+	return &replay, nil
+}
+
+// Publish 为订阅了该事件的每个 webhook 写入一条待投递记录。
+func (s *WebhookService) Publish(ctx context.Context, event Event) error {
+	if s == nil || s.repo == nil {
+		return errors.New("webhook service not initialized")
+	}
+	subscribers, err := s.repo.ListSubscribers(ctx, event.OwnerID, string(event.Type))
+	if err != nil {
+		return fmt.Errorf("list webhook subscribers: %w", err)
+	}
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	deliveries := make([]repository.WebhookDelivery, 0, len(subscribers))
+	for _, webhook := range subscribers {
+		deliveries = append(deliveries, repository.WebhookDelivery{
+			ID:            uuid.NewString(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       payload,
+			Status:        repository.DeliveryStatusPending,
+			NextAttemptAt: event.OccurredAt,
+		})
+	}
+	if err := s.repo.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// ownedWebhook 读取 webhook 并校验归属；不属于调用方的 webhook 按不存在处理。
+func (s *WebhookService) ownedWebhook(ctx context.Context, ownerID, id string) (*repository.Webhook, error) {
+	webhook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, repositoryError(err, "webhook not found")
+	}
+	if webhook.OwnerID != ownerID {
+		return nil, NewError(KindNotFound, "webhook not found")
+	}
+	return webhook, nil
+}
+
+func normalizeEventTypes(raw []string) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, NewError(KindValidation, "events must not be empty")
+	}
+	events := make([]string, 0, len(raw))
+	for _, e := range raw {
+		e = strings.TrimSpace(e)
+		if !slices.Contains(EventTypes, EventType(e)) {
+			return nil, NewError(KindValidation, fmt.Sprintf("unknown event type %q", e))
+		}
+		if !slices.Contains(events, e) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
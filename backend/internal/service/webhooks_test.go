@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"droplite/internal/repository"
+)
+
+type mockWebhookRepo struct {
+	mu         sync.Mutex
+	webhooks   []repository.Webhook
+	deliveries []repository.WebhookDelivery
+}
+
+func (m *mockWebhookRepo) Create(ctx context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks = append(m.webhooks, *webhook)
+	return webhook, nil
+}
+
+func (m *mockWebhookRepo) GetByID(ctx context.Context, id string) (*repository.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.webhooks {
+		if w.ID == id {
+			return &w, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockWebhookRepo) ListByOwner(ctx context.Context, ownerID string) ([]repository.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.Webhook
+	for _, w := range m.webhooks {
+		if w.OwnerID == ownerID {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWebhookRepo) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks = slices.DeleteFunc(m.webhooks, func(w repository.Webhook) bool { return w.ID == id })
+	return nil
+}
+
+func (m *mockWebhookRepo) ListSubscribers(ctx context.Context, ownerID, eventType string) ([]repository.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.Webhook
+	for _, w := range m.webhooks {
+		if w.OwnerID == ownerID && w.Active && slices.Contains(w.Events, eventType) {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWebhookRepo) EnqueueDeliveries(ctx context.Context, deliveries []repository.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, deliveries...)
+	return nil
+}
+
+func (m *mockWebhookRepo) GetDelivery(ctx context.Context, id string) (*repository.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deliveries {
+		if d.ID == id {
+			return &d, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *mockWebhookRepo) ListDeliveries(ctx context.Context, params repository.ListDeliveriesParams) ([]repository.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.WebhookID == params.WebhookID && (params.Status == "" || d.Status == params.Status) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWebhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var out []repository.WebhookDelivery
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.Status == repository.DeliveryStatusPending && !d.NextAttemptAt.After(now) && len(out) < limit {
+			d.NextAttemptAt = now.Add(lease)
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWebhookRepo) UpdateDelivery(ctx context.Context, delivery *repository.WebhookDelivery, claim repository.DeliveryClaim) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.ID == delivery.ID && d.Status == repository.DeliveryStatusPending &&
+			d.Attempts == claim.Attempts && d.NextAttemptAt.Equal(claim.NextAttemptAt) {
+			*d = *delivery
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+// due 让所有待投递记录立即到期，模拟退避时间已过。
+func (m *mockWebhookRepo) due() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		m.deliveries[i].NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+type recordingPublisher struct {
+	events []Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestWebhookService_RegisterWebhook_Validates(t *testing.T) {
+	svc := NewWebhookService(&mockWebhookRepo{})
+
+	cases := []RegisterWebhookInput{
+		{URL: "ftp://example.com/hook", Events: []string{"file.created"}},
+		{URL: "/relative", Events: []string{"file.created"}},
+		{URL: "https://example.com/hook"},
+		{URL: "https://example.com/hook", Events: []string{"file.renamed"}},
+	}
+	for _, input := range cases {
+		if _, err := svc.RegisterWebhook(context.Background(), input); ErrorKindOf(err) != KindValidation {
+			t.Fatalf("expected validation error for %+v, got %v", input, err)
+		}
+	}
+
+	webhook, err := svc.RegisterWebhook(context.Background(), RegisterWebhookInput{
+		OwnerID: "owner-a",
+		URL:     "https://example.com/hook",
+		Events:  []string{"file.created", "file.created", "file.deleted"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(webhook.Secret, "whsec_") || len(webhook.Events) != 2 || !webhook.Active {
+		t.Fatalf("unexpected webhook: %+v", webhook)
+	}
+
+	listed, err := svc.ListWebhooks(context.Background(), "owner-a")
+	if err != nil || len(listed) != 1 || listed[0].Secret != "" {
+		t.Fatalf("expected listing without secret, got %+v (%v)", listed, err)
+	}
+	if err := svc.DeleteWebhook(context.Background(), "owner-b", webhook.ID); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected other owner delete to be not_found, got %v", err)
+	}
+}
+
+func TestWebhookDispatcher_DeliversSignedEvents(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	hits := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hits <- received{header: r.Header.Clone(), body: body}
+	}))
+	defer receiver.Close()
+
+	repo := &mockWebhookRepo{}
+	webhooks := NewWebhookService(repo)
+	hook, err := webhooks.RegisterWebhook(context.Background(), RegisterWebhookInput{
+		OwnerID: "owner-a",
+		URL:     receiver.URL,
+		Events:  []string{string(EventFileCreated)},
+	})
+	if err != nil {
+		t.Fatalf("register webhook: %v", err)
+	}
+
+	files := NewFileService(&mockFileRepo{}, &mockWriter{})
+	files.SetEventPublisher(webhooks)
+	record, err := files.RegisterFile(context.Background(), RegisterFileInput{
+		OwnerID:      "owner-a",
+		OriginalName: "report.pdf",
+		MimeType:     "application/pdf",
+		SizeBytes:    4,
+		Reader:       strings.NewReader("data"),
+	})
+	if err != nil {
+		t.Fatalf("register file: %v", err)
+	}
+	// 其他 owner 的事件不会投递给该 webhook
+	if _, err := files.RegisterFile(context.Background(), RegisterFileInput{
+		OwnerID:      "owner-b",
+		OriginalName: "other.txt",
+		MimeType:     "text/plain",
+		SizeBytes:    4,
+		Reader:       strings.NewReader("data"),
+	}); err != nil {
+		t.Fatalf("register file: %v", err)
+	}
+
+	n, err := NewWebhookDispatcher(repo, receiver.Client()).DeliverDue(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("expected one delivery, got %d (%v)", n, err)
+	}
+
+	hit := <-hits
+	if hit.header.Get(WebhookEventHeader) != "file.created" || hit.header.Get(WebhookDeliveryHeader) != repo.deliveries[0].ID {
+		t.Fatalf("unexpected headers: %v", hit.header)
+	}
+	signature := hit.header.Get(WebhookSignatureHeader)
+	ts, _, _ := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+	unix, _ := strconv.ParseInt(ts, 10, 64)
+	if signature != SignWebhookPayload(hook.Secret, time.Unix(unix, 0), hit.body) {
+		t.Fatalf("signature %q does not verify", signature)
+	}
+
+	var event Event
+	if err := json.Unmarshal(hit.body, &event); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if event.Type != EventFileCreated || event.Data == nil || event.Data.ID != record.ID {
+		t.Fatalf("unexpected payload: %s", hit.body)
+	}
+	if d := repo.deliveries[0]; d.Status != repository.DeliveryStatusSucceeded || d.Attempts != 1 || d.ResponseStatus == nil || *d.ResponseStatus != http.StatusOK {
+		t.Fatalf("unexpected delivery log: %+v", d)
+	}
+}
+
+func TestWebhookDispatcher_RetriesWithBackoffAndReplays(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer receiver.Close()
+
+	repo := &mockWebhookRepo{}
+	webhooks := NewWebhookService(repo)
+	hook, err := webhooks.RegisterWebhook(context.Background(), RegisterWebhookInput{
+		OwnerID: "owner-a",
+		URL:     receiver.URL,
+		Events:  []string{string(EventFileDeleted)},
+	})
+	if err != nil {
+		t.Fatalf("register webhook: %v", err)
+	}
+	if err := webhooks.Publish(context.Background(), Event{
+		ID:         "evt-1",
+		Type:       EventFileDeleted,
+		OccurredAt: time.Now().UTC(),
+		OwnerID:    "owner-a",
+		Data:       &repository.FileRecord{ID: "file-1"},
+	}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	dispatcher := NewWebhookDispatcher(repo, receiver.Client())
+	dispatcher.MaxAttempts = 3
+
+	before := time.Now()
+	if _, err := dispatcher.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	d := repo.deliveries[0]
+	if d.Status != repository.DeliveryStatusPending || d.Attempts != 1 || *d.ResponseStatus != http.StatusBadGateway {
+		t.Fatalf("expected pending retry, got %+v", d)
+	}
+	if wait := d.NextAttemptAt.Sub(before); wait < dispatcher.BaseBackoff || wait > dispatcher.BaseBackoff+time.Minute {
+		t.Fatalf("unexpected first backoff %s", wait)
+	}
+	if got := dispatcher.backoff(2); got != 2*dispatcher.BaseBackoff {
+		t.Fatalf("expected backoff to double, got %s", got)
+	}
+	if got := dispatcher.backoff(30); got != dispatcher.MaxBackoff {
+		t.Fatalf("expected backoff to be capped, got %s", got)
+	}
+
+	// 未到期的记录不会被再次领取
+	if n, _ := dispatcher.DeliverDue(context.Background()); n != 0 {
+		t.Fatalf("expected no due deliveries, got %d", n)
+	}
+	for i := 0; i < 2; i++ {
+		repo.due()
+		if _, err := dispatcher.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+	}
+	if d := repo.deliveries[0]; d.Status != repository.DeliveryStatusFailed || d.Attempts != 3 || d.LastError == "" {
+		t.Fatalf("expected failed delivery after max attempts, got %+v", d)
+	}
+
+	failing.Store(false)
+	if _, err := webhooks.ReplayDelivery(context.Background(), "owner-b", hook.ID, repo.deliveries[0].ID); ErrorKindOf(err) != KindNotFound {
+		t.Fatalf("expected other owner replay to be not_found, got %v", err)
+	}
+	replay, err := webhooks.ReplayDelivery(context.Background(), "owner-a", hook.ID, repo.deliveries[0].ID)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replay.EventID != "evt-1" || replay.ID == repo.deliveries[0].ID {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+	if n, err := dispatcher.DeliverDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected replay to be delivered, got %d (%v)", n, err)
+	}
+
+	logs, err := webhooks.ListDeliveries(context.Background(), "owner-a", repository.ListDeliveriesParams{WebhookID: hook.ID})
+	if err != nil || len(logs) != 2 || logs[0].Status != repository.DeliveryStatusFailed || logs[1].Status != repository.DeliveryStatusSucceeded {
+		t.Fatalf("unexpected delivery log: %+v (%v)", logs, err)
+	}
+}
+
+func TestFileService_PublishesLifecycleEvents(t *testing.T) {
+	expiresAt := time.Now().Add(-time.Minute)
+	repo := &mockFileRepo{
+		getResult:  &repository.FileRecord{ID: "file-1", OwnerID: "owner-a"},
+		listResult: []repository.FileRecord{{ID: "file-2", OwnerID: "owner-a", ExpiresAt: &expiresAt}},
+	}
+	publisher := &recordingPublisher{}
+	svc := NewFileService(repo, &mockWriter{})
+	svc.SetEventPublisher(publisher)
+	ctx := context.Background()
+
+	if _, err := svc.UpdateMetadata(ctx, "file-1", "owner-a", map[string]any{"k": "v"}); err != nil {
+		t.Fatalf("update metadata: %v", err)
+	}
+	if err := svc.DeleteFile(ctx, "file-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	now := time.Now()
+	expired, err := svc.ExpireFiles(ctx, now)
+	if err != nil || expired != 1 {
+		t.Fatalf("expected one expired file, got %d (%v)", expired, err)
+	}
+	if !repo.listParams.ExpiresBefore.Equal(now) || slices.Contains(repo.listParams.Statuses, repository.FileStatusDeleted) {
+		t.Fatalf("unexpected expiry query: %+v", repo.listParams)
+	}
+
+	var types []EventType
+	for _, e := range publisher.events {
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventFileUpdated, EventFileDeleted, EventFileExpired}
+	if !slices.Equal(types, want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	if last := publisher.events[2]; last.OwnerID != "owner-a" || last.Data.Status != repository.FileStatusDeleted {
+		t.Fatalf("unexpected expired event: %+v", last)
+	}
+}
+
+func TestWebhookClient_RejectsNonPublicTargets(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	// 回环地址在建立连接时被拒绝，域名解析到内网地址同样在这一步拦下
+	resp, err := NewWebhookClient(time.Second).Post(receiver.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected loopback target to be rejected")
+	}
+	if !strings.Contains(err.Error(), "not a public address") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for addr, want := range map[string]bool{
+		"127.0.0.1:80":          false,
+		"10.1.2.3:443":          false,
+		"192.168.0.10:80":       false,
+		"169.254.169.254:80":    false,
+		"0.0.0.0:80":            false,
+		"100.64.1.1:80":         false,
+		"[::1]:80":              false,
+		"[fd00::1]:80":          false,
+		"[fe80::1]:80":          false,
+		"[::ffff:10.0.0.1]:80":  false,
+		"93.184.216.34:443":     true,
+		"[2606:4700::6810]:443": true,
+	} {
+		if got := checkWebhookAddress(addr) == nil; got != want {
+			t.Errorf("%s: expected allowed=%v", addr, want)
+		}
+	}
+}
+
+func TestWebhookDispatcher_DoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed.Store(true)
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	repo := &mockWebhookRepo{}
+	webhooks := NewWebhookService(repo)
+	if _, err := webhooks.RegisterWebhook(context.Background(), RegisterWebhookInput{
+		OwnerID: "owner-a",
+		URL:     receiver.URL + "/hook",
+		Events:  []string{string(EventFileDeleted)},
+	}); err != nil {
+		t.Fatalf("register webhook: %v", err)
+	}
+	if err := webhooks.Publish(context.Background(), Event{ID: "evt-1", Type: EventFileDeleted, OwnerID: "owner-a"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if _, err := NewWebhookDispatcher(repo, receiver.Client()).DeliverDue(context.Background()); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if followed.Load() {
+		t.Fatal("expected redirect not to be followed")
+	}
+	if d := repo.deliveries[0]; d.Status != repository.DeliveryStatusPending || !strings.Contains(d.LastError, "redirect") {
+		t.Fatalf("expected redirect to count as a failed attempt, got %+v", d)
+	}
+}
+
+func TestWebhookDispatcher_RespectsLease(t *testing.T) {
+	var hits atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer receiver.Close()
+
+	repo := &mockWebhookRepo{}
+	webhooks := NewWebhookService(repo)
+	if _, err := webhooks.RegisterWebhook(context.Background(), RegisterWebhookInput{
+		OwnerID: "owner-a",
+		URL:     receiver.URL,
+		Events:  []string{string(EventFileDeleted)},
+	}); err != nil {
+		t.Fatalf("register webhook: %v", err)
+	}
+	for _, id := range []string{"evt-1", "evt-2"} {
+		if err := webhooks.Publish(context.Background(), Event{ID: id, Type: EventFileDeleted, OwnerID: "owner-a"}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	// 默认租约覆盖整批请求的超时
+	client := receiver.Client()
+	client.Timeout = 10 * time.Second
+	dispatcher := NewWebhookDispatcher(repo, client)
+	if dispatcher.Lease < time.Duration(dispatcher.BatchSize)*client.Timeout {
+		t.Fatalf("lease %s shorter than a batch of request timeouts", dispatcher.Lease)
+	}
+
+	// 租约不足一次请求超时时不发出请求，记录留待租约过期后重新领取
+	dispatcher.Lease = time.Second
+	if n, err := dispatcher.DeliverDue(context.Background()); err != nil || n != 0 || hits.Load() != 0 {
+		t.Fatalf("expected no deliveries within a short lease, got %d (%v), %d hits", n, err, hits.Load())
+	}
+
+	// 领取后被其他实例重新领取的记录，旧结果不会覆盖新的领取
+	repo.due()
+	claimed, _ := repo.ClaimDueDeliveries(context.Background(), 1, time.Minute)
+	repo.due()
+	if _, err := repo.ClaimDueDeliveries(context.Background(), 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	stale := claimed[0]
+	stale.Status = repository.DeliveryStatusSucceeded
+	if err := repo.UpdateDelivery(context.Background(), &stale, repository.DeliveryClaim{Attempts: claimed[0].Attempts, NextAttemptAt: claimed[0].NextAttemptAt}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected stale claim to be rejected, got %v", err)
+	}
+	if repo.deliveries[0].Status != repository.DeliveryStatusPending {
+		t.Fatalf("expected delivery to stay pending, got %+v", repo.deliveries[0])
+	}
+}
@@ -0,0 +1,34 @@
+// Package backend 根据配置创建存储后端，供服务端与运维工具共用。
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"droplite/internal/config"
+	"droplite/internal/storage"
+	"droplite/internal/storage/local"
+	s3storage "droplite/internal/storage/s3"
+)
+
+// Open 按 cfg.StorageDriver 返回 S3 或本地存储。
+func Open(ctx context.Context, cfg *config.Config) (storage.Storage, error) {
+	switch cfg.StorageDriver {
+	case "s3":
+		s3Store, err := s3storage.New(ctx, s3storage.Config{
+			Endpoint:  cfg.S3Endpoint,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			UseSSL:    cfg.S3UseSSL,
+			PathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("init s3 storage: %w", err)
+		}
+		return s3Store, nil
+	default:
+		return local.NewWriter(cfg.StorageDir, ""), nil
+	}
+}
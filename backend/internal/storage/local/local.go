@@ -91,10 +91,26 @@ func (w *Writer) Read(ctx context.Context, key string) (io.ReadCloser, error) {
 	file, err := os.Open(targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found: %s", key)
+			return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
 		}
 		return nil, fmt.Errorf("open file: %w", err)
 	}
 
 	return file, nil
 }
+
+// Stat 返回文件大小。
+func (w *Writer) Stat(ctx context.Context, key string) (int64, error) {
+	if w == nil {
+		return 0, fmt.Errorf("local writer uninitialized")
+	}
+
+	info, err := os.Stat(filepath.Join(w.BaseDir, filepath.Clean(key)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+		}
+		return 0, fmt.Errorf("stat file: %w", err)
+	}
+	return info.Size(), nil
+}
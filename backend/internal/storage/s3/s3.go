@@ -103,7 +103,7 @@ func (s *Storage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
 		obj.Close()
 		errResp := minio.ToErrorResponse(err)
 		if errResp.Code == "NoSuchKey" {
-			return nil, fmt.Errorf("file not found: %s", key)
+			return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
 		}
 		return nil, fmt.Errorf("stat object: %w", err)
 	}
@@ -111,6 +111,24 @@ func (s *Storage) Read(ctx context.Context, key string) (io.ReadCloser, error) {
 	return obj, nil
 }
 
+// Stat 返回对象大小。
+func (s *Storage) Stat(ctx context.Context, key string) (int64, error) {
+	if s == nil || s.client == nil {
+		return 0, fmt.Errorf("s3 storage uninitialized")
+	}
+
+	cleanKey := filepath.ToSlash(filepath.Clean(key))
+
+	info, err := s.client.StatObject(ctx, s.bucket, cleanKey, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return 0, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+		}
+		return 0, fmt.Errorf("stat object: %w", err)
+	}
+	return info.Size, nil
+}
+
 // Delete 从 S3 存储删除文件（可选实现）。
 func (s *Storage) Delete(ctx context.Context, key string) error {
 	if s == nil || s.client == nil {
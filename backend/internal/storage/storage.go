@@ -2,9 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
 )
 
+// ErrNotFound 表示目标对象在存储中不存在。
+var ErrNotFound = errors.New("storage: object not found")
+
 // Writer 定义对象存储写接口，支持流式写入。
 type Writer interface {
 	Write(ctx context.Context, key string, r io.Reader) (Location, error)
@@ -26,3 +30,8 @@ type Location struct {
 	Path string
 	URL  string
 }
+
+// Statter 是可选接口，返回对象大小而不读取内容；对象不存在时返回 ErrNotFound。
+type Statter interface {
+	Stat(ctx context.Context, key string) (int64, error)
+}
@@ -0,0 +1,141 @@
+// Package tlsreload 从磁盘加载服务端证书与客户端 CA，并在文件变化或收到信号时热更新，已建立的连接不受影响。
+package tlsreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Files 是需要加载的文件路径，ClientCAFile 为空时不校验客户端证书。
+type Files struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Reloader 持有当前生效的证书与客户端 CA，可在多个 goroutine 间共享。
+type Reloader struct {
+	files      Files
+	clientAuth tls.ClientAuthType
+
+	current atomic.Pointer[material]
+
+	mu     sync.Mutex // 串行化 Reload
+	mtimes map[string]time.Time
+}
+
+type material struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// New 立即加载一次文件，任一文件无效时返回错误。requireClientCert 为 false 时客户端可以不出示证书，
+// 出示的证书仍须由客户端 CA 签发。
+func New(files Files, requireClientCert bool) (*Reloader, error) {
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, errors.New("tls certificate and key files are required")
+	}
+	r := &Reloader{files: files, clientAuth: tls.NoClientCert}
+	if files.ClientCAFile != "" {
+		r.clientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			r.clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新读取全部文件，失败时保留之前的证书并返回错误。
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mtimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+	next := &material{cert: &cert}
+	if r.files.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.files.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client ca bundle: %w", err)
+		}
+		next.clientCAs = x509.NewCertPool()
+		if !next.clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client ca bundle %s contains no certificates", r.files.ClientCAFile)
+		}
+	}
+	r.current.Store(next)
+	r.mtimes = mtimes
+	return nil
+}
+
+// ReloadIfChanged 在任一文件的修改时间变化时重新加载，返回是否进行了加载。
+func (r *Reloader) ReloadIfChanged() (bool, error) {
+	r.mu.Lock()
+	mtimes, err := r.stat()
+	changed := err == nil && !sameMtimes(mtimes, r.mtimes)
+	r.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, r.Reload()
+}
+
+// TLSConfig 返回监听使用的配置，每次握手读取当前的证书与客户端 CA。
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		current := r.current.Load()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.Certificates = []tls.Certificate{*current.cert}
+		config.ClientAuth = r.clientAuth
+		config.ClientCAs = current.clientCAs
+		return config, nil
+	}
+	return base
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	mtimes := make(map[string]time.Time, 3)
+	for _, path := range []string{r.files.CertFile, r.files.KeyFile, r.files.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		mtimes[path] = info.ModTime()
+	}
+	return mtimes, nil
+}
+
+func sameMtimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, mtime := range a {
+		if !mtime.Equal(b[path]) {
+			return false
+		}
+	}
+	return true
+}
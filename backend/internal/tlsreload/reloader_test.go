@@ -0,0 +1,121 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned 在 dir 下写入以 cn 为 CommonName 的自签名证书与私钥。
+func writeSelfSigned(t *testing.T, dir, cn string, mtime time.Time) Files {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := Files{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	writeFile(t, files.CertFile, certPEM, mtime)
+	writeFile(t, files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), mtime)
+	writeFile(t, files.ClientCAFile, certPEM, mtime)
+	return files
+}
+
+func writeFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedCommonName 返回握手时会下发的证书的 CommonName。
+func servedCommonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	config, err := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReloader_ReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	files := writeSelfSigned(t, dir, "first", start)
+
+	r, err := New(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _ := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Fatalf("expected client certificates to be required, got %v", config.ClientAuth)
+	}
+	if reloaded, err := r.ReloadIfChanged(); err != nil || reloaded {
+		t.Fatalf("expected no reload for unchanged files, got %v %v", reloaded, err)
+	}
+
+	writeSelfSigned(t, dir, "second", start.Add(time.Minute))
+	if reloaded, err := r.ReloadIfChanged(); err != nil || !reloaded {
+		t.Fatalf("expected reload after change, got %v %v", reloaded, err)
+	}
+	if cn := servedCommonName(t, r); cn != "second" {
+		t.Fatalf("expected new certificate to be served, got %q", cn)
+	}
+}
+
+func TestReloader_KeepsCertificateOnFailedReload(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	files := writeSelfSigned(t, dir, "first", start)
+
+	r, err := New(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 证书写到一半时私钥与证书不匹配
+	writeFile(t, files.KeyFile, []byte("not a key"), start.Add(time.Minute))
+	if _, err := r.ReloadIfChanged(); err == nil {
+		t.Fatal("expected reload of broken key to fail")
+	}
+	if cn := servedCommonName(t, r); cn != "first" {
+		t.Fatalf("expected previous certificate to stay in use, got %q", cn)
+	}
+}
@@ -0,0 +1,171 @@
+// Package client 是 DropLite REST API 的 Go SDK。
+//
+//	c, err := client.New("https://droplite.example.com", client.WithAPIKey("key"))
+//	// 服务间调用可改用请求签名，Key 本身不会出现在请求中
+//	c, err = client.New("https://droplite.example.com", client.WithRequestSigning("key"))
+//	file, err := c.UploadFile(ctx, client.UploadInput{Name: "report.pdf", Reader: f})
+//
+// 服务端返回的错误体会被解析为 *Error，可用 ErrorCode 判断错误码。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client 封装对 DropLite API 的调用，可在多个 goroutine 间共享。
+type Client struct {
+	baseURL       *url.URL
+	httpClient    *http.Client
+	authorization string
+	userAgent     string
+	// 请求签名的 Key ID 与密钥，由 WithRequestSigning 设置
+	signingKeyID  string
+	signingSecret string
+}
+
+// Option 配置 Client。
+type Option func(*Client)
+
+// WithAPIKey 使用 API Key 鉴权（Authorization: ApiKey <key>）。
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.authorization = "ApiKey " + key
+		c.signingKeyID, c.signingSecret = "", ""
+	}
+}
+
+// WithBearerToken 使用 Bearer Token 鉴权，如 Supabase 签发的 JWT。
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.authorization = "Bearer " + token
+		c.signingKeyID, c.signingSecret = "", ""
+	}
+}
+
+// WithHTTPClient 替换默认的 http.Client，可用于设置代理、TLS 或超时。
+// 上传与下载是流式的，不建议设置过短的 Timeout，应通过 ctx 控制单次请求。
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithUserAgent 设置请求的 User-Agent。
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+const defaultUserAgent = "droplite-go-client"
+
+// New 创建指向 baseURL（如 https://droplite.example.com）的 Client。
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base url must be http(s), got %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		userAgent:  defaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// newRequest 构建指向 path 的请求并附加鉴权头。
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// send 发送请求，启用了请求签名时先签名；非 2xx 响应会被解析为 *Error 并关闭响应体。
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.signing() {
+		if err := c.sign(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	return resp, nil
+}
+
+// doJSON 发送 JSON 请求并将响应信封中的 data 解码到 out（out 为 nil 时丢弃响应体）。
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeData(resp, out)
+}
+
+// decodeData 解码 {"data": ...} 响应信封。
+func decodeData(resp *http.Response, out any) error {
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
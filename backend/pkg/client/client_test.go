@@ -0,0 +1,184 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"droplite/internal/api"
+	"droplite/internal/config"
+	"droplite/internal/repository/memrepo"
+	"droplite/internal/service"
+	"droplite/pkg/client"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestServer(t *testing.T, cfg *config.Config) *httptest.Server {
+	t.Helper()
+	files := service.NewFileService(memrepo.NewFileRepository(), memrepo.NewStorage())
+	srv := httptest.NewServer(api.NewRouter(cfg, api.Handlers{
+		Files:  api.NewFileHandler(files, 1<<20),
+		Shares: api.NewShareHandler(files, service.NewShareLinks(files, "secret"), ""),
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(t *testing.T, baseURL string, opts ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(baseURL, opts...)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c
+}
+
+func TestClient_FileLifecycle(t *testing.T) {
+	srv := newTestServer(t, &config.Config{AuthEnabled: true, APIKeys: []string{"key-a"}})
+	c := newClient(t, srv.URL, client.WithAPIKey("key-a"))
+	ctx := context.Background()
+
+	content := strings.Repeat("droplite ", 4096)
+	var lastSent, lastTotal int64
+	file, err := c.UploadFile(ctx, client.UploadInput{
+		Name:     "notes.txt",
+		Reader:   strings.NewReader(content),
+		Size:     int64(len(content)),
+		MimeType: "text/plain",
+		Metadata: map[string]any{"project": "sdk"},
+		Progress: func(sent, total int64) { lastSent, lastTotal = sent, total },
+	})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if lastSent != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Fatalf("unexpected progress %d/%d", lastSent, lastTotal)
+	}
+	if file.SizeBytes != int64(len(content)) || file.Metadata["project"] != "sdk" || file.Status != client.FileStatusStored {
+		t.Fatalf("unexpected uploaded file %+v", file)
+	}
+
+	got, err := c.GetFile(ctx, file.ID)
+	if err != nil || got.OriginalName != "notes.txt" {
+		t.Fatalf("get file: %+v %v", got, err)
+	}
+
+	download, err := c.DownloadFile(ctx, file.ID)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	body, _ := io.ReadAll(download)
+	download.Close()
+	if string(body) != content || download.Filename != "notes.txt" || download.ContentType != "text/plain" {
+		t.Fatalf("unexpected download %q %s (%d bytes)", download.Filename, download.ContentType, len(body))
+	}
+
+	link, err := c.ShareFile(ctx, file.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	shared, err := http.Get(link.URL)
+	if err != nil {
+		t.Fatalf("open share link: %v", err)
+	}
+	shared.Body.Close()
+	if shared.StatusCode != http.StatusOK || time.Until(link.ExpiresAt) > time.Hour {
+		t.Fatalf("unexpected share link %d %+v", shared.StatusCode, link)
+	}
+
+	if err := c.DeleteFile(ctx, file.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = c.DownloadFile(ctx, file.ID)
+	if client.ErrorCode(err) != client.CodeNotFound {
+		t.Fatalf("expected not_found after delete, got %v", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 || apiErr.RequestID == "" {
+		t.Fatalf("expected typed error with request id, got %#v", err)
+	}
+}
+
+func TestClient_FilesIteratesAllPages(t *testing.T) {
+	srv := newTestServer(t, &config.Config{})
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.UploadFile(ctx, client.UploadInput{Name: "f.txt", Reader: strings.NewReader("x")}); err != nil {
+			t.Fatalf("upload %d: %v", i, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for file, err := range c.Files(ctx, client.ListOptions{Limit: 2}) {
+		if err != nil {
+			t.Fatalf("iterate: %v", err)
+		}
+		seen[file.ID] = true
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 distinct files, got %d", len(seen))
+	}
+
+	page, err := c.ListFiles(ctx, client.ListOptions{Limit: 2, Offset: 4})
+	if err != nil || len(page) != 1 {
+		t.Fatalf("expected last page with one file, got %d %v", len(page), err)
+	}
+}
+
+func TestClient_Authentication(t *testing.T) {
+	ctx := context.Background()
+
+	apiKeySrv := newTestServer(t, &config.Config{AuthEnabled: true, APIKeys: []string{"key-a"}})
+	_, err := newClient(t, apiKeySrv.URL, client.WithAPIKey("wrong")).ListFiles(ctx, client.ListOptions{})
+	if client.ErrorCode(err) != client.CodeUnauthorized {
+		t.Fatalf("expected unauthorized for wrong key, got %v", err)
+	}
+
+	const secret = "test-jwt-secret"
+	bearerSrv := newTestServer(t, &config.Config{AuthEnabled: true, AuthProvider: "supabase", SupabaseJWTSecret: secret})
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	if _, err := newClient(t, bearerSrv.URL, client.WithBearerToken(token)).ListFiles(ctx, client.ListOptions{}); err != nil {
+		t.Fatalf("list with bearer token: %v", err)
+	}
+}
+
+func TestClient_RequestSigning(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t, &config.Config{
+		AuthEnabled:           true,
+		APIKeys:               []string{"key-a"},
+		RequestSigningEnabled: true,
+		RequestSigningWindow:  time.Minute,
+	})
+	c := newClient(t, srv.URL, client.WithRequestSigning("key-a"))
+
+	uploaded, err := c.UploadFile(ctx, client.UploadInput{Name: "signed.txt", Reader: strings.NewReader("signed content")})
+	if err != nil {
+		t.Fatalf("signed upload: %v", err)
+	}
+	files, err := c.ListFiles(ctx, client.ListOptions{Limit: 10, Statuses: []client.FileStatus{client.FileStatusStored}})
+	if err != nil || len(files) != 1 || files[0].ID != uploaded.ID {
+		t.Fatalf("signed list: %v %+v", err, files)
+	}
+	// 签名请求与 Key 原值鉴权归属同一个 owner
+	if _, err := newClient(t, srv.URL, client.WithAPIKey("key-a")).GetFile(ctx, uploaded.ID); err != nil {
+		t.Fatalf("expected the signed upload to belong to key-a: %v", err)
+	}
+	if _, err := newClient(t, srv.URL, client.WithRequestSigning("key-b")).ListFiles(ctx, client.ListOptions{}); client.ErrorCode(err) != client.CodeUnauthorized {
+		t.Fatalf("expected unauthorized for an unknown signing key, got %v", err)
+	}
+}
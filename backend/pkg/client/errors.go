@@ -0,0 +1,97 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// 服务端错误码，与 API 错误体中的 code 一致。
+const (
+	CodeValidation          = "validation"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeQuotaExceeded       = "quota_exceeded"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeRateLimited         = "rate_limited"
+	CodeStorageUnavailable  = "storage_unavailable"
+	CodeDatabaseUnavailable = "database_unavailable"
+	CodeInternal            = "internal"
+)
+
+// Error 是服务端返回的错误响应。
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    map[string]any
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("droplite: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+	if e.RequestID != "" {
+		msg += " request_id=" + e.RequestID
+	}
+	return msg
+}
+
+// ErrorCode 返回 err 中服务端错误的错误码，非服务端错误返回空字符串。
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// parseError 将非 2xx 响应解析为 *Error；响应体不是标准错误体时按状态码推断错误码。
+func parseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	var envelope struct {
+		Error struct {
+			Code      string         `json:"code"`
+			Message   string         `json:"message"`
+			Details   map[string]any `json:"details"`
+			RequestID string         `json:"request_id"`
+		} `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Code != "" {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Details = envelope.Error.Details
+		apiErr.RequestID = envelope.Error.RequestID
+		return apiErr
+	}
+
+	apiErr.Code = codeForStatus(resp.StatusCode)
+	apiErr.Message = http.StatusText(resp.StatusCode)
+	return apiErr
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeValidation
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileStatus 描述文件的上传生命周期。
+type FileStatus string
+
+const (
+	FileStatusPending FileStatus = "pending"
+	FileStatusStored  FileStatus = "stored"
+	FileStatusFailed  FileStatus = "failed"
+	FileStatusDeleted FileStatus = "deleted"
+)
+
+// File 是文件元数据。
+type File struct {
+	ID           string         `json:"id"`
+	OriginalName string         `json:"original_name"`
+	MimeType     string         `json:"mime_type"`
+	SizeBytes    int64          `json:"size_bytes"`
+	StoragePath  string         `json:"storage_path"`
+	Checksum     *string        `json:"checksum,omitempty"`
+	Status       FileStatus     `json:"status"`
+	Metadata     map[string]any `json:"metadata,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	ExpiresAt    *time.Time     `json:"expires_at,omitempty"`
+}
+
+// ProgressFunc 报告已发送的字节数；total 未知时为 -1。
+type ProgressFunc func(sent, total int64)
+
+// UploadInput 描述一次上传。只有 Name 与 Reader 是必填的。
+type UploadInput struct {
+	Name      string
+	Reader    io.Reader
+	Size      int64  // 内容长度，仅用于进度回调的 total，未知时留 0
+	MimeType  string // 为空时由服务端根据内容识别
+	Checksum  string
+	Metadata  map[string]any
+	ExpiresAt *time.Time
+	Progress  ProgressFunc
+}
+
+// UploadFile 以 multipart/form-data 流式上传文件，内容不会整体读入内存。
+// 启用请求签名时内容先写入临时文件，进度回调反映的是写入临时文件的进度。
+func (c *Client) UploadFile(ctx context.Context, input UploadInput) (*File, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, fmt.Errorf("upload name is required")
+	}
+	if input.Reader == nil {
+		return nil, fmt.Errorf("upload reader is required")
+	}
+
+	var metadata []byte
+	if input.Metadata != nil {
+		var err error
+		if metadata, err = json.Marshal(input.Metadata); err != nil {
+			return nil, fmt.Errorf("encode metadata: %w", err)
+		}
+	}
+
+	var (
+		req *http.Request
+		err error
+	)
+	if c.signing() {
+		req, err = c.newSignedUploadRequest(ctx, input, metadata)
+	} else {
+		req, err = c.newStreamingUploadRequest(ctx, input, metadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var file File
+	if err := decodeData(resp, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// newStreamingUploadRequest 边生成 multipart 请求体边发送。
+func (c *Client) newStreamingUploadRequest(ctx context.Context, input UploadInput, metadata []byte) (*http.Request, error) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeUploadForm(form, input, metadata))
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/files", nil, pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req, nil
+}
+
+// newSignedUploadRequest 先将 multipart 请求体写入临时文件并计算哈希，签名需要在发送前知道请求体的 SHA-256。
+// 临时文件在请求体关闭时删除。
+func (c *Client) newSignedUploadRequest(ctx context.Context, input UploadInput, metadata []byte) (*http.Request, error) {
+	spool, err := os.CreateTemp("", "droplite-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("create upload spool: %w", err)
+	}
+	body := &spooledBody{File: spool}
+
+	sum := sha256.New()
+	form := multipart.NewWriter(io.MultiWriter(spool, sum))
+	if err := writeUploadForm(form, input, metadata); err != nil {
+		body.Close()
+		return nil, err
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("rewind upload spool: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/files", nil, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(headerSignatureContent, hex.EncodeToString(sum.Sum(nil)))
+	return req, nil
+}
+
+// spooledBody 在关闭时删除临时文件。
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	_ = os.Remove(b.File.Name())
+	return err
+}
+
+// writeUploadForm 先写入普通字段再写入文件内容，写完后关闭 multipart writer。
+func writeUploadForm(form *multipart.Writer, input UploadInput, metadata []byte) error {
+	fields := [][2]string{
+		{"original_name", input.Name},
+		{"checksum", input.Checksum},
+		{"metadata", string(metadata)},
+	}
+	if input.ExpiresAt != nil {
+		fields = append(fields, [2]string{"expires_at", input.ExpiresAt.UTC().Format(time.RFC3339)})
+	}
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     "file",
+		"filename": input.Name,
+	}))
+	if input.MimeType != "" {
+		header.Set("Content-Type", input.MimeType)
+	}
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	var src io.Reader = input.Reader
+	if input.Progress != nil {
+		total := input.Size
+		if total <= 0 {
+			total = -1
+		}
+		src = &progressReader{r: input.Reader, total: total, fn: input.Progress}
+	}
+	if _, err := io.Copy(part, src); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+type progressReader struct {
+	r     io.Reader
+	sent  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
+}
+
+// Download 是下载响应，调用方需负责关闭。
+type Download struct {
+	io.ReadCloser
+	Filename    string
+	ContentType string
+	Size        int64 // 未知时为 -1
+}
+
+// DownloadFile 返回文件内容流，只有 stored 状态的文件可以下载。
+func (c *Client) DownloadFile(ctx context.Context, id string) (*Download, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/files/"+url.PathEscape(id)+"/download", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "*/*")
+
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	download := &Download{
+		ReadCloser:  resp.Body,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		download.Filename = params["filename"]
+	}
+	return download, nil
+}
+
+// GetFile 返回单个文件的元数据。
+func (c *Client) GetFile(ctx context.Context, id string) (*File, error) {
+	var file File
+	if err := c.doJSON(ctx, http.MethodGet, "/files/"+url.PathEscape(id), nil, nil, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DeleteFile 软删除文件。
+func (c *Client) DeleteFile(ctx context.Context, id string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/files/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ShareLink 是免登录的限时下载链接。
+type ShareLink struct {
+	FileID       string    `json:"file_id"`
+	Token        string    `json:"token"`
+	URL          string    `json:"url"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	AllowedCIDRs []string  `json:"allowed_cidrs,omitempty"`
+	DeniedCIDRs  []string  `json:"denied_cidrs,omitempty"`
+}
+
+// ShareFile 为文件签发有效期为 ttl 的下载链接，ttl 为 0 时使用服务端默认值（24 小时）。
+func (c *Client) ShareFile(ctx context.Context, id string, ttl time.Duration) (*ShareLink, error) {
+	return c.ShareFileWithNetworks(ctx, id, ttl, nil, nil)
+}
+
+// ShareFileWithNetworks 与 ShareFile 相同，但链接只能从 allowedCIDRs 中的网络打开（为空时不限制），
+// deniedCIDRs 中的网络始终被拒绝。
+func (c *Client) ShareFileWithNetworks(ctx context.Context, id string, ttl time.Duration, allowedCIDRs, deniedCIDRs []string) (*ShareLink, error) {
+	body := map[string]any{}
+	if ttl > 0 {
+		body["expires_in"] = int64(ttl / time.Second)
+	}
+	if len(allowedCIDRs) > 0 {
+		body["allowed_cidrs"] = allowedCIDRs
+	}
+	if len(deniedCIDRs) > 0 {
+		body["denied_cidrs"] = deniedCIDRs
+	}
+	var link ShareLink
+	if err := c.doJSON(ctx, http.MethodPost, "/files/"+url.PathEscape(id)+"/share", nil, body, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ListOptions 控制文件列表的分页与过滤。
+type ListOptions struct {
+	Limit    int // 每页数量，为 0 时使用服务端默认值
+	Offset   int
+	Statuses []FileStatus // 为空时返回除 deleted 以外的文件
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	for _, status := range o.Statuses {
+		q.Add("status", string(status))
+	}
+	return q
+}
+
+// ListFiles 返回一页文件，按创建时间倒序。
+func (c *Client) ListFiles(ctx context.Context, opts ListOptions) ([]File, error) {
+	var files []File
+	if err := c.doJSON(ctx, http.MethodGet, "/files", opts.query(), nil, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// defaultPageSize 是 Files 迭代时未指定 Limit 的每页数量。
+const defaultPageSize = 100
+
+// Files 从 opts.Offset 开始逐页遍历文件，opts.Limit 作为每页数量。
+// 遇到错误时产出一次 (File{}, err) 后结束：
+//
+//	for file, err := range c.Files(ctx, client.ListOptions{}) {
+//		if err != nil { ... }
+//	}
+func (c *Client) Files(ctx context.Context, opts ListOptions) iter.Seq2[File, error] {
+	return func(yield func(File, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = defaultPageSize
+		}
+		for {
+			page, err := c.ListFiles(ctx, opts)
+			if err != nil {
+				yield(File{}, err)
+				return
+			}
+			for _, file := range page {
+				if !yield(file, nil) {
+					return
+				}
+			}
+			if len(page) < opts.Limit {
+				return
+			}
+			opts.Offset += len(page)
+		}
+	}
+}
@@ -2,6 +2,7 @@ import { useState, useRef } from "react";
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
 import { AuthProvider, useAuth } from "./components/AuthProvider";
 import LoginPage from "./pages/LoginPage";
+import { useFileEvents } from "./lib/fileEvents";
 import { LogOut, Upload, FileText, Trash2, Download, Package, Loader2, AlertCircle } from "lucide-react";
 
 // Types
@@ -58,6 +59,9 @@ function MainApp() {
   const fileInputRef = useRef<HTMLInputElement>(null);
   const queryClient = useQueryClient();
 
+  // 订阅服务端文件事件，其他设备或副本上的变更也会刷新列表
+  useFileEvents(API_BASE, session?.access_token);
+
   // Fetch Files
   const { data: files, isLoading, isError } = useQuery<FileRecord[]>({
     queryKey: ["files"],
@@ -0,0 +1,64 @@
+import { useEffect } from "react";
+import { useQueryClient } from "@tanstack/react-query";
+
+const RECONNECT_DELAY_MS = 3000;
+
+// EventSource 不能携带 Authorization 头，这里用 fetch 读取 SSE 流，
+// 断线后带上 Last-Event-ID 重连，服务端会补发期间错过的事件。
+export function useFileEvents(apiBase: string, token: string | undefined) {
+  const queryClient = useQueryClient();
+
+  useEffect(() => {
+    if (!token) return;
+
+    const controller = new AbortController();
+    let lastEventId = "";
+    let retryMs = RECONNECT_DELAY_MS;
+    let timer: ReturnType<typeof setTimeout> | undefined;
+
+    const connect = async () => {
+      try {
+        const headers: Record<string, string> = { Authorization: `Bearer ${token}` };
+        if (lastEventId) headers["Last-Event-ID"] = lastEventId;
+
+        const res = await fetch(`${apiBase}/events`, { headers, signal: controller.signal });
+        if (!res.ok || !res.body) throw new Error(`event stream failed: ${res.status}`);
+
+        const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+        let buffer = "";
+        for (;;) {
+          const { value, done } = await reader.read();
+          if (done) break;
+          buffer += value;
+
+          let end: number;
+          while ((end = buffer.indexOf("\n\n")) >= 0) {
+            const block = buffer.slice(0, end);
+            buffer = buffer.slice(end + 2);
+
+            let isEvent = false;
+            for (const line of block.split("\n")) {
+              if (line.startsWith("id: ")) lastEventId = line.slice(4);
+              else if (line.startsWith("data: ")) isEvent = true;
+              else if (line.startsWith("retry: ")) retryMs = Number(line.slice(7)) || retryMs;
+            }
+            if (isEvent) {
+              queryClient.invalidateQueries({ queryKey: ["files"] });
+            }
+          }
+        }
+      } catch {
+        if (controller.signal.aborted) return;
+      }
+      if (!controller.signal.aborted) {
+        timer = setTimeout(connect, retryMs);
+      }
+    };
+
+    connect();
+    return () => {
+      controller.abort();
+      clearTimeout(timer);
+    };
+  }, [apiBase, token, queryClient]);
+}